@@ -0,0 +1,114 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveAuth records the credential headers of every request
+func serveAuth(t *testing.T) func() []http.Header {
+	t.Helper()
+	var mu sync.Mutex
+	var got []http.Header
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, http.Header{
+			"Authorization": r.Header.Values("Authorization"),
+			"X-Auth-Key":    r.Header.Values("X-Auth-Key"),
+			"X-Auth-Email":  r.Header.Values("X-Auth-Email"),
+		})
+		mu.Unlock()
+		writeJSON(w, successResponse(queryResult(nil, nil, map[string]interface{}{"changes": 1})))
+	})
+	return func() []http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+}
+
+func TestTokenFuncRotation(t *testing.T) {
+	requests := serveAuth(t)
+
+	var mu sync.Mutex
+	token := "token-1"
+	client := cloudflare_d1_go.NewClientWithAuth("account_id", utils.TokenFunc(func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return token, nil
+	}))
+	client.DatabaseID = "db-1"
+
+	if _, err := client.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	mu.Lock()
+	token = "token-2"
+	mu.Unlock()
+	if _, err := client.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+
+	got := requests()
+	if len(got) != 2 || got[0].Get("Authorization") != "Bearer token-1" || got[1].Get("Authorization") != "Bearer token-2" {
+		t.Errorf("headers = %v, want the rotated token on the second request", got)
+	}
+}
+
+func TestTokenFuncError(t *testing.T) {
+	requests := serveAuth(t)
+
+	errVault := errors.New("vault sealed")
+	client := cloudflare_d1_go.NewClientWithAuth("account_id", utils.TokenFunc(func(ctx context.Context) (string, error) {
+		return "", errVault
+	}))
+	client.DatabaseID = "db-1"
+
+	if _, err := client.Exec("DELETE FROM users"); !errors.Is(err, errVault) {
+		t.Errorf("err = %v, want %v", err, errVault)
+	}
+	if n := len(requests()); n != 0 {
+		t.Errorf("%d requests sent without credentials", n)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	requests := serveAuth(t)
+
+	pool := cloudflare_d1_go.NewConnectionPoolWithOptions("account_id", "", cloudflare_d1_go.ClientOptions{
+		Auth: utils.APIKey{Key: "global-key", Email: "ops@example.com"},
+	})
+	if pool == nil {
+		t.Fatal("NewConnectionPoolWithOptions returned nil with Auth set")
+	}
+	defer pool.Close(context.Background())
+	if err := pool.ConnectWithID("db", "db-1"); err != nil {
+		t.Fatalf("ConnectWithID: %v", err)
+	}
+	if _, err := pool.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+
+	got := requests()
+	if len(got) != 1 || got[0].Get("X-Auth-Key") != "global-key" || got[0].Get("X-Auth-Email") != "ops@example.com" || got[0].Get("Authorization") != "" {
+		t.Errorf("headers = %v", got)
+	}
+}
+
+func TestNewClientWithAuthRequiresCredentials(t *testing.T) {
+	if cloudflare_d1_go.NewClientWithAuth("account_id", nil) != nil {
+		t.Error("NewClientWithAuth without a provider returned a client")
+	}
+	if cloudflare_d1_go.NewClientWithAuth("", utils.BearerToken("api_token")) != nil {
+		t.Error("NewClientWithAuth without an account returned a client")
+	}
+	if cloudflare_d1_go.NewClientWithOptions("account_id", "", cloudflare_d1_go.ClientOptions{}) != nil {
+		t.Error("NewClientWithOptions without a token or provider returned a client")
+	}
+}
@@ -0,0 +1,117 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestBatchSendsAllStatementsInOneRequest(t *testing.T) {
+	var requests int
+	var body struct {
+		Batch []struct {
+			SQL    string   `json:"sql"`
+			Params []string `json:"params"`
+		} `json:"batch"`
+	}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !strings.HasSuffix(r.URL.Path, "/d1/database/db-1/raw") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		writeJSON(w, successResponse(
+			queryResult(nil, nil, map[string]interface{}{"last_row_id": float64(7), "changes": float64(1)}),
+			queryResult([]string{"id", "name"}, [][]interface{}{{float64(7), "Alice"}}, map[string]interface{}{}),
+		))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	results, err := client.Batch([]utils.Statement{
+		{SQL: "INSERT INTO users (name, age) VALUES (?, ?)", Params: []interface{}{"Alice", 30}},
+		{SQL: "SELECT id, name FROM users WHERE name = ?", Params: []interface{}{"Alice"}},
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+	if len(body.Batch) != 2 {
+		t.Fatalf("expected 2 statements in body, got %d", len(body.Batch))
+	}
+	if body.Batch[0].Params[1] != "30" {
+		t.Errorf("expected converted param \"30\", got %q", body.Batch[0].Params[1])
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	lastID, _ := results[0].Result.LastInsertId()
+	if lastID != 7 {
+		t.Errorf("expected LastInsertId 7, got %d", lastID)
+	}
+
+	type User struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	var users []User
+	if err := results[1].Rows.StructScanAll(&users); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if len(users) != 1 || users[0].Name != "Alice" || users[0].ID != 7 {
+		t.Errorf("unexpected users: %+v", users)
+	}
+}
+
+func TestBatchReportsFailingStatementIndex(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		failed := queryResult(nil, nil, nil)
+		failed["success"] = false
+		writeJSON(w, successResponse(queryResult(nil, nil, nil), failed))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	_, err := client.BatchDB("db-1", []utils.Statement{
+		{SQL: "INSERT INTO t VALUES (1)"},
+		{SQL: "INSERT INTO t VALUES (2)"},
+	})
+
+	var batchErr *utils.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected *utils.BatchError, got %v", err)
+	}
+	if batchErr.Index != 1 {
+		t.Errorf("expected failing index 1, got %d", batchErr.Index)
+	}
+}
+
+func TestBatchAPIError(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, errorResponse(7500, "near \"INSRT\": syntax error"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	_, err := client.BatchDB("db-1", []utils.Statement{{SQL: "INSRT INTO t VALUES (1)"}})
+	if err == nil || !strings.Contains(err.Error(), "syntax error") {
+		t.Fatalf("expected API error, got %v", err)
+	}
+}
+
+func TestBatchWithoutConnection(t *testing.T) {
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	if _, err := client.Batch([]utils.Statement{{SQL: "SELECT 1"}}); err == nil {
+		t.Fatal("expected error when no database is connected")
+	}
+}
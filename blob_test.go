@@ -0,0 +1,87 @@
+//go:build cgo
+
+package cloudflared1_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	_ "github.com/youfun/cloudflare-d1-go/d1driver"
+)
+
+// pngBytes returns n pseudo-random bytes starting with the PNG signature,
+// which holds bytes that are not valid UTF-8
+func pngBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(b)
+	copy(b, "\x89PNG\r\n\x1a\n")
+	return b
+}
+
+type file struct {
+	ID   int64  `db:"id"`
+	Data []byte `db:"data"`
+}
+
+func TestBlobRoundTrip(t *testing.T) {
+	serveSQLite(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	if _, err := client.Exec("CREATE TABLE files (id INTEGER PRIMARY KEY, data BLOB)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+
+	image := pngBytes(64 << 10)
+	if _, err := client.Exec("INSERT INTO files (id, data) VALUES (1, ?), (2, ?)", image, []byte{}); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	var files []file
+	if err := client.Select(&files, "SELECT id, data FROM files ORDER BY id"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(files) != 2 || !bytes.Equal(files[0].Data, image) || files[1].Data == nil || len(files[1].Data) != 0 {
+		t.Fatalf("got %d files, first %d bytes, want the image and an empty blob back", len(files), len(files[0].Data))
+	}
+
+	var kind string
+	if err := client.Get(&kind, "SELECT typeof(data) FROM files WHERE data = ?", image); err != nil || kind != "blob" {
+		t.Errorf("typeof(data) = %q, %v; want blob", kind, err)
+	}
+
+	var raw json.RawMessage
+	if err := client.Get(&raw, "SELECT data FROM files WHERE id = 1"); err != nil || !bytes.Equal(raw, image) {
+		t.Errorf("json.RawMessage got %d bytes, %v; want the image", len(raw), err)
+	}
+}
+
+func TestBlobThroughDriver(t *testing.T) {
+	serveSQLite(t)
+
+	db, err := sql.Open("d1", "d1://"+testAccountID+":tok@11111111-2222-3333-4444-555555555555")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE files (id INTEGER PRIMARY KEY, data BLOB)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	image := pngBytes(4096)
+	if _, err := db.Exec("INSERT INTO files (id, data) VALUES (1, ?)", image); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	var data []byte
+	if err := db.QueryRow("SELECT data FROM files WHERE data = ?", image).Scan(&data); err != nil {
+		t.Fatalf("QueryRow failed: %v", err)
+	}
+	if !bytes.Equal(data, image) {
+		t.Errorf("got %d bytes back, want the %d bytes written", len(data), len(image))
+	}
+}
@@ -0,0 +1,142 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// flakyTransport answers requests as the current mode says: "down" fails
+// them at the transport level, "5xx" answers 503, "sql" answers a syntax
+// error and anything else succeeds
+type flakyTransport struct {
+	mode  atomic.Value
+	calls atomic.Int32
+}
+
+func (f *flakyTransport) set(mode string) { f.mode.Store(mode) }
+
+func (f *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.calls.Add(1)
+	rec := httptest.NewRecorder()
+	switch f.mode.Load() {
+	case "down":
+		return nil, errors.New("dial tcp: connection refused")
+	case "5xx":
+		rec.WriteHeader(http.StatusServiceUnavailable)
+	case "sql":
+		rec.WriteHeader(http.StatusBadRequest)
+		writeJSON(rec, errorResponse(7500, "near \"SELEC\": syntax error"))
+	default:
+		writeJSON(rec, successResponse(queryResult(nil, nil, nil)))
+	}
+	return rec.Result(), nil
+}
+
+func breakerClient(transport *flakyTransport, breaker *utils.CircuitBreaker) *cloudflare_d1_go.Client {
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		HTTPClient:     &http.Client{Transport: transport},
+		CircuitBreaker: breaker,
+	})
+	client.DatabaseID = "db-1"
+	return client
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	transport := &flakyTransport{}
+	breaker := utils.NewCircuitBreaker(3, 50*time.Millisecond)
+	client := breakerClient(transport, breaker)
+
+	transport.set("down")
+	_, _ = client.Exec("SELECT 1")
+	transport.set("5xx")
+	_, _ = client.Exec("SELECT 1")
+	if breaker.State() != utils.CircuitClosed {
+		t.Fatalf("state after 2 failures = %v, want closed", breaker.State())
+	}
+	_, _ = client.Exec("SELECT 1")
+	if breaker.State() != utils.CircuitOpen {
+		t.Fatalf("state after 3 failures = %v, want open", breaker.State())
+	}
+
+	transport.set("ok")
+	start := time.Now()
+	if _, err := client.Exec("SELECT 1"); !errors.Is(err, utils.ErrCircuitOpen) || time.Since(start) > 10*time.Millisecond {
+		t.Errorf("err = %v after %v, want ErrCircuitOpen at once", err, time.Since(start))
+	}
+	if transport.calls.Load() != 3 {
+		t.Errorf("%d requests sent, want 3: none while open", transport.calls.Load())
+	}
+
+	// A failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	if breaker.State() != utils.CircuitHalfOpen {
+		t.Fatalf("state after the cool-down = %v, want half-open", breaker.State())
+	}
+	transport.set("5xx")
+	_, _ = client.Exec("SELECT 1")
+	if breaker.State() != utils.CircuitOpen || transport.calls.Load() != 4 {
+		t.Fatalf("state after a failed probe = %v with %d requests, want open with 4", breaker.State(), transport.calls.Load())
+	}
+
+	// A successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	transport.set("ok")
+	if _, err := client.Exec("SELECT 1"); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	if breaker.State() != utils.CircuitClosed {
+		t.Errorf("state after a successful probe = %v, want closed", breaker.State())
+	}
+}
+
+func TestCircuitBreakerIgnoresSQLErrors(t *testing.T) {
+	transport := &flakyTransport{}
+	breaker := utils.NewCircuitBreaker(2, time.Minute)
+	client := breakerClient(transport, breaker)
+
+	transport.set("sql")
+	for i := 0; i < 5; i++ {
+		if _, err := client.Exec("SELEC 1"); err == nil || errors.Is(err, utils.ErrCircuitOpen) {
+			t.Fatalf("Exec %d: err = %v, want the syntax error", i, err)
+		}
+	}
+	if breaker.State() != utils.CircuitClosed {
+		t.Errorf("state after SQL errors = %v, want closed", breaker.State())
+	}
+
+	// A success resets the count of consecutive failures
+	transport.set("5xx")
+	_, _ = client.Exec("SELECT 1")
+	transport.set("ok")
+	_, _ = client.Exec("SELECT 1")
+	transport.set("5xx")
+	_, _ = client.Exec("SELECT 1")
+	if breaker.State() != utils.CircuitClosed {
+		t.Errorf("state after failures separated by a success = %v, want closed", breaker.State())
+	}
+}
+
+func TestCircuitBreakerSharedByPool(t *testing.T) {
+	transport := &flakyTransport{}
+	transport.set("down")
+	breaker := utils.NewCircuitBreaker(2, time.Minute)
+	pool := cloudflare_d1_go.NewConnectionPoolWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		HTTPClient:     &http.Client{Transport: transport},
+		CircuitBreaker: breaker,
+	})
+
+	for _, id := range []string{"db-1", "db-2", "db-3"} {
+		_ = pool.ConnectWithID("db", id)
+		_, _ = pool.Exec("SELECT 1")
+	}
+	if breaker.State() != utils.CircuitOpen || transport.calls.Load() != 2 {
+		t.Errorf("state = %v after %d requests, want open after 2 requests to two databases", breaker.State(), transport.calls.Load())
+	}
+}
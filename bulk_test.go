@@ -0,0 +1,168 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveBulk answers batch requests, reporting as changes the number of rows
+// of each statement. Requests with a statement containing failOn fail.
+func serveBulk(t *testing.T, failOn string) *[][]sentStatement {
+	var requests [][]sentStatement
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Batch []sentStatement `json:"batch"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body.Batch)
+
+		items := make([]interface{}, len(body.Batch))
+		for i, stmt := range body.Batch {
+			if failOn != "" && strings.Contains(strings.Join(stmt.Params, ","), failOn) {
+				writeJSON(w, errorResponse(7500, "UNIQUE constraint failed: users.email"))
+				return
+			}
+			rows := strings.Count(stmt.SQL, "), (") + 1
+			items[i] = queryResult(nil, nil, map[string]interface{}{"changes": rows})
+		}
+		writeJSON(w, successResponse(items...))
+	})
+	return &requests
+}
+
+func bulkRows(n int) [][]interface{} {
+	rows := make([][]interface{}, n)
+	for i := range rows {
+		rows[i] = []interface{}{fmt.Sprintf("user%d", i), i, nil}
+	}
+	return rows
+}
+
+func TestBulkInsertChunksByParams(t *testing.T) {
+	requests := serveBulk(t, "")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	n, err := client.BulkInsert("users", []string{"name", "age", "email"}, bulkRows(120))
+	if err != nil || n != 120 {
+		t.Fatalf("BulkInsert = %d, %v; want 120 rows", n, err)
+	}
+
+	if len(*requests) != 1 || len((*requests)[0]) != 3 {
+		t.Fatalf("requests = %d, want one batch of 3 statements", len(*requests))
+	}
+	first := (*requests)[0][0]
+	// Two parameters per row, NULL is inline: 50 rows fill the 100 parameters
+	if len(first.Params) != 100 || !strings.HasPrefix(first.SQL, `INSERT INTO "users" ("name", "age", "email") VALUES (?, ?, NULL), (?, ?, NULL)`) {
+		t.Errorf("first statement has %d params: %.100s", len(first.Params), first.SQL)
+	}
+	if first.Params[0] != "user0" || first.Params[1] != "0" {
+		t.Errorf("params = %v", first.Params[:2])
+	}
+	if last := (*requests)[0][2]; len(last.Params) != 40 || last.Params[0] != "user100" {
+		t.Errorf("last statement has %d params, starting at %v", len(last.Params), last.Params[0])
+	}
+}
+
+func TestBulkInsertChunksBySize(t *testing.T) {
+	requests := serveBulk(t, "")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	rows := make([][]interface{}, 10)
+	for i := range rows {
+		rows[i] = []interface{}{strings.Repeat("x", 30000)}
+	}
+	n, err := client.BulkInsert("notes", []string{"body"}, rows, cloudflare_d1_go.BulkStatementsPerRequest(2))
+	if err != nil || n != 10 {
+		t.Fatalf("BulkInsert = %d, %v; want 10 rows", n, err)
+	}
+
+	// 30 KB per row: three rows fit in a 100 KB statement
+	var perStatement []int
+	for _, request := range *requests {
+		if len(request) > 2 {
+			t.Errorf("request with %d statements, want at most 2", len(request))
+		}
+		for _, stmt := range request {
+			perStatement = append(perStatement, len(stmt.Params))
+		}
+	}
+	if !reflect.DeepEqual(perStatement, []int{3, 3, 3, 1}) {
+		t.Errorf("rows per statement = %v, want [3 3 3 1]", perStatement)
+	}
+}
+
+func TestBulkInsertChunkErrors(t *testing.T) {
+	requests := serveBulk(t, "user7")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	n, err := client.BulkInsert("users", []string{"name", "age", "email"}, bulkRows(10),
+		cloudflare_d1_go.BulkMaxRows(2), cloudflare_d1_go.BulkStatementsPerRequest(1))
+
+	var bulkErr *cloudflare_d1_go.BulkInsertError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("err = %v, want *BulkInsertError", err)
+	}
+	if n != 8 || len(*requests) != 5 {
+		t.Errorf("inserted %d rows in %d requests, want 8 in 5", n, len(*requests))
+	}
+	if len(bulkErr.Chunks) != 1 || bulkErr.Chunks[0].FirstRow != 6 || bulkErr.Chunks[0].Rows != 2 {
+		t.Errorf("chunks = %+v, want rows 6 and 7", bulkErr.Chunks)
+	}
+	if !strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		t.Errorf("error %q does not carry the cause", err)
+	}
+}
+
+func TestBulkInsertStructs(t *testing.T) {
+	requests := serveBulk(t, "")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	email := "bob@example.com"
+	users := []*insertUser{
+		{Name: "Alice", Nickname: "al"},
+		{Name: "Bob", Email: &email},
+	}
+	n, err := client.BulkInsertStructs("users", users, cloudflare_d1_go.BulkInsertOptions(cloudflare_d1_go.InsertOrIgnore()))
+	if err != nil || n != 2 {
+		t.Fatalf("BulkInsertStructs = %d, %v; want 2 rows", n, err)
+	}
+
+	want := sentStatement{
+		SQL:    `INSERT OR IGNORE INTO "users" ("name", "email", "nickname", "created_at", "deleted_at") VALUES (?, NULL, ?, ?, NULL), (?, ?, ?, ?, NULL)`,
+		Params: []string{"Alice", "al", "0001-01-01T00:00:00.000000000Z", "Bob", "bob@example.com", "", "0001-01-01T00:00:00.000000000Z"},
+	}
+	if got := (*requests)[0][0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %+v\nwant %+v", got, want)
+	}
+
+	if _, err := client.BulkInsertStructs("users", insertUser{}); err == nil {
+		t.Error("BulkInsertStructs accepted a struct instead of a slice")
+	}
+}
+
+func TestBulkInsertRejectsOversizedRows(t *testing.T) {
+	requests := serveBulk(t, "")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	if _, err := client.BulkInsert("notes", []string{"body"}, [][]interface{}{{strings.Repeat("x", 200000)}}); err == nil {
+		t.Error("BulkInsert accepted a row over the statement size limit")
+	}
+	if _, err := client.BulkInsert("users", []string{"name", "age"}, [][]interface{}{{"alice"}}); err == nil {
+		t.Error("BulkInsert accepted a row with too few values")
+	}
+	if len(*requests) != 0 {
+		t.Errorf("sent %d requests, want none", len(*requests))
+	}
+}
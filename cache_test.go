@@ -0,0 +1,165 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveCounted answers every query with the number of requests made so far,
+// as column n, after delay. Queries containing "broken" fail.
+func serveCounted(t *testing.T, delay time.Duration) *atomic.Int64 {
+	var requests atomic.Int64
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		time.Sleep(delay)
+		var stmt sentStatement
+		_ = json.NewDecoder(r.Body).Decode(&stmt)
+		if strings.Contains(stmt.SQL, "broken") {
+			writeJSON(w, errorResponse(7500, "no such table: broken"))
+			return
+		}
+		w.Header().Set("cf-ray", "8f00aa11bb22cc33-FRA")
+		writeJSON(w, successResponse(queryResult([]string{"n"}, [][]interface{}{{n}}, nil)))
+	})
+	return &requests
+}
+
+func newCachedClient(ttl time.Duration) *cloudflare_d1_go.Client {
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	return client.WithCache(cloudflare_d1_go.NewLRUCache(100), ttl)
+}
+
+func getN(t *testing.T, client *cloudflare_d1_go.Client, query string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := client.Get(&n, query, args...); err != nil {
+		t.Fatalf("Get(%q) failed: %v", query, err)
+	}
+	return n
+}
+
+func TestCacheTTL(t *testing.T) {
+	requests := serveCounted(t, 0)
+	client := newCachedClient(50 * time.Millisecond)
+
+	first := getN(t, client, "SELECT n FROM counters WHERE id = ?", 1)
+	if n := getN(t, client, "SELECT n\n  FROM counters\tWHERE id = ?", 1); n != first {
+		t.Errorf("reformatted query got %d, want the cached %d", n, first)
+	}
+	if n := getN(t, client, "SELECT n FROM counters WHERE id = ?", 2); n == first {
+		t.Error("query with other params was answered from the cache")
+	}
+	if requests.Load() != 2 {
+		t.Errorf("%d requests, want 2", requests.Load())
+	}
+
+	res, err := client.Query("SELECT n FROM counters WHERE id = ?", []string{"1"})
+	if err != nil || res.RayID() != "8f00aa11bb22cc33-FRA" || requests.Load() != 2 {
+		t.Errorf("cached Query = RayID %q, %v after %d requests; want the cached response with its headers", res.RayID(), err, requests.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n := getN(t, client, "SELECT n FROM counters WHERE id = ?", 1); n == first {
+		t.Error("expired result was returned")
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	requests := serveCounted(t, 0)
+	client := newCachedClient(time.Minute)
+
+	query := "SELECT n FROM counters WHERE id = ?"
+	first := getN(t, client, query, 1)
+	if _, err := client.Exec("UPDATE counters SET n = n + 1 WHERE id = ?", 1); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	second := getN(t, client, query, 1)
+	if second == first {
+		t.Error("result cached before a write was returned after it")
+	}
+
+	if err := client.InvalidateQuery(query, 1); err != nil {
+		t.Fatalf("InvalidateQuery failed: %v", err)
+	}
+	third := getN(t, client, query, 1)
+	other := getN(t, client, query, 2)
+	if third == second {
+		t.Error("InvalidateQuery did not remove the result")
+	}
+
+	client.InvalidateAll()
+	if getN(t, client, query, 1) == third || getN(t, client, query, 2) == other {
+		t.Error("InvalidateAll did not remove every result")
+	}
+	if requests.Load() != 7 {
+		t.Errorf("%d requests, want 7", requests.Load())
+	}
+}
+
+func TestCacheSkipsErrorsAndWrites(t *testing.T) {
+	requests := serveCounted(t, 0)
+	client := newCachedClient(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		var n int64
+		if err := client.Get(&n, "SELECT n FROM broken"); err == nil {
+			t.Fatal("Get of a failing query succeeded")
+		}
+		_, _ = client.Query("SELECT 1; DELETE FROM counters", nil)
+		_, _ = client.Query("INSERT INTO counters (n) SELECT 1", nil)
+	}
+	if requests.Load() != 6 {
+		t.Errorf("%d requests, want 6: errors, multi-statement queries and writes are not cached", requests.Load())
+	}
+}
+
+func TestCacheSharesConcurrentMisses(t *testing.T) {
+	requests := serveCounted(t, 50*time.Millisecond)
+	client := newCachedClient(time.Minute)
+
+	var wg sync.WaitGroup
+	results := make([]int64, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = client.Get(&results[i], "SELECT n FROM counters")
+		}()
+	}
+	wg.Wait()
+
+	if requests.Load() != 1 {
+		t.Errorf("%d requests, want 1", requests.Load())
+	}
+	for i, n := range results {
+		if n != 1 {
+			t.Errorf("result %d = %d, want 1", i, n)
+		}
+	}
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	cache := cloudflare_d1_go.NewLRUCache(2)
+	cache.Set("a", []byte("1"), time.Minute)
+	cache.Set("b", []byte("2"), time.Minute)
+	cache.Get("a")
+	cache.Set("c", []byte("3"), time.Minute)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("least recently used entry b was kept")
+	}
+	if v, ok := cache.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("a = %q, %v; want it kept", v, ok)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len = %d, want 2", cache.Len())
+	}
+}
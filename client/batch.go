@@ -0,0 +1,65 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// BatchDB executes several statements on the given database in a single API call.
+// D1 runs a batch as an implicit transaction: if one statement fails, none of them
+// are applied. The returned slice holds one result per statement, in order.
+// Errors that can be attributed to a single statement are returned as *utils.BatchError.
+func (c *Client) BatchDB(databaseID string, statements []utils.Statement) ([]utils.BatchResult, error) {
+	return c.BatchDBContext(context.Background(), databaseID, statements)
+}
+
+// BatchDBContext is BatchDB with a context that can cancel the request
+func (c *Client) BatchDBContext(ctx context.Context, databaseID string, statements []utils.Statement) ([]utils.BatchResult, error) {
+	if len(statements) == 0 {
+		return []utils.BatchResult{}, nil
+	}
+
+	body := batchBody{Batch: make([]queryBody, len(statements))}
+	for i, stmt := range statements {
+		stmtBody, err := c.newArgsBody(stmt.SQL, stmt.Params)
+		if err != nil {
+			return nil, &utils.BatchError{Index: i, Err: err}
+		}
+		body.Batch[i] = stmtBody
+	}
+
+	res, err := c.postSQL(ctx, databaseID, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]utils.BatchResult, len(statements))
+	for i := range statements {
+		rows, result, err := res.ResultSet(i)
+		if err != nil {
+			return nil, &utils.BatchError{Index: i, Err: err}
+		}
+		results[i] = utils.BatchResult{Rows: rows, Result: result}
+	}
+
+	return results, nil
+}
+
+// Batch executes several statements on the connected database in a single API call
+// Like BatchDB, all statements succeed or fail together
+func (c *Client) Batch(statements []utils.Statement) ([]utils.BatchResult, error) {
+	return c.BatchContext(context.Background(), statements)
+}
+
+// BatchContext is Batch with a context that can cancel the request
+func (c *Client) BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error) {
+	if c.DatabaseID == "" {
+		return nil, fmt.Errorf("no database connected, call ConnectDB first")
+	}
+	return c.BatchDBContext(ctx, c.DatabaseID, statements)
+}
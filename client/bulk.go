@@ -0,0 +1,255 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// defaultBulkStatements is the number of statements BulkInsert sends per
+// batch request, unless changed with BulkStatementsPerRequest
+const defaultBulkStatements = 20
+
+// BulkOption configures BulkInsert
+type BulkOption func(*bulkOptions)
+
+type bulkOptions struct {
+	insert     insertOptions
+	maxRows    int
+	statements int
+}
+
+// BulkInsertOptions applies InsertOrIgnore, InsertOrReplace or
+// OnConflictUpdate to every statement of a bulk insert
+func BulkInsertOptions(opts ...InsertOption) BulkOption {
+	return func(o *bulkOptions) {
+		for _, opt := range opts {
+			opt(&o.insert)
+		}
+	}
+}
+
+// BulkMaxRows limits the rows per INSERT statement below what the D1 limits allow
+func BulkMaxRows(n int) BulkOption {
+	return func(o *bulkOptions) {
+		o.maxRows = n
+	}
+}
+
+// BulkStatementsPerRequest sets how many INSERT statements are sent in one
+// batch request, 20 by default. The statements of a request are applied
+// atomically.
+func BulkStatementsPerRequest(n int) BulkOption {
+	return func(o *bulkOptions) {
+		o.statements = n
+	}
+}
+
+// ChunkError reports a failed statement of a bulk insert
+type ChunkError struct {
+	// FirstRow is the index of the first row of the statement in the input,
+	// and Rows the number of rows it held
+	FirstRow int
+	Rows     int
+	Err      error
+}
+
+// BulkInsertError is returned by BulkInsert if some statements failed. The
+// rows of the other statements were inserted.
+type BulkInsertError struct {
+	Chunks []ChunkError
+}
+
+func (e *BulkInsertError) Error() string {
+	first := e.Chunks[0]
+	return fmt.Sprintf("bulk insert: %d statements failed, the first with rows %d to %d: %v",
+		len(e.Chunks), first.FirstRow, first.FirstRow+first.Rows-1, first.Err)
+}
+
+func (e *BulkInsertError) Unwrap() []error {
+	errs := make([]error, len(e.Chunks))
+	for i, chunk := range e.Chunks {
+		errs[i] = chunk.Err
+	}
+	return errs
+}
+
+// bulkChunk is a multi-row INSERT statement and the rows it covers
+type bulkChunk struct {
+	stmt     utils.Statement
+	firstRow int
+	rows     int
+}
+
+// BulkInsert inserts rows into the given columns of table with multi-row
+// INSERT statements. Rows are split into statements that stay within the D1
+// limits of 100 bound parameters and 100 KB per statement, or those set in
+// ClientOptions, and the statements
+// are sent in batch requests. nil values are inserted as NULL.
+//
+// It returns the number of rows inserted. If statements fail, the others are
+// still sent, and the error is a *BulkInsertError listing the failed rows.
+func (c *Client) BulkInsert(table string, columns []string, rows [][]interface{}, opts ...BulkOption) (int64, error) {
+	return c.BulkInsertContext(context.Background(), table, columns, rows, opts...)
+}
+
+// BulkInsertContext is BulkInsert with a context that can cancel the requests
+func (c *Client) BulkInsertContext(ctx context.Context, table string, columns []string, rows [][]interface{}, opts ...BulkOption) (int64, error) {
+	o := bulkOptions{statements: defaultBulkStatements}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("no columns to insert into table %s", table)
+	}
+
+	chunks, err := c.bulkChunks(table, columns, rows, o)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	var failed []ChunkError
+	for start := 0; start < len(chunks); start += o.statements {
+		group := chunks[start:min(start+o.statements, len(chunks))]
+		stmts := make([]utils.Statement, len(group))
+		for i, chunk := range group {
+			stmts[i] = chunk.stmt
+		}
+
+		results, err := c.BatchContext(ctx, stmts)
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		if err != nil {
+			for _, chunk := range group {
+				failed = append(failed, ChunkError{FirstRow: chunk.firstRow, Rows: chunk.rows, Err: err})
+			}
+			continue
+		}
+		for _, res := range results {
+			if res.Result != nil {
+				n, _ := res.Result.RowsAffected()
+				total += n
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return total, &BulkInsertError{Chunks: failed}
+	}
+	return total, nil
+}
+
+// bulkChunks splits rows into INSERT statements within the D1 limits
+func (c *Client) bulkChunks(table string, columns []string, rows [][]interface{}, o bulkOptions) ([]bulkChunk, error) {
+	head, tail, err := insertClauses(table, columns, o.insert)
+	if err != nil {
+		return nil, err
+	}
+	head += " VALUES "
+
+	maxParams, maxBytes := c.boundParamsLimit(), c.statementBytesLimit()
+	var chunks []bulkChunk
+	var tuples []string
+	var args []interface{}
+	size, firstRow := 0, 0
+	flush := func(next int) {
+		if len(tuples) > 0 {
+			sql := head + strings.Join(tuples, ", ") + tail
+			chunks = append(chunks, bulkChunk{stmt: utils.Statement{SQL: sql, Params: args}, firstRow: firstRow, rows: len(tuples)})
+		}
+		tuples, args, size, firstRow = nil, nil, 0, next
+	}
+
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("row %d has %d values for %d columns", i, len(row), len(columns))
+		}
+		values := make([]string, len(row))
+		var rowArgs []interface{}
+		rowSize := 0
+		for j, value := range row {
+			values[j], rowArgs = placeholder(value, rowArgs)
+			if value != nil {
+				rowSize += len(fmt.Sprint(value))
+			}
+		}
+		tuple := "(" + strings.Join(values, ", ") + ")"
+		rowSize += len(tuple) + len(", ")
+
+		if len(rowArgs) > maxParams || len(head)+len(tail)+rowSize > maxBytes {
+			return nil, fmt.Errorf("row %d does not fit in a single statement: %w", i, ErrStatementTooLarge)
+		}
+		if len(args)+len(rowArgs) > maxParams || len(head)+len(tail)+size+rowSize > maxBytes ||
+			(o.maxRows > 0 && len(tuples) >= o.maxRows) {
+			flush(i)
+		}
+		tuples = append(tuples, tuple)
+		args = append(args, rowArgs...)
+		size += rowSize
+	}
+	flush(len(rows))
+	return chunks, nil
+}
+
+// BulkInsertStructs is BulkInsert for a slice of structs or struct pointers,
+// with columns taken from the db tags and name mapper like InsertStruct. A
+// zero omitempty field is inserted as NULL, so an INTEGER PRIMARY KEY gets a
+// generated value; a column whose field is zero and omitempty in every
+// element is left out.
+func (c *Client) BulkInsertStructs(table string, slice interface{}, opts ...BulkOption) (int64, error) {
+	return c.BulkInsertStructsContext(context.Background(), table, slice, opts...)
+}
+
+// BulkInsertStructsContext is BulkInsertStructs with a context that can
+// cancel the requests
+func (c *Client) BulkInsertStructsContext(ctx context.Context, table string, slice interface{}, opts ...BulkOption) (int64, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("BulkInsertStructs requires a slice of structs, got %T", slice)
+	}
+	if v.Len() == 0 {
+		return 0, nil
+	}
+
+	var names []string
+	used := make(map[string]bool)
+	structs := make([][]structColumn, v.Len())
+	for i := range structs {
+		columns, err := c.structColumns(v.Index(i).Interface())
+		if err != nil {
+			return 0, fmt.Errorf("element %d: %w", i, err)
+		}
+		structs[i] = columns
+		for _, col := range columns {
+			if i == 0 {
+				names = append(names, col.name)
+			}
+			used[col.name] = used[col.name] || !col.omit || !col.zero
+		}
+	}
+
+	var columns []string
+	for _, name := range names {
+		if used[name] {
+			columns = append(columns, name)
+		}
+	}
+	rows := make([][]interface{}, len(structs))
+	for i, fields := range structs {
+		for _, col := range fields {
+			if !used[col.name] {
+				continue
+			}
+			if col.omit && col.zero {
+				col.value = nil
+			}
+			rows[i] = append(rows[i], col.value)
+		}
+	}
+	return c.BulkInsertContext(ctx, table, columns, rows, opts...)
+}
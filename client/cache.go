@@ -0,0 +1,301 @@
+package cloudflared1
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// Cache stores the results of SELECT queries for a client made with
+// WithCache. Values are encoded responses; implementations may hold them in
+// memory, as LRUCache does, or in an external store. They must be safe for
+// concurrent use by multiple goroutines.
+type Cache interface {
+	// Get returns the value stored for key, unless it has expired
+	Get(key string) ([]byte, bool)
+	// Set stores value for key until ttl has passed
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete removes key
+	Delete(key string)
+	// Clear removes every key
+	Clear()
+}
+
+// WithCache returns a copy of the client that caches the results of SELECT
+// queries in cache for ttl, keyed by the database, the SQL with its
+// whitespace normalized, and the parameters. It covers Select, Get, Query
+// and every other method reading through a single statement.
+//
+// Any other statement sent through the copy, including batches, clears the
+// cache, as do InvalidateQuery and InvalidateAll. Writes made through other
+// clients, or by anyone else, are only seen once ttl has passed.
+// Concurrent identical queries that miss the cache share one request.
+func (c *Client) WithCache(cache Cache, ttl time.Duration) *Client {
+	bound := *c
+	bound.cache = &queryCache{cache: cache, ttl: ttl, calls: make(map[string]*cacheCall)}
+	return &bound
+}
+
+// InvalidateQuery removes the cached result of a query with args on the
+// connected database. It does nothing on a client without a cache.
+func (c *Client) InvalidateQuery(query string, args ...interface{}) error {
+	if c.cache == nil {
+		return nil
+	}
+	body, err := c.newArgsBody(query, args)
+	if err != nil {
+		return err
+	}
+	c.cache.cache.Delete(cacheKey(c.DatabaseID, body))
+	return nil
+}
+
+// InvalidateAll removes every cached result. It does nothing on a client
+// without a cache.
+func (c *Client) InvalidateAll() {
+	if c.cache != nil {
+		c.cache.invalidateAll()
+	}
+}
+
+// queryCache holds the cache of a client made with WithCache
+type queryCache struct {
+	cache Cache
+	ttl   time.Duration
+	// generation is increased by every invalidation, so a result fetched
+	// while a write ran is not stored
+	generation atomic.Int64
+
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+// cacheCall is a request for a cache miss, shared by identical queries
+type cacheCall struct {
+	done chan struct{}
+	res  *utils.APIResponse
+	data []byte
+	err  error
+}
+
+// cachedResponse is the form a response is stored in
+type cachedResponse struct {
+	Response *utils.APIResponse `json:"response"`
+	Header   http.Header        `json:"header"`
+}
+
+func (q *queryCache) invalidateAll() {
+	q.generation.Add(1)
+	q.cache.Clear()
+}
+
+// post answers a SELECT from the cache, or sends it and stores the result.
+// Every other body is sent and clears the cache.
+func (q *queryCache) post(ctx context.Context, c *Client, databaseID string, body interface{}) (*utils.APIResponse, error) {
+	stmt, ok := body.(queryBody)
+	if !ok || !isCacheable(stmt.SQL) {
+		res, err := c.send(ctx, databaseID, body)
+		q.invalidateAll()
+		return res, err
+	}
+
+	key := cacheKey(databaseID, stmt)
+	if data, ok := q.cache.Get(key); ok {
+		if res, err := decodeCached(data); err == nil {
+			return res, nil
+		}
+	}
+
+	q.mu.Lock()
+	if call, ok := q.calls[key]; ok {
+		q.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.data == nil {
+			return call.res, call.err
+		}
+		return decodeCached(call.data)
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	q.calls[key] = call
+	q.mu.Unlock()
+
+	generation := q.generation.Load()
+	call.res, call.err = c.send(ctx, databaseID, body)
+	if call.err == nil && call.res.Err() == nil {
+		data, err := json.Marshal(cachedResponse{Response: call.res, Header: call.res.Header})
+		if err == nil {
+			call.data = data
+			if q.generation.Load() == generation {
+				q.cache.Set(key, data, q.ttl)
+			}
+		}
+	}
+
+	q.mu.Lock()
+	delete(q.calls, key)
+	q.mu.Unlock()
+	close(call.done)
+	return call.res, call.err
+}
+
+// decodeCached decodes a stored response into a response of its own
+func decodeCached(data []byte) (*utils.APIResponse, error) {
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	cached.Response.Header = cached.Header
+	return cached.Response, nil
+}
+
+// cacheKey identifies a statement on a database
+func cacheKey(databaseID string, stmt queryBody) string {
+	params, _ := json.Marshal(stmt.Params)
+	return databaseID + "\x00" + normalizeSQL(stmt.SQL) + "\x00" + string(params)
+}
+
+// isCacheable reports whether query is a single SELECT statement
+func isCacheable(query string) bool {
+	query = normalizeSQL(query)
+	if len(query) < len("SELECT ") || !strings.EqualFold(query[:len("SELECT ")], "SELECT ") {
+		return false
+	}
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '\'', '"', '`':
+			if end := strings.IndexByte(query[i+1:], c); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(query)
+			}
+		case ';':
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeSQL collapses runs of whitespace outside quotes into one space
+// and trims it from both ends, so queries differing only in layout share a
+// cache entry
+func normalizeSQL(query string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			space = true
+			continue
+		case '\'', '"', '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				end = len(query) - i - 1
+			} else {
+				end++
+			}
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteString(query[i : i+end+1])
+			i += end
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// LRUCache is an in-memory Cache holding up to a fixed number of results,
+// evicting the least recently used first. It is safe for concurrent use.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // most recently used first
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache returns an LRUCache holding up to capacity results
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (l *LRUCache) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (l *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := &lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if elem, ok := l.entries[key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.entries[key] = l.order.PushFront(entry)
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *LRUCache) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[key]; ok {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+	}
+}
+
+func (l *LRUCache) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = make(map[string]*list.Element)
+	l.order.Init()
+}
+
+// Len returns the number of results held, including expired ones not yet
+// evicted
+func (l *LRUCache) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
@@ -0,0 +1,64 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// Count runs a query returning a single integer, such as SELECT COUNT(*), on
+// the connected database and returns it
+// Like sqlx: n, err := client.Count("SELECT COUNT(*) FROM users WHERE age > ?", 25)
+func (c *Client) Count(query string, args ...interface{}) (int64, error) {
+	return c.CountContext(context.Background(), query, args...)
+}
+
+// CountContext is Count with a context that can cancel the request
+func (c *Client) CountContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	rows, err := c.queryRows(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	return utils.ScanOne[int64](rows)
+}
+
+// CountTable returns the number of rows of table matching where, a condition
+// with ? placeholders for args. An empty where counts all rows.
+// Example: n, err := client.CountTable("users", "active = ?", true)
+func (c *Client) CountTable(table, where string, args ...interface{}) (int64, error) {
+	return c.CountTableContext(context.Background(), table, where, args...)
+}
+
+// CountTableContext is CountTable with a context that can cancel the request
+func (c *Client) CountTableContext(ctx context.Context, table, where string, args ...interface{}) (int64, error) {
+	quoted, err := utils.QuoteIdentifier(table)
+	if err != nil {
+		return 0, fmt.Errorf("invalid table name: %w", err)
+	}
+	query := "SELECT COUNT(*) FROM " + quoted
+	if where != "" {
+		query += " WHERE " + where
+	}
+	return c.CountContext(ctx, query, args...)
+}
+
+// Exists reports whether a query returns at least one row. The query is
+// wrapped with LIMIT 1, so D1 stops at the first match.
+// Example: taken, err := client.Exists("SELECT 1 FROM users WHERE email = ?", email)
+func (c *Client) Exists(query string, args ...interface{}) (bool, error) {
+	return c.ExistsContext(context.Background(), query, args...)
+}
+
+// ExistsContext is Exists with a context that can cancel the request
+func (c *Client) ExistsContext(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	rows, err := c.queryRows(ctx, "SELECT 1 FROM ("+trimTrailingSemicolons(query)+") LIMIT 1", args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
@@ -0,0 +1,34 @@
+package cloudflared1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCredentials is matched by the errors of New and NewPool for
+// malformed credentials
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// accountIDLength is the length of a Cloudflare account ID in hex digits
+const accountIDLength = 32
+
+// validateCredentials checks the form of an account ID and API token. The
+// token may be empty if other credentials are set. Neither is part of the
+// error.
+func validateCredentials(accountID, apiToken string, hasAuth bool) error {
+	if accountID == "" {
+		return fmt.Errorf("%w: account ID is empty", ErrInvalidCredentials)
+	}
+	if len(accountID) != accountIDLength || strings.Trim(strings.ToLower(accountID), "0123456789abcdef") != "" {
+		// Not quoted, in case a token was passed by mistake
+		return fmt.Errorf("%w: account ID is not a %d-character hex string (got %d characters), copy it from the Cloudflare dashboard", ErrInvalidCredentials, accountIDLength, len(accountID))
+	}
+	if apiToken == "" && !hasAuth {
+		return fmt.Errorf("%w: API token is empty", ErrInvalidCredentials)
+	}
+	if strings.ContainsAny(apiToken, " \t\r\n") {
+		return fmt.Errorf("%w: API token contains whitespace, e.g. a trailing newline or a \"Bearer \" prefix", ErrInvalidCredentials)
+	}
+	return nil
+}
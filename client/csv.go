@@ -0,0 +1,26 @@
+package cloudflared1
+
+import (
+	"context"
+	"io"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// QueryToCSV runs a query and writes its rows to w as CSV with a header row,
+// see utils.Rows.WriteCSV
+// Example: err := client.QueryToCSV(os.Stdout, "SELECT * FROM users WHERE age > ?", 30)
+func (c *Client) QueryToCSV(w io.Writer, query string, args ...interface{}) error {
+	return c.QueryToCSVContext(context.Background(), w, query, args...)
+}
+
+// QueryToCSVContext is QueryToCSV with a context that can cancel the request
+func (c *Client) QueryToCSVContext(ctx context.Context, w io.Writer, query string, args ...interface{}) error {
+	rows, err := c.queryRows(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return rows.WriteCSV(w, utils.CSVOptions{})
+}
@@ -1,8 +1,12 @@
 package cloudflared1
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/youfun/cloudflare-d1-go/utils"
 )
@@ -11,8 +15,107 @@ type Client struct {
 	AccountID  string
 	APIToken   string
 	DatabaseID string
+
+	nameMapper        utils.NameMapper
+	strictScan        bool
+	keepSemicolons    bool
+	skipParamCheck    bool
+	maxBoundParams    int
+	maxStatementBytes int
+	queryEndpoint     bool
+	recorder          *Recorder
+	usage             *usageCounters
+	requester         utils.Requester
+	baseURL           string
+	log               *logConfig
+	slowThreshold     time.Duration
+	slowHook          SlowQueryHook
+	metrics           MetricsCollector
+	tracer            Tracer
+	session           *Session
+	cache             *queryCache
+	// reresolve looks up the ID of the database again after D1 reported
+	// staleID as not found, for clients bound by a pool, see postSQL
+	reresolve func(ctx context.Context, staleID string) (string, error)
+	// auth points to the credentials of ClientOptions.Auth, identifying
+	// them for sqlKey. Copies of the client share it.
+	auth *utils.AuthProvider
+}
+
+// ClientOptions holds the optional settings of New and NewPool.
+// The zero value gives a plain client authenticating with the API token.
+type ClientOptions struct {
+	// Retry retries requests that failed with a rate limit or a transient
+	// server error, see utils.RetryPolicy for which requests are retried
+	Retry utils.RetryPolicy
+	// HTTPClient sends the requests, e.g. with a proxy or custom transport.
+	// nil means http.DefaultClient.
+	HTTPClient *http.Client
+	// BaseURL replaces DefaultBaseURL, e.g. to point tests at a local
+	// httptest server. Account and database paths are appended to it.
+	BaseURL string
+	// RequestTimeout limits each HTTP request, including reading the
+	// response. Zero means no limit besides the context of the call.
+	RequestTimeout time.Duration
+	// SlowQueryHook is called for every query or batch that took longer than
+	// SlowQueryThreshold, measured by wall-clock time or by the duration D1
+	// reported. Both must be set to enable it.
+	SlowQueryThreshold time.Duration
+	SlowQueryHook      SlowQueryHook
+	// Metrics receives the operation, duration, error and row counts of
+	// every API call. A pool also reports its Connect cache hits and misses
+	// if Metrics implements ConnectCacheCollector.
+	Metrics MetricsCollector
+	// Tracer traces every query and batch, see the d1otel module for
+	// OpenTelemetry. Use the Context methods to propagate a parent span.
+	Tracer Tracer
+	// MaxBoundParams and MaxStatementBytes replace DefaultMaxBoundParams
+	// and DefaultMaxStatementBytes, in case D1 changes its limits. Zero
+	// means the default.
+	MaxBoundParams    int
+	MaxStatementBytes int
+	// RateLimiter delays requests to stay within a request rate, such as
+	// the 1200 requests per five minutes Cloudflare allows an account. It
+	// covers every request of the client, and of all databases of a pool
+	// created with these options. Blocking respects the context of the call.
+	RateLimiter *utils.RateLimiter
+	// CircuitBreaker fails requests fast with utils.ErrCircuitOpen after
+	// repeated transport failures or 5xx responses, see
+	// utils.NewCircuitBreaker. Like RateLimiter, it covers every request of
+	// the client, and of all databases of a pool created with these options.
+	CircuitBreaker *utils.CircuitBreaker
+	// Auth replaces the API token as the credentials of every request:
+	// utils.APIKey for a legacy API key and email, or utils.TokenFunc for
+	// tokens fetched per request. The token argument may then be empty.
+	Auth utils.AuthProvider
+	// Headers are sent with every request, e.g. to tag traffic for a
+	// proxy. A header replaces a default of the same name, such as the
+	// User-Agent (see utils.UserAgent). Use utils.WithHeader for the headers
+	// of a single call.
+	Headers http.Header
+}
+
+// DefaultBaseURL is the Cloudflare API all requests go to unless
+// ClientOptions.BaseURL is set
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+// New creates a client for the account with optional settings such as a
+// retry policy. apiToken may be empty if opts.Auth is set. Malformed
+// credentials are reported right away instead of by the first request: an
+// account ID that is not the 32-character hex ID shown in the dashboard,
+// or an API token containing whitespace, e.g. a trailing newline read from
+// a file. The errors match ErrInvalidCredentials.
+func New(accountID, apiToken string, opts ClientOptions) (*Client, error) {
+	if err := validateCredentials(accountID, apiToken, opts.Auth != nil); err != nil {
+		return nil, err
+	}
+	return newClient(accountID, apiToken, opts), nil
 }
 
+// NewClient creates a client for the account, or returns nil if an
+// argument is empty.
+//
+// Deprecated: Use New, which reports why the credentials are invalid.
 func NewClient(accountID, apiToken string) *Client {
 	if accountID == "" || apiToken == "" {
 		return nil
@@ -20,102 +123,230 @@ func NewClient(accountID, apiToken string) *Client {
 	return &Client{
 		AccountID: accountID,
 		APIToken:  apiToken,
+		usage:     &usageCounters{},
+	}
+}
+
+// NewClientWithOptions is NewClient with optional settings such as a retry policy.
+// apiToken may be empty if opts.Auth is set.
+//
+// Deprecated: Use New, which reports why the credentials are invalid.
+func NewClientWithOptions(accountID, apiToken string, opts ClientOptions) *Client {
+	if accountID == "" || apiToken == "" && opts.Auth == nil {
+		return nil
+	}
+	return newClient(accountID, apiToken, opts)
+}
+
+// newClient creates a client from checked credentials
+func newClient(accountID, apiToken string, opts ClientOptions) *Client {
+	c := &Client{
+		AccountID: accountID,
+		APIToken:  apiToken,
+		usage:     &usageCounters{},
+	}
+	if opts.Auth != nil {
+		c.auth = &opts.Auth
 	}
+	c.requester = utils.Requester{Retry: opts.Retry, HTTPClient: opts.HTTPClient, Timeout: opts.RequestTimeout,
+		Limiter: opts.RateLimiter, Breaker: opts.CircuitBreaker, Header: opts.Headers.Clone(), Auth: opts.Auth}
+	c.baseURL = strings.TrimRight(opts.BaseURL, "/")
+	c.slowThreshold, c.slowHook = opts.SlowQueryThreshold, opts.SlowQueryHook
+	c.metrics, c.tracer = opts.Metrics, opts.Tracer
+	c.maxBoundParams, c.maxStatementBytes = opts.MaxBoundParams, opts.MaxStatementBytes
+	return c
+}
+
+// NewClientWithAuth is NewClient with other credentials than a fixed API
+// token: utils.APIKey for a legacy API key and email, or utils.TokenFunc
+// for tokens fetched per request. NewClient is the utils.BearerToken case.
+// The APIToken field of the client is empty and unused.
+//
+// Deprecated: Use New with ClientOptions.Auth, which reports why the
+// credentials are invalid.
+func NewClientWithAuth(accountID string, auth utils.AuthProvider) *Client {
+	if auth == nil {
+		return nil
+	}
+	return NewClientWithOptions(accountID, "", ClientOptions{Auth: auth})
+}
+
+// SetNameMapper sets how struct fields without a db tag map to column names.
+// It applies to Select, Get and every helper that reflects over structs.
+// nil restores utils.DefaultMapper (snake_case).
+func (c *Client) SetNameMapper(mapper utils.NameMapper) {
+	c.nameMapper = mapper
+}
+
+// SetStrictScan makes Select, Get and the other methods that scan into
+// structs fail with a *utils.UnmatchedError when a result column matches no
+// struct field or a field matches no column, listing both. By default such
+// columns are ignored and such fields keep their zero value. Enabling it in
+// tests catches misspelled db tags.
+func (c *Client) SetStrictScan(enabled bool) {
+	c.strictScan = enabled
+}
+
+// SetTrimSemicolons controls whether trailing semicolons are removed from
+// queries before they are sent. It is enabled by default, because D1 answers
+// "SELECT 1;" with a second, empty result set.
+func (c *Client) SetTrimSemicolons(enabled bool) {
+	c.keepSemicolons = !enabled
+}
+
+// SetCheckParamCount controls whether the placeholders of each statement
+// are counted before it is sent, failing with a *utils.ParamCountError if
+// they don't match its parameters instead of waiting for D1 to reject it.
+// It is enabled by default; disable it for SQL the placeholder counting of
+// utils.CountPlaceholders gets wrong. Statements it cannot count, such as
+// ones with named placeholders, are always sent.
+func (c *Client) SetCheckParamCount(enabled bool) {
+	c.skipParamCheck = !enabled
+}
+
+// UseQueryEndpoint controls whether queries are sent to the /query endpoint
+// instead of /raw, e.g. behind a proxy that only allows /query. /query
+// returns rows as objects; they are converted on receipt, so results scan the
+// same either way. It is disabled by default.
+func (c *Client) UseQueryEndpoint(enabled bool) {
+	c.queryEndpoint = enabled
 }
 
 func (c *Client) ListDB() (*utils.APIResponse, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/d1/database", c.AccountID)
-	return utils.DoRequest("GET", url, "", c.APIToken)
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+	return c.do(context.Background(), OpListDatabases, "GET", c.buildURL(), "")
 }
 
+// CreateDB creates a database with default options, see CreateDBWithOptions
+// for a location hint and a typed result
 func (c *Client) CreateDB(name string) (*utils.APIResponse, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/d1/database", c.AccountID)
-	body := fmt.Sprintf(`{"name":"%s"}`, name)
-	return utils.DoRequest("POST", url, body, c.APIToken)
+	return c.createDB(name, CreateDBOptions{})
 }
 
 func (c *Client) DeleteDB(databaseID string) (*utils.APIResponse, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/d1/database/%s", c.AccountID, databaseID)
-	return utils.DoRequest("DELETE", url, "", c.APIToken)
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+	return c.do(context.Background(), OpDeleteDatabase, "DELETE", c.buildURL(databaseID), "")
 }
 
 // Runs SQL query on the D1 database with parameters
+// nil and empty params are equivalent; both are sent as an empty array
 func (c *Client) QueryDB(databaseID string, query string, params []string) (*utils.APIResponse, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/d1/database/%s/raw", c.AccountID, databaseID)
-
-	// Build request body with proper JSON encoding
-	requestBody := map[string]interface{}{
-		"sql":    query,
-		"params": params,
-	}
-
-	bodyBytes, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
-	}
+	return c.QueryDBContext(context.Background(), databaseID, query, params)
+}
 
-	return utils.DoRequest("POST", url, string(bodyBytes), c.APIToken)
+// QueryDBContext is QueryDB with a context that can cancel the request
+func (c *Client) QueryDBContext(ctx context.Context, databaseID string, query string, params []string) (*utils.APIResponse, error) {
+	return c.postSQL(ctx, databaseID, c.newQueryBody(query, params))
 }
 
 func (c *Client) CreateTableWithID(databaseID, createQuery string) (*utils.APIResponse, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/d1/database/%s/raw", c.AccountID, databaseID)
+	return c.postSQL(context.Background(), databaseID, c.newQueryBody(createQuery, nil))
+}
 
-	requestBody := map[string]interface{}{
-		"sql":    createQuery,
-		"params": []string{},
+func (c *Client) RemoveTableWithID(databaseID, tableName string) (*utils.APIResponse, error) {
+	query, err := dropTableQuery(tableName)
+	if err != nil {
+		return nil, err
 	}
+	return c.postSQL(context.Background(), databaseID, c.newQueryBody(query, nil))
+}
 
-	bodyBytes, err := json.Marshal(requestBody)
+// dropTableQuery builds the statement RemoveTable runs; the table name is
+// quoted so it can't carry extra SQL
+func dropTableQuery(tableName string) (string, error) {
+	quoted, err := utils.QuoteIdentifier(tableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		return "", err
 	}
-
-	return utils.DoRequest("POST", url, string(bodyBytes), c.APIToken)
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s;", quoted), nil
 }
 
-func (c *Client) RemoveTableWithID(databaseID, tableName string) (*utils.APIResponse, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/d1/database/%s/raw", c.AccountID, databaseID)
-	query := fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName)
-
-	requestBody := map[string]interface{}{
-		"sql":    query,
-		"params": []string{},
+// ConnectDB finds and connects to a database by name, storing its ID for future operations
+// The name is filtered server-side and every page of matches is searched.
+// ConnectDB changes the client; to share one client across goroutines that
+// target different databases, use WithDatabaseName instead.
+func (c *Client) ConnectDB(name string) error {
+	id, err := c.lookupDatabaseID(name)
+	if err != nil {
+		return err
 	}
+	c.DatabaseID = id
+	return nil
+}
 
-	bodyBytes, err := json.Marshal(requestBody)
+// WithDatabase returns a copy of the client bound to databaseID. The copy
+// shares the configuration and usage counters of c, and c is not changed.
+// A bound client is safe for concurrent use by multiple goroutines as long as
+// its setters and fields are left alone once it is shared.
+func (c *Client) WithDatabase(databaseID string) *Client {
+	bound := *c
+	bound.DatabaseID = databaseID
+	return &bound
+}
+
+// WithDatabaseName is WithDatabase for a database found by name, like ConnectDB
+func (c *Client) WithDatabaseName(name string) (*Client, error) {
+	id, err := c.lookupDatabaseID(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		return nil, err
 	}
-
-	return utils.DoRequest("POST", url, string(bodyBytes), c.APIToken)
+	return c.WithDatabase(id), nil
 }
 
-// ConnectDB finds and connects to a database by name, storing its ID for future operations
-func (c *Client) ConnectDB(name string) error {
-	resp, err := c.ListDB()
+// lookupDatabaseID returns the ID of the database with the given name
+func (c *Client) lookupDatabaseID(name string) (string, error) {
+	dbs, err := c.listAllDBs(name)
 	if err != nil {
-		return fmt.Errorf("failed to list databases: %w", err)
+		return "", fmt.Errorf("failed to list databases: %w", err)
 	}
 
-	// Parse response to find database with matching name
-	databases := resp.Result.([]interface{})
-	for _, db := range databases {
-		dbMap := db.(map[string]interface{})
-		if dbMap["name"].(string) == name {
-			c.DatabaseID = dbMap["uuid"].(string)
-			return nil
+	// The filter also matches other names containing name
+	for _, db := range dbs {
+		if db.Name != name {
+			continue
+		}
+		if db.UUID == "" {
+			return "", fmt.Errorf("unexpected list response shape: database %s has no uuid", name)
 		}
+		return db.UUID, nil
 	}
 
-	return fmt.Errorf("database with name %s not found", name)
+	return "", &databaseNameError{name: name}
+}
+
+// ErrDatabaseNotFound is matched by errors.Is when no database has the name
+// passed to Connect or WithDatabaseName
+var ErrDatabaseNotFound = errors.New("database not found")
+
+// databaseNameError reports that no database is named name
+type databaseNameError struct {
+	name string
+}
+
+func (e *databaseNameError) Error() string {
+	return fmt.Sprintf("database with name %s not found", e.name)
+}
+
+func (e *databaseNameError) Unwrap() error {
+	return ErrDatabaseNotFound
 }
 
-// Query runs SQL query on the connected database
+// Query runs SQL query on the connected database. Params are bound as text;
+// pass utils.Null for a NULL parameter.
 func (c *Client) Query(query string, params []string) (*utils.APIResponse, error) {
+	return c.QueryContext(context.Background(), query, params)
+}
+
+// QueryContext is Query with a context that can cancel the request
+func (c *Client) QueryContext(ctx context.Context, query string, params []string) (*utils.APIResponse, error) {
 	if c.DatabaseID == "" {
 		return nil, fmt.Errorf("no database connected, call ConnectDB first")
 	}
-	return c.QueryDB(c.DatabaseID, query, params)
+	return c.QueryDBContext(ctx, c.DatabaseID, query, params)
 }
 
 // CreateTable creates a table in the connected database
@@ -136,49 +367,105 @@ func (c *Client) RemoveTable(tableName string) (*utils.APIResponse, error) {
 
 // Select executes a query and scans all results into a slice, similar to sqlx.Select
 // Like sqlx: client.Select(&users, "SELECT * FROM users WHERE age > ?", 25)
+// users may be a []User, a []*User or a []map[string]interface{}.
 func (c *Client) Select(dest interface{}, query string, args ...interface{}) error {
-	params, err := utils.ConvertParams(args...)
-	if err != nil {
-		return err
-	}
-	res, err := c.Query(query, params)
+	return c.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext is Select with a context that can cancel the request
+func (c *Client) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	rows, err := c.queryRows(ctx, query, args...)
 	if err != nil {
 		return err
 	}
-	return res.StructScanAll(dest)
+	defer rows.Close()
+
+	return rows.StructScanAll(dest)
 }
 
 // Get executes a query and scans the first result into a struct, similar to sqlx.Get
 // Like sqlx: client.Get(&user, "SELECT * FROM users WHERE id = ?", 123)
+// Returns utils.ErrNoRows when the query matches no rows
 func (c *Client) Get(dest interface{}, query string, args ...interface{}) error {
-	params, err := utils.ConvertParams(args...)
+	return c.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext is Get with a context that can cancel the request
+func (c *Client) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	rows, err := c.queryRows(ctx, query, args...)
 	if err != nil {
 		return err
 	}
-	res, err := c.Query(query, params)
+	defer rows.Close()
+
+	if !rows.Next() {
+		return utils.ErrNoRows
+	}
+	return rows.ScanInto(dest)
+}
+
+// queryRows runs a query with converted args and returns its rows
+func (c *Client) queryRows(ctx context.Context, query string, args ...interface{}) (*utils.Rows, error) {
+	res, err := c.queryArgs(ctx, query, args)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return res.Get(dest)
+	return c.toRows(res)
 }
 
-// Exec executes a query and returns the number of rows affected, similar to sqlx.Exec
-// Like sqlx: rowsAffected, err := client.Exec("UPDATE users SET age = ? WHERE id = ?", 30, 123)
-func (c *Client) Exec(query string, args ...interface{}) (int64, error) {
-	params, err := utils.ConvertParams(args...)
+// queryArgs is QueryContext for parameters given as Go values
+func (c *Client) queryArgs(ctx context.Context, query string, args []interface{}) (*utils.APIResponse, error) {
+	body, err := c.newArgsBody(query, args)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	if c.DatabaseID == "" {
+		return nil, fmt.Errorf("no database connected, call ConnectDB first")
+	}
+	return c.postSQL(ctx, c.DatabaseID, body)
+}
 
-	res, err := c.Query(query, params)
+// toRows converts a response to Rows that scan with the client's name
+// mapper and strictness
+func (c *Client) toRows(res *utils.APIResponse) (*utils.Rows, error) {
+	rows, err := res.ToRows()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	rows.SetNameMapper(c.nameMapper)
+	rows.SetStrict(c.strictScan)
+	return rows, nil
+}
 
-	result, err := res.ToResult()
+// Exec executes a query and returns the number of rows affected, similar to sqlx.Exec
+// Like sqlx: rowsAffected, err := client.Exec("UPDATE users SET age = ? WHERE id = ?", 30, 123)
+func (c *Client) Exec(query string, args ...interface{}) (int64, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec with a context that can cancel the request
+func (c *Client) ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := c.ExecResultContext(ctx, query, args...)
 	if err != nil {
 		return 0, err
 	}
 
 	return result.RowsAffected()
 }
+
+// ExecResult executes a query and returns its Result, mirroring database/sql's Exec
+// Like database/sql: result, err := client.ExecResult("INSERT INTO users (name) VALUES (?)", "Alice")
+// then result.LastInsertId() and result.RowsAffected()
+func (c *Client) ExecResult(query string, args ...interface{}) (*utils.Result, error) {
+	return c.ExecResultContext(context.Background(), query, args...)
+}
+
+// ExecResultContext is ExecResult with a context that can cancel the request
+func (c *Client) ExecResultContext(ctx context.Context, query string, args ...interface{}) (*utils.Result, error) {
+	res, err := c.queryArgs(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.ToResult()
+}
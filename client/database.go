@@ -0,0 +1,193 @@
+package cloudflared1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// LocationHint names the region a new database is placed in
+type LocationHint string
+
+// Regions accepted as primary location hint
+const (
+	LocationWesternNorthAmerica LocationHint = "wnam"
+	LocationEasternNorthAmerica LocationHint = "enam"
+	LocationWesternEurope       LocationHint = "weur"
+	LocationEasternEurope       LocationHint = "eeur"
+	LocationAsiaPacific         LocationHint = "apac"
+	LocationOceania             LocationHint = "oc"
+)
+
+// CreateDBOptions holds the optional settings of CreateDBWithOptions.
+// The zero value creates a database with Cloudflare's defaults.
+type CreateDBOptions struct {
+	// PrimaryLocationHint places the database close to a region.
+	// Empty lets Cloudflare pick the region closest to the request.
+	PrimaryLocationHint LocationHint
+}
+
+// createDBBody is the JSON body of the create database call
+type createDBBody struct {
+	Name                string       `json:"name"`
+	PrimaryLocationHint LocationHint `json:"primary_location_hint,omitempty"`
+}
+
+// DatabaseInfo describes a D1 database as returned by the API
+type DatabaseInfo struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	FileSize  int64     `json:"file_size"`
+	NumTables int       `json:"num_tables"`
+}
+
+// CreateDBWithOptions creates a database and returns its description.
+// Unlike CreateDB, an error reported by the API is returned as error.
+func (c *Client) CreateDBWithOptions(name string, opts CreateDBOptions) (*DatabaseInfo, error) {
+	res, err := c.createDB(name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var info DatabaseInfo
+	if err := decodeResult(res, &info); err != nil {
+		return nil, fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+	return &info, nil
+}
+
+// createDB sends the create database call; CreateDB returns its response as is
+func (c *Client) createDB(name string, opts CreateDBOptions) (*utils.APIResponse, error) {
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+	body, err := json.Marshal(createDBBody{Name: name, PrimaryLocationHint: opts.PrimaryLocationHint})
+	if err != nil {
+		return nil, err
+	}
+	return c.do(context.Background(), OpCreateDatabase, "POST", c.buildURL(), string(body))
+}
+
+// GetDatabase returns the description of a database, e.g. to verify that a
+// cached database ID still exists. A deleted database is reported as error.
+func (c *Client) GetDatabase(databaseID string) (*DatabaseInfo, error) {
+	return c.getDatabase(context.Background(), databaseID)
+}
+
+// getDatabase is GetDatabase with a context
+func (c *Client) getDatabase(ctx context.Context, databaseID string) (*DatabaseInfo, error) {
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+	res, err := c.do(ctx, OpGetDatabase, "GET", c.buildURL(databaseID), "")
+	if err != nil {
+		return nil, err
+	}
+
+	var info DatabaseInfo
+	if err := decodeResult(res, &info); err != nil {
+		return nil, fmt.Errorf("failed to get database %s: %w", databaseID, err)
+	}
+	return &info, nil
+}
+
+// Ping runs SELECT 1 on the connected database and returns nil only if the
+// round trip succeeded
+func (c *Client) Ping() error {
+	return c.PingContext(context.Background())
+}
+
+// PingContext is Ping with a context that can cancel the request
+func (c *Client) PingContext(ctx context.Context) error {
+	res, err := c.QueryContext(ctx, "SELECT 1", nil)
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// listPageSize is the page size ListAllDBs and ConnectDB request
+const listPageSize = 100
+
+// ListDBPaged returns one page of the databases of the account, together with
+// the pagination info of the response. page starts at 1; TotalCount of the
+// info tells how many databases there are.
+func (c *Client) ListDBPaged(page, perPage int) ([]DatabaseInfo, *utils.ResultInfo, error) {
+	return c.listDBs(url.Values{
+		"page":     {strconv.Itoa(page)},
+		"per_page": {strconv.Itoa(perPage)},
+	})
+}
+
+// ListAllDBs returns every database of the account, following pagination
+func (c *Client) ListAllDBs() ([]DatabaseInfo, error) {
+	return c.listAllDBs("")
+}
+
+// listAllDBs fetches pages until all databases are read. A non-empty name is
+// sent as filter, which the API matches against database names server-side.
+func (c *Client) listAllDBs(name string) ([]DatabaseInfo, error) {
+	all := []DatabaseInfo{}
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(listPageSize)},
+		}
+		if name != "" {
+			query.Set("name", name)
+		}
+
+		dbs, info, err := c.listDBs(query)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, dbs...)
+
+		// Responses without result_info are not paginated
+		if info == nil || len(dbs) == 0 || len(all) >= info.TotalCount {
+			return all, nil
+		}
+	}
+}
+
+// listDBs requests one page of the database list
+func (c *Client) listDBs(query url.Values) ([]DatabaseInfo, *utils.ResultInfo, error) {
+	if c.recorder != nil {
+		return nil, nil, errDryRun
+	}
+	res, err := c.do(context.Background(), OpListDatabases, "GET", c.buildURL()+"?"+query.Encode(), "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := res.Err(); err != nil {
+		return nil, nil, err
+	}
+	var dbs []DatabaseInfo
+	if err := decodeResult(res, &dbs); err != nil {
+		return nil, nil, fmt.Errorf("unexpected list response shape: %w", err)
+	}
+	return dbs, res.ResultInfo, nil
+}
+
+// decodeResult checks res for API errors and decodes its result into v
+func decodeResult(res *utils.APIResponse, v interface{}) error {
+	if err := res.Err(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(res.Result)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unexpected result format: %w", err)
+	}
+	return nil
+}
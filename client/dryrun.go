@@ -0,0 +1,97 @@
+package cloudflared1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// errDryRun is returned by calls that cannot be recorded, such as database management
+var errDryRun = errors.New("not available on a dry-run client")
+
+// Recorder collects the statements a dry-run client would have sent.
+// Every helper that generates SQL goes through the same request path, so the
+// recorder shows the exact SQL and parameters of any of them.
+type Recorder struct {
+	mu         sync.Mutex
+	statements []utils.Statement
+}
+
+// NewDryRunClient returns a client that records statements instead of sending them
+func NewDryRunClient() (*Client, *Recorder) {
+	return (&Client{DatabaseID: "dry-run"}).DryRun()
+}
+
+// DryRun returns a copy of the client that records statements instead of
+// sending them, together with its recorder. Every statement is answered with
+// an empty, successful result set. Database management calls such as ListDB
+// return an error, since they cannot be answered without the API.
+func (c *Client) DryRun() (*Client, *Recorder) {
+	rec := &Recorder{}
+	dry := *c
+	dry.recorder = rec
+	return &dry, rec
+}
+
+// Statements returns the recorded statements in the order they were issued.
+// Params hold the values as sent to D1.
+func (r *Recorder) Statements() []utils.Statement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]utils.Statement(nil), r.statements...)
+}
+
+// Reset discards the recorded statements
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = nil
+}
+
+// String formats the recorded statements for snapshots and debugging,
+// one statement per block followed by its parameters
+func (r *Recorder) String() string {
+	var b strings.Builder
+	for i, stmt := range r.Statements() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		params, _ := json.Marshal(stmt.Params)
+		fmt.Fprintf(&b, "%s\n-- params: %s\n", stmt.SQL, params)
+	}
+	return b.String()
+}
+
+// record stores the statements of a request body and returns the response a
+// successful request would have produced
+func (r *Recorder) record(body interface{}) *utils.APIResponse {
+	var bodies []queryBody
+	switch b := body.(type) {
+	case queryBody:
+		bodies = []queryBody{b}
+	case batchBody:
+		bodies = b.Batch
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]interface{}, len(bodies))
+	for i, qb := range bodies {
+		params := make([]interface{}, len(qb.Params))
+		for j, p := range qb.Params {
+			params[j] = p
+		}
+		r.statements = append(r.statements, utils.Statement{SQL: qb.SQL, Params: params})
+		result[i] = map[string]interface{}{
+			"results": map[string]interface{}{"columns": []interface{}{}, "rows": []interface{}{}},
+			"meta":    map[string]interface{}{},
+			"success": true,
+		}
+	}
+	return &utils.APIResponse{Result: result, Success: true}
+}
@@ -0,0 +1,181 @@
+package cloudflared1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultExportPollInterval is how often ExportDatabase asks whether an
+// export has finished, unless ExportOptions.PollInterval is set
+const defaultExportPollInterval = time.Second
+
+// ExportOptions holds the optional settings of ExportDatabase.
+// The zero value exports the schema and data of all tables without
+// downloading the dump.
+type ExportOptions struct {
+	// Tables limits the export to these tables; empty exports all tables
+	Tables []string
+	// NoData exports only the schema, NoSchema only the data
+	NoData   bool
+	NoSchema bool
+	// PollInterval is the wait between two status requests while the export
+	// runs. Zero means one second.
+	PollInterval time.Duration
+	// Output receives the SQL dump once the export finished. nil leaves the
+	// download to the caller, through ExportResult.SignedURL.
+	Output io.Writer
+}
+
+// ExportResult describes a finished export
+type ExportResult struct {
+	// Bookmark is the database state the dump was taken at
+	Bookmark string
+	// Filename is the suggested name of the dump file
+	Filename string
+	// SignedURL is where the dump can be downloaded, without authentication,
+	// for a limited time
+	SignedURL string
+	// Messages are the progress messages reported by the API
+	Messages []string
+	// Written is the number of bytes written to ExportOptions.Output
+	Written int64
+}
+
+// exportBody is the JSON body of the export call
+type exportBody struct {
+	OutputFormat    string            `json:"output_format"`
+	CurrentBookmark string            `json:"current_bookmark,omitempty"`
+	DumpOptions     exportDumpOptions `json:"dump_options"`
+}
+
+type exportDumpOptions struct {
+	NoData   bool     `json:"no_data,omitempty"`
+	NoSchema bool     `json:"no_schema,omitempty"`
+	Tables   []string `json:"tables,omitempty"`
+}
+
+// exportStatus is the result of the export call
+type exportStatus struct {
+	AtBookmark string   `json:"at_bookmark"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error"`
+	Messages   []string `json:"messages"`
+	Result     struct {
+		Filename  string `json:"filename"`
+		SignedURL string `json:"signed_url"`
+	} `json:"result"`
+}
+
+// ExportDatabase exports the connected database as an SQL dump, e.g. for
+// backups. It starts the export, polls until it finished and, if
+// opts.Output is set, downloads the dump into it.
+func (c *Client) ExportDatabase(opts ExportOptions) (*ExportResult, error) {
+	return c.ExportDatabaseContext(context.Background(), opts)
+}
+
+// ExportDatabaseContext is ExportDatabase with a context that limits the
+// whole export, including polling and the download
+func (c *Client) ExportDatabaseContext(ctx context.Context, opts ExportOptions) (*ExportResult, error) {
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+	if c.DatabaseID == "" {
+		return nil, fmt.Errorf("no database connected, call ConnectDB first")
+	}
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultExportPollInterval
+	}
+	body := exportBody{
+		OutputFormat: "polling",
+		DumpOptions:  exportDumpOptions{NoData: opts.NoData, NoSchema: opts.NoSchema, Tables: opts.Tables},
+	}
+
+	var status exportStatus
+	for {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		res, err := c.do(ctx, OpExportDatabase, "POST", c.buildURL(c.DatabaseID, "export"), string(payload))
+		if err != nil {
+			return nil, err
+		}
+		status = exportStatus{}
+		if err := decodeResult(res, &status); err != nil {
+			return nil, fmt.Errorf("failed to export database %s: %w", c.DatabaseID, err)
+		}
+
+		if status.Status == "complete" {
+			break
+		}
+		if status.Status == "error" || status.Error != "" {
+			return nil, fmt.Errorf("failed to export database %s: %s", c.DatabaseID, status.Error)
+		}
+
+		// Later calls ask for the export started at this bookmark instead of starting another
+		body.CurrentBookmark = status.AtBookmark
+		if err := wait(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ExportResult{
+		Bookmark:  status.AtBookmark,
+		Filename:  status.Result.Filename,
+		SignedURL: status.Result.SignedURL,
+		Messages:  status.Messages,
+	}
+	if opts.Output != nil {
+		n, err := c.download(ctx, result.SignedURL, opts.Output)
+		result.Written = n
+		if err != nil {
+			return result, fmt.Errorf("failed to download export of database %s: %w", c.DatabaseID, err)
+		}
+	}
+	return result, nil
+}
+
+// download copies the body of a GET request to w. The request carries no
+// API token, since signed URLs point outside the Cloudflare API.
+func (c *Client) download(ctx context.Context, target string, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return 0, fmt.Errorf("HTTP %d", res.StatusCode)
+	}
+	return io.Copy(w, res.Body)
+}
+
+// httpClient returns the HTTP client requests of c are sent with
+func (c *Client) httpClient() *http.Client {
+	if c.requester.HTTPClient != nil {
+		return c.requester.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// wait blocks for d or until ctx is done
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
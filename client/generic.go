@@ -0,0 +1,69 @@
+package cloudflared1
+
+import (
+	"context"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// SelectAll executes a query on the connected database and returns all rows as a []T
+// T can be a struct, a pointer to a struct, or a single column type such as string or int64
+// Like sqlx: users, err := SelectAll[User](client, "SELECT * FROM users WHERE age > ?", 25)
+func SelectAll[T any](c *Client, query string, args ...any) ([]T, error) {
+	rows, err := c.queryRows(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return utils.ScanAll[T](rows)
+}
+
+// GetOne executes a query on the connected database and returns the first row as a T
+// It returns utils.ErrNoRows if the query returned no rows
+// Like sqlx: user, err := GetOne[User](client, "SELECT * FROM users WHERE id = ?", 123)
+func GetOne[T any](c *Client, query string, args ...any) (T, error) {
+	rows, err := c.queryRows(context.Background(), query, args...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer rows.Close()
+
+	return utils.ScanOne[T](rows)
+}
+
+// PoolSelectAll is SelectAll on the currently connected database of a pool
+func PoolSelectAll[T any](p *ConnectionPool, query string, args ...any) ([]T, error) {
+	client, ctx, done, err := p.current(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	rows, err := client.queryRows(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return utils.ScanAll[T](rows)
+}
+
+// PoolGetOne is GetOne on the currently connected database of a pool
+func PoolGetOne[T any](p *ConnectionPool, query string, args ...any) (T, error) {
+	var zero T
+	client, ctx, done, err := p.current(context.Background())
+	if err != nil {
+		return zero, err
+	}
+	defer done()
+
+	rows, err := client.queryRows(ctx, query, args...)
+	if err != nil {
+		return zero, err
+	}
+	defer rows.Close()
+
+	return utils.ScanOne[T](rows)
+}
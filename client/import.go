@@ -0,0 +1,259 @@
+package cloudflared1
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportPhase names the step an import is in
+type ImportPhase string
+
+// Phases reported to ImportOptions.Progress, in order
+const (
+	// ImportPreparing hashes the file, which the API identifies it by
+	ImportPreparing ImportPhase = "preparing"
+	ImportUploading ImportPhase = "uploading"
+	// ImportIngesting runs while D1 executes the statements of the file
+	ImportIngesting ImportPhase = "ingesting"
+	ImportComplete  ImportPhase = "complete"
+)
+
+// ImportProgress describes how far an import got
+type ImportProgress struct {
+	Phase ImportPhase
+	// BytesUploaded of TotalBytes were sent so far
+	BytesUploaded int64
+	TotalBytes    int64
+}
+
+// ImportOptions holds the optional settings of ImportSQL
+type ImportOptions struct {
+	// PollInterval is the wait between two status requests while the file
+	// is ingested. Zero means one second.
+	PollInterval time.Duration
+	// Progress is called when the phase changes and while the file is
+	// uploaded. It is called from the goroutine running ImportSQL.
+	Progress func(ImportProgress)
+}
+
+// ImportError is returned when D1 failed to execute an imported file
+type ImportError struct {
+	// Message is the error reported by the API
+	Message string
+	// Line is the line of the file the error refers to, if the message names
+	// one, or zero
+	Line int
+	// Messages are the progress messages reported before the error
+	Messages []string
+}
+
+func (e *ImportError) Error() string {
+	return "import failed: " + e.Message
+}
+
+// importLine finds a line number in an import error message
+var importLine = regexp.MustCompile(`(?i)\bline:? (\d+)`)
+
+func newImportError(message string, messages []string) *ImportError {
+	err := &ImportError{Message: message, Messages: messages}
+	if m := importLine.FindStringSubmatch(message); m != nil {
+		err.Line, _ = strconv.Atoi(m[1])
+	}
+	return err
+}
+
+// importBody is the JSON body of the import calls
+type importBody struct {
+	Action          string `json:"action"`
+	Etag            string `json:"etag,omitempty"`
+	Filename        string `json:"filename,omitempty"`
+	CurrentBookmark string `json:"current_bookmark,omitempty"`
+}
+
+// importStatus is the result of the import calls
+type importStatus struct {
+	UploadURL  string   `json:"upload_url"`
+	Filename   string   `json:"filename"`
+	AtBookmark string   `json:"at_bookmark"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error"`
+	Messages   []string `json:"messages"`
+}
+
+// ImportSQL executes an SQL file, such as a dump of ExportDatabase, on the
+// connected database. The file is uploaded to Cloudflare and then ingested
+// by D1. r is streamed; if it is not an io.ReadSeeker it is spooled to a
+// temporary file first, since the upload must be hashed before it is sent.
+func (c *Client) ImportSQL(r io.Reader, opts ImportOptions) error {
+	return c.ImportSQLContext(context.Background(), r, opts)
+}
+
+// ImportSQLContext is ImportSQL with a context that limits the whole import.
+// Ending the context stops waiting for the ingest, not the ingest itself.
+func (c *Client) ImportSQLContext(ctx context.Context, r io.Reader, opts ImportOptions) error {
+	if c.recorder != nil {
+		return errDryRun
+	}
+	if c.DatabaseID == "" {
+		return fmt.Errorf("no database connected, call ConnectDB first")
+	}
+
+	progress := opts.Progress
+	if progress == nil {
+		progress = func(ImportProgress) {}
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultExportPollInterval
+	}
+
+	progress(ImportProgress{Phase: ImportPreparing})
+	file, size, etag, cleanup, err := hashImport(r)
+	if err != nil {
+		return fmt.Errorf("failed to read import file: %w", err)
+	}
+	defer cleanup()
+
+	status, err := c.importCall(ctx, importBody{Action: "init", Etag: etag})
+	if err != nil {
+		return err
+	}
+	// An empty upload URL means the same file was uploaded before
+	if status.UploadURL != "" {
+		counted := &progressReader{r: file, total: size, report: progress}
+		progress(ImportProgress{Phase: ImportUploading, TotalBytes: size})
+		if err := c.upload(ctx, status.UploadURL, counted, size, etag); err != nil {
+			return fmt.Errorf("failed to upload import file: %w", err)
+		}
+	}
+
+	progress(ImportProgress{Phase: ImportIngesting, BytesUploaded: size, TotalBytes: size})
+	status, err = c.importCall(ctx, importBody{Action: "ingest", Etag: etag, Filename: status.Filename})
+	for err == nil && status.Status != "complete" {
+		if status.Status == "error" || status.Error != "" {
+			return newImportError(status.Error, status.Messages)
+		}
+		if err := wait(ctx, interval); err != nil {
+			return err
+		}
+		status, err = c.importCall(ctx, importBody{Action: "poll", CurrentBookmark: status.AtBookmark})
+	}
+	if err != nil {
+		return err
+	}
+
+	progress(ImportProgress{Phase: ImportComplete, BytesUploaded: size, TotalBytes: size})
+	return nil
+}
+
+// importCall sends one of the import calls and decodes its result
+func (c *Client) importCall(ctx context.Context, body importBody) (*importStatus, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(ctx, OpImportDatabase, "POST", c.buildURL(c.DatabaseID, "import"), string(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	var status importStatus
+	if err := decodeResult(res, &status); err != nil {
+		return nil, fmt.Errorf("failed to import into database %s: %w", c.DatabaseID, err)
+	}
+	return &status, nil
+}
+
+// hashImport returns the MD5 hex digest and size of r, and r rewound to its
+// start. A reader that cannot seek is copied to a temporary file, which
+// cleanup removes.
+func hashImport(r io.Reader) (io.Reader, int64, string, func(), error) {
+	cleanup := func() {}
+	var start int64
+	seeker, ok := r.(io.ReadSeeker)
+	if ok {
+		// The file starts at the current offset of the reader
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, 0, "", cleanup, err
+		}
+	} else {
+		tmp, err := os.CreateTemp("", "d1-import-*.sql")
+		if err != nil {
+			return nil, 0, "", cleanup, err
+		}
+		cleanup = func() {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+		if _, err := io.Copy(tmp, r); err != nil {
+			return nil, 0, "", cleanup, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, 0, "", cleanup, err
+		}
+		seeker = tmp
+	}
+
+	hash := md5.New()
+	size, err := io.Copy(hash, seeker)
+	if err != nil {
+		return nil, 0, "", cleanup, err
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, "", cleanup, err
+	}
+	return seeker, size, hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
+
+// upload sends the file to the signed URL of the import. The storage answers
+// with the MD5 of what it received as ETag, which must match.
+func (c *Client) upload(ctx context.Context, target string, body io.Reader, size int64, etag string) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", target, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", res.StatusCode)
+	}
+	if got := strings.Trim(res.Header.Get("ETag"), `"`); got != "" && got != etag {
+		return fmt.Errorf("the upload was corrupted: ETag %s, want %s", got, etag)
+	}
+	return nil
+}
+
+// progressReader reports the bytes read through it
+type progressReader struct {
+	r      io.Reader
+	n      int64
+	total  int64
+	report func(ImportProgress)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.report(ImportProgress{Phase: ImportUploading, BytesUploaded: p.n, TotalBytes: p.total})
+	}
+	return n, err
+}
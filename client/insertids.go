@@ -0,0 +1,218 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// InsertIDsOption configures BatchInsertIDs
+type InsertIDsOption func(*insertIDsOptions)
+
+type insertIDsOptions struct {
+	column      string
+	noReturning bool
+	dest        interface{}
+}
+
+// IDColumn sets the column holding the generated ID, "id" by default
+func IDColumn(name string) InsertIDsOption {
+	return func(o *insertIDsOptions) {
+		o.column = name
+	}
+}
+
+// WithoutReturning recovers IDs from last_row_id instead of appending
+// RETURNING, see BatchInsertIDs for when that is possible
+func WithoutReturning() InsertIDsOption {
+	return func(o *insertIDsOptions) {
+		o.noReturning = true
+	}
+}
+
+// WriteIDsTo stores the IDs into the ID field of each element of dest, a
+// pointer to a []T or []*T with one element per inserted row, in input order.
+// The field is the one mapped to the ID column, by db tag or name mapper.
+func WriteIDsTo(dest interface{}) InsertIDsOption {
+	return func(o *insertIDsOptions) {
+		o.dest = dest
+	}
+}
+
+// IDRecoveryError reports why the generated IDs of an INSERT statement could not be recovered
+type IDRecoveryError struct {
+	Index  int // index of the statement in the batch
+	Reason string
+}
+
+func (e *IDRecoveryError) Error() string {
+	return fmt.Sprintf("cannot recover inserted IDs of statement %d: %s", e.Index, e.Reason)
+}
+
+var (
+	returningClause = regexp.MustCompile(`(?i)\bRETURNING\b`)
+	upsertClause    = regexp.MustCompile(`(?i)\bON\s+CONFLICT\b|^\s*REPLACE\b|^\s*INSERT\s+OR\s+REPLACE\b`)
+	insertTable     = regexp.MustCompile("(?is)^\\s*(?:INSERT(?:\\s+OR\\s+\\w+)?|REPLACE)\\s+INTO\\s+(\"(?:[^\"]|\"\")+\"|`[^`]+`|\\[[^\\]]+\\]|[\\w.]+)")
+)
+
+// BatchInsertIDs runs INSERT statements as one atomic batch on the connected
+// database and returns the generated ID of every inserted row, in statement
+// and row order, so multi-row inserts can be followed by their child rows.
+//
+// RETURNING with the ID column is appended to each statement. A statement that
+// already has a RETURNING clause, or every statement with WithoutReturning,
+// falls back to last_row_id: the IDs of its rows are last_row_id-changes+1
+// through last_row_id. That only holds for generated IDs of an AUTOINCREMENT
+// table without upserts, since the batch is a single transaction; otherwise an
+// *IDRecoveryError explains why the IDs are unknown and the batch is not sent.
+func (c *Client) BatchInsertIDs(statements []utils.Statement, opts ...InsertIDsOption) ([]int64, error) {
+	return c.BatchInsertIDsContext(context.Background(), statements, opts...)
+}
+
+// BatchInsertIDsContext is BatchInsertIDs with a context that can cancel the requests
+func (c *Client) BatchInsertIDsContext(ctx context.Context, statements []utils.Statement, opts ...InsertIDsOption) ([]int64, error) {
+	o := insertIDsOptions{column: "id"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	quoted, err := utils.QuoteIdentifier(o.column)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID column: %w", err)
+	}
+
+	// Plan every statement before sending anything
+	batch := make([]utils.Statement, len(statements))
+	returning := make([]bool, len(statements))
+	checked := make(map[string]bool)
+	for i, stmt := range statements {
+		table := insertTable.FindStringSubmatch(stmt.SQL)
+		if table == nil {
+			return nil, &IDRecoveryError{Index: i, Reason: "not an INSERT statement"}
+		}
+
+		batch[i] = stmt
+		if !o.noReturning && !returningClause.MatchString(stmt.SQL) {
+			batch[i].SQL = trimTrailingSemicolons(stmt.SQL) + " RETURNING " + quoted
+			returning[i] = true
+			continue
+		}
+
+		if upsertClause.MatchString(stmt.SQL) {
+			return nil, &IDRecoveryError{Index: i, Reason: "last_row_id does not identify the rows of an upsert"}
+		}
+		auto, ok := checked[table[1]]
+		if !ok {
+			if auto, err = c.isAutoincrement(ctx, table[1]); err != nil {
+				return nil, err
+			}
+			checked[table[1]] = auto
+		}
+		if !auto {
+			return nil, &IDRecoveryError{Index: i, Reason: fmt.Sprintf("table %s is not AUTOINCREMENT, so IDs of earlier rows may be reused", table[1])}
+		}
+	}
+
+	results, err := c.BatchContext(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int64{}
+	for i, res := range results {
+		if returning[i] {
+			got, err := utils.ScanAll[int64](res.Rows)
+			if err != nil {
+				return nil, &utils.BatchError{Index: i, Err: err}
+			}
+			ids = append(ids, got...)
+			continue
+		}
+
+		last, _ := res.Result.LastInsertId()
+		n, _ := res.Result.RowsAffected()
+		for id := last - n + 1; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	if o.dest != nil {
+		if err := assignIDs(o.dest, ids, o.column, c.nameMapper); err != nil {
+			return ids, err
+		}
+	}
+	return ids, nil
+}
+
+// isAutoincrement reports whether the table was declared with AUTOINCREMENT.
+// table is the name as written in the statement, possibly quoted.
+func (c *Client) isAutoincrement(ctx context.Context, table string) (bool, error) {
+	name := unquoteIdentifier(table)
+	var schema struct {
+		SQL string `db:"sql"`
+	}
+	err := c.GetContext(ctx, &schema, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", name)
+	if err != nil {
+		return false, fmt.Errorf("failed to read schema of %s: %w", name, err)
+	}
+	return strings.Contains(strings.ToUpper(schema.SQL), "AUTOINCREMENT"), nil
+}
+
+// unquoteIdentifier strips the quotes SQLite accepts around identifiers
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 {
+		switch first, last := name[0], name[len(name)-1]; {
+		case first == '"' && last == '"':
+			return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		case first == '`' && last == '`', first == '[' && last == ']':
+			return name[1 : len(name)-1]
+		}
+	}
+	return name
+}
+
+// assignIDs stores ids into the field mapped to column of each element of dest
+func assignIDs(dest interface{}, ids []int64, column string, mapper utils.NameMapper) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("WriteIDsTo needs a pointer to a slice, got %T", dest)
+	}
+	slice := v.Elem()
+	if slice.Len() != len(ids) {
+		return fmt.Errorf("got %d IDs for %d elements", len(ids), slice.Len())
+	}
+
+	var index []int
+	for _, fc := range utils.StructColumns(slice.Type().Elem(), mapper) {
+		if fc.Column == column {
+			index = fc.Index
+			break
+		}
+	}
+	if index == nil {
+		return fmt.Errorf("%s has no field for column %s", slice.Type().Elem(), column)
+	}
+
+	for i, id := range ids {
+		elem := slice.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				return fmt.Errorf("element %d is nil", i)
+			}
+			elem = elem.Elem()
+		}
+		field := utils.FieldByIndex(elem, index)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(id)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(uint64(id))
+		default:
+			return fmt.Errorf("ID field of %s is %s, not an integer", slice.Type().Elem(), field.Type())
+		}
+	}
+	return nil
+}
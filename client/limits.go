@@ -0,0 +1,58 @@
+package cloudflared1
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// D1 limits a statement to 100 bound parameters and 100,000 bytes of SQL.
+// ClientOptions.MaxBoundParams and MaxStatementBytes override them.
+const (
+	DefaultMaxBoundParams    = 100
+	DefaultMaxStatementBytes = 100000
+)
+
+// ErrStatementTooLarge is returned, wrapped with the size and the limit,
+// before sending a statement with more SQL or bound parameters than D1
+// accepts. BulkInsert splits its rows to stay within the limits instead.
+var ErrStatementTooLarge = errors.New("statement exceeds the D1 limits")
+
+// boundParamsLimit returns the most bound parameters a statement may have
+func (c *Client) boundParamsLimit() int {
+	if c.maxBoundParams > 0 {
+		return c.maxBoundParams
+	}
+	return DefaultMaxBoundParams
+}
+
+// statementBytesLimit returns the most bytes of SQL a statement may have
+func (c *Client) statementBytesLimit() int {
+	if c.maxStatementBytes > 0 {
+		return c.maxStatementBytes
+	}
+	return DefaultMaxStatementBytes
+}
+
+// checkLimits returns ErrStatementTooLarge, in a BatchError for a batch,
+// for the first statement of body beyond the limits of the client
+func (c *Client) checkLimits(body interface{}) error {
+	_, batch := body.(batchBody)
+	for i, stmt := range statements(body) {
+		var err error
+		switch {
+		case len(stmt.SQL) > c.statementBytesLimit():
+			err = fmt.Errorf("%w: %d bytes of SQL, the limit is %d", ErrStatementTooLarge, len(stmt.SQL), c.statementBytesLimit())
+		case len(stmt.Params) > c.boundParamsLimit():
+			err = fmt.Errorf("%w: %d bound parameters, the limit is %d", ErrStatementTooLarge, len(stmt.Params), c.boundParamsLimit())
+		default:
+			continue
+		}
+		if batch {
+			return &utils.BatchError{Index: i, Err: err}
+		}
+		return err
+	}
+	return nil
+}
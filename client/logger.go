@@ -0,0 +1,185 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// Operations reported in LogEntry.Operation
+const (
+	OpQuery           = "query"
+	OpBatch           = "batch"
+	OpListDatabases   = "list_databases"
+	OpGetDatabase     = "get_database"
+	OpCreateDatabase  = "create_database"
+	OpDeleteDatabase  = "delete_database"
+	OpExportDatabase  = "export_database"
+	OpImportDatabase  = "import_database"
+	OpTimeTravel      = "time_travel"
+	OpRestoreDatabase = "restore_database"
+	OpVerifyToken     = "verify_token"
+)
+
+// LogEntry describes one API call made by a client
+type LogEntry struct {
+	// Operation is one of the Op constants
+	Operation string
+	// SQL is the statement sent, or the statements of a batch joined by
+	// "; ". It is empty for database management calls.
+	SQL string
+	// ParamCount is the number of parameters, and ParamTypes their Go types.
+	// Parameters passed to Query as strings are reported as "string".
+	ParamCount int
+	ParamTypes []string
+	// Params holds the parameter values as sent, only if the logger was set
+	// with LogParamValues
+	Params   []string
+	Duration time.Duration
+	// RowsRead and RowsWritten are summed over the statements
+	RowsRead    int64
+	RowsWritten int64
+	// Err is the error of the call, including errors reported by the API
+	Err error
+}
+
+// Logger receives a LogEntry after every API call. It is called from the
+// goroutine that made the call, with the context of the call.
+type Logger func(ctx context.Context, entry LogEntry)
+
+// LogOption configures SetLogger
+type LogOption func(*logConfig)
+
+type logConfig struct {
+	logger      Logger
+	paramValues bool
+}
+
+// LogParamValues includes the parameter values in LogEntry.Params. They are
+// left out by default, since they often carry personal data or secrets.
+func LogParamValues() LogOption {
+	return func(c *logConfig) {
+		c.paramValues = true
+	}
+}
+
+// SetLogger sets a logger called after every query, batch and database
+// management call. Requests made by the migrations executor are included.
+// nil removes the logger.
+func (c *Client) SetLogger(logger Logger, opts ...LogOption) {
+	if logger == nil {
+		c.log = nil
+		return
+	}
+	cfg := &logConfig{logger: logger}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	c.log = cfg
+}
+
+// SlogLogger returns a Logger that writes to logger: successful calls at
+// debug level, failed calls at error level
+func SlogLogger(logger *slog.Logger) Logger {
+	return func(ctx context.Context, entry LogEntry) {
+		attrs := []slog.Attr{
+			slog.String("operation", entry.Operation),
+			slog.Duration("duration", entry.Duration),
+		}
+		if entry.SQL != "" {
+			attrs = append(attrs,
+				slog.String("sql", entry.SQL),
+				slog.Int("param_count", entry.ParamCount),
+				slog.Any("param_types", entry.ParamTypes),
+				slog.Int64("rows_read", entry.RowsRead),
+				slog.Int64("rows_written", entry.RowsWritten),
+			)
+		}
+		if entry.Params != nil {
+			attrs = append(attrs, slog.Any("params", entry.Params))
+		}
+
+		level := slog.LevelDebug
+		if entry.Err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", entry.Err.Error()))
+		}
+		logger.LogAttrs(ctx, level, "d1 "+entry.Operation, attrs...)
+	}
+}
+
+// logCall passes a finished call to the logger, if one is set. body is the
+// queryBody or batchBody of a query, or nil for a management call.
+func (c *Client) logCall(ctx context.Context, op string, body interface{}, start time.Time, res *utils.APIResponse, err error) {
+	if c.log == nil {
+		return
+	}
+
+	entry := LogEntry{Operation: op, Duration: time.Since(start), Err: err}
+	if err == nil && res != nil {
+		entry.Err = res.Err()
+		entry.RowsRead, entry.RowsWritten = res.RowCounts()
+	}
+
+	entry.SQL = statementsSQL(body)
+	for _, stmt := range statements(body) {
+		entry.ParamCount += len(stmt.Params)
+		entry.ParamTypes = append(entry.ParamTypes, stmt.paramTypes()...)
+		if c.log.paramValues {
+			entry.Params = append(entry.Params, stmt.Params...)
+		}
+	}
+
+	c.log.logger(ctx, entry)
+}
+
+// statements returns the statements of a queryBody or batchBody
+func statements(body interface{}) []queryBody {
+	switch b := body.(type) {
+	case queryBody:
+		return []queryBody{b}
+	case batchBody:
+		return b.Batch
+	}
+	return nil
+}
+
+// statementsSQL returns the SQL of a queryBody or batchBody, the statements
+// of a batch joined by "; "
+func statementsSQL(body interface{}) string {
+	stmts := statements(body)
+	sql := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		sql[i] = stmt.SQL
+	}
+	return strings.Join(sql, "; ")
+}
+
+// paramTypes returns the Go types of the parameters of a statement
+func (b queryBody) paramTypes() []string {
+	if b.types != nil {
+		return b.types
+	}
+	types := make([]string, len(b.Params))
+	for i := range types {
+		types[i] = "string"
+	}
+	return types
+}
+
+// typeNames returns the Go type of each arg, "nil" for nil
+func typeNames(args []interface{}) []string {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			names[i] = "nil"
+			continue
+		}
+		names[i] = fmt.Sprintf("%T", arg)
+	}
+	return names
+}
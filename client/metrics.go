@@ -0,0 +1,43 @@
+package cloudflared1
+
+import (
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// MetricsCollector receives measurements of the API calls made by a client,
+// e.g. to export them as Prometheus metrics; the d1prometheus module has an
+// implementation. It is called after every call with one of the Op
+// constants, from the goroutine that made the call, so it must be safe for
+// concurrent use.
+type MetricsCollector interface {
+	ObserveQuery(op string, duration time.Duration, err error, rowsRead, rowsWritten int64)
+}
+
+// ConnectCacheCollector is implemented by collectors that also count whether
+// ConnectionPool.Connect found the database in its cache
+type ConnectCacheCollector interface {
+	ObserveConnect(cacheHit bool)
+}
+
+// observe passes a finished call to the metrics collector, if one is set
+func (c *Client) observe(op string, start time.Time, res *utils.APIResponse, err error) {
+	if c.metrics == nil {
+		return
+	}
+
+	var read, written int64
+	if err == nil && res != nil {
+		err = res.Err()
+		read, written = res.RowCounts()
+	}
+	c.metrics.ObserveQuery(op, time.Since(start), err, read, written)
+}
+
+// observeConnect reports a Connect cache lookup, if the collector counts them
+func (c *Client) observeConnect(cacheHit bool) {
+	if collector, ok := c.metrics.(ConnectCacheCollector); ok {
+		collector.ObserveConnect(cacheHit)
+	}
+}
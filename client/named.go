@@ -0,0 +1,38 @@
+package cloudflared1
+
+import (
+	"context"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// NamedExec executes a query with :name placeholders bound from arg, similar to sqlx.NamedExec
+// arg is a struct with db tags or a map[string]interface{}
+// Like sqlx: client.NamedExec("INSERT INTO users (name, age) VALUES (:name, :age)", user)
+func (c *Client) NamedExec(query string, arg interface{}) (int64, error) {
+	return c.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext is NamedExec with a context that can cancel the request
+func (c *Client) NamedExecContext(ctx context.Context, query string, arg interface{}) (int64, error) {
+	query, args, err := utils.BindNamedMapper(query, arg, c.nameMapper)
+	if err != nil {
+		return 0, err
+	}
+	return c.ExecContext(ctx, query, args...)
+}
+
+// NamedSelect executes a query with :name placeholders bound from arg and scans all results into dest
+// Like sqlx: client.NamedSelect(&users, "SELECT * FROM users WHERE age > :age", map[string]interface{}{"age": 25})
+func (c *Client) NamedSelect(dest interface{}, query string, arg interface{}) error {
+	return c.NamedSelectContext(context.Background(), dest, query, arg)
+}
+
+// NamedSelectContext is NamedSelect with a context that can cancel the request
+func (c *Client) NamedSelectContext(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	query, args, err := utils.BindNamedMapper(query, arg, c.nameMapper)
+	if err != nil {
+		return err
+	}
+	return c.SelectContext(ctx, dest, query, args...)
+}
@@ -1,358 +1,1340 @@
-package cloudflared1
-
-import (
-	"fmt"
-	"sync"
-	"time"
-
-	"github.com/youfun/cloudflare-d1-go/utils"
-)
-
-// ConnectionInfo holds database connection metadata
-type ConnectionInfo struct {
-	DatabaseID string
-	Name       string
-	CachedAt   time.Time
-}
-
-// ConnectionPool manages database connections with caching and persistence
-// Similar to sqlx.DB but for Cloudflare D1
-type ConnectionPool struct {
-	accountID       string
-	apiToken        string
-	connections     map[string]*ConnectionInfo
-	currentDB       string
-	mu              sync.RWMutex
-	maxCacheAge     time.Duration
-	autoReconnect   bool
-	lastHealthCheck time.Time
-}
-
-// NewConnectionPool creates a new connection pool
-func NewConnectionPool(accountID, apiToken string) *ConnectionPool {
-	if accountID == "" || apiToken == "" {
-		return nil
-	}
-	return &ConnectionPool{
-		accountID:     accountID,
-		apiToken:      apiToken,
-		connections:   make(map[string]*ConnectionInfo),
-		maxCacheAge:   24 * time.Hour, // Cache for 24 hours by default
-		autoReconnect: true,
-	}
-}
-
-// Connect connects to a database by name, with automatic caching
-// If cached, returns immediately without API call
-// Like sqlx: pool.Connect("database_name")
-func (p *ConnectionPool) Connect(dbName string) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	// Check if already connected and cache is valid
-	if connInfo, exists := p.connections[dbName]; exists {
-		if time.Since(connInfo.CachedAt) < p.maxCacheAge {
-			p.currentDB = dbName
-			return nil // Return from cache
-		}
-	}
-
-	// Cache miss or expired, fetch from API
-	client := &Client{
-		AccountID: p.accountID,
-		APIToken:  p.apiToken,
-	}
-
-	if err := client.ConnectDB(dbName); err != nil {
-		return fmt.Errorf("failed to connect to database %s: %w", dbName, err)
-	}
-
-	// Cache the connection info
-	p.connections[dbName] = &ConnectionInfo{
-		DatabaseID: client.DatabaseID,
-		Name:       dbName,
-		CachedAt:   time.Now(),
-	}
-
-	p.currentDB = dbName
-	return nil
-}
-
-// ConnectWithID connects directly using database ID
-// Useful when you already know the database ID
-func (p *ConnectionPool) ConnectWithID(dbName, databaseID string) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	p.connections[dbName] = &ConnectionInfo{
-		DatabaseID: databaseID,
-		Name:       dbName,
-		CachedAt:   time.Now(),
-	}
-
-	p.currentDB = dbName
-	return nil
-}
-
-// Query executes a query on the currently connected database
-// Like sqlx: result := pool.Query("SELECT * FROM users")
-func (p *ConnectionPool) Query(query string, params []string) (*utils.APIResponse, error) {
-	p.mu.RLock()
-	connInfo, exists := p.connections[p.currentDB]
-	p.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("no database connected, call Connect first")
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.Query(query, params)
-}
-
-// Select executes a query and scans all results into a slice, similar to sqlx.Select
-// Like sqlx: pool.Select(&users, "SELECT * FROM users WHERE age > ?", 25)
-func (p *ConnectionPool) Select(dest interface{}, query string, args ...interface{}) error {
-	p.mu.RLock()
-	connInfo, exists := p.connections[p.currentDB]
-	p.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("no database connected, call Connect first")
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.Select(dest, query, args...)
-}
-
-// Get executes a query and scans the first result into a struct, similar to sqlx.Get
-// Like sqlx: pool.Get(&user, "SELECT * FROM users WHERE id = ?", 123)
-func (p *ConnectionPool) Get(dest interface{}, query string, args ...interface{}) error {
-	p.mu.RLock()
-	connInfo, exists := p.connections[p.currentDB]
-	p.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("no database connected, call Connect first")
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.Get(dest, query, args...)
-}
-
-// Exec executes a query and returns the number of rows affected, similar to sqlx.Exec
-// Like sqlx: rowsAffected, err := pool.Exec("UPDATE users SET age = ? WHERE id = ?", 30, 123)
-func (p *ConnectionPool) Exec(query string, args ...interface{}) (int64, error) {
-	p.mu.RLock()
-	connInfo, exists := p.connections[p.currentDB]
-	p.mu.RUnlock()
-
-	if !exists {
-		return 0, fmt.Errorf("no database connected, call Connect first")
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.Exec(query, args...)
-}
-
-// QueryDB executes a query on a specific database in the pool
-// Like sqlx: result := pool.QueryDB(dbName, "SELECT * FROM users")
-func (p *ConnectionPool) QueryDB(dbName string, query string, params []string) (*utils.APIResponse, error) {
-	p.mu.RLock()
-	connInfo, exists := p.connections[dbName]
-	p.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("database %s not connected, call Connect first", dbName)
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.Query(query, params)
-}
-
-// CreateTable creates a table in the currently connected database
-func (p *ConnectionPool) CreateTable(createQuery string) (*utils.APIResponse, error) {
-	p.mu.RLock()
-	connInfo, exists := p.connections[p.currentDB]
-	p.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("no database connected, call Connect first")
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.CreateTable(createQuery)
-}
-
-// RemoveTable removes a table from the currently connected database
-func (p *ConnectionPool) RemoveTable(tableName string) (*utils.APIResponse, error) {
-	p.mu.RLock()
-	connInfo, exists := p.connections[p.currentDB]
-	p.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("no database connected, call Connect first")
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.RemoveTable(tableName)
-}
-
-// RemoveTableDB removes a table from a specific database in the pool
-func (p *ConnectionPool) RemoveTableDB(dbName, tableName string) (*utils.APIResponse, error) {
-	p.mu.RLock()
-	connInfo, exists := p.connections[dbName]
-	p.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("database %s not connected, call Connect first", dbName)
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.RemoveTable(tableName)
-}
-
-// CreateTableDB creates a table in a specific database in the pool
-func (p *ConnectionPool) CreateTableDB(dbName, createQuery string) (*utils.APIResponse, error) {
-	p.mu.RLock()
-	connInfo, exists := p.connections[dbName]
-	p.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("database %s not connected, call Connect first", dbName)
-	}
-
-	client := &Client{
-		AccountID:  p.accountID,
-		APIToken:   p.apiToken,
-		DatabaseID: connInfo.DatabaseID,
-	}
-
-	return client.CreateTable(createQuery)
-}
-
-// GetCurrentDB returns the name of the currently connected database
-func (p *ConnectionPool) GetCurrentDB() string {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.currentDB
-}
-
-// GetDatabaseID returns the ID of a cached database connection
-func (p *ConnectionPool) GetDatabaseID(dbName string) string {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	if connInfo, exists := p.connections[dbName]; exists {
-		return connInfo.DatabaseID
-	}
-	return ""
-}
-
-// ClearCache removes a database from cache, forcing re-query on next Connect
-func (p *ConnectionPool) ClearCache(dbName string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	delete(p.connections, dbName)
-}
-
-// ClearAllCache removes all databases from cache
-func (p *ConnectionPool) ClearAllCache() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.connections = make(map[string]*ConnectionInfo)
-	p.currentDB = ""
-}
-
-// SetCacheAge sets the maximum age for cached connections
-// Default is 24 hours. Set to 0 for no caching.
-func (p *ConnectionPool) SetCacheAge(duration time.Duration) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.maxCacheAge = duration
-}
-
-// SetAutoReconnect enables/disables automatic reconnection on failure
-func (p *ConnectionPool) SetAutoReconnect(enabled bool) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.autoReconnect = enabled
-}
-
-// ListCachedDatabases returns a list of all cached database names
-func (p *ConnectionPool) ListCachedDatabases() []string {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	var dbNames []string
-	for name := range p.connections {
-		dbNames = append(dbNames, name)
-	}
-	return dbNames
-}
-
-// IsCached checks if a database connection is cached
-func (p *ConnectionPool) IsCached(dbName string) bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	if connInfo, exists := p.connections[dbName]; exists {
-		return time.Since(connInfo.CachedAt) < p.maxCacheAge
-	}
-	return false
-}
-
-// GetCacheInfo returns information about a cached connection
-func (p *ConnectionPool) GetCacheInfo(dbName string) *ConnectionInfo {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	if connInfo, exists := p.connections[dbName]; exists {
-		// Return a copy to prevent external modification
-		return &ConnectionInfo{
-			DatabaseID: connInfo.DatabaseID,
-			Name:       connInfo.Name,
-			CachedAt:   connInfo.CachedAt,
-		}
-	}
-	return nil
-}
+package cloudflared1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// ConnectionInfo holds database connection metadata
+type ConnectionInfo struct {
+	DatabaseID string
+	Name       string
+	CachedAt   time.Time
+	// LastHealthCheck is when Ping or the refresher started with StartRefresh
+	// last found the database alive, or the zero time
+	LastHealthCheck time.Time
+	// LastUsedAt and QueryCount are the usage of the database, see
+	// DatabaseStats. They are only set in the copies returned by GetCacheInfo.
+	LastUsedAt time.Time
+	QueryCount int64
+
+	client   *Client // bound to DatabaseID, shared by all operations on the entry
+	counters *entryCounters
+}
+
+// ConnectionPool manages database connections with caching and persistence
+// Similar to sqlx.DB but for Cloudflare D1
+type ConnectionPool struct {
+	base            *Client // configuration of the bound clients, see bind
+	connections     map[string]*ConnectionInfo
+	currentDB       string
+	mu              sync.RWMutex
+	maxCacheAge     time.Duration
+	autoReconnect   bool
+	lastHealthCheck time.Time
+	cacheHook       func(CacheEvent)
+	lookups         map[string]*lookupCall
+	// autoSavePath and autoSaveError are set by SetAutoSave, saveMu
+	// serializes SaveCache
+	autoSavePath  string
+	autoSaveError func(error)
+	saveMu        sync.Mutex
+	usage         *usageCounters
+	stats         poolCounters
+	maxCached     int
+	fanOutLimit   int // see SetFanOutConcurrency
+	evictHook     func(CacheEvent)
+
+	// shutdown state, see Close
+	ctx      context.Context
+	cancel   context.CancelFunc
+	closed   bool
+	inflight sync.WaitGroup
+	owned    []Closer
+}
+
+// NewPool creates a connection pool with the settings of New, which apply
+// to every database of the pool, and reports malformed credentials like New
+func NewPool(accountID, apiToken string, opts ClientOptions) (*ConnectionPool, error) {
+	base, err := New(accountID, apiToken, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newPool(base), nil
+}
+
+// NewConnectionPool creates a new connection pool, or returns nil if an
+// argument is empty
+//
+// Deprecated: Use NewPool, which reports why the credentials are invalid.
+func NewConnectionPool(accountID, apiToken string) *ConnectionPool {
+	return NewConnectionPoolWithOptions(accountID, apiToken, ClientOptions{})
+}
+
+// NewConnectionPoolWithOptions is NewConnectionPool with the settings of
+// NewClientWithOptions, which apply to every database of the pool.
+// apiToken may be empty if opts.Auth is set.
+//
+// Deprecated: Use NewPool, which reports why the credentials are invalid.
+func NewConnectionPoolWithOptions(accountID, apiToken string, opts ClientOptions) *ConnectionPool {
+	base := NewClientWithOptions(accountID, apiToken, opts)
+	if base == nil {
+		return nil
+	}
+	return newPool(base)
+}
+
+// newPool creates a pool configured like base
+func newPool(base *Client) *ConnectionPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ConnectionPool{
+		base:          base,
+		connections:   make(map[string]*ConnectionInfo),
+		lookups:       make(map[string]*lookupCall),
+		maxCacheAge:   24 * time.Hour, // Cache for 24 hours by default
+		autoReconnect: true,
+		usage:         base.usage,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Connect connects to a database by name, with automatic caching
+// If cached, returns immediately without API call
+// Like sqlx: pool.Connect("database_name")
+//
+// Connect makes dbName the current database of the pool, which the query
+// methods of the pool run against. The current database is shared by all
+// goroutines, so code using several databases concurrently must use DB
+// handles instead, which are bound to one database. Using the current
+// database is meant for programs working with a single one.
+func (p *ConnectionPool) Connect(dbName string) error {
+	return p.connect(dbName, true)
+}
+
+// connect caches the ID of dbName, making it the current database if
+// setCurrent is set
+func (p *ConnectionPool) connect(dbName string, setCurrent bool) error {
+	_, err := p.connectPinned(dbName, setCurrent, false)
+	return err
+}
+
+// connectPinned is connect. With pin, it also counts an operation in flight
+// on the entry, under the lock that finds or adds it, so that the entry is
+// not evicted before the caller starts its operation; the caller must
+// decrement inflight of the returned counters then.
+func (p *ConnectionPool) connectPinned(dbName string, setCurrent, pin bool) (*entryCounters, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+
+	// Check if already connected and cache is valid
+	if connInfo, exists := p.connections[dbName]; exists {
+		if time.Since(connInfo.CachedAt) < p.maxCacheAge {
+			connInfo.counters.touch(p.stats.tick())
+			if pin {
+				connInfo.counters.inflight.Add(1)
+			}
+			if setCurrent {
+				p.currentDB = dbName
+			}
+			base := p.base
+			p.mu.Unlock()
+			p.stats.hits.Add(1)
+			base.observeConnect(true)
+			return connInfo.counters, nil // Return from cache
+		}
+		p.stats.evictions.Add(1)
+	}
+
+	base := p.base
+	p.mu.Unlock()
+	p.stats.misses.Add(1)
+	base.observeConnect(false)
+
+	// Cache miss or expired, fetch from API. The lock is not held during the
+	// request, so loggers and hooks called by the client may use the pool.
+	databaseID, err := p.lookup(base, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database %s: %w", dbName, err)
+	}
+
+	// Cache the connection info, unless a concurrent Connect sharing the
+	// lookup already did
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	var events []CacheEvent
+	if connInfo, exists := p.connections[dbName]; !exists || connInfo.DatabaseID != databaseID || time.Since(connInfo.CachedAt) >= p.maxCacheAge {
+		event, err := p.setEntryLocked(dbName, databaseID)
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	counters := p.connections[dbName].counters
+	if pin {
+		counters.inflight.Add(1)
+	}
+
+	if setCurrent {
+		p.currentDB = dbName
+	}
+	evicted := p.evictLocked(dbName)
+	p.mu.Unlock()
+
+	p.emit(events...)
+	p.evicted(evicted)
+	return counters, nil
+}
+
+// lookupCall is a lookup of a database ID, shared by concurrent lookups of
+// the same name
+type lookupCall struct {
+	done chan struct{}
+	id   string
+	err  error
+}
+
+// lookup returns the ID of the database dbName. Concurrent lookups of the
+// same name, e.g. many goroutines connecting at startup, share one request
+// and its result or error.
+func (p *ConnectionPool) lookup(base *Client, dbName string) (string, error) {
+	p.mu.Lock()
+	if call, ok := p.lookups[dbName]; ok {
+		p.mu.Unlock()
+		<-call.done
+		return call.id, call.err
+	}
+	call := &lookupCall{done: make(chan struct{})}
+	p.lookups[dbName] = call
+	p.mu.Unlock()
+
+	call.id, call.err = base.lookupDatabaseID(dbName)
+
+	p.mu.Lock()
+	delete(p.lookups, dbName)
+	p.mu.Unlock()
+	close(call.done)
+	return call.id, call.err
+}
+
+// ConnectWithID connects directly using database ID
+// Useful when you already know the database ID
+func (p *ConnectionPool) ConnectWithID(dbName, databaseID string) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	event, err := p.setEntryLocked(dbName, databaseID)
+	if err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.currentDB = dbName
+	evicted := p.evictLocked(dbName)
+	p.mu.Unlock()
+
+	p.emit(event)
+	p.evicted(evicted)
+	return nil
+}
+
+// WarmCache caches every database of the account for which filter returns
+// true, or all of them if filter is nil, and returns how many it cached. It
+// reads the database list once, instead of one lookup per Connect, e.g. for
+// a dashboard iterating all tenant databases. The entries expire and are
+// evicted like those of Connect.
+func (p *ConnectionPool) WarmCache(filter func(name string) bool) (int, error) {
+	p.mu.RLock()
+	base := p.base
+	p.mu.RUnlock()
+
+	dbs, err := base.listAllDBs("")
+	if err != nil {
+		return 0, fmt.Errorf("failed to warm cache: %w", err)
+	}
+	matching := dbs[:0]
+	for _, db := range dbs {
+		if filter == nil || filter(db.Name) {
+			matching = append(matching, db)
+		}
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return 0, ErrPoolClosed
+	}
+	var events []CacheEvent
+	for _, db := range matching {
+		event, err := p.setEntryLocked(db.Name, db.UUID)
+		if err != nil {
+			// Not usable, e.g. listed without an ID
+			continue
+		}
+		events = append(events, event)
+	}
+	evicted := p.evictLocked("")
+	p.mu.Unlock()
+
+	p.emit(events...)
+	p.evicted(evicted)
+	return len(events), nil
+}
+
+// setEntryLocked stores a fresh cache entry for dbName. Every cache write goes
+// through here so that entries are validated and observable in one place.
+// The caller must hold p.mu for writing and emit the returned event after unlocking.
+func (p *ConnectionPool) setEntryLocked(dbName, databaseID string) (CacheEvent, error) {
+	if dbName == "" {
+		return CacheEvent{}, fmt.Errorf("database name must not be empty")
+	}
+	if databaseID == "" {
+		return CacheEvent{}, fmt.Errorf("database ID for %s must not be empty", dbName)
+	}
+
+	event := CacheEvent{Type: CacheEntrySet, Name: dbName, DatabaseID: databaseID}
+	counters := &entryCounters{}
+	if old, exists := p.connections[dbName]; exists {
+		event.OldDatabaseID = old.DatabaseID
+		counters = old.counters
+	}
+	counters.touch(p.stats.tick())
+
+	p.connections[dbName] = &ConnectionInfo{
+		DatabaseID: databaseID,
+		Name:       dbName,
+		CachedAt:   time.Now(),
+		client:     p.bind(p.base, dbName, databaseID),
+		counters:   counters,
+	}
+	return event, nil
+}
+
+// bind returns a client for the entry dbName, configured like base, that
+// looks up the database again if D1 reports databaseID as not found
+func (p *ConnectionPool) bind(base *Client, dbName, databaseID string) *Client {
+	client := base.WithDatabase(databaseID)
+	client.reresolve = func(ctx context.Context, staleID string) (string, error) {
+		return p.reresolve(dbName, staleID)
+	}
+	return client
+}
+
+// reresolve returns the current ID of the database dbName after D1 reported
+// staleID as not found, e.g. because the database was deleted and created
+// again. With auto-reconnect enabled it looks the name up and updates the
+// cache entry; an entry that was updated meanwhile is used as is.
+func (p *ConnectionPool) reresolve(dbName, staleID string) (string, error) {
+	p.mu.RLock()
+	connInfo, exists := p.connections[dbName]
+	reconnect := p.autoReconnect && !p.closed
+	base := p.base
+	p.mu.RUnlock()
+
+	if !reconnect {
+		return staleID, nil
+	}
+	if exists && connInfo.DatabaseID != staleID {
+		return connInfo.DatabaseID, nil
+	}
+
+	databaseID, err := p.lookup(base, dbName)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	if connInfo, exists := p.connections[dbName]; p.closed || exists && connInfo.DatabaseID != staleID {
+		p.mu.Unlock()
+		return databaseID, nil
+	}
+	event, err := p.setEntryLocked(dbName, databaseID)
+	evicted := p.evictLocked(dbName)
+	p.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	event.Type = CacheEntryUpdated
+	p.emit(event)
+	p.evicted(evicted)
+	return databaseID, nil
+}
+
+// Query executes a query on the currently connected database
+// Like sqlx: result := pool.Query("SELECT * FROM users")
+func (p *ConnectionPool) Query(query string, params []string) (*utils.APIResponse, error) {
+	return p.QueryContext(context.Background(), query, params)
+}
+
+// QueryContext is Query with a context that can cancel the request
+func (p *ConnectionPool) QueryContext(ctx context.Context, query string, params []string) (*utils.APIResponse, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, query, params)
+}
+
+// Select executes a query and scans all results into a slice, similar to sqlx.Select
+// Like sqlx: pool.Select(&users, "SELECT * FROM users WHERE age > ?", 25)
+func (p *ConnectionPool) Select(dest interface{}, query string, args ...interface{}) error {
+	return p.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext is Select with a context that can cancel the request
+func (p *ConnectionPool) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.SelectContext(ctx, dest, query, args...)
+}
+
+// Get executes a query and scans the first result into a struct, similar to sqlx.Get
+// Like sqlx: pool.Get(&user, "SELECT * FROM users WHERE id = ?", 123)
+func (p *ConnectionPool) Get(dest interface{}, query string, args ...interface{}) error {
+	return p.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext is Get with a context that can cancel the request
+func (p *ConnectionPool) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.GetContext(ctx, dest, query, args...)
+}
+
+// Exec executes a query and returns the number of rows affected, similar to sqlx.Exec
+// Like sqlx: rowsAffected, err := pool.Exec("UPDATE users SET age = ? WHERE id = ?", 30, 123)
+func (p *ConnectionPool) Exec(query string, args ...interface{}) (int64, error) {
+	return p.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec with a context that can cancel the request
+func (p *ConnectionPool) ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.ExecContext(ctx, query, args...)
+}
+
+// ExecResult executes a query and returns its Result, including LastInsertId
+// Like database/sql: result, err := pool.ExecResult("INSERT INTO users (name) VALUES (?)", "Alice")
+func (p *ConnectionPool) ExecResult(query string, args ...interface{}) (*utils.Result, error) {
+	return p.ExecResultContext(context.Background(), query, args...)
+}
+
+// ExecResultContext is ExecResult with a context that can cancel the request
+func (p *ConnectionPool) ExecResultContext(ctx context.Context, query string, args ...interface{}) (*utils.Result, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.ExecResultContext(ctx, query, args...)
+}
+
+// NamedExec executes a query with :name placeholders bound from arg on the currently connected database
+// Like sqlx: pool.NamedExec("INSERT INTO users (name, age) VALUES (:name, :age)", user)
+func (p *ConnectionPool) NamedExec(query string, arg interface{}) (int64, error) {
+	return p.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext is NamedExec with a context that can cancel the request
+func (p *ConnectionPool) NamedExecContext(ctx context.Context, query string, arg interface{}) (int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.NamedExecContext(ctx, query, arg)
+}
+
+// InsertStruct inserts a struct as a row of table in the currently connected database, see Client.InsertStruct
+func (p *ConnectionPool) InsertStruct(table string, v interface{}, opts ...InsertOption) (*utils.Result, error) {
+	return p.InsertStructContext(context.Background(), table, v, opts...)
+}
+
+// InsertStructContext is InsertStruct with a context that can cancel the request
+func (p *ConnectionPool) InsertStructContext(ctx context.Context, table string, v interface{}, opts ...InsertOption) (*utils.Result, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.InsertStructContext(ctx, table, v, opts...)
+}
+
+// UpdateStruct updates a row of table in the currently connected database from a struct, see Client.UpdateStruct
+func (p *ConnectionPool) UpdateStruct(table string, v interface{}, keyColumns ...string) (int64, error) {
+	return p.UpdateStructWithOptionsContext(context.Background(), table, v, UpdateOptions{KeyColumns: keyColumns})
+}
+
+// UpdateStructContext is UpdateStruct with a context that can cancel the request
+func (p *ConnectionPool) UpdateStructContext(ctx context.Context, table string, v interface{}, keyColumns ...string) (int64, error) {
+	return p.UpdateStructWithOptionsContext(ctx, table, v, UpdateOptions{KeyColumns: keyColumns})
+}
+
+// UpdateStructWithOptions is UpdateStruct with options for partial updates
+func (p *ConnectionPool) UpdateStructWithOptions(table string, v interface{}, opts UpdateOptions) (int64, error) {
+	return p.UpdateStructWithOptionsContext(context.Background(), table, v, opts)
+}
+
+// UpdateStructWithOptionsContext is UpdateStructWithOptions with a context that can cancel the request
+func (p *ConnectionPool) UpdateStructWithOptionsContext(ctx context.Context, table string, v interface{}, opts UpdateOptions) (int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.UpdateStructWithOptionsContext(ctx, table, v, opts)
+}
+
+// BulkInsert inserts rows into table in the currently connected database, see Client.BulkInsert
+func (p *ConnectionPool) BulkInsert(table string, columns []string, rows [][]interface{}, opts ...BulkOption) (int64, error) {
+	return p.BulkInsertContext(context.Background(), table, columns, rows, opts...)
+}
+
+// BulkInsertContext is BulkInsert with a context that can cancel the requests
+func (p *ConnectionPool) BulkInsertContext(ctx context.Context, table string, columns []string, rows [][]interface{}, opts ...BulkOption) (int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.BulkInsertContext(ctx, table, columns, rows, opts...)
+}
+
+// BulkInsertStructs inserts a slice of structs into table in the currently connected database, see Client.BulkInsertStructs
+func (p *ConnectionPool) BulkInsertStructs(table string, slice interface{}, opts ...BulkOption) (int64, error) {
+	return p.BulkInsertStructsContext(context.Background(), table, slice, opts...)
+}
+
+// BulkInsertStructsContext is BulkInsertStructs with a context that can cancel the requests
+func (p *ConnectionPool) BulkInsertStructsContext(ctx context.Context, table string, slice interface{}, opts ...BulkOption) (int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.BulkInsertStructsContext(ctx, table, slice, opts...)
+}
+
+// Count runs a query returning a single integer on the currently connected database, see Client.Count
+func (p *ConnectionPool) Count(query string, args ...interface{}) (int64, error) {
+	return p.CountContext(context.Background(), query, args...)
+}
+
+// CountContext is Count with a context that can cancel the request
+func (p *ConnectionPool) CountContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.CountContext(ctx, query, args...)
+}
+
+// CountTable returns the number of rows of a table in the currently connected database, see Client.CountTable
+func (p *ConnectionPool) CountTable(table, where string, args ...interface{}) (int64, error) {
+	return p.CountTableContext(context.Background(), table, where, args...)
+}
+
+// CountTableContext is CountTable with a context that can cancel the request
+func (p *ConnectionPool) CountTableContext(ctx context.Context, table, where string, args ...interface{}) (int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.CountTableContext(ctx, table, where, args...)
+}
+
+// Exists reports whether a query returns at least one row on the currently connected database, see Client.Exists
+func (p *ConnectionPool) Exists(query string, args ...interface{}) (bool, error) {
+	return p.ExistsContext(context.Background(), query, args...)
+}
+
+// ExistsContext is Exists with a context that can cancel the request
+func (p *ConnectionPool) ExistsContext(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	return client.ExistsContext(ctx, query, args...)
+}
+
+// QueryToCSV runs a query on the currently connected database and writes
+// its rows to w as CSV, see Client.QueryToCSV
+func (p *ConnectionPool) QueryToCSV(w io.Writer, query string, args ...interface{}) error {
+	return p.QueryToCSVContext(context.Background(), w, query, args...)
+}
+
+// QueryToCSVContext is QueryToCSV with a context that can cancel the request
+func (p *ConnectionPool) QueryToCSVContext(ctx context.Context, w io.Writer, query string, args ...interface{}) error {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.QueryToCSVContext(ctx, w, query, args...)
+}
+
+// NamedSelect executes a query with :name placeholders bound from arg and scans all results into dest
+// Like sqlx: pool.NamedSelect(&users, "SELECT * FROM users WHERE age > :age", map[string]interface{}{"age": 25})
+func (p *ConnectionPool) NamedSelect(dest interface{}, query string, arg interface{}) error {
+	return p.NamedSelectContext(context.Background(), dest, query, arg)
+}
+
+// NamedSelectContext is NamedSelect with a context that can cancel the request
+func (p *ConnectionPool) NamedSelectContext(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.NamedSelectContext(ctx, dest, query, arg)
+}
+
+// QueryDB executes a query on a specific database in the pool
+// Like sqlx: result := pool.QueryDB(dbName, "SELECT * FROM users")
+func (p *ConnectionPool) QueryDB(dbName string, query string, params []string) (*utils.APIResponse, error) {
+	return p.QueryDBContext(context.Background(), dbName, query, params)
+}
+
+// QueryDBContext is QueryDB with a context that can cancel the request
+func (p *ConnectionPool) QueryDBContext(ctx context.Context, dbName string, query string, params []string) (*utils.APIResponse, error) {
+	client, ctx, done, err := p.connected(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, query, params)
+}
+
+// SelectDB is Select on a specific database in the pool. Like the other DB
+// methods it connects to dbName first if it is not cached and auto-reconnect
+// is enabled, and leaves the current database unchanged.
+func (p *ConnectionPool) SelectDB(dbName string, dest interface{}, query string, args ...interface{}) error {
+	return p.SelectDBContext(context.Background(), dbName, dest, query, args...)
+}
+
+// SelectDBContext is SelectDB with a context that can cancel the request
+func (p *ConnectionPool) SelectDBContext(ctx context.Context, dbName string, dest interface{}, query string, args ...interface{}) error {
+	client, ctx, done, err := p.connected(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.SelectContext(ctx, dest, query, args...)
+}
+
+// GetDB is Get on a specific database in the pool, see SelectDB
+func (p *ConnectionPool) GetDB(dbName string, dest interface{}, query string, args ...interface{}) error {
+	return p.GetDBContext(context.Background(), dbName, dest, query, args...)
+}
+
+// GetDBContext is GetDB with a context that can cancel the request
+func (p *ConnectionPool) GetDBContext(ctx context.Context, dbName string, dest interface{}, query string, args ...interface{}) error {
+	client, ctx, done, err := p.connected(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.GetContext(ctx, dest, query, args...)
+}
+
+// ExecDB is Exec on a specific database in the pool, see SelectDB
+func (p *ConnectionPool) ExecDB(dbName string, query string, args ...interface{}) (int64, error) {
+	return p.ExecDBContext(context.Background(), dbName, query, args...)
+}
+
+// ExecDBContext is ExecDB with a context that can cancel the request
+func (p *ConnectionPool) ExecDBContext(ctx context.Context, dbName string, query string, args ...interface{}) (int64, error) {
+	client, ctx, done, err := p.connected(ctx, dbName)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.ExecContext(ctx, query, args...)
+}
+
+// Batch executes several statements atomically on the currently connected database
+func (p *ConnectionPool) Batch(statements []utils.Statement) ([]utils.BatchResult, error) {
+	return p.BatchContext(context.Background(), statements)
+}
+
+// BatchContext is Batch with a context that can cancel the request
+func (p *ConnectionPool) BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.BatchContext(ctx, statements)
+}
+
+// BatchDB executes several statements atomically on a specific database in the pool
+func (p *ConnectionPool) BatchDB(dbName string, statements []utils.Statement) ([]utils.BatchResult, error) {
+	return p.BatchDBContext(context.Background(), dbName, statements)
+}
+
+// BatchDBContext is BatchDB with a context that can cancel the request
+func (p *ConnectionPool) BatchDBContext(ctx context.Context, dbName string, statements []utils.Statement) ([]utils.BatchResult, error) {
+	client, ctx, done, err := p.connected(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.BatchContext(ctx, statements)
+}
+
+// BatchInsertIDs runs INSERT statements on the currently connected database
+// and returns the generated IDs, see Client.BatchInsertIDs
+func (p *ConnectionPool) BatchInsertIDs(statements []utils.Statement, opts ...InsertIDsOption) ([]int64, error) {
+	return p.BatchInsertIDsContext(context.Background(), statements, opts...)
+}
+
+// BatchInsertIDsContext is BatchInsertIDs with a context that can cancel the requests
+func (p *ConnectionPool) BatchInsertIDsContext(ctx context.Context, statements []utils.Statement, opts ...InsertIDsOption) ([]int64, error) {
+	client, ctx, done, err := p.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.BatchInsertIDsContext(ctx, statements, opts...)
+}
+
+// Ping checks that the currently connected database answers a query, see Client.Ping.
+// A successful check is recorded as LastHealthCheck. If the check fails and
+// auto reconnect is enabled, the cache entry is dropped, so that the next
+// Connect resolves the database ID by name again.
+func (p *ConnectionPool) Ping() error {
+	return p.PingContext(context.Background())
+}
+
+// PingContext is Ping with a context that can cancel the request.
+// A cancelled check does not drop the cache entry.
+func (p *ConnectionPool) PingContext(ctx context.Context) error {
+	dbName, client, opCtx, done, err := p.currentNamed(ctx)
+	if err != nil {
+		return err
+	}
+	return p.ping(ctx, opCtx, client, done, dbName)
+}
+
+// ping checks that the database dbName still exists using client, dropping
+// its cache entry if it does not, and calls done
+func (p *ConnectionPool) ping(ctx, opCtx context.Context, client *Client, done func(), dbName string) error {
+	err := client.PingContext(opCtx)
+	done()
+
+	if err == nil {
+		p.mu.Lock()
+		p.healthyLocked(dbName, time.Now())
+		p.mu.Unlock()
+		return nil
+	}
+
+	p.mu.RLock()
+	reconnect := p.autoReconnect && !p.closed
+	p.mu.RUnlock()
+	if reconnect && ctx.Err() == nil {
+		p.ClearCache(dbName)
+	}
+	return err
+}
+
+// healthyLocked records that the database dbName was found alive at t.
+// The caller must hold p.mu for writing.
+func (p *ConnectionPool) healthyLocked(dbName string, t time.Time) {
+	p.lastHealthCheck = t
+	if connInfo, exists := p.connections[dbName]; exists {
+		connInfo.LastHealthCheck = t
+	}
+}
+
+// VerifyToken checks the credentials of the pool, see Client.VerifyToken
+func (p *ConnectionPool) VerifyToken() (*TokenInfo, error) {
+	return p.VerifyTokenContext(context.Background())
+}
+
+// VerifyTokenContext is VerifyToken with a context that can cancel the requests
+func (p *ConnectionPool) VerifyTokenContext(ctx context.Context) (*TokenInfo, error) {
+	p.mu.RLock()
+	base := p.base
+	p.mu.RUnlock()
+	return base.VerifyTokenContext(ctx)
+}
+
+// LastHealthCheck returns when Ping last succeeded, or the zero time if it never did
+func (p *ConnectionPool) LastHealthCheck() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.lastHealthCheck
+}
+
+// ListTables returns the names of the tables in the currently connected database
+func (p *ConnectionPool) ListTables(opts ...ListTablesOption) ([]string, error) {
+	client, _, done, err := p.current(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.ListTables(opts...)
+}
+
+// TableExists reports whether a table exists in the currently connected database
+func (p *ConnectionPool) TableExists(name string) (bool, error) {
+	client, _, done, err := p.current(context.Background())
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	return client.TableExists(name)
+}
+
+// DescribeTable returns the columns of a table in the currently connected database
+func (p *ConnectionPool) DescribeTable(name string) ([]ColumnInfo, error) {
+	client, _, done, err := p.current(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.DescribeTable(name)
+}
+
+// DeclareColumnTypes sets the declared column types of a table in the
+// currently connected database on rows, see Client.DeclareColumnTypes
+func (p *ConnectionPool) DeclareColumnTypes(rows *utils.Rows, table string) error {
+	client, _, done, err := p.current(context.Background())
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.DeclareColumnTypes(rows, table)
+}
+
+// ListIndexes returns the indexes of a table in the currently connected database
+func (p *ConnectionPool) ListIndexes(table string) ([]IndexInfo, error) {
+	client, _, done, err := p.current(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.ListIndexes(table)
+}
+
+// CreateTable creates a table in the currently connected database
+func (p *ConnectionPool) CreateTable(createQuery string) (*utils.APIResponse, error) {
+	client, ctx, done, err := p.current(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, createQuery, nil)
+}
+
+// RemoveTable removes a table from the currently connected database
+func (p *ConnectionPool) RemoveTable(tableName string) (*utils.APIResponse, error) {
+	query, err := dropTableQuery(tableName)
+	if err != nil {
+		return nil, err
+	}
+	client, ctx, done, err := p.current(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, query, nil)
+}
+
+// RemoveTableDB removes a table from a specific database in the pool
+func (p *ConnectionPool) RemoveTableDB(dbName, tableName string) (*utils.APIResponse, error) {
+	query, err := dropTableQuery(tableName)
+	if err != nil {
+		return nil, err
+	}
+	client, ctx, done, err := p.connected(context.Background(), dbName)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, query, nil)
+}
+
+// CreateTableDB creates a table in a specific database in the pool
+func (p *ConnectionPool) CreateTableDB(dbName, createQuery string) (*utils.APIResponse, error) {
+	client, ctx, done, err := p.connected(context.Background(), dbName)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, createQuery, nil)
+}
+
+// DB returns a handle bound to the database dbName, connecting to it first
+// if it is not cached. Unlike the query methods of the pool, the handle
+// always runs against its own database, so it is safe for concurrent use,
+// also next to handles for other databases. See PoolDB.
+func (p *ConnectionPool) DB(dbName string) (*PoolDB, error) {
+	p.mu.RLock()
+	closed := p.closed
+	_, cached := p.connections[dbName]
+	p.mu.RUnlock()
+
+	if closed {
+		return nil, ErrPoolClosed
+	}
+	if !cached {
+		if err := p.connect(dbName, false); err != nil {
+			return nil, err
+		}
+	}
+	return &PoolDB{pool: p, name: dbName}, nil
+}
+
+// current returns a client for the currently connected database, see database
+func (p *ConnectionPool) current(ctx context.Context) (*Client, context.Context, func(), error) {
+	_, client, ctx, done, err := p.currentNamed(ctx)
+	return client, ctx, done, err
+}
+
+// currentNamed is current, also returning the name of the database the
+// client is for, which a concurrent SwitchDB may have changed since
+func (p *ConnectionPool) currentNamed(ctx context.Context) (string, *Client, context.Context, func(), error) {
+	p.mu.RLock()
+	dbName := p.currentDB
+	p.mu.RUnlock()
+
+	if dbName == "" {
+		return "", nil, nil, nil, fmt.Errorf("no database connected, call Connect first")
+	}
+	client, ctx, done, err := p.connected(ctx, dbName)
+	if err != nil && !errors.Is(err, ErrPoolClosed) {
+		return "", nil, nil, nil, fmt.Errorf("no database connected, call Connect first: %w", err)
+	}
+	return dbName, client, ctx, done, err
+}
+
+// database returns a client for dbName and registers an in-flight operation.
+// The returned context is also cancelled when the pool is force-closed.
+// The caller must call done once the operation finished.
+func (p *ConnectionPool) database(ctx context.Context, dbName string) (*Client, context.Context, func(), error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	client, err := p.clientLocked(dbName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	counters := p.connections[dbName].counters
+	counters.use(p.stats.tick())
+
+	// The entry is not evicted while the operation is in flight
+	ctx, done := p.beginLocked(ctx)
+	return client, ctx, func() {
+		counters.inflight.Add(-1)
+		done()
+	}, nil
+}
+
+// connected is database, reconnecting to dbName first if its cache entry
+// was dropped and auto-reconnect is enabled
+func (p *ConnectionPool) connected(ctx context.Context, dbName string) (*Client, context.Context, func(), error) {
+	client, opCtx, done, err := p.database(ctx, dbName)
+	if err == nil || errors.Is(err, ErrPoolClosed) {
+		return client, opCtx, done, err
+	}
+	p.mu.RLock()
+	reconnect := p.autoReconnect
+	p.mu.RUnlock()
+	if !reconnect {
+		return nil, nil, nil, err
+	}
+
+	// Pinned, so that connects of other goroutines, or the hooks run before
+	// connect returns, do not evict the entry before database pins it for
+	// the operation
+	counters, err := p.connectPinned(dbName, false, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer counters.inflight.Add(-1)
+	return p.database(ctx, dbName)
+}
+
+// clientLocked returns the bound client of the cached database dbName.
+// It is shared, so the caller must not change it. The caller must hold p.mu.
+func (p *ConnectionPool) clientLocked(dbName string) (*Client, error) {
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	connInfo, exists := p.connections[dbName]
+	if !exists {
+		names := make([]string, 0, len(p.connections))
+		for name := range p.connections {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("database %s not connected, call Connect first", dbName)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("database %s not connected, call Connect first (connected: %s)", dbName, strings.Join(names, ", "))
+	}
+	return connInfo.client, nil
+}
+
+// beginLocked registers an in-flight operation that Close waits for.
+// The caller must hold p.mu and have checked that the pool is open.
+func (p *ConnectionPool) beginLocked(ctx context.Context) (context.Context, func()) {
+	p.inflight.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(p.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		p.inflight.Done()
+	}
+}
+
+// Close stops the pool from accepting new operations and waits for in-flight
+// requests to finish. If ctx expires first, the remaining requests are
+// cancelled and Close reports that the pool did not drain. Components owned
+// by the pool are closed afterwards, in reverse order of registration.
+// Every operation after Close returns ErrPoolClosed. Calling Close again is a no-op.
+func (p *ConnectionPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	owned := p.owned
+	p.owned = nil
+	p.mu.Unlock()
+
+	var failed []ComponentError
+
+	drained := make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		failed = append(failed, ComponentError{
+			Component: componentName(p),
+			Err:       fmt.Errorf("in-flight requests did not drain: %w", ctx.Err()),
+		})
+	}
+	p.cancel()
+
+	for i := len(owned) - 1; i >= 0; i-- {
+		if err := closeWithDeadline(ctx, owned[i]); err != nil {
+			failed = append(failed, ComponentError{Component: componentName(owned[i]), Err: err})
+		}
+	}
+
+	if len(failed) > 0 {
+		return &ShutdownError{Failed: failed}
+	}
+	return nil
+}
+
+// own registers a background component whose lifetime is bound to the pool,
+// so that Close shuts it down. Components are created by the pool itself;
+// a pool that is already closed closes the component right away.
+func (p *ConnectionPool) own(c Closer) {
+	p.mu.Lock()
+	if !p.closed {
+		p.owned = append(p.owned, c)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	_ = c.Close(context.Background())
+}
+
+// configureLocked changes the configuration of the pool and binds every
+// cached entry again. Bound clients are never changed, since operations in
+// flight may still use them. The caller must hold p.mu for writing.
+func (p *ConnectionPool) configureLocked(configure func(*Client)) {
+	base := p.base.WithDatabase("")
+	configure(base)
+	p.base = base
+	for _, connInfo := range p.connections {
+		connInfo.client = p.bind(base, connInfo.Name, connInfo.DatabaseID)
+	}
+}
+
+// SetNameMapper sets how struct fields without a db tag map to column names
+// for all queries made through the pool. nil restores utils.DefaultMapper.
+func (p *ConnectionPool) SetNameMapper(mapper utils.NameMapper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configureLocked(func(c *Client) { c.SetNameMapper(mapper) })
+}
+
+// SetStrictScan controls whether scanning results of the pool into structs
+// fails on unmatched columns and fields, see Client.SetStrictScan
+func (p *ConnectionPool) SetStrictScan(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configureLocked(func(c *Client) { c.SetStrictScan(enabled) })
+}
+
+// SetTrimSemicolons controls whether trailing semicolons are removed from
+// queries made through the pool, see Client.SetTrimSemicolons
+func (p *ConnectionPool) SetTrimSemicolons(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configureLocked(func(c *Client) { c.SetTrimSemicolons(enabled) })
+}
+
+// SetCheckParamCount controls whether the placeholders of queries made
+// through the pool are counted before they are sent, see
+// Client.SetCheckParamCount
+func (p *ConnectionPool) SetCheckParamCount(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configureLocked(func(c *Client) { c.SetCheckParamCount(enabled) })
+}
+
+// UseQueryEndpoint controls whether queries made through the pool are sent
+// to the /query endpoint, see Client.UseQueryEndpoint
+func (p *ConnectionPool) UseQueryEndpoint(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configureLocked(func(c *Client) { c.UseQueryEndpoint(enabled) })
+}
+
+// SetLogger sets a logger for every call made through the pool, including
+// the lookups of Connect, see Client.SetLogger
+func (p *ConnectionPool) SetLogger(logger Logger, opts ...LogOption) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configureLocked(func(c *Client) { c.SetLogger(logger, opts...) })
+}
+
+// GetCurrentDB returns the name of the currently connected database
+func (p *ConnectionPool) GetCurrentDB() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.currentDB
+}
+
+// GetDatabaseID returns the ID of a cached database connection
+func (p *ConnectionPool) GetDatabaseID(dbName string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if connInfo, exists := p.connections[dbName]; exists {
+		return connInfo.DatabaseID
+	}
+	return ""
+}
+
+// UpdateCacheEntry replaces the database ID of a cached entry without an API call,
+// e.g. when the configuration layer already knows a fresh UUID.
+// Queries issued after the update use the new ID.
+func (p *ConnectionPool) UpdateCacheEntry(dbName, databaseID string) error {
+	p.mu.Lock()
+	if _, exists := p.connections[dbName]; !exists {
+		p.mu.Unlock()
+		return fmt.Errorf("database %s is not cached, call Connect or ConnectWithID first", dbName)
+	}
+	event, err := p.setEntryLocked(dbName, databaseID)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	event.Type = CacheEntryUpdated
+	p.emit(event)
+	return nil
+}
+
+// RenameCacheEntry moves a cached entry to a new name, e.g. after the database
+// was renamed in the dashboard. If the entry is the current database, the
+// current database follows the rename.
+func (p *ConnectionPool) RenameCacheEntry(oldName, newName string) error {
+	if newName == "" {
+		return fmt.Errorf("database name must not be empty")
+	}
+
+	p.mu.Lock()
+	connInfo, exists := p.connections[oldName]
+	if !exists {
+		p.mu.Unlock()
+		return fmt.Errorf("database %s is not cached", oldName)
+	}
+	if _, taken := p.connections[newName]; taken {
+		p.mu.Unlock()
+		return fmt.Errorf("database %s is already cached", newName)
+	}
+
+	delete(p.connections, oldName)
+	p.connections[newName] = &ConnectionInfo{
+		DatabaseID:      connInfo.DatabaseID,
+		Name:            newName,
+		CachedAt:        connInfo.CachedAt,
+		LastHealthCheck: connInfo.LastHealthCheck,
+		client:          p.bind(p.base, newName, connInfo.DatabaseID),
+		counters:        connInfo.counters,
+	}
+	if p.currentDB == oldName {
+		p.currentDB = newName
+	}
+	p.mu.Unlock()
+
+	p.emit(CacheEvent{Type: CacheEntryRenamed, Name: newName, OldName: oldName, DatabaseID: connInfo.DatabaseID})
+	return nil
+}
+
+// ClearCache removes a database from cache, forcing re-query on next Connect
+func (p *ConnectionPool) ClearCache(dbName string) {
+	p.mu.Lock()
+	connInfo, exists := p.connections[dbName]
+	delete(p.connections, dbName)
+	p.mu.Unlock()
+
+	if exists {
+		p.stats.evictions.Add(1)
+		p.emit(CacheEvent{Type: CacheEntryRemoved, Name: dbName, DatabaseID: connInfo.DatabaseID})
+	}
+}
+
+// ClearAllCache removes all databases from cache
+func (p *ConnectionPool) ClearAllCache() {
+	p.mu.Lock()
+	events := make([]CacheEvent, 0, len(p.connections))
+	for name, connInfo := range p.connections {
+		events = append(events, CacheEvent{Type: CacheEntryRemoved, Name: name, DatabaseID: connInfo.DatabaseID})
+	}
+	p.connections = make(map[string]*ConnectionInfo)
+	p.currentDB = ""
+	p.mu.Unlock()
+
+	p.stats.evictions.Add(int64(len(events)))
+	p.emit(events...)
+}
+
+// SetCacheAge sets the maximum age for cached connections
+// Default is 24 hours. Set to 0 for no caching.
+func (p *ConnectionPool) SetCacheAge(duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxCacheAge = duration
+}
+
+// SetAutoReconnect enables/disables automatic reconnection on failure.
+// When enabled, which is the default, a query whose database D1 reports as
+// not found looks the name up again and is retried once against the new ID,
+// a failed Ping drops the cache entry, and entries that are not cached are
+// connected on first use.
+func (p *ConnectionPool) SetAutoReconnect(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.autoReconnect = enabled
+}
+
+// ListCachedDatabases returns a list of all cached database names
+func (p *ConnectionPool) ListCachedDatabases() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var dbNames []string
+	for name := range p.connections {
+		dbNames = append(dbNames, name)
+	}
+	return dbNames
+}
+
+// IsCached checks if a database connection is cached
+func (p *ConnectionPool) IsCached(dbName string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if connInfo, exists := p.connections[dbName]; exists {
+		return time.Since(connInfo.CachedAt) < p.maxCacheAge
+	}
+	return false
+}
+
+// GetCacheInfo returns information about a cached connection
+func (p *ConnectionPool) GetCacheInfo(dbName string) *ConnectionInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if connInfo, exists := p.connections[dbName]; exists {
+		// Return a copy to prevent external modification
+		usage := connInfo.counters.snapshot()
+		return &ConnectionInfo{
+			DatabaseID:      connInfo.DatabaseID,
+			Name:            connInfo.Name,
+			CachedAt:        connInfo.CachedAt,
+			LastHealthCheck: connInfo.LastHealthCheck,
+			LastUsedAt:      usage.LastUsedAt,
+			QueryCount:      usage.QueryCount,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package cloudflared1
+
+// CacheEventType identifies the kind of change made to the pool cache
+type CacheEventType int
+
+const (
+	// CacheEntrySet is emitted when an entry is added or refreshed by Connect or ConnectWithID
+	CacheEntrySet CacheEventType = iota
+	// CacheEntryUpdated is emitted when UpdateCacheEntry changes the database ID of an entry
+	CacheEntryUpdated
+	// CacheEntryRenamed is emitted when RenameCacheEntry moves an entry to a new name
+	CacheEntryRenamed
+	// CacheEntryRemoved is emitted when an entry is dropped from the cache
+	CacheEntryRemoved
+)
+
+func (t CacheEventType) String() string {
+	switch t {
+	case CacheEntrySet:
+		return "set"
+	case CacheEntryUpdated:
+		return "updated"
+	case CacheEntryRenamed:
+		return "renamed"
+	case CacheEntryRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// CacheEvent describes a single change to the pool cache
+type CacheEvent struct {
+	Type       CacheEventType
+	Name       string
+	DatabaseID string
+
+	// OldName is set for renames, OldDatabaseID for updates and refreshes of an existing entry
+	OldName       string
+	OldDatabaseID string
+}
+
+// OnCacheEvent registers a callback invoked after every cache mutation.
+// The callback runs after the pool lock is released, so it may call back into the pool.
+// Passing nil removes the callback.
+func (p *ConnectionPool) OnCacheEvent(fn func(CacheEvent)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cacheHook = fn
+}
+
+// emit delivers events to the registered cache hook. Must be called without holding p.mu.
+func (p *ConnectionPool) emit(events ...CacheEvent) {
+	if len(events) == 0 {
+		return
+	}
+	p.autoSave()
+
+	p.mu.RLock()
+	hook := p.cacheHook
+	p.mu.RUnlock()
+
+	if hook == nil {
+		return
+	}
+	for _, e := range events {
+		hook(e)
+	}
+}
@@ -0,0 +1,71 @@
+package cloudflared1
+
+// SetMaxCachedDatabases limits the number of cached databases. When an entry
+// is added beyond the limit, the least recently used entry is evicted, with
+// Connect and every operation on a database counting as a use. The current
+// database and databases with operations in flight are never evicted, so
+// the cache may exceed the limit while all other entries are busy.
+// Evictions are counted by Stats and reported to OnEvict and, as removals,
+// to OnCacheEvent. Set to 0 for no limit, the default.
+func (p *ConnectionPool) SetMaxCachedDatabases(n int) {
+	p.mu.Lock()
+	p.maxCached = n
+	evicted := p.evictLocked("")
+	p.mu.Unlock()
+
+	p.evicted(evicted)
+}
+
+// OnEvict registers a callback invoked for every entry evicted by the limit
+// of SetMaxCachedDatabases. Like OnCacheEvent, it runs after the pool lock
+// is released. Passing nil removes the callback.
+func (p *ConnectionPool) OnEvict(fn func(CacheEvent)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictHook = fn
+}
+
+// evictLocked removes least recently used entries until the cache fits the
+// limit, keeping the entry keep, e.g. the one just added. The caller must
+// hold p.mu for writing and pass the returned events to evicted after
+// unlocking.
+func (p *ConnectionPool) evictLocked(keep string) []CacheEvent {
+	var events []CacheEvent
+	for p.maxCached > 0 && len(p.connections) > p.maxCached {
+		var victim *ConnectionInfo
+		for name, connInfo := range p.connections {
+			if name == keep || name == p.currentDB || connInfo.counters.inflight.Load() > 0 {
+				continue
+			}
+			if victim == nil || connInfo.counters.recency.Load() < victim.counters.recency.Load() {
+				victim = connInfo
+			}
+		}
+		if victim == nil {
+			// Everything else is in use
+			break
+		}
+		delete(p.connections, victim.Name)
+		events = append(events, CacheEvent{Type: CacheEntryRemoved, Name: victim.Name, DatabaseID: victim.DatabaseID})
+	}
+	return events
+}
+
+// evicted reports entries removed by evictLocked. Must be called without
+// holding p.mu.
+func (p *ConnectionPool) evicted(events []CacheEvent) {
+	if len(events) == 0 {
+		return
+	}
+	p.stats.evictions.Add(int64(len(events)))
+
+	p.mu.RLock()
+	hook := p.evictHook
+	p.mu.RUnlock()
+	if hook != nil {
+		for _, e := range events {
+			hook(e)
+		}
+	}
+	p.emit(events...)
+}
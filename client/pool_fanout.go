@@ -0,0 +1,185 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// defaultFanOutConcurrency is the number of databases SelectMany queries at
+// a time unless SetFanOutConcurrency is called
+const defaultFanOutConcurrency = 8
+
+// DatabaseError reports a database that failed in a query run on several
+// databases
+type DatabaseError struct {
+	Database string
+	Err      error
+}
+
+func (e DatabaseError) Error() string {
+	return fmt.Sprintf("database %s: %v", e.Database, e.Err)
+}
+
+// FanOutError is returned by SelectMany and SelectManyFunc if the query
+// failed on some databases, in the order the databases were passed. The
+// results of the other databases were delivered.
+type FanOutError struct {
+	Failed []DatabaseError
+}
+
+func (e *FanOutError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("query failed on %d databases: %s", len(e.Failed), strings.Join(msgs, "; "))
+}
+
+func (e *FanOutError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// SetFanOutConcurrency sets how many databases SelectMany and SelectManyFunc
+// query at a time. 0 restores the default of 8.
+func (p *ConnectionPool) SetFanOutConcurrency(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fanOutLimit = n
+}
+
+// SelectMany runs query on every database of dbNames concurrently, like
+// SelectDB, and stores the rows of all of them in dest, a pointer to a
+// slice, grouped by database in the order of dbNames. Databases that are
+// not cached are connected to first. If the query fails on some databases,
+// dest holds the rows of the others and the error is a *FanOutError naming
+// the failed databases.
+func (p *ConnectionPool) SelectMany(dbNames []string, dest interface{}, query string, args ...interface{}) error {
+	return p.SelectManyContext(context.Background(), dbNames, dest, query, args...)
+}
+
+// SelectManyContext is SelectMany with a context that can cancel the requests
+func (p *ConnectionPool) SelectManyContext(ctx context.Context, dbNames []string, dest interface{}, query string, args ...interface{}) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("SelectMany: dest must be a pointer to a slice, got %T", dest)
+	}
+	sliceType := destValue.Elem().Type()
+
+	parts := make([]reflect.Value, len(dbNames))
+	err := p.fanOutQuery(ctx, dbNames, query, args, func(i int, rows *utils.Rows) error {
+		part := reflect.New(sliceType)
+		if err := rows.StructScanAll(part.Interface()); err != nil {
+			return err
+		}
+		parts[i] = part.Elem()
+		return nil
+	})
+
+	all := reflect.MakeSlice(sliceType, 0, 0)
+	for _, part := range parts {
+		if part.IsValid() {
+			all = reflect.AppendSlice(all, part)
+		}
+	}
+	destValue.Elem().Set(all)
+	return err
+}
+
+// SelectManyFunc runs query on every database of dbNames concurrently like
+// SelectMany, but passes the rows of each database to fn as they arrive
+// instead of collecting them, e.g. to stream them to a client. fn is not
+// called concurrently. An error returned by fn counts as a failure of that
+// database.
+func (p *ConnectionPool) SelectManyFunc(dbNames []string, fn func(dbName string, rows *utils.Rows) error, query string, args ...interface{}) error {
+	return p.SelectManyFuncContext(context.Background(), dbNames, fn, query, args...)
+}
+
+// SelectManyFuncContext is SelectManyFunc with a context that can cancel the requests
+func (p *ConnectionPool) SelectManyFuncContext(ctx context.Context, dbNames []string, fn func(dbName string, rows *utils.Rows) error, query string, args ...interface{}) error {
+	var mu sync.Mutex
+	return p.fanOutQuery(ctx, dbNames, query, args, func(i int, rows *utils.Rows) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(dbNames[i], rows)
+	})
+}
+
+// fanOutQuery runs query on the databases of dbNames, see fanOut, and passes
+// the rows of the i-th database to handle, which may be called concurrently
+func (p *ConnectionPool) fanOutQuery(ctx context.Context, dbNames []string, query string, args []interface{}, handle func(i int, rows *utils.Rows) error) error {
+	return p.fanOut(ctx, dbNames, func(ctx context.Context, i int, client *Client) error {
+		rows, err := client.queryRows(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		return handle(i, rows)
+	})
+}
+
+// execMany runs an update on the databases of dbNames, see fanOut, and
+// returns the number of rows affected in all of them
+func (p *ConnectionPool) execMany(ctx context.Context, dbNames []string, query string, args []interface{}) (int64, error) {
+	var total atomic.Int64
+	err := p.fanOut(ctx, dbNames, func(ctx context.Context, i int, client *Client) error {
+		n, err := client.ExecContext(ctx, query, args...)
+		total.Add(n)
+		return err
+	})
+	return total.Load(), err
+}
+
+// fanOut calls fn with a client for each database of dbNames, connecting to
+// databases that are not cached, SetFanOutConcurrency at a time. It returns
+// a *FanOutError for the databases that failed.
+func (p *ConnectionPool) fanOut(ctx context.Context, dbNames []string, fn func(ctx context.Context, i int, client *Client) error) error {
+	p.mu.RLock()
+	concurrency := p.fanOutLimit
+	p.mu.RUnlock()
+	if concurrency <= 0 {
+		concurrency = defaultFanOutConcurrency
+	}
+
+	errs := make([]error, len(dbNames))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, dbName := range dbNames {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			client, ctx, done, err := p.connected(ctx, dbName)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer done()
+			errs[i] = fn(ctx, i, client)
+		}()
+	}
+	wg.Wait()
+
+	var failed []DatabaseError
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, DatabaseError{Database: dbNames[i], Err: err})
+		}
+	}
+	if len(failed) > 0 {
+		return &FanOutError{Failed: failed}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package cloudflared1
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// cacheFileVersion is the format version of files written by SaveCache
+const cacheFileVersion = 1
+
+// cacheFile is the JSON form of the pool cache. It holds no credentials.
+type cacheFile struct {
+	Version   int              `json:"version"`
+	AccountID string           `json:"account_id"`
+	Databases []cacheFileEntry `json:"databases"`
+}
+
+type cacheFileEntry struct {
+	Name       string    `json:"name"`
+	DatabaseID string    `json:"database_id"`
+	CachedAt   time.Time `json:"cached_at"`
+}
+
+// SaveCache writes the cached database names and IDs, with the time they
+// were cached, to a JSON file readable only by the owner, so a restarted
+// process can skip the lookups with LoadCache. The API token is not saved.
+// The file is replaced atomically.
+func (p *ConnectionPool) SaveCache(path string) error {
+	p.saveMu.Lock()
+	defer p.saveMu.Unlock()
+
+	p.mu.RLock()
+	file := cacheFile{Version: cacheFileVersion, AccountID: p.base.AccountID}
+	for _, connInfo := range p.connections {
+		file.Databases = append(file.Databases, cacheFileEntry{
+			Name:       connInfo.Name,
+			DatabaseID: connInfo.DatabaseID,
+			CachedAt:   connInfo.CachedAt,
+		})
+	}
+	p.mu.RUnlock()
+	sort.Slice(file.Databases, func(i, j int) bool { return file.Databases[i].Name < file.Databases[j].Name })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp creates the file with mode 0600
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save pool cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save pool cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save pool cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save pool cache: %w", err)
+	}
+	return nil
+}
+
+// LoadCache adds the entries of a file written by SaveCache to the cache,
+// keeping the time they were originally cached: entries older than the
+// cache age are looked up again by the next Connect. Databases that are
+// already cached are left as they are. A missing file is reported with an
+// error satisfying errors.Is(err, fs.ErrNotExist), which a first start may
+// ignore. Files of another account are rejected.
+func (p *ConnectionPool) LoadCache(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load pool cache: %w", err)
+	}
+
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to load pool cache %s: %w", path, err)
+	}
+	if file.Version != cacheFileVersion {
+		return fmt.Errorf("failed to load pool cache %s: unsupported version %d", path, file.Version)
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	if file.AccountID != p.base.AccountID {
+		p.mu.Unlock()
+		return fmt.Errorf("failed to load pool cache %s: it belongs to account %s", path, file.AccountID)
+	}
+	var events []CacheEvent
+	for _, entry := range file.Databases {
+		if _, exists := p.connections[entry.Name]; exists {
+			continue
+		}
+		event, err := p.setEntryLocked(entry.Name, entry.DatabaseID)
+		if err != nil {
+			p.mu.Unlock()
+			p.emit(events...)
+			return fmt.Errorf("failed to load pool cache %s: %w", path, err)
+		}
+		p.connections[entry.Name].CachedAt = entry.CachedAt
+		events = append(events, event)
+	}
+	evicted := p.evictLocked("")
+	p.mu.Unlock()
+
+	p.emit(events...)
+	p.evicted(evicted)
+	return nil
+}
+
+// SetAutoSave saves the cache to path with SaveCache after every change,
+// e.g. every Connect that looked a database up. Errors are passed to
+// onError, which may be nil. An empty path disables saving.
+func (p *ConnectionPool) SetAutoSave(path string, onError func(error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.autoSavePath, p.autoSaveError = path, onError
+}
+
+// autoSave saves the cache if enabled with SetAutoSave
+func (p *ConnectionPool) autoSave() {
+	p.mu.RLock()
+	path, onError := p.autoSavePath, p.autoSaveError
+	p.mu.RUnlock()
+
+	if path == "" {
+		return
+	}
+	if err := p.SaveCache(path); err != nil && onError != nil {
+		onError(err)
+	}
+}
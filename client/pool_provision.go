@@ -0,0 +1,104 @@
+package cloudflared1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ConnectOrCreateOptions holds the settings of ConnectOrCreate
+type ConnectOrCreateOptions struct {
+	// CreateDBOptions are used if the database is created
+	CreateDBOptions
+
+	// Setup runs once on a database that was created, e.g. to apply
+	// migrations with migrations.Setup
+	Setup func(db Queryer) error
+
+	// ReadyTimeout bounds the wait until a created database answers queries.
+	// 0 means 30 seconds.
+	ReadyTimeout time.Duration
+}
+
+// defaultReadyTimeout is the ReadyTimeout of ConnectOrCreate if none is set
+const defaultReadyTimeout = 30 * time.Second
+
+// ConnectOrCreate connects to dbName like Connect, creating the database if
+// it does not exist. A created database is pinged until it answers queries,
+// since new D1 databases may take a moment, then cached and set up with
+// opts.Setup. created reports whether the database was created, e.g. to
+// seed it; it is also set if Setup failed.
+func (p *ConnectionPool) ConnectOrCreate(dbName string, opts ConnectOrCreateOptions) (created bool, err error) {
+	return p.ConnectOrCreateContext(context.Background(), dbName, opts)
+}
+
+// ConnectOrCreateContext is ConnectOrCreate with a context that can cancel
+// the wait for a created database
+func (p *ConnectionPool) ConnectOrCreateContext(ctx context.Context, dbName string, opts ConnectOrCreateOptions) (created bool, err error) {
+	err = p.connect(dbName, true)
+	if err == nil || !errors.Is(err, ErrDatabaseNotFound) {
+		return false, err
+	}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return false, ErrPoolClosed
+	}
+	base := p.base
+	ctx, done := p.beginLocked(ctx)
+	p.mu.RUnlock()
+	defer done()
+
+	info, err := base.CreateDBWithOptions(dbName, opts.CreateDBOptions)
+	if err != nil {
+		// Another process may have created it meanwhile
+		if connErr := p.connect(dbName, true); connErr == nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create database %s: %w", dbName, err)
+	}
+
+	timeout := opts.ReadyTimeout
+	if timeout == 0 {
+		timeout = defaultReadyTimeout
+	}
+	if err := waitReady(ctx, base.WithDatabase(info.UUID), timeout); err != nil {
+		return true, fmt.Errorf("database %s was created but is not ready: %w", dbName, err)
+	}
+
+	if err := p.ConnectWithID(dbName, info.UUID); err != nil {
+		return true, err
+	}
+	if opts.Setup != nil {
+		if err := opts.Setup(&PoolDB{pool: p, name: dbName}); err != nil {
+			return true, fmt.Errorf("failed to set up database %s: %w", dbName, err)
+		}
+	}
+	return true, nil
+}
+
+// waitReady pings client until it succeeds, backing off from 100ms to 2s
+// between attempts, and returns the last error once timeout passed
+func waitReady(ctx context.Context, client *Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := 100 * time.Millisecond
+	for {
+		err := client.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(2*delay, 2*time.Second)
+	}
+}
@@ -0,0 +1,98 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CacheRefresher re-validates the cache entries of a pool in the
+// background, see ConnectionPool.StartRefresh
+type CacheRefresher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartRefresh re-validates the cache entries older than the cache age (see
+// SetCacheAge) with GetDatabase every interval, until ctx is done or the
+// returned refresher is closed. Entries of databases that still exist are
+// renewed and their LastHealthCheck set; entries of deleted databases are
+// dropped, so their next use looks the name up again. The refresher is
+// owned by the pool: Close stops it. interval must be positive.
+func (p *ConnectionPool) StartRefresh(ctx context.Context, interval time.Duration) *CacheRefresher {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &CacheRefresher{cancel: cancel, done: make(chan struct{})}
+	// Created here so that an invalid interval panics in the caller
+	ticker := time.NewTicker(interval)
+	go r.run(ctx, ticker, p)
+	p.own(r)
+	return r
+}
+
+func (r *CacheRefresher) run(ctx context.Context, ticker *time.Ticker, p *ConnectionPool) {
+	defer close(r.done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.refresh(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Close stops the refresher, cancelling a refresh in progress, and waits
+// for it to finish or for ctx to expire. Calling Close again is a no-op.
+func (r *CacheRefresher) Close(ctx context.Context) error {
+	r.cancel()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cache refresher did not stop: %w", ctx.Err())
+	}
+}
+
+// refresh re-validates the cache entries older than the cache age
+func (p *ConnectionPool) refresh(ctx context.Context) {
+	p.mu.RLock()
+	base := p.base
+	var stale []ConnectionInfo
+	for _, connInfo := range p.connections {
+		if time.Since(connInfo.CachedAt) >= p.maxCacheAge {
+			stale = append(stale, *connInfo)
+		}
+	}
+	p.mu.RUnlock()
+
+	for _, entry := range stale {
+		_, err := base.getDatabase(ctx, entry.DatabaseID)
+		if err != nil && !databaseNotFound(nil, err) {
+			// e.g. a network error, try again on the next tick
+			continue
+		}
+
+		p.mu.Lock()
+		connInfo, exists := p.connections[entry.Name]
+		if !exists || connInfo.DatabaseID != entry.DatabaseID {
+			// Changed meanwhile
+			p.mu.Unlock()
+			continue
+		}
+		if err == nil {
+			now := time.Now()
+			connInfo.CachedAt = now
+			p.healthyLocked(entry.Name, now)
+			p.mu.Unlock()
+			continue
+		}
+		delete(p.connections, entry.Name)
+		p.mu.Unlock()
+		p.stats.evictions.Add(1)
+
+		p.emit(CacheEvent{Type: CacheEntryRemoved, Name: entry.Name, DatabaseID: entry.DatabaseID})
+	}
+}
@@ -0,0 +1,95 @@
+package cloudflared1
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats is a snapshot of the cache and usage counters of a ConnectionPool
+type PoolStats struct {
+	// Hits and Misses count the Connect calls, including the implicit ones of
+	// auto-reconnect, that found the database in the cache or looked it up
+	Hits   int64
+	Misses int64
+	// Evictions counts the entries that were removed from the cache or
+	// replaced because they were older than the cache age
+	Evictions int64
+	// ActiveDatabases is the number of cached databases
+	ActiveDatabases int
+	// Databases holds the usage of each cached database by name
+	Databases map[string]DatabaseStats
+}
+
+// DatabaseStats is the usage of one cached database
+type DatabaseStats struct {
+	// QueryCount counts the operations run on the database through the pool
+	// or its DB handles; a batch counts once
+	QueryCount int64
+	// LastUsedAt is when the last operation started, or the zero time
+	LastUsedAt time.Time
+}
+
+// poolCounters are the counters of Stats. They are updated atomically, so
+// reading them does not wait for queries.
+type poolCounters struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	clock     atomic.Int64 // orders the uses of entries, see entryCounters.recency
+}
+
+// tick returns the next value of the use clock
+func (c *poolCounters) tick() int64 {
+	return c.clock.Add(1)
+}
+
+// entryCounters are the usage counters of a cache entry. They are kept when
+// the entry is refreshed, updated or renamed, but not when it is removed.
+type entryCounters struct {
+	queries  atomic.Int64
+	lastUsed atomic.Int64 // Unix nanoseconds, 0 if never used
+	recency  atomic.Int64 // use clock tick of the last use, see SetMaxCachedDatabases
+	inflight atomic.Int64 // operations in flight
+}
+
+// touch records a use of the entry at tick without an operation, e.g. Connect
+func (c *entryCounters) touch(tick int64) {
+	c.recency.Store(tick)
+}
+
+// use records the start of an operation on the entry at tick. The caller
+// must decrement inflight when it finished.
+func (c *entryCounters) use(tick int64) {
+	c.inflight.Add(1)
+	c.queries.Add(1)
+	c.lastUsed.Store(time.Now().UnixNano())
+	c.touch(tick)
+}
+
+// snapshot returns the current values of the counters
+func (c *entryCounters) snapshot() DatabaseStats {
+	stats := DatabaseStats{QueryCount: c.queries.Load()}
+	if lastUsed := c.lastUsed.Load(); lastUsed != 0 {
+		stats.LastUsedAt = time.Unix(0, lastUsed)
+	}
+	return stats
+}
+
+// Stats returns the cache hit, miss and eviction counts of the pool and the
+// usage of each cached database, e.g. for a metrics scraper
+func (p *ConnectionPool) Stats() PoolStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := PoolStats{
+		Hits:            p.stats.hits.Load(),
+		Misses:          p.stats.misses.Load(),
+		Evictions:       p.stats.evictions.Load(),
+		ActiveDatabases: len(p.connections),
+		Databases:       make(map[string]DatabaseStats, len(p.connections)),
+	}
+	for name, connInfo := range p.connections {
+		stats.Databases[name] = connInfo.counters.snapshot()
+	}
+	return stats
+}
@@ -0,0 +1,438 @@
+package cloudflared1
+
+import (
+	"context"
+	"io"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// PoolDB is a handle on one database of a ConnectionPool, returned by
+// ConnectionPool.DB. The query methods of the pool run against the database
+// of the last Connect, which is shared by all goroutines; a PoolDB always
+// runs against its own database, so it is safe for concurrent use, also next
+// to handles for other databases.
+//
+// A PoolDB uses the cached connection of the pool and follows its later
+// configuration. If the cache entry was dropped, e.g. by a failed Ping, it
+// reconnects unless auto-reconnect is disabled. Close waits for its requests.
+//
+// A PoolDB offers the query methods of Client. Account-level calls,
+// configuration and the other Client methods it does not have are reached
+// through Client, which returns a client bound to the database.
+type PoolDB struct {
+	pool *ConnectionPool
+	name string
+}
+
+// Name returns the name of the database of the handle
+func (d *PoolDB) Name() string {
+	return d.name
+}
+
+// DatabaseID returns the ID of the database of the handle, or "" if it is
+// not cached
+func (d *PoolDB) DatabaseID() string {
+	return d.pool.GetDatabaseID(d.name)
+}
+
+// Client returns a client bound to the database of the handle, configured
+// like the pool, for the Client methods a PoolDB does not offer. The client
+// is a copy, so changing it does not affect the pool. Requests made through
+// it are not tracked by Close.
+func (d *PoolDB) Client() (*Client, error) {
+	client, _, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return nil, err
+	}
+	done()
+	return client.WithDatabase(client.DatabaseID), nil
+}
+
+// Query executes a query on the database of the handle
+// Like sqlx: result := db.Query("SELECT * FROM users")
+func (d *PoolDB) Query(query string, params []string) (*utils.APIResponse, error) {
+	return d.QueryContext(context.Background(), query, params)
+}
+
+// QueryContext is Query with a context that can cancel the request
+func (d *PoolDB) QueryContext(ctx context.Context, query string, params []string) (*utils.APIResponse, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, query, params)
+}
+
+// Select executes a query and scans all results into a slice, similar to sqlx.Select
+// Like sqlx: db.Select(&users, "SELECT * FROM users WHERE age > ?", 25)
+func (d *PoolDB) Select(dest interface{}, query string, args ...interface{}) error {
+	return d.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext is Select with a context that can cancel the request
+func (d *PoolDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.SelectContext(ctx, dest, query, args...)
+}
+
+// Get executes a query and scans the first result into a struct, similar to sqlx.Get
+// Like sqlx: db.Get(&user, "SELECT * FROM users WHERE id = ?", 123)
+func (d *PoolDB) Get(dest interface{}, query string, args ...interface{}) error {
+	return d.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext is Get with a context that can cancel the request
+func (d *PoolDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.GetContext(ctx, dest, query, args...)
+}
+
+// Exec executes a query and returns the number of rows affected, similar to sqlx.Exec
+// Like sqlx: rowsAffected, err := db.Exec("UPDATE users SET age = ? WHERE id = ?", 30, 123)
+func (d *PoolDB) Exec(query string, args ...interface{}) (int64, error) {
+	return d.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec with a context that can cancel the request
+func (d *PoolDB) ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.ExecContext(ctx, query, args...)
+}
+
+// ExecResult executes a query and returns its Result, including LastInsertId
+// Like database/sql: result, err := db.ExecResult("INSERT INTO users (name) VALUES (?)", "Alice")
+func (d *PoolDB) ExecResult(query string, args ...interface{}) (*utils.Result, error) {
+	return d.ExecResultContext(context.Background(), query, args...)
+}
+
+// ExecResultContext is ExecResult with a context that can cancel the request
+func (d *PoolDB) ExecResultContext(ctx context.Context, query string, args ...interface{}) (*utils.Result, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.ExecResultContext(ctx, query, args...)
+}
+
+// NamedExec executes a query with :name placeholders bound from arg on the database of the handle
+// Like sqlx: db.NamedExec("INSERT INTO users (name, age) VALUES (:name, :age)", user)
+func (d *PoolDB) NamedExec(query string, arg interface{}) (int64, error) {
+	return d.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext is NamedExec with a context that can cancel the request
+func (d *PoolDB) NamedExecContext(ctx context.Context, query string, arg interface{}) (int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.NamedExecContext(ctx, query, arg)
+}
+
+// InsertStruct inserts a struct as a row of table in the database of the handle, see Client.InsertStruct
+func (d *PoolDB) InsertStruct(table string, v interface{}, opts ...InsertOption) (*utils.Result, error) {
+	return d.InsertStructContext(context.Background(), table, v, opts...)
+}
+
+// InsertStructContext is InsertStruct with a context that can cancel the request
+func (d *PoolDB) InsertStructContext(ctx context.Context, table string, v interface{}, opts ...InsertOption) (*utils.Result, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.InsertStructContext(ctx, table, v, opts...)
+}
+
+// UpdateStruct updates a row of table in the database of the handle from a struct, see Client.UpdateStruct
+func (d *PoolDB) UpdateStruct(table string, v interface{}, keyColumns ...string) (int64, error) {
+	return d.UpdateStructWithOptionsContext(context.Background(), table, v, UpdateOptions{KeyColumns: keyColumns})
+}
+
+// UpdateStructContext is UpdateStruct with a context that can cancel the request
+func (d *PoolDB) UpdateStructContext(ctx context.Context, table string, v interface{}, keyColumns ...string) (int64, error) {
+	return d.UpdateStructWithOptionsContext(ctx, table, v, UpdateOptions{KeyColumns: keyColumns})
+}
+
+// UpdateStructWithOptions is UpdateStruct with options for partial updates
+func (d *PoolDB) UpdateStructWithOptions(table string, v interface{}, opts UpdateOptions) (int64, error) {
+	return d.UpdateStructWithOptionsContext(context.Background(), table, v, opts)
+}
+
+// UpdateStructWithOptionsContext is UpdateStructWithOptions with a context that can cancel the request
+func (d *PoolDB) UpdateStructWithOptionsContext(ctx context.Context, table string, v interface{}, opts UpdateOptions) (int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.UpdateStructWithOptionsContext(ctx, table, v, opts)
+}
+
+// BulkInsert inserts rows into table in the database of the handle, see Client.BulkInsert
+func (d *PoolDB) BulkInsert(table string, columns []string, rows [][]interface{}, opts ...BulkOption) (int64, error) {
+	return d.BulkInsertContext(context.Background(), table, columns, rows, opts...)
+}
+
+// BulkInsertContext is BulkInsert with a context that can cancel the requests
+func (d *PoolDB) BulkInsertContext(ctx context.Context, table string, columns []string, rows [][]interface{}, opts ...BulkOption) (int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.BulkInsertContext(ctx, table, columns, rows, opts...)
+}
+
+// BulkInsertStructs inserts a slice of structs into table in the database of the handle, see Client.BulkInsertStructs
+func (d *PoolDB) BulkInsertStructs(table string, slice interface{}, opts ...BulkOption) (int64, error) {
+	return d.BulkInsertStructsContext(context.Background(), table, slice, opts...)
+}
+
+// BulkInsertStructsContext is BulkInsertStructs with a context that can cancel the requests
+func (d *PoolDB) BulkInsertStructsContext(ctx context.Context, table string, slice interface{}, opts ...BulkOption) (int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.BulkInsertStructsContext(ctx, table, slice, opts...)
+}
+
+// Count runs a query returning a single integer on the database of the handle, see Client.Count
+func (d *PoolDB) Count(query string, args ...interface{}) (int64, error) {
+	return d.CountContext(context.Background(), query, args...)
+}
+
+// CountContext is Count with a context that can cancel the request
+func (d *PoolDB) CountContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.CountContext(ctx, query, args...)
+}
+
+// CountTable returns the number of rows of a table in the database of the handle, see Client.CountTable
+func (d *PoolDB) CountTable(table, where string, args ...interface{}) (int64, error) {
+	return d.CountTableContext(context.Background(), table, where, args...)
+}
+
+// CountTableContext is CountTable with a context that can cancel the request
+func (d *PoolDB) CountTableContext(ctx context.Context, table, where string, args ...interface{}) (int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	return client.CountTableContext(ctx, table, where, args...)
+}
+
+// Exists reports whether a query returns at least one row on the database of the handle, see Client.Exists
+func (d *PoolDB) Exists(query string, args ...interface{}) (bool, error) {
+	return d.ExistsContext(context.Background(), query, args...)
+}
+
+// ExistsContext is Exists with a context that can cancel the request
+func (d *PoolDB) ExistsContext(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	return client.ExistsContext(ctx, query, args...)
+}
+
+// QueryToCSV runs a query on the database of the handle and writes
+// its rows to w as CSV, see Client.QueryToCSV
+func (d *PoolDB) QueryToCSV(w io.Writer, query string, args ...interface{}) error {
+	return d.QueryToCSVContext(context.Background(), w, query, args...)
+}
+
+// QueryToCSVContext is QueryToCSV with a context that can cancel the request
+func (d *PoolDB) QueryToCSVContext(ctx context.Context, w io.Writer, query string, args ...interface{}) error {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.QueryToCSVContext(ctx, w, query, args...)
+}
+
+// NamedSelect executes a query with :name placeholders bound from arg and scans all results into dest
+// Like sqlx: db.NamedSelect(&users, "SELECT * FROM users WHERE age > :age", map[string]interface{}{"age": 25})
+func (d *PoolDB) NamedSelect(dest interface{}, query string, arg interface{}) error {
+	return d.NamedSelectContext(context.Background(), dest, query, arg)
+}
+
+// NamedSelectContext is NamedSelect with a context that can cancel the request
+func (d *PoolDB) NamedSelectContext(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.NamedSelectContext(ctx, dest, query, arg)
+}
+
+// Batch executes several statements atomically on the database of the handle
+func (d *PoolDB) Batch(statements []utils.Statement) ([]utils.BatchResult, error) {
+	return d.BatchContext(context.Background(), statements)
+}
+
+// BatchContext is Batch with a context that can cancel the request
+func (d *PoolDB) BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.BatchContext(ctx, statements)
+}
+
+// BatchInsertIDs runs INSERT statements on the database of the handle
+// and returns the generated IDs, see Client.BatchInsertIDs
+func (d *PoolDB) BatchInsertIDs(statements []utils.Statement, opts ...InsertIDsOption) ([]int64, error) {
+	return d.BatchInsertIDsContext(context.Background(), statements, opts...)
+}
+
+// BatchInsertIDsContext is BatchInsertIDs with a context that can cancel the requests
+func (d *PoolDB) BatchInsertIDsContext(ctx context.Context, statements []utils.Statement, opts ...InsertIDsOption) ([]int64, error) {
+	client, ctx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.BatchInsertIDsContext(ctx, statements, opts...)
+}
+
+// ListTables returns the names of the tables in the database of the handle
+func (d *PoolDB) ListTables(opts ...ListTablesOption) ([]string, error) {
+	client, _, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.ListTables(opts...)
+}
+
+// TableExists reports whether a table exists in the database of the handle
+func (d *PoolDB) TableExists(name string) (bool, error) {
+	client, _, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	return client.TableExists(name)
+}
+
+// DescribeTable returns the columns of a table in the database of the handle
+func (d *PoolDB) DescribeTable(name string) ([]ColumnInfo, error) {
+	client, _, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.DescribeTable(name)
+}
+
+// DeclareColumnTypes sets the declared column types of a table in the
+// database of the handle on rows, see Client.DeclareColumnTypes
+func (d *PoolDB) DeclareColumnTypes(rows *utils.Rows, table string) error {
+	client, _, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return client.DeclareColumnTypes(rows, table)
+}
+
+// ListIndexes returns the indexes of a table in the database of the handle
+func (d *PoolDB) ListIndexes(table string) ([]IndexInfo, error) {
+	client, _, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.ListIndexes(table)
+}
+
+// CreateTable creates a table in the database of the handle
+func (d *PoolDB) CreateTable(createQuery string) (*utils.APIResponse, error) {
+	client, ctx, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, createQuery, nil)
+}
+
+// RemoveTable removes a table from the database of the handle
+func (d *PoolDB) RemoveTable(tableName string) (*utils.APIResponse, error) {
+	query, err := dropTableQuery(tableName)
+	if err != nil {
+		return nil, err
+	}
+	client, ctx, done, err := d.pool.connected(context.Background(), d.name)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return client.QueryContext(ctx, query, nil)
+}
+
+// Ping checks that the database of the handle still exists, see
+// ConnectionPool.Ping
+func (d *PoolDB) Ping() error {
+	return d.PingContext(context.Background())
+}
+
+// PingContext is Ping with a context that can cancel the request
+func (d *PoolDB) PingContext(ctx context.Context) error {
+	client, opCtx, done, err := d.pool.connected(ctx, d.name)
+	if err != nil {
+		return err
+	}
+	return d.pool.ping(ctx, opCtx, client, done, d.name)
+}
@@ -0,0 +1,32 @@
+package cloudflared1
+
+import (
+	"context"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// Queryer is the query surface of a connected database. Client and
+// ConnectionPool implement it; code that only runs queries can accept a
+// Queryer, and implementations can be checked against the shared behavior
+// with the conformance package.
+type Queryer interface {
+	Query(query string, params []string) (*utils.APIResponse, error)
+	QueryContext(ctx context.Context, query string, params []string) (*utils.APIResponse, error)
+	Select(dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	Get(dest interface{}, query string, args ...interface{}) error
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (int64, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error)
+	ExecResult(query string, args ...interface{}) (*utils.Result, error)
+	ExecResultContext(ctx context.Context, query string, args ...interface{}) (*utils.Result, error)
+	Batch(statements []utils.Statement) ([]utils.BatchResult, error)
+	BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error)
+}
+
+var (
+	_ Queryer = (*Client)(nil)
+	_ Queryer = (*ConnectionPool)(nil)
+	_ Queryer = (*Session)(nil)
+)
@@ -0,0 +1,189 @@
+package cloudflared1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// queryBody is the JSON body of one statement sent to the raw query endpoint
+type queryBody struct {
+	SQL    string   `json:"sql"`
+	Params []string `json:"params"`
+
+	types []string // Go types of the params before conversion, for logging
+}
+
+// batchBody is the JSON body of several statements sent in one request
+type batchBody struct {
+	Batch []queryBody `json:"batch"`
+}
+
+// newQueryBody builds the body of a single statement.
+// nil and empty params are the same: both are sent as an empty array,
+// because D1 does not reliably accept "params": null.
+// Trailing semicolons are removed unless disabled with SetTrimSemicolons,
+// and utils.Null parameters are bound as NULL.
+func (c *Client) newQueryBody(query string, params []string) queryBody {
+	if params == nil {
+		params = []string{}
+	}
+	if !c.keepSemicolons {
+		query = trimTrailingSemicolons(query)
+	}
+	query, params = utils.BindNulls(query, params)
+	return queryBody{SQL: query, Params: params}
+}
+
+// newArgsBody is newQueryBody for parameters given as Go values
+func (c *Client) newArgsBody(query string, args []interface{}) (queryBody, error) {
+	query, params, err := utils.BindParams(query, args)
+	if err != nil {
+		return queryBody{}, err
+	}
+	body := c.newQueryBody(query, params)
+	body.types = typeNames(args)
+	return body, nil
+}
+
+// checkParamCount returns a *utils.ParamCountError, in a BatchError for a
+// batch, for the first statement whose placeholders don't match its params
+func checkParamCount(body interface{}) error {
+	_, batch := body.(batchBody)
+	for i, stmt := range statements(body) {
+		n, err := utils.CountPlaceholders(stmt.SQL)
+		if err != nil || n == len(stmt.Params) {
+			continue
+		}
+		err = &utils.ParamCountError{Placeholders: n, Params: len(stmt.Params)}
+		if batch {
+			return &utils.BatchError{Index: i, Err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+// trimTrailingSemicolons removes trailing semicolons and whitespace.
+// D1 treats the text after the last semicolon as another, empty statement
+// and answers it with an extra result set.
+func trimTrailingSemicolons(query string) string {
+	return strings.TrimRight(query, "; \t\r\n")
+}
+
+// postSQL sends a query or batch body to the raw endpoint of a database,
+// or to the query endpoint if enabled with UseQueryEndpoint
+func (c *Client) postSQL(ctx context.Context, databaseID string, body interface{}) (*utils.APIResponse, error) {
+	if !c.skipParamCheck {
+		if err := checkParamCount(body); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.checkLimits(body); err != nil {
+		return nil, err
+	}
+	if c.recorder != nil {
+		return c.recorder.record(body), nil
+	}
+
+	res, err := c.post(ctx, databaseID, body)
+	// A database deleted and recreated under the same name has a new ID
+	if c.reresolve != nil && databaseNotFound(res, err) {
+		if id, resolveErr := c.reresolve(ctx, databaseID); resolveErr == nil && id != databaseID {
+			return c.post(ctx, id, body)
+		}
+	}
+	return res, err
+}
+
+// post sends a checked body through the query cache, if any
+func (c *Client) post(ctx context.Context, databaseID string, body interface{}) (*utils.APIResponse, error) {
+	if c.cache != nil {
+		return c.cache.post(ctx, c, databaseID, body)
+	}
+	return c.send(ctx, databaseID, body)
+}
+
+// errDatabaseNotFound is the API error code of an unknown database ID
+const errDatabaseNotFound = 7404
+
+// databaseNotFound reports whether a request failed because its database
+// does not exist
+func databaseNotFound(res *utils.APIResponse, err error) bool {
+	if err == nil && res != nil {
+		err = res.Err()
+	}
+	var apiErr *utils.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == errDatabaseNotFound
+}
+
+// send posts a checked query or batch body and reports the call to the
+// logger, metrics, tracer and usage counters
+func (c *Client) send(ctx context.Context, databaseID string, body interface{}) (*utils.APIResponse, error) {
+	op := OpQuery
+	if _, ok := body.(batchBody); ok {
+		op = OpBatch
+	}
+
+	endpoint, do := "raw", c.requester.DoCounted
+	if c.queryEndpoint {
+		endpoint, do = "query", c.requester.DoObjectRowsCounted
+	}
+	target := c.buildURL(databaseID, endpoint)
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	ctx, endTrace := c.startTrace(ctx, op, databaseID, body)
+	start := time.Now()
+	res, received, err := do(ctx, "POST", target, string(bodyBytes), c.APIToken)
+	endTrace(res, err)
+	if c.session != nil && res != nil {
+		c.session.update(res.Header.Get(SessionHeader))
+	}
+	if received > 0 {
+		c.usage.add(res, statementCount(body), int64(len(bodyBytes)), received)
+	}
+	c.logCall(ctx, op, body, start, res, err)
+	c.observe(op, start, res, err)
+	if err == nil {
+		c.checkSlow(ctx, body, start, res)
+	}
+	return res, err
+}
+
+// do sends a management request, such as listing or creating databases
+func (c *Client) do(ctx context.Context, op, method, target, payload string) (*utils.APIResponse, error) {
+	start := time.Now()
+	res, _, err := c.requester.DoCounted(ctx, method, target, payload, c.APIToken)
+	c.logCall(ctx, op, nil, start, res, err)
+	c.observe(op, start, res, err)
+	return res, err
+}
+
+// apiBase returns the URL the API paths are appended to
+func (c *Client) apiBase() string {
+	if c.baseURL == "" {
+		return DefaultBaseURL
+	}
+	return c.baseURL
+}
+
+// buildURL returns the URL of the database collection of the account, with
+// segments such as a database ID and an endpoint appended, path-escaped
+func (c *Client) buildURL(segments ...string) string {
+	var b strings.Builder
+	b.WriteString(c.apiBase() + "/accounts/" + url.PathEscape(c.AccountID) + "/d1/database")
+	for _, segment := range segments {
+		b.WriteString("/" + url.PathEscape(segment))
+	}
+	return b.String()
+}
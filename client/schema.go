@@ -0,0 +1,155 @@
+package cloudflared1
+
+import (
+	"fmt"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// ColumnInfo describes a table column as reported by PRAGMA table_info
+type ColumnInfo struct {
+	Name    string
+	Type    string
+	NotNull bool
+	// DefaultValue is the default value expression, nil if the column has none
+	DefaultValue *string
+	// PrimaryKey is the 1-based position of the column in the primary key, 0 if not part of it
+	PrimaryKey int
+}
+
+// IndexInfo describes an index as reported by PRAGMA index_list and index_info
+type IndexInfo struct {
+	Name   string
+	Unique bool
+	// Origin is "c" for CREATE INDEX, "u" for UNIQUE constraints and "pk" for primary keys
+	Origin  string
+	Partial bool
+	// Columns are the indexed columns in index order; expressions are reported as ""
+	Columns []string
+}
+
+// DescribeTable returns the columns of a table in the connected database
+// It returns an error if the table does not exist
+func (c *Client) DescribeTable(name string) ([]ColumnInfo, error) {
+	quoted, err := utils.QuoteIdentifier(name)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.Query(fmt.Sprintf("PRAGMA table_info(%s)", quoted), nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := res.ToRows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []ColumnInfo{}
+	for rows.Next() {
+		var cid, notNull, pk int64
+		var col ColumnInfo
+		var dflt interface{}
+		if err := rows.Scan(&cid, &col.Name, &col.Type, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		col.NotNull = notNull != 0
+		col.PrimaryKey = int(pk)
+		if dflt != nil {
+			s := fmt.Sprintf("%v", dflt)
+			col.DefaultValue = &s
+		}
+		columns = append(columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// PRAGMA table_info returns no rows instead of failing for unknown tables
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", name)
+	}
+	return columns, nil
+}
+
+// DeclareColumnTypes looks up the declared column types of table with
+// DescribeTable and sets them on rows, so that rows.ColumnTypes reports them
+// rather than types inferred from the values. Columns of rows that are not
+// columns of table keep inferred types.
+func (c *Client) DeclareColumnTypes(rows *utils.Rows, table string) error {
+	columns, err := c.DescribeTable(table)
+	if err != nil {
+		return err
+	}
+	declared := make(map[string]utils.DeclaredColumn, len(columns))
+	for _, col := range columns {
+		declared[col.Name] = utils.DeclaredColumn{Type: col.Type, NotNull: col.NotNull}
+	}
+	rows.SetDeclaredColumns(declared)
+	return nil
+}
+
+// ListIndexes returns the indexes of a table in the connected database
+func (c *Client) ListIndexes(table string) ([]IndexInfo, error) {
+	quoted, err := utils.QuoteIdentifier(table)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.Query(fmt.Sprintf("PRAGMA index_list(%s)", quoted), nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := res.ToRows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []IndexInfo{}
+	for rows.Next() {
+		var seq, unique, partial int64
+		var idx IndexInfo
+		if err := rows.Scan(&seq, &idx.Name, &unique, &idx.Origin, &partial); err != nil {
+			return nil, err
+		}
+		idx.Unique = unique != 0
+		idx.Partial = partial != 0
+		indexes = append(indexes, idx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		return indexes, nil
+	}
+
+	// Fetch the columns of all indexes in one request
+	statements := make([]utils.Statement, len(indexes))
+	for i, idx := range indexes {
+		quotedIdx, err := utils.QuoteIdentifier(idx.Name)
+		if err != nil {
+			return nil, err
+		}
+		statements[i] = utils.Statement{SQL: fmt.Sprintf("PRAGMA index_info(%s)", quotedIdx)}
+	}
+	results, err := c.Batch(statements)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		indexes[i].Columns = []string{}
+		for result.Rows.Next() {
+			var seqno, cid int64
+			var name interface{}
+			if err := result.Rows.Scan(&seqno, &cid, &name); err != nil {
+				return nil, err
+			}
+			col, _ := name.(string)
+			indexes[i].Columns = append(indexes[i].Columns, col)
+		}
+	}
+	return indexes, nil
+}
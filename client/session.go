@@ -0,0 +1,157 @@
+package cloudflared1
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// SessionHeader carries the bookmark or constraint of a session to D1, and
+// the bookmark of the database state a query observed back to the client
+const SessionHeader = "x-d1-bookmark"
+
+// Session constraints accepted by NewSession
+const (
+	// SessionFirstPrimary sends the first query of the session to the
+	// primary database, so it observes all writes made so far
+	SessionFirstPrimary = "first-primary"
+	// SessionFirstUnconstrained lets the first query run on any replica,
+	// which is fastest but may return slightly stale data
+	SessionFirstUnconstrained = "first-unconstrained"
+)
+
+// Session runs queries on the connected database of a client with sequential
+// consistency under D1 read replication: every query observes the writes of
+// the queries before it in the session, whichever replica answers it.
+// A Session is safe for concurrent use by multiple goroutines.
+type Session struct {
+	client     *Client
+	constraint string
+
+	mu       sync.Mutex
+	bookmark string
+}
+
+// NewSession starts a session on the connected database. constraint is
+// SessionFirstPrimary, SessionFirstUnconstrained, or a bookmark returned by
+// Bookmark of an earlier session, e.g. one stored in a cookie, to continue
+// from the state that session observed. An empty constraint is
+// SessionFirstUnconstrained.
+//
+// The session uses a copy of the client, like WithDatabase; setters called
+// on c later do not affect it.
+func (c *Client) NewSession(constraint string) *Session {
+	s := &Session{constraint: constraint}
+	switch constraint {
+	case "":
+		s.constraint = SessionFirstUnconstrained
+	case SessionFirstPrimary, SessionFirstUnconstrained:
+	default:
+		s.bookmark = constraint
+	}
+
+	bound := *c
+	bound.session = s
+	s.client = &bound
+	return s
+}
+
+// Bookmark returns the latest bookmark returned by D1 in this session, or
+// the bookmark the session was started with. It is empty until the first
+// query of a session started with a constraint has been answered.
+func (s *Session) Bookmark() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bookmark
+}
+
+// update records a bookmark returned by D1. Bookmarks sort in the order of
+// the database states they stand for, so answers of concurrent queries that
+// arrive out of order do not move the session back.
+func (s *Session) update(bookmark string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bookmark > s.bookmark {
+		s.bookmark = bookmark
+	}
+}
+
+// bound returns the client of the session, set up to send the current
+// bookmark, or the constraint before the first answer
+func (s *Session) bound() *Client {
+	value := s.Bookmark()
+	if value == "" {
+		value = s.constraint
+	}
+
+	c := *s.client
+	header := c.requester.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(SessionHeader, value)
+	c.requester.Header = header
+	return &c
+}
+
+// Query is Client.Query within the session
+func (s *Session) Query(query string, params []string) (*utils.APIResponse, error) {
+	return s.QueryContext(context.Background(), query, params)
+}
+
+// QueryContext is Client.QueryContext within the session
+func (s *Session) QueryContext(ctx context.Context, query string, params []string) (*utils.APIResponse, error) {
+	return s.bound().QueryContext(ctx, query, params)
+}
+
+// Select is Client.Select within the session
+func (s *Session) Select(dest interface{}, query string, args ...interface{}) error {
+	return s.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext is Client.SelectContext within the session
+func (s *Session) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.bound().SelectContext(ctx, dest, query, args...)
+}
+
+// Get is Client.Get within the session
+func (s *Session) Get(dest interface{}, query string, args ...interface{}) error {
+	return s.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext is Client.GetContext within the session
+func (s *Session) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.bound().GetContext(ctx, dest, query, args...)
+}
+
+// Exec is Client.Exec within the session
+func (s *Session) Exec(query string, args ...interface{}) (int64, error) {
+	return s.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Client.ExecContext within the session
+func (s *Session) ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return s.bound().ExecContext(ctx, query, args...)
+}
+
+// ExecResult is Client.ExecResult within the session
+func (s *Session) ExecResult(query string, args ...interface{}) (*utils.Result, error) {
+	return s.ExecResultContext(context.Background(), query, args...)
+}
+
+// ExecResultContext is Client.ExecResultContext within the session
+func (s *Session) ExecResultContext(ctx context.Context, query string, args ...interface{}) (*utils.Result, error) {
+	return s.bound().ExecResultContext(ctx, query, args...)
+}
+
+// Batch is Client.Batch within the session
+func (s *Session) Batch(statements []utils.Statement) ([]utils.BatchResult, error) {
+	return s.BatchContext(context.Background(), statements)
+}
+
+// BatchContext is Client.BatchContext within the session
+func (s *Session) BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error) {
+	return s.bound().BatchContext(ctx, statements)
+}
@@ -0,0 +1,284 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Shards routes keys, such as tenant or user IDs, to the databases of a
+// pool. By default a key goes to the database at hash(key) modulo the
+// number of databases, so adding a database moves most keys; with
+// ConsistentHashing only about 1/n of the keys move. AddShard and
+// RemoveShard report which keys moved, see ShardChange.
+type Shards struct {
+	pool     *ConnectionPool
+	hash     func(key string) uint32
+	replicas int // virtual nodes per database, 0 for modulo
+
+	mu       sync.RWMutex
+	router   shardRouter
+	onChange func(ShardChange)
+}
+
+// ShardOption configures NewShards
+type ShardOption func(*Shards)
+
+// ConsistentHashing places each database at replicas points of a hash ring
+// and routes a key to the database of the next point, so that changing the
+// set of databases only moves the keys of the affected ring segments. More
+// replicas spread keys more evenly; 0 means 100.
+func ConsistentHashing(replicas int) ShardOption {
+	return func(s *Shards) {
+		if replicas <= 0 {
+			replicas = 100
+		}
+		s.replicas = replicas
+	}
+}
+
+// NewShards routes keys to the databases dbNames of pool using hash, or
+// 32-bit FNV-1a if hash is nil. The databases are connected to on first use.
+func NewShards(pool *ConnectionPool, dbNames []string, hash func(key string) uint32, opts ...ShardOption) (*Shards, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("shards need a pool")
+	}
+	if hash == nil {
+		hash = fnv32a
+	}
+	s := &Shards{pool: pool, hash: hash}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	router, err := s.newRouter(dbNames)
+	if err != nil {
+		return nil, err
+	}
+	s.router = router
+	return s, nil
+}
+
+// fnv32a is the default hash of NewShards
+func fnv32a(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// ShardName returns the name of the database of key
+func (s *Shards) ShardName(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.router.route(key)
+}
+
+// ForKey returns a handle on the database of key, see ConnectionPool.DB
+func (s *Shards) ForKey(key string) (*PoolDB, error) {
+	return s.pool.DB(s.ShardName(key))
+}
+
+// Names returns the names of the databases, in the order they were added
+func (s *Shards) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.router.names()...)
+}
+
+// ExecOnAll runs an update on every database concurrently, see
+// ConnectionPool.SelectMany, and returns the number of rows affected in
+// all of them. Failed databases are named by a *FanOutError.
+func (s *Shards) ExecOnAll(query string, args ...interface{}) (int64, error) {
+	return s.ExecOnAllContext(context.Background(), query, args...)
+}
+
+// ExecOnAllContext is ExecOnAll with a context that can cancel the requests
+func (s *Shards) ExecOnAllContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return s.pool.execMany(ctx, s.Names(), query, args)
+}
+
+// SelectFromAll runs query on every database and collects the rows in dest,
+// see ConnectionPool.SelectMany
+func (s *Shards) SelectFromAll(dest interface{}, query string, args ...interface{}) error {
+	return s.SelectFromAllContext(context.Background(), dest, query, args...)
+}
+
+// SelectFromAllContext is SelectFromAll with a context that can cancel the requests
+func (s *Shards) SelectFromAllContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return s.pool.SelectManyContext(ctx, s.Names(), dest, query, args...)
+}
+
+// ShardChange describes a change of the databases of Shards. Moved tells
+// which keys are routed elsewhere since, so their data can be copied over.
+type ShardChange struct {
+	Added   string // set by AddShard
+	Removed string // set by RemoveShard
+	before  shardRouter
+	after   shardRouter
+}
+
+// Moved returns the databases of key before and after the change, and
+// whether they differ
+func (c ShardChange) Moved(key string) (from, to string, moved bool) {
+	from, to = c.before.route(key), c.after.route(key)
+	return from, to, from != to
+}
+
+// OnChange registers a callback invoked after every AddShard and
+// RemoveShard, e.g. to start moving the data of the keys that moved.
+// Passing nil removes the callback.
+func (s *Shards) OnChange(fn func(ShardChange)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// AddShard adds the database dbName. Keys routed to it from then on are
+// reported by the Moved method of the returned change.
+func (s *Shards) AddShard(dbName string) (ShardChange, error) {
+	return s.change(func(names []string) ([]string, error) {
+		return append(names, dbName), nil
+	}, ShardChange{Added: dbName})
+}
+
+// RemoveShard removes the database dbName. Its keys are routed to the other
+// databases from then on, see the Moved method of the returned change.
+func (s *Shards) RemoveShard(dbName string) (ShardChange, error) {
+	return s.change(func(names []string) ([]string, error) {
+		for i, name := range names {
+			if name == dbName {
+				return append(names[:i], names[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("database %s is not a shard", dbName)
+	}, ShardChange{Removed: dbName})
+}
+
+// change replaces the databases by the result of edit and reports the change
+func (s *Shards) change(edit func(names []string) ([]string, error), change ShardChange) (ShardChange, error) {
+	s.mu.Lock()
+	names, err := edit(append([]string(nil), s.router.names()...))
+	if err != nil {
+		s.mu.Unlock()
+		return ShardChange{}, err
+	}
+	router, err := s.newRouter(names)
+	if err != nil {
+		s.mu.Unlock()
+		return ShardChange{}, err
+	}
+	change.before, change.after = s.router, router
+	s.router = router
+	hook := s.onChange
+	s.mu.Unlock()
+
+	if hook != nil {
+		hook(change)
+	}
+	return change, nil
+}
+
+// shardRouter maps keys to database names
+type shardRouter interface {
+	route(key string) string
+	names() []string
+}
+
+// newRouter returns the router of the configured strategy for names
+func (s *Shards) newRouter(names []string) (shardRouter, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("shards need at least one database")
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == "" {
+			return nil, fmt.Errorf("database name must not be empty")
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("database %s is listed twice", name)
+		}
+		seen[name] = true
+	}
+
+	if s.replicas == 0 {
+		return moduloRouter{dbNames: names, hash: s.hash}, nil
+	}
+	return newRingRouter(names, s.hash, s.replicas), nil
+}
+
+// moduloRouter routes a key to the database at hash(key) modulo their number
+type moduloRouter struct {
+	dbNames []string
+	hash    func(string) uint32
+}
+
+func (r moduloRouter) route(key string) string {
+	return r.dbNames[r.hash(key)%uint32(len(r.dbNames))]
+}
+
+func (r moduloRouter) names() []string {
+	return r.dbNames
+}
+
+// ringRouter routes a key to the database owning the first point of the
+// hash ring at or after hash(key)
+type ringRouter struct {
+	dbNames []string
+	hash    func(string) uint32
+	points  []uint32 // sorted
+	owners  []string // database of each point
+}
+
+func newRingRouter(names []string, hash func(string) uint32, replicas int) *ringRouter {
+	type point struct {
+		at    uint32
+		owner string
+	}
+	points := make([]point, 0, len(names)*replicas)
+	for _, name := range names {
+		for i := 0; i < replicas; i++ {
+			points = append(points, point{at: mix32(hash(name + "#" + strconv.Itoa(i))), owner: name})
+		}
+	}
+	// Ties are broken by name, so the ring does not depend on the order of names
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].at != points[j].at {
+			return points[i].at < points[j].at
+		}
+		return points[i].owner < points[j].owner
+	})
+
+	r := &ringRouter{dbNames: names, hash: hash, points: make([]uint32, len(points)), owners: make([]string, len(points))}
+	for i, p := range points {
+		r.points[i], r.owners[i] = p.at, p.owner
+	}
+	return r
+}
+
+func (r *ringRouter) route(key string) string {
+	h := mix32(r.hash(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i]
+}
+
+func (r *ringRouter) names() []string {
+	return r.dbNames
+}
+
+// mix32 is the finalizer of MurmurHash3. Hashes such as FNV place similar
+// keys, like the points of one database, close together; mixing spreads
+// them over the ring. It is a bijection, so it loses no information.
+func mix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,105 @@
+package cloudflared1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrPoolClosed is returned by ConnectionPool operations after Close was called
+var ErrPoolClosed = errors.New("connection pool is closed")
+
+// Closer is implemented by every component that owns background work or
+// in-flight requests. Close stops accepting new work, waits for pending work
+// to drain and returns once done or once ctx expires, whichever comes first.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// ComponentError reports a component that failed to shut down cleanly
+type ComponentError struct {
+	Component string
+	Err       error
+}
+
+func (e ComponentError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Component, e.Err)
+}
+
+// ShutdownError lists every component that did not drain before the deadline
+// or returned an error from Close
+type ShutdownError struct {
+	Failed []ComponentError
+}
+
+func (e *ShutdownError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		msgs[i] = f.Error()
+	}
+	return "shutdown failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *ShutdownError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// Shutdown closes all components concurrently and waits for them with the
+// deadline of ctx. It returns a *ShutdownError naming the components that
+// failed to drain, or nil if all of them closed cleanly.
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	err := cloudflare_d1_go.Shutdown(ctx, pool)
+func Shutdown(ctx context.Context, components ...Closer) error {
+	errs := make([]error, len(components))
+
+	var wg sync.WaitGroup
+	for i, c := range components {
+		wg.Add(1)
+		go func(i int, c Closer) {
+			defer wg.Done()
+			errs[i] = closeWithDeadline(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	var failed []ComponentError
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, ComponentError{Component: componentName(components[i]), Err: err})
+		}
+	}
+	if len(failed) > 0 {
+		return &ShutdownError{Failed: failed}
+	}
+	return nil
+}
+
+// closeWithDeadline calls c.Close and gives up once ctx expires, so a
+// component ignoring its context cannot block the whole shutdown
+func closeWithDeadline(ctx context.Context, c Closer) error {
+	done := make(chan error, 1)
+	go func() { done <- c.Close(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("did not drain: %w", ctx.Err())
+	}
+}
+
+// componentName names a component in shutdown errors
+func componentName(c Closer) string {
+	if s, ok := c.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", c)
+}
@@ -0,0 +1,44 @@
+package cloudflared1
+
+import (
+	"context"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// SlowQuery describes a query or batch that took longer than
+// ClientOptions.SlowQueryThreshold
+type SlowQuery struct {
+	// SQL is the statement sent, or the statements of a batch joined by "; "
+	SQL string
+	// Duration is the wall-clock time of the request
+	Duration time.Duration
+	// ServerDuration is the execution time D1 reported, summed over the statements
+	ServerDuration time.Duration
+	// RowsRead is summed over the statements; a growing value often points
+	// to a full table scan
+	RowsRead int64
+}
+
+// SlowQueryHook receives the queries that exceeded the slow query threshold.
+// It is called from the goroutine that made the query, without holding any
+// pool lock, so it may use the client or pool.
+type SlowQueryHook func(ctx context.Context, query SlowQuery)
+
+// checkSlow calls the slow query hook if the query took longer than the
+// threshold, by wall-clock time or by the duration D1 reported
+func (c *Client) checkSlow(ctx context.Context, body interface{}, start time.Time, res *utils.APIResponse) {
+	if c.slowHook == nil || c.slowThreshold <= 0 || res == nil {
+		return
+	}
+
+	query := SlowQuery{SQL: statementsSQL(body), Duration: time.Since(start)}
+	for _, meta := range res.Metas() {
+		query.ServerDuration += time.Duration(meta.Duration * float64(time.Millisecond))
+		query.RowsRead += meta.RowsRead
+	}
+	if query.Duration > c.slowThreshold || query.ServerDuration > c.slowThreshold {
+		c.slowHook(ctx, query)
+	}
+}
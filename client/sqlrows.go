@@ -0,0 +1,83 @@
+package cloudflared1
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+var (
+	sqlMu        sync.Mutex
+	sqlConnector func(*Client) driver.Connector
+	sqlDBs       = make(map[sqlKey]*sql.DB)
+)
+
+// sqlKey identifies the *sql.DB shared by clients with the same configuration.
+// Clients counting usage separately get separate DBs, so that queries are
+// counted where they were made, and so do clients using different endpoints.
+type sqlKey struct {
+	accountID, apiToken, databaseID string
+	auth                            *utils.AuthProvider
+	usage                           *usageCounters
+	queryEndpoint                   bool
+}
+
+// RegisterSQLConnector sets how SQLRows reaches the database/sql driver.
+// It is called by the d1driver package when it is imported and is not
+// meant to be called otherwise.
+func RegisterSQLConnector(fn func(*Client) driver.Connector) {
+	sqlMu.Lock()
+	defer sqlMu.Unlock()
+	sqlConnector = fn
+}
+
+// SQLRows runs a query through the "d1" database/sql driver and returns standard *sql.Rows,
+// for libraries that only accept *sql.Rows. It requires importing the d1driver package:
+//
+//	import _ "github.com/youfun/cloudflare-d1-go/d1driver"
+//
+// The rows hold no connection to D1, so closing them only releases memory.
+func (c *Client) SQLRows(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.SQLRowsContext(context.Background(), query, args...)
+}
+
+// SQLRowsContext is SQLRows with a context. As with database/sql, cancelling
+// the context while iterating closes the rows and rows.Err reports the cancellation.
+func (c *Client) SQLRowsContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if c.DatabaseID == "" {
+		return nil, fmt.Errorf("no database connected, call ConnectDB first")
+	}
+
+	db, err := c.sqlDB()
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+// sqlDB returns the *sql.DB for the client's configuration, creating it on first use.
+// A *sql.DB runs a background goroutine until closed, so one is kept per
+// configuration instead of opening one per call.
+func (c *Client) sqlDB() (*sql.DB, error) {
+	sqlMu.Lock()
+	defer sqlMu.Unlock()
+
+	if sqlConnector == nil {
+		return nil, fmt.Errorf("database/sql driver not registered, import github.com/youfun/cloudflare-d1-go/d1driver")
+	}
+
+	key := sqlKey{accountID: c.AccountID, apiToken: c.APIToken, auth: c.auth, databaseID: c.DatabaseID, usage: c.usage, queryEndpoint: c.queryEndpoint}
+	if db, ok := sqlDBs[key]; ok {
+		return db, nil
+	}
+
+	// The connector keeps its own copy, so later changes to c do not leak into the shared DB
+	clientCopy := *c
+	db := sql.OpenDB(sqlConnector(&clientCopy))
+	sqlDBs[key] = db
+	return db, nil
+}
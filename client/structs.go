@@ -0,0 +1,333 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// InsertOption configures InsertStruct
+type InsertOption func(*insertOptions)
+
+type insertOptions struct {
+	verb     string
+	conflict []string
+}
+
+// InsertOrIgnore skips the row if it violates a constraint, such as a
+// duplicate primary key
+func InsertOrIgnore() InsertOption {
+	return func(o *insertOptions) {
+		o.verb = "INSERT OR IGNORE"
+	}
+}
+
+// InsertOrReplace deletes the rows the new row conflicts with before inserting it
+func InsertOrReplace() InsertOption {
+	return func(o *insertOptions) {
+		o.verb = "INSERT OR REPLACE"
+	}
+}
+
+// OnConflictUpdate turns the insert into an upsert: if a row with the same
+// values in columns exists, its other columns are updated instead. columns
+// must have a unique index. If every inserted column is in columns, the row
+// is skipped.
+func OnConflictUpdate(columns ...string) InsertOption {
+	return func(o *insertOptions) {
+		o.conflict = columns
+	}
+}
+
+// structColumn is a column of a struct and the value of its field
+type structColumn struct {
+	name  string
+	value interface{}
+	zero  bool
+	omit  bool // the db tag has omitempty
+}
+
+// structColumns returns the columns of struct v, a struct or a pointer to
+// one, with their values. Pointer fields are dereferenced; nil is nil.
+func (c *Client) structColumns(v interface{}) ([]structColumn, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("struct argument must not be nil")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("struct argument must be a struct or a pointer to one, got %T", v)
+	}
+
+	fields := utils.StructColumns(rv.Type(), c.nameMapper)
+	columns := make([]structColumn, 0, len(fields))
+	for _, fc := range fields {
+		field, err := rv.FieldByIndexErr(fc.Index)
+		if err != nil {
+			// A field of a nil embedded struct pointer is NULL
+			columns = append(columns, structColumn{name: fc.Column, zero: true, omit: fc.OmitEmpty})
+			continue
+		}
+		col := structColumn{name: fc.Column, zero: field.IsZero(), omit: fc.OmitEmpty}
+		if fc.JSON {
+			value, err := utils.JSONValue(field)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", fc.Field.Name, err)
+			}
+			col.value = value
+			columns = append(columns, col)
+			continue
+		}
+		for field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() != reflect.Ptr {
+			value, err := utils.DriverValue(field.Interface())
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", fc.Field.Name, err)
+			}
+			col.value = value
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// placeholder returns the SQL for a value: NULL for nil, since parameters
+// are sent as strings and cannot carry NULL, or a bound ? otherwise
+func placeholder(value interface{}, args []interface{}) (string, []interface{}) {
+	if value == nil {
+		return "NULL", args
+	}
+	return "?", append(args, value)
+}
+
+// insertStructSQL builds the INSERT statement of InsertStruct
+func insertStructSQL(table string, columns []structColumn, opts insertOptions) (string, []interface{}, error) {
+	var names, values []string
+	var args []interface{}
+	for _, col := range columns {
+		if col.omit && col.zero {
+			continue
+		}
+		var value string
+		value, args = placeholder(col.value, args)
+		names = append(names, col.name)
+		values = append(values, value)
+	}
+
+	head, tail, err := insertClauses(table, names, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(names) == 0 {
+		return head + " DEFAULT VALUES" + tail, nil, nil
+	}
+	return head + " VALUES (" + strings.Join(values, ", ") + ")" + tail, args, nil
+}
+
+// insertClauses returns the SQL before the VALUES of an INSERT into the
+// columns of table, such as `INSERT INTO "users" ("name")`, and the conflict
+// clause after it
+func insertClauses(table string, columns []string, opts insertOptions) (string, string, error) {
+	quotedTable, err := utils.QuoteIdentifier(table)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid table name: %w", err)
+	}
+	names, err := quoteColumns(columns)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid column name: %w", err)
+	}
+	verb := opts.verb
+	if verb == "" {
+		verb = "INSERT"
+	}
+
+	head := verb + " INTO " + quotedTable
+	if len(names) > 0 {
+		head += " (" + strings.Join(names, ", ") + ")"
+	}
+	if len(opts.conflict) == 0 {
+		return head, "", nil
+	}
+
+	target, err := quoteColumns(opts.conflict)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid conflict column: %w", err)
+	}
+	conflict := make(map[string]bool)
+	for _, column := range opts.conflict {
+		conflict[column] = true
+	}
+	var updates []string
+	for i, column := range columns {
+		if !conflict[column] {
+			updates = append(updates, names[i]+" = excluded."+names[i])
+		}
+	}
+
+	tail := " ON CONFLICT (" + strings.Join(target, ", ") + ")"
+	if len(updates) == 0 {
+		return head, tail + " DO NOTHING", nil
+	}
+	return head, tail + " DO UPDATE SET " + strings.Join(updates, ", "), nil
+}
+
+// quoteColumns quotes each column name with utils.QuoteIdentifier
+func quoteColumns(columns []string) ([]string, error) {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		var err error
+		if quoted[i], err = utils.QuoteIdentifier(column); err != nil {
+			return nil, err
+		}
+	}
+	return quoted, nil
+}
+
+// InsertStruct inserts v, a struct or a pointer to one, as a row of table.
+// Columns are taken from the db tags of the fields, or the name mapper for
+// fields without one; fields tagged `db:"-"` are skipped, and so are zero
+// fields tagged omitempty, e.g. `db:"id,omitempty"` for an auto-increment
+// key. Nil pointer fields are inserted as NULL. The result holds the ID of
+// the inserted row.
+//
+// Like sqlx: client.InsertStruct("users", &User{Name: "Alice", Age: 30})
+func (c *Client) InsertStruct(table string, v interface{}, opts ...InsertOption) (*utils.Result, error) {
+	return c.InsertStructContext(context.Background(), table, v, opts...)
+}
+
+// InsertStructContext is InsertStruct with a context that can cancel the request
+func (c *Client) InsertStructContext(ctx context.Context, table string, v interface{}, opts ...InsertOption) (*utils.Result, error) {
+	var o insertOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	columns, err := c.structColumns(v)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := insertStructSQL(table, columns, o)
+	if err != nil {
+		return nil, err
+	}
+	return c.ExecResultContext(ctx, query, args...)
+}
+
+// UpdateOptions holds the settings of UpdateStructWithOptions.
+// The zero value updates every column of the row with the ID in column "id".
+type UpdateOptions struct {
+	// KeyColumns select the row to update; they are left out of the SET list.
+	// Empty means "id".
+	KeyColumns []string
+	// OnlyNonZero leaves zero fields out of the SET list, for partial updates
+	// from a struct that only has the changed fields set
+	OnlyNonZero bool
+	// Columns limits the SET list to these columns; empty means all
+	Columns []string
+}
+
+// UpdateStruct updates the row of table whose key columns hold the values of
+// the matching fields of v, setting every other column from v. Columns are
+// taken from db tags and the name mapper like InsertStruct; keyColumns
+// defaults to "id". It returns the number of rows updated, which is zero if
+// no row has the key; that is not an error.
+//
+// Like sqlx: client.UpdateStruct("users", &user, "id")
+func (c *Client) UpdateStruct(table string, v interface{}, keyColumns ...string) (int64, error) {
+	return c.UpdateStructContext(context.Background(), table, v, keyColumns...)
+}
+
+// UpdateStructContext is UpdateStruct with a context that can cancel the request
+func (c *Client) UpdateStructContext(ctx context.Context, table string, v interface{}, keyColumns ...string) (int64, error) {
+	return c.UpdateStructWithOptionsContext(ctx, table, v, UpdateOptions{KeyColumns: keyColumns})
+}
+
+// UpdateStructWithOptions is UpdateStruct with options for partial updates
+func (c *Client) UpdateStructWithOptions(table string, v interface{}, opts UpdateOptions) (int64, error) {
+	return c.UpdateStructWithOptionsContext(context.Background(), table, v, opts)
+}
+
+// UpdateStructWithOptionsContext is UpdateStructWithOptions with a context
+// that can cancel the request
+func (c *Client) UpdateStructWithOptionsContext(ctx context.Context, table string, v interface{}, opts UpdateOptions) (int64, error) {
+	columns, err := c.structColumns(v)
+	if err != nil {
+		return 0, err
+	}
+	query, args, err := updateStructSQL(table, columns, opts)
+	if err != nil {
+		return 0, err
+	}
+	return c.ExecContext(ctx, query, args...)
+}
+
+// updateStructSQL builds the UPDATE statement of UpdateStruct
+func updateStructSQL(table string, columns []structColumn, opts UpdateOptions) (string, []interface{}, error) {
+	quotedTable, err := utils.QuoteIdentifier(table)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid table name: %w", err)
+	}
+	keys := opts.KeyColumns
+	if len(keys) == 0 {
+		keys = []string{"id"}
+	}
+
+	byName := make(map[string]structColumn, len(columns))
+	for _, col := range columns {
+		byName[col.name] = col
+	}
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	allowed := make(map[string]bool, len(opts.Columns))
+	for _, column := range opts.Columns {
+		if _, ok := byName[column]; !ok {
+			return "", nil, fmt.Errorf("column %q is not a field of the struct", column)
+		}
+		allowed[column] = true
+	}
+
+	var sets []string
+	var args []interface{}
+	for _, col := range columns {
+		if isKey[col.name] || (len(allowed) > 0 && !allowed[col.name]) || (opts.OnlyNonZero && col.zero) {
+			continue
+		}
+		name, err := utils.QuoteIdentifier(col.name)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid column name: %w", err)
+		}
+		var value string
+		value, args = placeholder(col.value, args)
+		sets = append(sets, name+" = "+value)
+	}
+	if len(sets) == 0 {
+		return "", nil, fmt.Errorf("no columns to update in table %s", table)
+	}
+
+	where := make([]string, len(keys))
+	for i, key := range keys {
+		col, ok := byName[key]
+		if !ok {
+			return "", nil, fmt.Errorf("key column %q is not a field of the struct", key)
+		}
+		if col.value == nil {
+			return "", nil, fmt.Errorf("key column %q is NULL", key)
+		}
+		name, err := utils.QuoteIdentifier(key)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid key column: %w", err)
+		}
+		where[i] = name + " = ?"
+		args = append(args, col.value)
+	}
+
+	return "UPDATE " + quotedTable + " SET " + strings.Join(sets, ", ") + " WHERE " + strings.Join(where, " AND "), args, nil
+}
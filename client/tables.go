@@ -0,0 +1,81 @@
+package cloudflared1
+
+import (
+	"strings"
+)
+
+// ListTablesOption configures ListTables
+type ListTablesOption func(*listTablesOptions)
+
+type listTablesOptions struct {
+	includeInternal bool
+}
+
+// IncludeInternalTables makes ListTables also return SQLite and D1 internal
+// tables such as sqlite_sequence, d1_migrations and _cf_KV
+func IncludeInternalTables() ListTablesOption {
+	return func(o *listTablesOptions) {
+		o.includeInternal = true
+	}
+}
+
+// internalTablePrefixes are the name prefixes of tables managed by SQLite or D1
+var internalTablePrefixes = []string{"sqlite_", "d1_", "_cf_"}
+
+// ListTables returns the names of the tables in the connected database, sorted by name
+// Internal tables are left out unless IncludeInternalTables is passed
+func (c *Client) ListTables(opts ...ListTablesOption) ([]string, error) {
+	var o listTablesOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res, err := c.Query("SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := res.ToRows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !o.includeInternal && isInternalTable(name) {
+			continue
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// TableExists reports whether a table with the given name exists in the connected database
+func (c *Client) TableExists(name string) (bool, error) {
+	res, err := c.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", []string{name})
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.ToRows()
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
+
+func isInternalTable(name string) bool {
+	for _, prefix := range internalTablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
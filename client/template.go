@@ -0,0 +1,127 @@
+package cloudflared1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// BatchExecutor runs statements as one atomic batch; Client and ConnectionPool implement it
+type BatchExecutor interface {
+	BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error)
+}
+
+// TemplateStatement is one statement of a BatchTemplate
+type TemplateStatement struct {
+	SQL       string `json:"sql"`
+	NumParams int    `json:"num_params"`
+}
+
+// BatchTemplate is an ordered list of parameterized statements that is
+// validated once and executed repeatedly with different parameters, e.g. by a
+// recurring job. It can be stored as JSON; decoding validates it again and
+// checks the fingerprint, so a template edited by hand is rejected.
+type BatchTemplate struct {
+	Statements []TemplateStatement `json:"statements"`
+	// Fingerprint identifies the statements, so runs can be attributed to a template version
+	Fingerprint string `json:"fingerprint"`
+}
+
+// NewBatchTemplate validates the statements and counts their placeholders
+func NewBatchTemplate(statements ...string) (*BatchTemplate, error) {
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("batch template needs at least one statement")
+	}
+
+	t := &BatchTemplate{Statements: make([]TemplateStatement, len(statements))}
+	for i, sql := range statements {
+		if trimTrailingSemicolons(sql) == "" {
+			return nil, &utils.BatchError{Index: i, Err: fmt.Errorf("empty statement")}
+		}
+		n, err := utils.CountPlaceholders(sql)
+		if err != nil {
+			return nil, &utils.BatchError{Index: i, Err: err}
+		}
+		t.Statements[i] = TemplateStatement{SQL: sql, NumParams: n}
+	}
+	t.Fingerprint = t.fingerprint()
+	return t, nil
+}
+
+// fingerprint hashes the SQL of every statement in order
+func (t *BatchTemplate) fingerprint() string {
+	h := sha256.New()
+	for _, stmt := range t.Statements {
+		// The length prefix keeps ["ab", "c"] and ["a", "bc"] apart
+		fmt.Fprintf(h, "%d:%s;", len(stmt.SQL), stmt.SQL)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// UnmarshalJSON decodes a stored template and validates it like NewBatchTemplate
+func (t *BatchTemplate) UnmarshalJSON(data []byte) error {
+	var stored struct {
+		Statements  []TemplateStatement `json:"statements"`
+		Fingerprint string              `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	sqls := make([]string, len(stored.Statements))
+	for i, stmt := range stored.Statements {
+		sqls[i] = stmt.SQL
+	}
+	fresh, err := NewBatchTemplate(sqls...)
+	if err != nil {
+		return fmt.Errorf("invalid batch template: %w", err)
+	}
+	if fresh.Fingerprint != stored.Fingerprint {
+		return fmt.Errorf("batch template fingerprint mismatch: stored %s, computed %s", stored.Fingerprint, fresh.Fingerprint)
+	}
+
+	*t = *fresh
+	return nil
+}
+
+// Bind pairs each statement with its parameters after checking their number.
+// params holds one parameter list per statement; it may be nil if no
+// statement takes parameters.
+func (t *BatchTemplate) Bind(params [][]interface{}) ([]utils.Statement, error) {
+	if params != nil && len(params) != len(t.Statements) {
+		return nil, fmt.Errorf("got parameters for %d statements, template has %d", len(params), len(t.Statements))
+	}
+
+	statements := make([]utils.Statement, len(t.Statements))
+	for i, stmt := range t.Statements {
+		var p []interface{}
+		if params != nil {
+			p = params[i]
+		}
+		if len(p) != stmt.NumParams {
+			return nil, &utils.BatchError{Index: i, Err: fmt.Errorf("expected %d parameters, got %d", stmt.NumParams, len(p))}
+		}
+		statements[i] = utils.Statement{SQL: stmt.SQL, Params: p}
+	}
+	return statements, nil
+}
+
+// Execute binds params (see Bind) and runs the statements as one atomic batch
+// on the connected database of db. Arity errors are returned before anything
+// is sent. The results hold one entry per statement, in order.
+func (t *BatchTemplate) Execute(db BatchExecutor, params [][]interface{}) ([]utils.BatchResult, error) {
+	return t.ExecuteContext(context.Background(), db, params)
+}
+
+// ExecuteContext is Execute with a context that can cancel the request
+func (t *BatchTemplate) ExecuteContext(ctx context.Context, db BatchExecutor, params [][]interface{}) ([]utils.BatchResult, error) {
+	statements, err := t.Bind(params)
+	if err != nil {
+		return nil, err
+	}
+	return db.BatchContext(ctx, statements)
+}
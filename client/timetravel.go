@@ -0,0 +1,121 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// TimeTravelInfo describes a point in the history of a database
+type TimeTravelInfo struct {
+	// Bookmark identifies the database state; RestoreToBookmark accepts it
+	Bookmark string `json:"bookmark"`
+}
+
+// RestoreResult describes a finished Time Travel restore
+type RestoreResult struct {
+	// Bookmark is the state the database was restored to
+	Bookmark string `json:"bookmark"`
+	// PreviousBookmark is the state before the restore, which undoes it
+	// when passed to RestoreToBookmark
+	PreviousBookmark string `json:"previous_bookmark"`
+	// Message is the status message of the restore
+	Message string `json:"message"`
+}
+
+// TimeTravelInfo returns the bookmark of the current state of the connected
+// database, e.g. to note it before a risky migration
+func (c *Client) TimeTravelInfo() (*TimeTravelInfo, error) {
+	return c.TimeTravelInfoContext(context.Background())
+}
+
+// TimeTravelInfoContext is TimeTravelInfo with a context that can cancel the request
+func (c *Client) TimeTravelInfoContext(ctx context.Context) (*TimeTravelInfo, error) {
+	return c.timeTravelBookmark(ctx, url.Values{})
+}
+
+// BookmarkAt returns the bookmark of the state the connected database had at t.
+// Time Travel reaches back 30 days.
+func (c *Client) BookmarkAt(t time.Time) (*TimeTravelInfo, error) {
+	return c.BookmarkAtContext(context.Background(), t)
+}
+
+// BookmarkAtContext is BookmarkAt with a context that can cancel the request
+func (c *Client) BookmarkAtContext(ctx context.Context, t time.Time) (*TimeTravelInfo, error) {
+	return c.timeTravelBookmark(ctx, url.Values{"timestamp": {t.UTC().Format(time.RFC3339)}})
+}
+
+// RestoreToBookmark restores the connected database to the state of
+// bookmark. Writes made after that state are undone; the result holds the
+// bookmark to undo the restore itself.
+func (c *Client) RestoreToBookmark(bookmark string) (*RestoreResult, error) {
+	return c.RestoreToBookmarkContext(context.Background(), bookmark)
+}
+
+// RestoreToBookmarkContext is RestoreToBookmark with a context that can
+// cancel the request
+func (c *Client) RestoreToBookmarkContext(ctx context.Context, bookmark string) (*RestoreResult, error) {
+	if bookmark == "" {
+		return nil, fmt.Errorf("no bookmark to restore to")
+	}
+	return c.restore(ctx, url.Values{"bookmark": {bookmark}})
+}
+
+// RestoreToTimestamp restores the connected database to the state it had at t
+func (c *Client) RestoreToTimestamp(t time.Time) (*RestoreResult, error) {
+	return c.RestoreToTimestampContext(context.Background(), t)
+}
+
+// RestoreToTimestampContext is RestoreToTimestamp with a context that can
+// cancel the request
+func (c *Client) RestoreToTimestampContext(ctx context.Context, t time.Time) (*RestoreResult, error) {
+	return c.restore(ctx, url.Values{"timestamp": {t.UTC().Format(time.RFC3339)}})
+}
+
+// timeTravelBookmark looks up the bookmark of the connected database for query
+func (c *Client) timeTravelBookmark(ctx context.Context, query url.Values) (*TimeTravelInfo, error) {
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+	if c.DatabaseID == "" {
+		return nil, fmt.Errorf("no database connected, call ConnectDB first")
+	}
+
+	target := c.buildURL(c.DatabaseID, "time_travel", "bookmark")
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	res, err := c.do(ctx, OpTimeTravel, "GET", target, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var info TimeTravelInfo
+	if err := decodeResult(res, &info); err != nil {
+		return nil, fmt.Errorf("failed to get bookmark of database %s: %w", c.DatabaseID, err)
+	}
+	return &info, nil
+}
+
+// restore restores the connected database to the state selected by query
+func (c *Client) restore(ctx context.Context, query url.Values) (*RestoreResult, error) {
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+	if c.DatabaseID == "" {
+		return nil, fmt.Errorf("no database connected, call ConnectDB first")
+	}
+
+	target := c.buildURL(c.DatabaseID, "time_travel", "restore") + "?" + query.Encode()
+	res, err := c.do(ctx, OpRestoreDatabase, "POST", target, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var result RestoreResult
+	if err := decodeResult(res, &result); err != nil {
+		return nil, fmt.Errorf("failed to restore database %s: %w", c.DatabaseID, err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,55 @@
+package cloudflared1
+
+import (
+	"context"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// Tracer traces the queries and batches of a client, e.g. as OpenTelemetry
+// spans; the d1otel module has an implementation. StartQuery is called before
+// the request is sent. The returned context is used for the request, so spans
+// of an instrumented HTTP client become children of the query span, and end
+// is called once the response was read.
+type Tracer interface {
+	StartQuery(ctx context.Context, query TracedQuery) (context.Context, func(TraceResult))
+}
+
+// TracedQuery describes a query or batch about to be sent
+type TracedQuery struct {
+	// Operation is OpQuery or OpBatch
+	Operation  string
+	DatabaseID string
+	// SQL is the statement, or the statements of a batch joined by "; ".
+	// It holds placeholders, not parameter values.
+	SQL string
+}
+
+// TraceResult describes how a traced query ended
+type TraceResult struct {
+	// RayID is the cf-ray header of the response, empty if none was received
+	RayID       string
+	RowsRead    int64
+	RowsWritten int64
+	// Err is the error of the call, including errors reported by the API
+	Err error
+}
+
+// startTrace starts tracing a query if the client has a tracer. end must be
+// called with the outcome of the request.
+func (c *Client) startTrace(ctx context.Context, op, databaseID string, body interface{}) (context.Context, func(*utils.APIResponse, error)) {
+	if c.tracer == nil {
+		return ctx, func(*utils.APIResponse, error) {}
+	}
+
+	ctx, end := c.tracer.StartQuery(ctx, TracedQuery{Operation: op, DatabaseID: databaseID, SQL: statementsSQL(body)})
+	return ctx, func(res *utils.APIResponse, err error) {
+		result := TraceResult{Err: err}
+		if err == nil && res != nil {
+			result.Err = res.Err()
+			result.RayID = res.RayID()
+			result.RowsRead, result.RowsWritten = res.RowCounts()
+		}
+		end(result)
+	}
+}
@@ -0,0 +1,174 @@
+package cloudflared1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// Usage is a snapshot of the cumulative usage counters of a Client or
+// ConnectionPool. It covers every request sent to the query endpoint,
+// including those of the migrations executor, Batch and the database/sql
+// driver. Database management calls such as ListDB are not counted.
+type Usage struct {
+	RowsRead      int64 // sum of rows_read reported by D1
+	RowsWritten   int64 // sum of rows_written reported by D1
+	Queries       int64 // statements executed, a batch counts each statement
+	BytesSent     int64 // request bodies
+	BytesReceived int64 // response bodies
+}
+
+// usageCounters holds the counters behind Usage. Copies of a client share
+// them, and so do all clients created by a pool.
+type usageCounters struct {
+	rowsRead      atomic.Int64
+	rowsWritten   atomic.Int64
+	queries       atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+}
+
+// add records one response of the query endpoint
+func (u *usageCounters) add(res *utils.APIResponse, queries int, sent, received int64) {
+	if u == nil {
+		return
+	}
+	u.queries.Add(int64(queries))
+	u.bytesSent.Add(sent)
+	u.bytesReceived.Add(received)
+	if res != nil {
+		read, written := res.RowCounts()
+		u.rowsRead.Add(read)
+		u.rowsWritten.Add(written)
+	}
+}
+
+func (u *usageCounters) snapshot() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{
+		RowsRead:      u.rowsRead.Load(),
+		RowsWritten:   u.rowsWritten.Load(),
+		Queries:       u.queries.Load(),
+		BytesSent:     u.bytesSent.Load(),
+		BytesReceived: u.bytesReceived.Load(),
+	}
+}
+
+// reset zeroes the counters and returns their values before the reset.
+// Each counter is swapped atomically, so nothing counted concurrently is lost.
+func (u *usageCounters) reset() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{
+		RowsRead:      u.rowsRead.Swap(0),
+		RowsWritten:   u.rowsWritten.Swap(0),
+		Queries:       u.queries.Swap(0),
+		BytesSent:     u.bytesSent.Swap(0),
+		BytesReceived: u.bytesReceived.Swap(0),
+	}
+}
+
+// statementCount returns how many statements a request body holds
+func statementCount(body interface{}) int {
+	if b, ok := body.(batchBody); ok {
+		return len(b.Batch)
+	}
+	return 1
+}
+
+// Usage returns the cumulative usage of the client since it was created or
+// last reset. Clients created with New count their own requests;
+// clients returned by a pool count into the pool.
+func (c *Client) Usage() Usage {
+	return c.usage.snapshot()
+}
+
+// ResetUsage zeroes the usage counters and returns their values before the reset
+func (c *Client) ResetUsage() Usage {
+	return c.usage.reset()
+}
+
+// ReportUsage calls fn with the cumulative usage every interval, e.g. to ship
+// it to a billing pipeline, until the returned reporter is closed.
+// interval must be positive.
+func (c *Client) ReportUsage(interval time.Duration, fn func(Usage)) *UsageReporter {
+	return startUsageReporter(c.usage, interval, fn)
+}
+
+// Usage returns the cumulative usage of all requests made through the pool,
+// including clients returned by DB, since it was created or last reset.
+func (p *ConnectionPool) Usage() Usage {
+	return p.usage.snapshot()
+}
+
+// ResetUsage zeroes the pool's usage counters and returns their values before the reset
+func (p *ConnectionPool) ResetUsage() Usage {
+	return p.usage.reset()
+}
+
+// ReportUsage calls fn with the pool's cumulative usage every interval.
+// The reporter is owned by the pool: Close stops it after in-flight requests drained.
+func (p *ConnectionPool) ReportUsage(interval time.Duration, fn func(Usage)) *UsageReporter {
+	r := startUsageReporter(p.usage, interval, fn)
+	p.own(r)
+	return r
+}
+
+// UsageReporter calls a function with a usage snapshot at a fixed interval
+type UsageReporter struct {
+	usage *usageCounters
+	fn    func(Usage)
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func startUsageReporter(usage *usageCounters, interval time.Duration, fn func(Usage)) *UsageReporter {
+	r := &UsageReporter{
+		usage: usage,
+		fn:    fn,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	// Created here so that an invalid interval panics in the caller
+	ticker := time.NewTicker(interval)
+	go r.run(ticker)
+	return r
+}
+
+func (r *UsageReporter) run(ticker *time.Ticker) {
+	defer close(r.done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.fn(r.usage.snapshot())
+		case <-r.stop:
+			// Report once more, so usage since the last tick is not lost
+			r.fn(r.usage.snapshot())
+			return
+		}
+	}
+}
+
+// Close stops the reporter after a final report and waits for it to finish
+// or for ctx to expire. Calling Close again is a no-op.
+func (r *UsageReporter) Close(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("usage reporter did not stop: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,109 @@
+package cloudflared1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// ErrUnauthorized is returned by VerifyToken when the API rejects the
+// credentials of a client, or they cannot access its account
+var ErrUnauthorized = errors.New("credentials rejected, check the API token and account ID")
+
+// TokenInfo describes the credentials of a client as checked by VerifyToken
+type TokenInfo struct {
+	// ID, Status ("active", "disabled" or "expired") and ExpiresOn are
+	// reported by /user/tokens/verify. They are empty for account-owned
+	// tokens and API keys, which that endpoint does not know.
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	ExpiresOn time.Time `json:"expires_on"`
+	// AccountMatch reports whether the credentials can list the databases
+	// of the account of the client
+	AccountMatch bool `json:"-"`
+}
+
+// authErrorCodes are the API error codes of rejected credentials
+var authErrorCodes = map[int]bool{
+	1000:  true, // invalid API token
+	6003:  true, // invalid request headers
+	6100:  true, // invalid email
+	6101:  true, // invalid email
+	6102:  true, // invalid API key
+	6103:  true, // invalid API key
+	7403:  true, // account not authorized
+	9103:  true, // unknown API key or email
+	9106:  true, // missing credentials
+	9109:  true, // unauthorized
+	10000: true, // authentication error
+	10001: true, // authentication error
+}
+
+// isUnauthorized reports whether err means the API rejected the credentials
+func isUnauthorized(err error) bool {
+	var httpErr *utils.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden
+	}
+
+	// errors.Join of the API errors
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		var apiErr *utils.APIError
+		if errors.As(err, &apiErr) && authErrorCodes[apiErr.Code] {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyToken checks the credentials of the client before doing work, so a
+// misconfigured token fails with an error wrapping ErrUnauthorized instead
+// of an error deep inside the first query. It verifies the token with
+// /user/tokens/verify, then lists one database of the account. Credentials
+// that are valid but cannot access the account return their TokenInfo
+// together with the error.
+func (c *Client) VerifyToken() (*TokenInfo, error) {
+	return c.VerifyTokenContext(context.Background())
+}
+
+// VerifyTokenContext is VerifyToken with a context that can cancel the requests
+func (c *Client) VerifyTokenContext(ctx context.Context) (*TokenInfo, error) {
+	if c.recorder != nil {
+		return nil, errDryRun
+	}
+
+	info := &TokenInfo{}
+	res, verifyErr := c.do(ctx, OpVerifyToken, "GET", c.apiBase()+"/user/tokens/verify", "")
+	if verifyErr == nil {
+		verifyErr = decodeResult(res, info)
+	}
+	// Account-owned tokens and API keys are rejected by this endpoint, so
+	// the account decides for them
+	if verifyErr != nil && !isUnauthorized(verifyErr) {
+		return nil, fmt.Errorf("failed to verify token: %w", verifyErr)
+	}
+
+	res, err := c.do(ctx, OpListDatabases, "GET", c.buildURL()+"?per_page=1", "")
+	if err == nil {
+		err = res.Err()
+	}
+	switch {
+	case err == nil:
+		info.AccountMatch = true
+		return info, nil
+	case !isUnauthorized(err):
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	case verifyErr != nil:
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	default:
+		return info, fmt.Errorf("%w: token %s cannot access account %s: %w", ErrUnauthorized, info.ID, c.AccountID, err)
+	}
+}
@@ -0,0 +1,130 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestPoolForwardsClientOptions(t *testing.T) {
+	// Nothing may go through http.DefaultClient
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request %s went through http.DefaultClient", r.URL)
+	})
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.Method == http.MethodGet {
+			writeJSON(w, map[string]interface{}{"result": []interface{}{map[string]interface{}{"uuid": "db-1", "name": "main"}}, "success": true})
+			return
+		}
+		writeJSON(w, successResponse(queryResult([]string{"n"}, [][]interface{}{{1}}, nil)))
+	}))
+	defer srv.Close()
+
+	pool := cloudflare_d1_go.NewConnectionPoolWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		HTTPClient: srv.Client(),
+		BaseURL:    srv.URL + "/api/",
+	})
+	if err := pool.Connect("main"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if _, err := pool.Exec("UPDATE t SET n = 1"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+
+	want := []string{"/api/accounts/account_id/d1/database", "/api/accounts/account_id/d1/database/db-1/raw"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		BaseURL:        srv.URL,
+		HTTPClient:     srv.Client(),
+		RequestTimeout: 20 * time.Millisecond,
+	})
+	client.DatabaseID = "db-1"
+
+	start := time.Now()
+	_, err := client.Query("SELECT 1", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want a deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Query returned after %v", elapsed)
+	}
+}
+
+func TestPoolMethodsUseOptionsTransport(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request %s went through http.DefaultClient", r.URL)
+	})
+
+	var databases []string
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		databases = append(databases, strings.Split(r.URL.Path, "/")[7])
+		rec := httptest.NewRecorder()
+		writeJSON(rec, successResponse(queryResult([]string{"n"}, [][]interface{}{{1}}, nil)))
+		return rec.Result(), nil
+	})
+	pool := cloudflare_d1_go.NewConnectionPoolWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		HTTPClient: &http.Client{Transport: transport},
+	})
+	if err := pool.ConnectWithID("other", "db-2"); err != nil {
+		t.Fatalf("ConnectWithID failed: %v", err)
+	}
+	if err := pool.ConnectWithID("main", "db-1"); err != nil {
+		t.Fatalf("ConnectWithID failed: %v", err)
+	}
+
+	var rows []struct {
+		N int `db:"n"`
+	}
+	var row struct {
+		N int `db:"n"`
+	}
+	calls := map[string]func() error{
+		"Query":         func() error { _, err := pool.Query("SELECT 1 AS n", nil); return err },
+		"Select":        func() error { return pool.Select(&rows, "SELECT 1 AS n") },
+		"Get":           func() error { return pool.Get(&row, "SELECT 1 AS n") },
+		"Exec":          func() error { _, err := pool.Exec("UPDATE t SET n = 1"); return err },
+		"Count":         func() error { _, err := pool.Count("SELECT 1 AS n"); return err },
+		"QueryDB":       func() error { _, err := pool.QueryDB("other", "SELECT 1 AS n", nil); return err },
+		"Batch":         func() error { _, err := pool.Batch([]utils.Statement{{SQL: "SELECT 1 AS n"}}); return err },
+		"CreateTableDB": func() error { _, err := pool.CreateTableDB("other", "CREATE TABLE t (n INTEGER)"); return err },
+	}
+	for name, call := range calls {
+		databases = nil
+		if err := call(); err != nil {
+			t.Errorf("%s failed: %v", name, err)
+			continue
+		}
+		want := "db-1"
+		if strings.HasSuffix(name, "DB") {
+			want = "db-2"
+		}
+		if len(databases) != 1 || databases[0] != want {
+			t.Errorf("%s sent %v through the options transport, want one request to %s", name, databases, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cloudflared1_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestColumnOrder(t *testing.T) {
+	tests := []struct {
+		fixture string
+		columns []string
+		first   []interface{}
+	}{
+		{"raw_users.json", []string{"name", "id", "email"}, []interface{}{"Alice", float64(1), "alice@example.com"}},
+		{"raw_object_rows.json", []string{"name", "id", "email"}, []interface{}{"Alice", float64(1), "alice@example.com"}},
+		// Without a columns array the keys are sorted
+		{"raw_object_rows_no_columns.json", []string{"email", "id", "name"}, []interface{}{"alice@example.com", float64(1), "Alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			// Map iteration order changes between runs, so repeat to catch it
+			for i := 0; i < 20; i++ {
+				rows, err := loadFixture(t, tt.fixture).ToRows()
+				if err != nil {
+					t.Fatalf("ToRows failed: %v", err)
+				}
+				if columns, _ := rows.Columns(); !reflect.DeepEqual(columns, tt.columns) {
+					t.Fatalf("Columns() = %v, want %v", columns, tt.columns)
+				}
+
+				got := make([]interface{}, 3)
+				rows.Next()
+				if err := rows.Scan(&got[0], &got[1], &got[2]); err != nil {
+					t.Fatalf("Scan failed: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.first) {
+					t.Fatalf("Scan = %v, want %v", got, tt.first)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRowsInfersSortedColumns(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		rows := utils.NewRows([]map[string]interface{}{{"b": 1, "c": 2, "a": 3}, {"d": 4}}, nil)
+		if columns, _ := rows.Columns(); !reflect.DeepEqual(columns, []string{"a", "b", "c", "d"}) {
+			t.Fatalf("Columns() = %v, want [a b c d]", columns)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package cloudflared1_test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type columnTypeWant struct {
+	name, dbType     string
+	nullable, nullOK bool
+	scanType         reflect.Type
+}
+
+func checkColumnTypes(t *testing.T, types []*utils.ColumnType, want []columnTypeWant) {
+	t.Helper()
+	if len(types) != len(want) {
+		t.Fatalf("got %d column types, want %d", len(types), len(want))
+	}
+	for i, w := range want {
+		ct := types[i]
+		nullable, ok := ct.Nullable()
+		if ct.Name() != w.name || ct.DatabaseTypeName() != w.dbType || nullable != w.nullable || ok != w.nullOK || ct.ScanType() != w.scanType {
+			t.Errorf("column %d = %s %q nullable %v/%v %v, want %+v", i, ct.Name(), ct.DatabaseTypeName(), nullable, ok, ct.ScanType(), w)
+		}
+		if _, _, ok := ct.DecimalSize(); ok {
+			t.Errorf("column %s reports a decimal size", ct.Name())
+		}
+	}
+}
+
+var (
+	typeInt64   = reflect.TypeOf(int64(0))
+	typeFloat64 = reflect.TypeOf(float64(0))
+	typeString  = reflect.TypeOf("")
+	typeAny     = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+func TestColumnTypesInferred(t *testing.T) {
+	rows := utils.NewRows([]map[string]interface{}{
+		{"id": float64(1), "score": float64(2), "name": "Alice", "avatar": []interface{}{float64(137), float64(80)}, "note": nil, "mixed": "a"},
+		{"id": float64(2), "score": 2.5, "name": nil, "avatar": nil, "note": nil, "mixed": float64(1)},
+	}, []string{"id", "score", "name", "avatar", "note", "mixed"})
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("ColumnTypes failed: %v", err)
+	}
+	checkColumnTypes(t, types, []columnTypeWant{
+		{"id", "INTEGER", false, false, typeInt64},
+		{"score", "REAL", false, false, typeFloat64},
+		{"name", "TEXT", true, true, typeString},
+		{"avatar", "BLOB", true, true, reflect.TypeOf([]byte(nil))},
+		{"note", "", true, true, typeAny},
+		{"mixed", "", false, false, typeAny},
+	})
+}
+
+func TestColumnTypesDeclared(t *testing.T) {
+	serveSchema(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	rows := utils.NewRows([]map[string]interface{}{
+		{"id": float64(1), "email": "a@example.com", "n": float64(3)},
+	}, []string{"id", "email", "n"})
+	if err := client.DeclareColumnTypes(rows, "users"); err != nil {
+		t.Fatalf("DeclareColumnTypes failed: %v", err)
+	}
+
+	types, _ := rows.ColumnTypes()
+	checkColumnTypes(t, types, []columnTypeWant{
+		{"id", "INTEGER", true, true, typeInt64},
+		{"email", "TEXT", false, true, typeString},
+		{"n", "INTEGER", false, false, typeInt64},
+	})
+
+	rows.SetDeclaredColumns(map[string]utils.DeclaredColumn{"id": {Type: "bigint"}, "email": {Type: "varchar(20)"}, "n": {Type: "datetime", NotNull: true}})
+	types, _ = rows.ColumnTypes()
+	checkColumnTypes(t, types, []columnTypeWant{
+		{"id", "BIGINT", true, true, typeInt64},
+		{"email", "VARCHAR(20)", true, true, typeString},
+		{"n", "DATETIME", false, true, reflect.TypeOf(time.Time{})},
+	})
+}
+
+func TestDriverColumnTypes(t *testing.T) {
+	serveDriver(t)
+
+	db, err := sql.Open("d1", "d1://"+testAccountID+":tok@app")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name, score, note FROM users")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("ColumnTypes failed: %v", err)
+	}
+	var names []string
+	for _, ct := range types {
+		names = append(names, ct.DatabaseTypeName())
+	}
+	if want := []string{"INTEGER", "TEXT", "REAL", "TEXT"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("DatabaseTypeName = %v, want %v", names, want)
+	}
+	if nullable, ok := types[3].Nullable(); !nullable || !ok || types[0].ScanType() != typeInt64 {
+		t.Errorf("note nullable = %v/%v, id scan type = %v", nullable, ok, types[0].ScanType())
+	}
+}
@@ -0,0 +1,416 @@
+// Package conformance is a behavioral test suite for implementations of
+// cloudflared1.Queryer. The Client and the ConnectionPool of this module run
+// it, and so can test doubles or wrappers written by users:
+//
+//	func TestMyQueryer(t *testing.T) {
+//		conformance.RunQueryerTests(t, func() cloudflared1.Queryer {
+//			return newMyQueryer(t)
+//		})
+//	}
+//
+// The suite only creates and drops its own tables, named conformance_*, so it
+// can run against a real, otherwise used database.
+package conformance
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// RunQueryerTests runs the suite as subtests of t. factory is called once per
+// subtest and must return a Queryer connected to a database the suite may
+// create tables in.
+func RunQueryerTests(t *testing.T, factory func() cloudflare_d1_go.Queryer) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, cloudflare_d1_go.Queryer, string)
+	}{
+		{"ParameterTypes", testParameterTypes},
+		{"Null", testNull},
+		{"NoRows", testNoRows},
+		{"ExecResult", testExecResult},
+		{"Batch", testBatch},
+		{"BatchIsAtomic", testBatchIsAtomic},
+		{"MultipleStatements", testMultipleStatements},
+		{"ScanRoundTrip", testScanRoundTrip},
+		{"InvalidSQL", testInvalidSQL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := factory()
+			tt.fn(t, q, tableName(t, q))
+		})
+	}
+}
+
+var tableCount atomic.Int64
+
+// tableName returns a table name unique to the run and drops the table when t finishes
+func tableName(t *testing.T, q cloudflare_d1_go.Queryer) string {
+	name := fmt.Sprintf("conformance_%d_%d", time.Now().UnixNano(), tableCount.Add(1))
+	t.Cleanup(func() {
+		if _, err := q.Exec("DROP TABLE IF EXISTS " + name); err != nil {
+			t.Errorf("failed to drop %s: %v", name, err)
+		}
+	})
+	return name
+}
+
+// mustExec runs a statement and fails the test if it returns an error
+func mustExec(t *testing.T, q cloudflare_d1_go.Queryer, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := q.Exec(query, args...); err != nil {
+		t.Fatalf("Exec(%q) failed: %v", query, err)
+	}
+}
+
+// count returns the number of rows in table matching where
+func count(t *testing.T, q cloudflare_d1_go.Queryer, table, where string, args ...interface{}) int64 {
+	t.Helper()
+	var row struct {
+		N int64 `db:"n"`
+	}
+	query := "SELECT COUNT(*) AS n FROM " + table
+	if where != "" {
+		query += " WHERE " + where
+	}
+	if err := q.Get(&row, query, args...); err != nil {
+		t.Fatalf("Get(%q) failed: %v", query, err)
+	}
+	return row.N
+}
+
+// testParameterTypes checks that every supported parameter type is bound and
+// read back with the value it had
+func testParameterTypes(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, i INTEGER, f REAL, s TEXT, b INTEGER, ts TEXT, j TEXT)")
+
+	at := time.Date(2024, 2, 29, 13, 45, 30, 0, time.UTC)
+	text := `it's "quoted", ünïcode 用户; -- not a comment`
+	mustExec(t, q, "INSERT INTO "+table+" (id, i, f, s, b, ts, j) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		1, int64(-9007199254740991), 2.5, text, true, at, map[string]int{"k": 1})
+
+	var got struct {
+		I  int64     `db:"i"`
+		F  float64   `db:"f"`
+		S  string    `db:"s"`
+		B  bool      `db:"b"`
+		TS time.Time `db:"ts"`
+		J  string    `db:"j"`
+	}
+	if err := q.Get(&got, "SELECT i, f, s, b, ts, j FROM "+table+" WHERE id = ?", 1); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.I != -9007199254740991 {
+		t.Errorf("int64 = %d, want -9007199254740991", got.I)
+	}
+	if got.F != 2.5 {
+		t.Errorf("float64 = %v, want 2.5", got.F)
+	}
+	if got.S != text {
+		t.Errorf("string = %q, want %q", got.S, text)
+	}
+	if !got.B {
+		t.Error("bool = false, want true")
+	}
+	if !got.TS.Equal(at) {
+		t.Errorf("time.Time = %v, want %v", got.TS, at)
+	}
+	if got.J != `{"k":1}` {
+		t.Errorf("map = %q, want JSON {\"k\":1}", got.J)
+	}
+
+	// Every integer type binds as its decimal value
+	ints := []interface{}{int(1), int8(2), int16(3), int32(4), int64(5), uint(6), uint8(7), uint16(8), uint32(9), uint64(10)}
+	for i, v := range ints {
+		mustExec(t, q, "INSERT INTO "+table+" (id, i, b) VALUES (?, ?, ?)", 100+i, v, false)
+	}
+	var sum struct {
+		Total int64 `db:"total"`
+	}
+	if err := q.Get(&sum, "SELECT SUM(i) AS total FROM "+table+" WHERE id >= ? AND b = ?", 100, false); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if sum.Total != 55 {
+		t.Errorf("sum of integer parameters = %d, want 55", sum.Total)
+	}
+}
+
+// testNull checks that NULL columns scan to zero values and invalid sql.Null* values
+func testNull(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	mustExec(t, q, "INSERT INTO "+table+" (id, name, age) VALUES (1, NULL, NULL), (2, 'Bob', 42)")
+
+	type row struct {
+		ID      int64          `db:"id"`
+		Name    string         `db:"name"`
+		Age     int64          `db:"age"`
+		NullStr sql.NullString `db:"null_name"`
+		NullInt sql.NullInt64  `db:"null_age"`
+	}
+	var rows []row
+	if err := q.Select(&rows, "SELECT id, name, age, name AS null_name, age AS null_age FROM "+table+" ORDER BY id"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if rows[0].Name != "" || rows[0].Age != 0 {
+		t.Errorf("NULL columns scanned to %q and %d, want zero values", rows[0].Name, rows[0].Age)
+	}
+	if rows[0].NullStr.Valid || rows[0].NullInt.Valid {
+		t.Errorf("NULL columns scanned to valid sql.Null values: %+v", rows[0])
+	}
+	if rows[1].NullStr != (sql.NullString{String: "Bob", Valid: true}) || rows[1].NullInt != (sql.NullInt64{Int64: 42, Valid: true}) {
+		t.Errorf("non-NULL columns scanned to %+v and %+v", rows[1].NullStr, rows[1].NullInt)
+	}
+
+	var maps []map[string]interface{}
+	if err := q.Select(&maps, "SELECT name FROM "+table+" WHERE id = ?", 1); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(maps) != 1 || maps[0]["name"] != nil {
+		t.Errorf("NULL column in a map = %v, want nil", maps)
+	}
+
+	if n := count(t, q, table, "name IS NULL"); n != 1 {
+		t.Errorf("%d rows with NULL name, want 1", n)
+	}
+}
+
+// testNoRows checks that Get reports utils.ErrNoRows and Select returns an empty slice
+func testNoRows(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, name TEXT)")
+
+	var one struct {
+		ID int64 `db:"id"`
+	}
+	err := q.Get(&one, "SELECT id FROM "+table+" WHERE id = ?", 1)
+	if !errors.Is(err, utils.ErrNoRows) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get on no rows returned %v, want utils.ErrNoRows", err)
+	}
+
+	var many []struct {
+		ID int64 `db:"id"`
+	}
+	if err := q.Select(&many, "SELECT id FROM "+table); err != nil {
+		t.Fatalf("Select on no rows failed: %v", err)
+	}
+	if len(many) != 0 {
+		t.Errorf("Select on no rows returned %d rows", len(many))
+	}
+}
+
+// testExecResult checks RowsAffected and LastInsertId of inserts, updates and deletes
+func testExecResult(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, name TEXT)")
+
+	check := func(query string, wantAffected, wantLastID int64, args ...interface{}) {
+		t.Helper()
+		res, err := q.ExecResult(query, args...)
+		if err != nil {
+			t.Fatalf("ExecResult(%q) failed: %v", query, err)
+		}
+		affected, _ := res.RowsAffected()
+		if affected != wantAffected {
+			t.Errorf("%q: RowsAffected = %d, want %d", query, affected, wantAffected)
+		}
+		if wantLastID >= 0 {
+			if id, _ := res.LastInsertId(); id != wantLastID {
+				t.Errorf("%q: LastInsertId = %d, want %d", query, id, wantLastID)
+			}
+		}
+	}
+
+	check("INSERT INTO "+table+" (id, name) VALUES (?, ?)", 1, 7, 7, "a")
+	check("INSERT INTO "+table+" (name) VALUES (?), (?), (?)", 3, 10, "b", "c", "d")
+	// LastInsertId is only meaningful after an INSERT, so it is not checked below
+	check("UPDATE "+table+" SET name = ? WHERE id > ?", 3, -1, "x", 7)
+	check("UPDATE "+table+" SET name = ? WHERE id = ?", 0, -1, "y", 999)
+	check("DELETE FROM "+table+" WHERE id IN (?, ?)", 2, -1, 7, 8)
+
+	n, err := q.Exec("DELETE FROM " + table)
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Exec returned %d, want 2 rows affected", n)
+	}
+}
+
+// testBatch checks that a batch returns one result per statement, in order
+func testBatch(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, name TEXT)")
+
+	results, err := q.Batch([]utils.Statement{
+		{SQL: "INSERT INTO " + table + " (id, name) VALUES (?, ?)", Params: []interface{}{1, "a"}},
+		{SQL: "INSERT INTO " + table + " (id, name) VALUES (?, ?), (?, ?)", Params: []interface{}{2, "b", 3, "c"}},
+		{SQL: "SELECT name FROM " + table + " WHERE id > ? ORDER BY id", Params: []interface{}{1}},
+	})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	for i, want := range []int64{1, 2} {
+		if n, _ := results[i].Result.RowsAffected(); n != want {
+			t.Errorf("statement %d: RowsAffected = %d, want %d", i, n, want)
+		}
+	}
+	if id, _ := results[1].Result.LastInsertId(); id != 3 {
+		t.Errorf("statement 1: LastInsertId = %d, want 3", id)
+	}
+
+	var names []struct {
+		Name string `db:"name"`
+	}
+	if err := results[2].Rows.StructScanAll(&names); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if len(names) != 2 || names[0].Name != "b" || names[1].Name != "c" {
+		t.Errorf("SELECT in batch returned %+v, want b and c", names)
+	}
+}
+
+// testBatchIsAtomic checks that a failing statement rolls back the whole batch
+func testBatchIsAtomic(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+
+	_, err := q.Batch([]utils.Statement{
+		{SQL: "INSERT INTO " + table + " (id, name) VALUES (?, ?)", Params: []interface{}{1, "a"}},
+		{SQL: "INSERT INTO " + table + " (id, name) VALUES (?, NULL)", Params: []interface{}{2}},
+	})
+	if err == nil {
+		t.Fatal("expected an error for the NOT NULL violation")
+	}
+	if n := count(t, q, table, ""); n != 0 {
+		t.Errorf("%d rows applied by the failed batch, want 0", n)
+	}
+}
+
+// testMultipleStatements checks that a query of several statements runs all
+// of them and answers with the result of the last one
+func testMultipleStatements(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, name TEXT)")
+
+	res, err := q.Query("INSERT INTO "+table+" (id, name) VALUES (1, 'a'); UPDATE "+table+" SET name = 'b' WHERE id = 1; SELECT id, name FROM "+table+";", nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	rows, err := res.ToRows()
+	if err != nil {
+		t.Fatalf("ToRows failed: %v", err)
+	}
+	defer rows.Close()
+
+	var got []struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	if err := rows.StructScanAll(&got); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 1 || got[0].Name != "b" {
+		t.Errorf("got %+v, want the row after the UPDATE", got)
+	}
+}
+
+// testScanRoundTrip checks that rows written through the Queryer read back
+// the same through every scan destination
+func testScanRoundTrip(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	mustExec(t, q, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, user_name TEXT, score REAL, active INTEGER)")
+
+	type user struct {
+		ID     int64   `db:"id"`
+		Name   string  `db:"user_name"`
+		Score  float64 `db:"score"`
+		Active bool    `db:"active"`
+	}
+	want := []user{
+		{ID: 1, Name: "Alice", Score: 9.5, Active: true},
+		{ID: 2, Name: "", Score: 0, Active: false},
+		{ID: 3, Name: strings.Repeat("x", 1000), Score: -1.25, Active: true},
+	}
+	for _, u := range want {
+		mustExec(t, q, "INSERT INTO "+table+" (id, user_name, score, active) VALUES (?, ?, ?, ?)", u.ID, u.Name, u.Score, u.Active)
+	}
+	query := "SELECT id, user_name, score, active FROM " + table + " ORDER BY id"
+
+	var values []user
+	if err := q.Select(&values, query); err != nil {
+		t.Fatalf("Select into []T failed: %v", err)
+	}
+	var pointers []*user
+	if err := q.Select(&pointers, query); err != nil {
+		t.Fatalf("Select into []*T failed: %v", err)
+	}
+	if len(values) != len(want) || len(pointers) != len(want) {
+		t.Fatalf("got %d and %d rows, want %d", len(values), len(pointers), len(want))
+	}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Errorf("[]T row %d = %+v, want %+v", i, values[i], want[i])
+		}
+		if *pointers[i] != want[i] {
+			t.Errorf("[]*T row %d = %+v, want %+v", i, *pointers[i], want[i])
+		}
+	}
+
+	var one user
+	if err := q.Get(&one, query+" LIMIT 1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if one != want[0] {
+		t.Errorf("Get = %+v, want %+v", one, want[0])
+	}
+
+	res, err := q.Query(query, nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	rows, err := res.ToRows()
+	if err != nil {
+		t.Fatalf("ToRows failed: %v", err)
+	}
+	defer rows.Close()
+	if cols, _ := rows.Columns(); strings.Join(cols, ",") != "id,user_name,score,active" {
+		t.Errorf("Columns = %v", cols)
+	}
+	for i := 0; rows.Next(); i++ {
+		var u user
+		if err := rows.Scan(&u.ID, &u.Name, &u.Score, &u.Active); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if i >= len(want) || u != want[i] {
+			t.Errorf("Scan row %d = %+v", i, u)
+		}
+	}
+}
+
+// testInvalidSQL checks that errors reported by the database are returned.
+// Query returns the response as is, so its error may also be in the response.
+func testInvalidSQL(t *testing.T, q cloudflare_d1_go.Queryer, table string) {
+	if _, err := q.Exec("INSERT INTO " + table + " (id) VALUES (1)"); err == nil {
+		t.Error("Exec on a missing table succeeded")
+	}
+
+	var rows []map[string]interface{}
+	if err := q.Select(&rows, "SELEKT 1"); err == nil {
+		t.Error("Select with a syntax error succeeded")
+	}
+
+	res, err := q.Query("SELEKT 1", nil)
+	if err == nil && res.Err() == nil {
+		t.Error("Query with a syntax error succeeded")
+	}
+}
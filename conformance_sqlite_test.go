@@ -0,0 +1,206 @@
+//go:build cgo
+
+package cloudflared1_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/conformance"
+)
+
+// TestConformanceFakeServer runs the conformance suite against a fake D1
+// server backed by an in-memory SQLite database
+func TestConformanceFakeServer(t *testing.T) {
+	serveSQLite(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	t.Run("Client", func(t *testing.T) {
+		conformance.RunQueryerTests(t, func() cloudflare_d1_go.Queryer { return client })
+	})
+	t.Run("Pool", func(t *testing.T) {
+		conformance.RunQueryerTests(t, func() cloudflare_d1_go.Queryer { return pool })
+	})
+}
+
+// serveSQLite answers the raw query endpoint like D1 does, from an in-memory
+// SQLite database. Each request runs in one transaction, so a failing
+// statement rolls back the whole request.
+func serveSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/raw") {
+			writeJSON(w, errorResponse(7400, "not found"))
+			return
+		}
+
+		type statement struct {
+			SQL    string   `json:"sql"`
+			Params []string `json:"params"`
+		}
+		var body struct {
+			statement
+			Batch []statement `json:"batch"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, errorResponse(7400, err.Error()))
+			return
+		}
+		statements := body.Batch
+		if statements == nil {
+			statements = []statement{body.statement}
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			writeJSON(w, errorResponse(7500, err.Error()))
+			return
+		}
+		var items []interface{}
+		for _, stmt := range statements {
+			parts := splitStatements(stmt.SQL)
+			for _, part := range parts {
+				var params []string
+				if len(parts) == 1 {
+					params = stmt.Params
+				}
+				item, err := runStatement(tx, part, params)
+				if err != nil {
+					_ = tx.Rollback()
+					writeJSON(w, errorResponse(7500, err.Error()))
+					return
+				}
+				items = append(items, item)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			writeJSON(w, errorResponse(7500, err.Error()))
+			return
+		}
+		writeJSON(w, successResponse(items...))
+	})
+}
+
+// runStatement executes one statement and builds its /raw result item.
+// Params are bound as text, as D1 receives them.
+func runStatement(tx *sql.Tx, query string, params []string) (map[string]interface{}, error) {
+	var before int64
+	if err := tx.QueryRow("SELECT total_changes()").Scan(&before); err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(params))
+	for i, p := range params {
+		args[i] = p
+	}
+	rs, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rs.Columns()
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+	var rows [][]interface{}
+	for rs.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rs.Scan(pointers...); err != nil {
+			rs.Close()
+			return nil, err
+		}
+		for i, v := range values {
+			// D1 returns blobs as arrays of bytes
+			if b, ok := v.([]byte); ok {
+				ints := make([]int, len(b))
+				for j := range b {
+					ints[j] = int(b[j])
+				}
+				values[i] = ints
+			}
+		}
+		rows = append(rows, values)
+	}
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	var changes, lastRowID, after int64
+	if err := tx.QueryRow("SELECT changes(), last_insert_rowid(), total_changes()").Scan(&changes, &lastRowID, &after); err != nil {
+		return nil, err
+	}
+	if columns == nil {
+		columns = []string{}
+	}
+	return queryResult(columns, rows, map[string]interface{}{
+		"changes":      changes,
+		"last_row_id":  lastRowID,
+		"rows_read":    len(rows),
+		"rows_written": after - before,
+		"changed_db":   after > before,
+	}), nil
+}
+
+// splitStatements splits SQL text into its non-empty statements. Semicolons
+// in string literals, quoted identifiers and comments do not split.
+func splitStatements(query string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			for i++; i < len(query) && query[i] != end; i++ {
+			}
+		case strings.HasPrefix(query[i:], "--"):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		case c == ';':
+			parts = append(parts, query[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, query[start:])
+
+	statements := parts[:0]
+	for _, p := range parts {
+		if strings.TrimSpace(p) != "" {
+			statements = append(statements, p)
+		}
+	}
+	return statements
+}
@@ -0,0 +1,40 @@
+package cloudflared1_test
+
+import (
+	"os"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/conformance"
+)
+
+// TestConformanceRealAPI runs the conformance suite against a real D1
+// database. It creates and drops its own tables in CLOUDFLARE_DB_NAME.
+func TestConformanceRealAPI(t *testing.T) {
+	accountID := os.Getenv("CLOUDFLARE_ACCOUNT_ID")
+	apiToken := os.Getenv("CLOUDFLARE_API_TOKEN")
+	dbName := os.Getenv("CLOUDFLARE_DB_NAME")
+	if accountID == "" || apiToken == "" || dbName == "" {
+		t.Skip("set CLOUDFLARE_ACCOUNT_ID, CLOUDFLARE_API_TOKEN and CLOUDFLARE_DB_NAME to run against the real API")
+	}
+
+	client := cloudflare_d1_go.NewClient(accountID, apiToken)
+	if err := client.ConnectDB(dbName); err != nil {
+		t.Fatalf("ConnectDB failed: %v", err)
+	}
+	pool := cloudflare_d1_go.NewConnectionPool(accountID, apiToken)
+	if err := pool.Connect(dbName); err != nil {
+		t.Fatalf("pool.Connect failed: %v", err)
+	}
+
+	t.Run("Client", func(t *testing.T) {
+		conformance.RunQueryerTests(t, func() cloudflare_d1_go.Queryer { return client })
+	})
+	t.Run("Pool", func(t *testing.T) {
+		conformance.RunQueryerTests(t, func() cloudflare_d1_go.Queryer { return pool })
+	})
+	t.Run("Session", func(t *testing.T) {
+		session := client.NewSession(cloudflare_d1_go.SessionFirstPrimary)
+		conformance.RunQueryerTests(t, func() cloudflare_d1_go.Queryer { return session })
+	})
+}
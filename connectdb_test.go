@@ -0,0 +1,68 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestConnectDBAPIError(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, errorResponse(10000, "Authentication error"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "bad_token")
+	err := client.ConnectDB("app")
+
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 10000 {
+		t.Fatalf("ConnectDB = %v, want *utils.APIError with code 10000", err)
+	}
+	if !strings.Contains(err.Error(), "Authentication error") {
+		t.Errorf("error %q does not carry the API message", err)
+	}
+}
+
+func TestConnectDBUnexpectedResponse(t *testing.T) {
+	tests := map[string]interface{}{
+		"missing uuid":  successResponse(map[string]interface{}{"name": "app"}),
+		"not an array":  map[string]interface{}{"result": map[string]interface{}{"name": "app"}, "success": true},
+		"wrong types":   successResponse(map[string]interface{}{"name": 7, "uuid": true}),
+		"non-object db": successResponse("app"),
+	}
+	for name, payload := range tests {
+		mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, payload)
+		})
+
+		client := cloudflare_d1_go.NewClient("account_id", "api_token")
+		err := client.ConnectDB("app")
+		if err == nil || !strings.Contains(err.Error(), "unexpected list response shape") {
+			t.Errorf("%s: ConnectDB = %v, want unexpected shape error", name, err)
+		}
+		if client.DatabaseID != "" {
+			t.Errorf("%s: DatabaseID = %q, want empty", name, client.DatabaseID)
+		}
+	}
+}
+
+func TestConnectDBEmptyResult(t *testing.T) {
+	for _, payload := range []interface{}{
+		map[string]interface{}{"result": []interface{}{}, "success": true},
+		map[string]interface{}{"result": nil, "success": true},
+	} {
+		mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, payload)
+		})
+
+		client := cloudflare_d1_go.NewClient("account_id", "api_token")
+		err := client.ConnectDB("app")
+		if err == nil || !strings.Contains(err.Error(), "database with name app not found") || !errors.Is(err, cloudflare_d1_go.ErrDatabaseNotFound) {
+			t.Errorf("ConnectDB = %v, want not found error", err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveCounts answers COUNT queries with 3, and queries mentioning
+// "missing" with no rows
+func serveCounts(t *testing.T) *[]sentStatement {
+	var sent []sentStatement
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, map[string]interface{}{"result": []interface{}{map[string]interface{}{"uuid": "db-1", "name": "main"}}, "success": true})
+			return
+		}
+		var stmt sentStatement
+		_ = json.NewDecoder(r.Body).Decode(&stmt)
+		sent = append(sent, stmt)
+		switch {
+		case strings.Contains(stmt.SQL, "missing"):
+			writeJSON(w, successResponse(queryResult([]string{"1"}, nil, nil)))
+		case strings.Contains(stmt.SQL, "COUNT"):
+			writeJSON(w, successResponse(queryResult([]string{"COUNT(*)"}, [][]interface{}{{3}}, nil)))
+		default:
+			writeJSON(w, successResponse(queryResult([]string{"1"}, [][]interface{}{{1}}, nil)))
+		}
+	})
+	return &sent
+}
+
+func TestCount(t *testing.T) {
+	sent := serveCounts(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	n, err := client.Count("SELECT COUNT(*) FROM users WHERE age > ?", 25)
+	if err != nil || n != 3 {
+		t.Errorf("Count = %d, %v; want 3", n, err)
+	}
+	n, err = client.CountTable("users", "active = ?", true)
+	if err != nil || n != 3 {
+		t.Errorf("CountTable = %d, %v; want 3", n, err)
+	}
+	_, _ = client.CountTable("users", "")
+
+	want := []sentStatement{
+		{SQL: "SELECT COUNT(*) FROM users WHERE age > ?", Params: []string{"25"}},
+		{SQL: `SELECT COUNT(*) FROM "users" WHERE active = ?`, Params: []string{"1"}},
+		{SQL: `SELECT COUNT(*) FROM "users"`, Params: []string{}},
+	}
+	if !reflect.DeepEqual(*sent, want) {
+		t.Errorf("sent %+v\nwant %+v", *sent, want)
+	}
+
+	if _, err := client.Count("SELECT id FROM missing"); !errors.Is(err, utils.ErrNoRows) {
+		t.Errorf("Count without rows: err = %v, want ErrNoRows", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	sent := serveCounts(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	found, err := client.Exists("SELECT id FROM users WHERE email = ?;", "alice@example.com")
+	if err != nil || !found {
+		t.Errorf("Exists = %v, %v; want true", found, err)
+	}
+	if got := (*sent)[0].SQL; got != "SELECT 1 FROM (SELECT id FROM users WHERE email = ?) LIMIT 1" {
+		t.Errorf("SQL = %s", got)
+	}
+
+	found, err = client.Exists("SELECT id FROM missing")
+	if err != nil || found {
+		t.Errorf("Exists = %v, %v; want false", found, err)
+	}
+}
+
+func TestPoolCountAndExists(t *testing.T) {
+	serveCounts(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	if err := pool.Connect("main"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if n, err := pool.CountTable("users", ""); err != nil || n != 3 {
+		t.Errorf("CountTable = %d, %v; want 3", n, err)
+	}
+	if found, err := pool.Exists("SELECT id FROM users"); err != nil || !found {
+		t.Errorf("Exists = %v, %v; want true", found, err)
+	}
+}
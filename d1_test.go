@@ -1,10 +1,17 @@
 package cloudflared1_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
-	cloudflare_d1_go "github.com/ashayas/cloudflare-d1-go/client"
-	"github.com/ashayas/cloudflare-d1-go/utils"
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
 )
 
 func TestNewClient(t *testing.T) {
@@ -60,9 +67,147 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestNewReportsInvalidCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		accountID string
+		apiToken  string
+		opts      cloudflare_d1_go.ClientOptions
+		wantErr   string
+	}{
+		{name: "valid", accountID: testAccountID, apiToken: "s3cr3t"},
+		{name: "upper-case account ID", accountID: strings.ToUpper(testAccountID), apiToken: "s3cr3t"},
+		{name: "auth instead of token", accountID: testAccountID, opts: cloudflare_d1_go.ClientOptions{Auth: utils.BearerToken("s3cr3t")}},
+		{name: "empty account ID", apiToken: "s3cr3t", wantErr: "account ID is empty"},
+		{name: "short account ID", accountID: "1234567890", apiToken: "s3cr3t", wantErr: "got 10 characters"},
+		{name: "non-hex account ID", accountID: strings.Repeat("g", 32), apiToken: "s3cr3t", wantErr: "hex string"},
+		{name: "empty token", accountID: testAccountID, wantErr: "API token is empty"},
+		{name: "trailing newline", accountID: testAccountID, apiToken: "s3cr3t\n", wantErr: "whitespace"},
+		{name: "bearer prefix", accountID: testAccountID, apiToken: "Bearer s3cr3t", wantErr: "whitespace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := cloudflare_d1_go.New(tt.accountID, tt.apiToken, tt.opts)
+			pool, poolErr := cloudflare_d1_go.NewPool(tt.accountID, tt.apiToken, tt.opts)
+			if tt.wantErr == "" {
+				if err != nil || client == nil || poolErr != nil || pool == nil {
+					t.Fatalf("New = %v, NewPool = %v, want no error", err, poolErr)
+				}
+				if client.AccountID != tt.accountID {
+					t.Errorf("AccountID = %q, want %q", client.AccountID, tt.accountID)
+				}
+				return
+			}
+			for _, err := range []error{err, poolErr} {
+				if !errors.Is(err, cloudflare_d1_go.ErrInvalidCredentials) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want ErrInvalidCredentials with %q", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "s3cr3t") {
+					t.Errorf("error %q contains the token", err)
+				}
+			}
+			if client != nil || pool != nil {
+				t.Error("constructor returned a value with an error")
+			}
+		})
+	}
+}
+
+// fakeAPI is an in-memory stand-in for the D1 management and raw query
+// endpoints, served by an httptest server
+type fakeAPI struct {
+	mu        sync.Mutex
+	databases map[string]string // uuid to name
+	tables    map[string]bool   // uuid + "/" + table name
+	rows      map[string][][]interface{}
+}
+
+// newFakeAPIClient returns a client whose requests go to a fakeAPI
+func newFakeAPIClient(t *testing.T) *cloudflare_d1_go.Client {
+	t.Helper()
+	api := &fakeAPI{databases: map[string]string{}, tables: map[string]bool{}, rows: map[string][][]interface{}{}}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	return cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		HTTPClient: srv.Client(),
+		BaseURL:    srv.URL + "/client/v4",
+	})
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/client/v4/accounts/account_id/d1/database")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		list := []interface{}{}
+		for id, name := range f.databases {
+			list = append(list, map[string]interface{}{"uuid": id, "name": name})
+		}
+		writeJSON(w, map[string]interface{}{"result": list, "success": true, "errors": []interface{}{}})
+	case path == "" && r.Method == http.MethodPost:
+		var body struct {
+			Name string `json:"name"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		id := fmt.Sprintf("uuid-%d", len(f.databases)+1)
+		f.databases[id] = body.Name
+		writeJSON(w, map[string]interface{}{"result": map[string]interface{}{"uuid": id, "name": body.Name}, "success": true, "errors": []interface{}{}})
+	case f.databases[parts[0]] == "":
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, errorResponse(7404, "database not found"))
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		delete(f.databases, parts[0])
+		writeJSON(w, map[string]interface{}{"result": nil, "success": true, "errors": []interface{}{}})
+	case len(parts) == 2 && parts[1] == "raw":
+		var body struct {
+			SQL    string   `json:"sql"`
+			Params []string `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, f.query(parts[0], body.SQL, body.Params))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, errorResponse(7400, "unexpected request "+r.Method+" "+r.URL.Path))
+	}
+}
+
+// query understands just the statements of the tests below
+func (f *fakeAPI) query(db, sql string, params []string) map[string]interface{} {
+	fields := strings.Fields(strings.Trim(sql, ";"))
+	switch {
+	case strings.HasPrefix(sql, "CREATE TABLE"):
+		f.tables[db+"/"+fields[5]] = true
+	case strings.HasPrefix(sql, "DROP TABLE"):
+		table := strings.Trim(fields[4], `"`)
+		delete(f.tables, db+"/"+table)
+		delete(f.rows, db+"/"+table)
+	case strings.HasPrefix(sql, "INSERT INTO"):
+		if !f.tables[db+"/"+fields[2]] {
+			return errorResponse(7500, "no such table: "+fields[2])
+		}
+		key := db + "/" + fields[2]
+		f.rows[key] = append(f.rows[key], []interface{}{len(f.rows[key]) + 1, params[0]})
+		return successResponse(queryResult(nil, nil, map[string]interface{}{"changes": 1, "last_row_id": len(f.rows[key])}))
+	case strings.HasPrefix(sql, "SELECT"):
+		var rows [][]interface{}
+		for _, row := range f.rows[db+"/"+fields[3]] {
+			if row[1] == params[0] {
+				rows = append(rows, row)
+			}
+		}
+		return successResponse(queryResult([]string{"id", "name"}, rows, nil))
+	}
+	return successResponse(queryResult(nil, nil, nil))
+}
+
 // TestListDB lists the databases
 func TestListDB(t *testing.T) {
-	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client := newFakeAPIClient(t)
 	res, err := client.ListDB()
 	if err != nil {
 		t.Errorf("ListDB failed: %v", err)
@@ -76,7 +221,7 @@ func TestListDB(t *testing.T) {
 
 // TestCreateAndDeleteDB creates a database, then deletes it
 func TestCreateAndDeleteDB(t *testing.T) {
-	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client := newFakeAPIClient(t)
 	res, err := client.CreateDB("test-db-2")
 	if err != nil {
 		t.Errorf("CreateDB failed: %v", err)
@@ -104,7 +249,7 @@ func TestCreateAndDeleteDB(t *testing.T) {
 
 // TestCreateAndRemoveTable creates a table, then removes it
 func TestCreateAndRemoveTable(t *testing.T) {
-	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client := newFakeAPIClient(t)
 
 	// Create a test database
 	res, err := client.CreateDB("test_db_3")
@@ -153,7 +298,7 @@ func TestCreateAndRemoveTable(t *testing.T) {
 
 // TestQueryDB creates a table, inserts a row, then selects it and deletes the table and database
 func TestQueryDB(t *testing.T) {
-	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client := newFakeAPIClient(t)
 
 	// Create a test database
 	res, err := client.CreateDB("test_db_6")
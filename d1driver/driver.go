@@ -0,0 +1,354 @@
+// Package d1driver registers a "d1" driver with database/sql, so that the
+// standard library, sqlx and other database/sql tooling can talk to Cloudflare D1.
+//
+//	import _ "github.com/youfun/cloudflare-d1-go/d1driver"
+//
+//	db, err := sql.Open("d1", "d1://ACCOUNT_ID:API_TOKEN@DB_NAME")
+//
+// The database part of the DSN is a database name, which is resolved to its ID
+// on the first connection, or a database UUID, which is used as is.
+// D1 has no interactive transactions, so Begin returns ErrTxNotSupported;
+// use Client.Batch to apply several statements atomically.
+//
+// Values in result rows are mapped to driver values as follows:
+//
+//	JSON null                     nil
+//	whole number                  int64
+//	other number                  float64
+//	string                        string
+//	array of bytes (BLOB)         []byte
+//	anything else                 []byte holding the JSON encoding
+//
+// Importing the package also enables Client.SQLRows.
+package d1driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	cloudflared1 "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// ErrTxNotSupported is returned by Begin, because D1 has no interactive transactions
+var ErrTxNotSupported = errors.New("d1driver: transactions are not supported, use Client.Batch")
+
+func init() {
+	sql.Register("d1", &Driver{})
+	cloudflared1.RegisterSQLConnector(NewConnector)
+}
+
+// Config is a parsed DSN
+type Config struct {
+	AccountID string
+	APIToken  string
+	// Database is a database name or UUID
+	Database string
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsUUID reports whether Database is a database ID rather than a name
+func (c *Config) IsUUID() bool {
+	return uuidPattern.MatchString(c.Database)
+}
+
+// ParseDSN parses a DSN of the form d1://ACCOUNT_ID:API_TOKEN@DB_NAME_OR_UUID
+func ParseDSN(dsn string) (*Config, error) {
+	rest, ok := strings.CutPrefix(dsn, "d1://")
+	if !ok {
+		return nil, fmt.Errorf("d1driver: DSN must start with d1://")
+	}
+
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return nil, fmt.Errorf("d1driver: DSN must look like d1://ACCOUNT_ID:API_TOKEN@DB_NAME")
+	}
+	accountID, apiToken, _ := strings.Cut(rest[:at], ":")
+
+	database, err := url.PathUnescape(strings.TrimSuffix(rest[at+1:], "/"))
+	if err != nil {
+		return nil, fmt.Errorf("d1driver: invalid database in DSN: %w", err)
+	}
+
+	cfg := &Config{AccountID: accountID, APIToken: apiToken, Database: database}
+	if cfg.AccountID == "" || cfg.APIToken == "" || cfg.Database == "" {
+		return nil, fmt.Errorf("d1driver: DSN must contain account ID, API token and database")
+	}
+	return cfg, nil
+}
+
+// Driver is the database/sql driver registered as "d1"
+type Driver struct{}
+
+// Open returns a connection for a DSN, see ParseDSN
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{cfg: cfg}, nil
+}
+
+// NewConnector returns a connector for an existing client, for use with sql.OpenDB.
+// The client must be connected to a database.
+//
+//	db := sql.OpenDB(d1driver.NewConnector(client))
+func NewConnector(client *cloudflared1.Client) driver.Connector {
+	return &connector{client: client}
+}
+
+type connector struct {
+	cfg *Config
+
+	mu     sync.Mutex
+	client *cloudflared1.Client
+}
+
+// Connect resolves the database once and hands out connections sharing the client
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == nil {
+		client, err := cloudflared1.New(c.cfg.AccountID, c.cfg.APIToken, cloudflared1.ClientOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("d1driver: %w", err)
+		}
+		if c.cfg.IsUUID() {
+			client.DatabaseID = c.cfg.Database
+		} else if err := client.ConnectDB(c.cfg.Database); err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+
+	if c.client.DatabaseID == "" {
+		return nil, fmt.Errorf("d1driver: client is not connected to a database")
+	}
+	return &conn{client: c.client}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+// conn is a connection. D1 is stateless HTTP, so a connection is just the client.
+type conn struct {
+	client *cloudflared1.Client
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, ErrTxNotSupported
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nil, ErrTxNotSupported
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	params, err := positional(args)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.client.ExecResultContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	params, err := positional(args)
+	if err != nil {
+		return nil, err
+	}
+
+	query, converted, err := utils.BindParams(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.QueryContext(ctx, query, converted)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := res.ToRows()
+	if err != nil {
+		return nil, err
+	}
+	columns, _ := r.Columns()
+	return &rows{rows: r, columns: columns}, nil
+}
+
+// positional converts driver arguments to the positional arguments D1 accepts
+func positional(args []driver.NamedValue) ([]interface{}, error) {
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("d1driver: named parameter %s is not supported, use ?", arg.Name)
+		}
+		params[i] = arg.Value
+	}
+	return params, nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1, because placeholders are counted by D1
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+// rows adapts utils.Rows to driver.Rows
+type rows struct {
+	rows    *utils.Rows
+	columns []string
+	types   []*utils.ColumnType
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+// columnType returns the type of column i, inferred from the values as
+// utils.Rows.ColumnTypes does
+func (r *rows) columnType(i int) *utils.ColumnType {
+	if r.types == nil {
+		r.types, _ = r.rows.ColumnTypes()
+	}
+	return r.types[i]
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(i int) string {
+	return r.columnType(i).DatabaseTypeName()
+}
+
+func (r *rows) ColumnTypeNullable(i int) (nullable, ok bool) {
+	return r.columnType(i).Nullable()
+}
+
+func (r *rows) ColumnTypeScanType(i int) reflect.Type {
+	return r.columnType(i).ScanType()
+}
+
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		return io.EOF
+	}
+
+	raw := make([]interface{}, len(r.columns))
+	ptrs := make([]interface{}, len(raw))
+	for i := range raw {
+		ptrs[i] = &raw[i]
+	}
+	if err := r.rows.Scan(ptrs...); err != nil {
+		return err
+	}
+
+	for i, v := range raw {
+		dest[i] = driverValue(v)
+	}
+	return nil
+}
+
+// driverValue maps a JSON decoded D1 value to a driver.Value, see the package documentation
+func driverValue(v interface{}) driver.Value {
+	switch v := v.(type) {
+	case nil, string, bool:
+		return v
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	case []interface{}:
+		if b, ok := blobValue(v); ok {
+			return b
+		}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return b
+}
+
+// blobValue converts the JSON array D1 uses for BLOB columns to bytes
+func blobValue(values []interface{}) ([]byte, bool) {
+	b := make([]byte, len(values))
+	for i, v := range values {
+		f, ok := v.(float64)
+		if !ok || f < 0 || f > 255 || f != math.Trunc(f) {
+			return nil, false
+		}
+		b[i] = byte(f)
+	}
+	return b, true
+}
@@ -0,0 +1,167 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/d1driver"
+)
+
+// serveDriver mocks the database list and raw query endpoints for driver tests
+// and records the paths of all requests.
+func serveDriver(t *testing.T) *[]string {
+	t.Helper()
+	var paths []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+
+		if r.Method == http.MethodGet {
+			writeJSON(w, successResponse(map[string]interface{}{"name": "app", "uuid": "11111111-2222-3333-4444-555555555555"}))
+			return
+		}
+
+		var body struct {
+			SQL    string   `json:"sql"`
+			Params []string `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		if strings.HasPrefix(body.SQL, "SELECT") {
+			writeJSON(w, successResponse(queryResult(
+				[]string{"id", "name", "score", "note"},
+				[][]interface{}{{1, "Alice", 9.5, nil}, {2, "Bob", 7, "hi"}},
+				map[string]interface{}{"rows_read": float64(2)},
+			)))
+			return
+		}
+		writeJSON(w, successResponse(queryResult(nil, nil, map[string]interface{}{"last_row_id": float64(3), "changes": float64(1)})))
+	})
+	return &paths
+}
+
+func TestParseDSN(t *testing.T) {
+	cfg, err := d1driver.ParseDSN("d1://acc:tok@my_db")
+	if err != nil {
+		t.Fatalf("ParseDSN failed: %v", err)
+	}
+	want := &d1driver.Config{AccountID: "acc", APIToken: "tok", Database: "my_db"}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ParseDSN = %+v, want %+v", cfg, want)
+	}
+	if cfg.IsUUID() {
+		t.Error("database name reported as UUID")
+	}
+
+	cfg, err = d1driver.ParseDSN("d1://acc:tok@11111111-2222-3333-4444-555555555555")
+	if err != nil {
+		t.Fatalf("ParseDSN failed: %v", err)
+	}
+	if !cfg.IsUUID() {
+		t.Error("database UUID not recognized")
+	}
+
+	for _, dsn := range []string{"postgres://acc:tok@db", "d1://acc:tok", "d1://acc@db", "d1://:tok@db", "d1://acc:tok@"} {
+		if _, err := d1driver.ParseDSN(dsn); err == nil {
+			t.Errorf("ParseDSN(%q) succeeded, want error", dsn)
+		}
+	}
+}
+
+func TestDriverQueryContext(t *testing.T) {
+	paths := serveDriver(t)
+
+	db, err := sql.Open("d1", "d1://"+testAccountID+":tok@app")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT id, name, score, note FROM users WHERE id > ?", 0)
+	if err != nil {
+		t.Fatalf("QueryContext failed: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Columns failed: %v", err)
+	}
+	if !reflect.DeepEqual(columns, []string{"id", "name", "score", "note"}) {
+		t.Errorf("Columns = %v", columns)
+	}
+
+	type row struct {
+		id    int64
+		name  string
+		score float64
+		note  sql.NullString
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.name, &r.score, &r.note); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows.Err: %v", err)
+	}
+	want := []row{
+		{1, "Alice", 9.5, sql.NullString{}},
+		{2, "Bob", 7, sql.NullString{String: "hi", Valid: true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %+v, want %+v", got, want)
+	}
+
+	// The name is resolved once, queries go to the resolved ID
+	var r row
+	if err := db.QueryRow("SELECT id, name, score, note FROM users").Scan(&r.id, &r.name, &r.score, &r.note); err != nil {
+		t.Fatalf("QueryRow failed: %v", err)
+	}
+	wantPaths := []string{
+		"GET /client/v4/accounts/" + testAccountID + "/d1/database",
+		"POST /client/v4/accounts/" + testAccountID + "/d1/database/11111111-2222-3333-4444-555555555555/raw",
+		"POST /client/v4/accounts/" + testAccountID + "/d1/database/11111111-2222-3333-4444-555555555555/raw",
+	}
+	if !reflect.DeepEqual(*paths, wantPaths) {
+		t.Errorf("paths = %v, want %v", *paths, wantPaths)
+	}
+}
+
+func TestDriverExecContext(t *testing.T) {
+	paths := serveDriver(t)
+
+	db, err := sql.Open("d1", "d1://"+testAccountID+":tok@11111111-2222-3333-4444-555555555555")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	result, err := db.ExecContext(context.Background(), "INSERT INTO users (name) VALUES (?)", "Carol")
+	if err != nil {
+		t.Fatalf("ExecContext failed: %v", err)
+	}
+	if id, _ := result.LastInsertId(); id != 3 {
+		t.Errorf("LastInsertId = %d, want 3", id)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("RowsAffected = %d, want 1", n)
+	}
+
+	// A UUID DSN needs no lookup
+	if len(*paths) != 1 || !strings.HasPrefix((*paths)[0], "POST ") {
+		t.Errorf("paths = %v, want a single query", *paths)
+	}
+
+	if _, err := db.Begin(); !errors.Is(err, d1driver.ErrTxNotSupported) {
+		t.Errorf("Begin = %v, want ErrTxNotSupported", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package d1otel traces the queries of cloudflare-d1-go clients and pools
+// with OpenTelemetry. It is a module of its own, so the client does not
+// depend on the OpenTelemetry libraries.
+//
+//	client, err := cloudflared1.New(accountID, apiToken, cloudflared1.ClientOptions{
+//		Tracer: d1otel.NewTracer(otel.GetTracerProvider(), d1otel.Options{}),
+//	})
+//	client.SelectContext(ctx, &users, "SELECT * FROM users")
+package d1otel
+
+import (
+	"context"
+	"unicode/utf8"
+
+	cloudflared1 "github.com/youfun/cloudflare-d1-go/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer spans are created with
+const instrumentationName = "github.com/youfun/cloudflare-d1-go/d1otel"
+
+// DefaultMaxStatementLength is the length db.statement is truncated to
+// unless Options.MaxStatementLength is set
+const DefaultMaxStatementLength = 2048
+
+// Options holds the optional settings of NewTracer
+type Options struct {
+	// MaxStatementLength truncates db.statement to as many bytes.
+	// Zero means DefaultMaxStatementLength.
+	MaxStatementLength int
+	// OmitStatement leaves out db.statement, e.g. if table names are sensitive.
+	// Parameter values are never recorded.
+	OmitStatement bool
+}
+
+// Attribute keys set on the spans besides the db.* conventions
+const (
+	RayIDKey       = attribute.Key("cloudflare.ray_id")
+	RowsReadKey    = attribute.Key("d1.rows_read")
+	RowsWrittenKey = attribute.Key("d1.rows_written")
+)
+
+type tracer struct {
+	tracer trace.Tracer
+	opts   Options
+}
+
+// NewTracer returns a cloudflared1.Tracer that records every query as a span
+// named "d1.query", and every batch as "d1.batch", with tracers of provider
+func NewTracer(provider trace.TracerProvider, opts Options) cloudflared1.Tracer {
+	if opts.MaxStatementLength <= 0 {
+		opts.MaxStatementLength = DefaultMaxStatementLength
+	}
+	return &tracer{tracer: provider.Tracer(instrumentationName), opts: opts}
+}
+
+func (t *tracer) StartQuery(ctx context.Context, query cloudflared1.TracedQuery) (context.Context, func(cloudflared1.TraceResult)) {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "cloudflare_d1"),
+		attribute.String("db.name", query.DatabaseID),
+		attribute.String("db.operation", query.Operation),
+	}
+	if !t.opts.OmitStatement {
+		attrs = append(attrs, attribute.String("db.statement", truncate(query.SQL, t.opts.MaxStatementLength)))
+	}
+
+	ctx, span := t.tracer.Start(ctx, "d1."+query.Operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, func(result cloudflared1.TraceResult) {
+		if result.RayID != "" {
+			span.SetAttributes(RayIDKey.String(result.RayID))
+		}
+		span.SetAttributes(RowsReadKey.Int64(result.RowsRead), RowsWrittenKey.Int64(result.RowsWritten))
+		if result.Err != nil {
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
+		}
+		span.End()
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,121 @@
+package d1otel_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudflared1 "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/d1otel"
+	"github.com/youfun/cloudflare-d1-go/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newClient returns a client traced into a span recorder, talking to a
+// server that answers queries on db-1 and fails everything else
+func newClient(t *testing.T, opts d1otel.Options) (*cloudflared1.Client, *tracetest.SpanRecorder) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("cf-ray", "8f00aa11bb22cc33-FRA")
+		if !strings.Contains(r.URL.Path, "/db-1/") {
+			_, _ = w.Write([]byte(`{"result":null,"success":false,"errors":[{"code":7404,"message":"database not found"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":[{"results":{"columns":["id"],"rows":[[1]]},"meta":{"rows_read":12,"rows_written":0},"success":true}],"success":true,"errors":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := cloudflared1.NewClientWithOptions("account_id", "api_token", cloudflared1.ClientOptions{
+		BaseURL:    srv.URL,
+		HTTPClient: srv.Client(),
+		Tracer:     d1otel.NewTracer(provider, opts),
+	})
+	client.DatabaseID = "db-1"
+	return client, recorder
+}
+
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestQuerySpan(t *testing.T) {
+	client, recorder := newClient(t, d1otel.Options{})
+
+	provider := sdktrace.NewTracerProvider()
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "handler")
+	var users []struct {
+		ID int64 `db:"id"`
+	}
+	if err := client.SelectContext(ctx, &users, "SELECT id FROM users WHERE email = ?", "alice@example.com"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "d1.query" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q with parent %v, want d1.query under the handler span", span.Name(), span.Parent().SpanID())
+	}
+	got := attrs(span)
+	want := map[attribute.Key]string{
+		"db.system":         "cloudflare_d1",
+		"db.name":           "db-1",
+		"db.statement":      "SELECT id FROM users WHERE email = ?",
+		"cloudflare.ray_id": "8f00aa11bb22cc33-FRA",
+	}
+	for key, value := range want {
+		if got[key].AsString() != value {
+			t.Errorf("%s = %q, want %q", key, got[key].AsString(), value)
+		}
+	}
+	if got[d1otel.RowsReadKey].AsInt64() != 12 {
+		t.Errorf("rows read = %v, want 12", got[d1otel.RowsReadKey])
+	}
+	if span.Status().Code == codes.Error {
+		t.Errorf("status = %v, want unset", span.Status())
+	}
+}
+
+func TestErrorSpanAndStatementOptions(t *testing.T) {
+	client, recorder := newClient(t, d1otel.Options{OmitStatement: true})
+
+	_, _ = client.WithDatabase("db-missing").Exec("DELETE FROM users")
+	_, _ = client.Batch([]utils.Statement{{SQL: "SELECT 1"}, {SQL: "SELECT 2"}})
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if span := spans[0]; span.Status().Code != codes.Error || len(span.Events()) == 0 {
+		t.Errorf("status = %v, events = %v, want the recorded error", span.Status(), span.Events())
+	}
+	if spans[1].Name() != "d1.batch" {
+		t.Errorf("batch span name = %q", spans[1].Name())
+	}
+	for _, span := range spans {
+		if _, ok := attrs(span)["db.statement"]; ok {
+			t.Errorf("span %q has db.statement with OmitStatement", span.Name())
+		}
+	}
+
+	client, recorder = newClient(t, d1otel.Options{MaxStatementLength: 12})
+	_, _ = client.Exec("UPDATE users SET name = ?", "x")
+	if got := attrs(recorder.Ended()[0])["db.statement"].AsString(); got != "UPDATE users" {
+		t.Errorf("db.statement = %q, want it truncated to 12 bytes", got)
+	}
+}
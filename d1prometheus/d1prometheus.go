@@ -0,0 +1,113 @@
+// Package d1prometheus exports the metrics of cloudflare-d1-go clients and
+// pools to Prometheus. It is a module of its own, so the client does not
+// depend on the Prometheus libraries.
+//
+//	collector := d1prometheus.NewCollector()
+//	prometheus.MustRegister(collector)
+//	pool, err := cloudflared1.NewPool(accountID, apiToken, cloudflared1.ClientOptions{
+//		Metrics: collector,
+//	})
+package d1prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	cloudflared1 "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// Collector implements cloudflared1.MetricsCollector and
+// cloudflared1.ConnectCacheCollector with these metrics:
+//
+//	d1_queries_total{operation, status}         counter, status is "ok" or "error"
+//	d1_query_duration_seconds{operation}        histogram
+//	d1_rows_read_total{operation}               counter
+//	d1_rows_written_total{operation}            counter
+//	d1_pool_connect_total{cache}                counter, cache is "hit" or "miss"
+//
+// It is a prometheus.Collector, so it is registered as a whole.
+type Collector struct {
+	queries     *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	rowsRead    *prometheus.CounterVec
+	rowsWritten *prometheus.CounterVec
+	connects    *prometheus.CounterVec
+}
+
+var (
+	_ cloudflared1.MetricsCollector      = (*Collector)(nil)
+	_ cloudflared1.ConnectCacheCollector = (*Collector)(nil)
+	_ prometheus.Collector               = (*Collector)(nil)
+)
+
+// NewCollector creates a Collector with the default histogram buckets
+func NewCollector() *Collector {
+	return NewCollectorWithBuckets(prometheus.DefBuckets)
+}
+
+// NewCollectorWithBuckets creates a Collector whose duration histogram has
+// the given buckets, in seconds
+func NewCollectorWithBuckets(buckets []float64) *Collector {
+	return &Collector{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "d1_queries_total",
+			Help: "D1 API calls by operation and status.",
+		}, []string{"operation", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "d1_query_duration_seconds",
+			Help:    "Wall-clock duration of D1 API calls.",
+			Buckets: buckets,
+		}, []string{"operation"}),
+		rowsRead: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "d1_rows_read_total",
+			Help: "Rows read by D1 queries, as billed.",
+		}, []string{"operation"}),
+		rowsWritten: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "d1_rows_written_total",
+			Help: "Rows written by D1 queries, as billed.",
+		}, []string{"operation"}),
+		connects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "d1_pool_connect_total",
+			Help: "ConnectionPool.Connect calls by whether the database was cached.",
+		}, []string{"cache"}),
+	}
+}
+
+// ObserveQuery records one API call
+func (c *Collector) ObserveQuery(op string, duration time.Duration, err error, rowsRead, rowsWritten int64) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	c.queries.WithLabelValues(op, status).Inc()
+	c.duration.WithLabelValues(op).Observe(duration.Seconds())
+	c.rowsRead.WithLabelValues(op).Add(float64(rowsRead))
+	c.rowsWritten.WithLabelValues(op).Add(float64(rowsWritten))
+}
+
+// ObserveConnect records whether Connect found the database in the pool cache
+func (c *Collector) ObserveConnect(cacheHit bool) {
+	cache := "miss"
+	if cacheHit {
+		cache = "hit"
+	}
+	c.connects.WithLabelValues(cache).Inc()
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.queries.Describe(ch)
+	c.duration.Describe(ch)
+	c.rowsRead.Describe(ch)
+	c.rowsWritten.Describe(ch)
+	c.connects.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.queries.Collect(ch)
+	c.duration.Collect(ch)
+	c.rowsRead.Collect(ch)
+	c.rowsWritten.Collect(ch)
+	c.connects.Collect(ch)
+}
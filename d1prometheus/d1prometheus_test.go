@@ -0,0 +1,88 @@
+package d1prometheus_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	cloudflared1 "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/d1prometheus"
+)
+
+func serve(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"result":[{"uuid":"db-1","name":"main"}],"success":true,"errors":[]}`))
+		case strings.Contains(r.URL.Path, "db-missing"):
+			_, _ = w.Write([]byte(`{"result":null,"success":false,"errors":[{"code":7404,"message":"not found"}]}`))
+		default:
+			item := map[string]interface{}{
+				"results": map[string]interface{}{"columns": []string{"n"}, "rows": [][]int{{1}}},
+				"meta":    map[string]interface{}{"rows_read": 4, "rows_written": 1},
+				"success": true,
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": []interface{}{item}, "success": true})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCollector(t *testing.T) {
+	srv := serve(t)
+	collector := d1prometheus.NewCollector()
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+
+	pool := cloudflared1.NewConnectionPoolWithOptions("account_id", "api_token", cloudflared1.ClientOptions{
+		BaseURL:    srv.URL,
+		HTTPClient: srv.Client(),
+		Metrics:    collector,
+	})
+	if err := pool.Connect("main"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := pool.Connect("main"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	_, _ = pool.Exec("UPDATE t SET n = 1")
+	_, _ = pool.Exec("UPDATE t SET n = 2")
+	_ = pool.ConnectWithID("missing", "db-missing")
+	// Without looking the name up again after the failure
+	pool.SetAutoReconnect(false)
+	_, _ = pool.QueryDB("missing", "SELECT 1", nil)
+
+	want := `
+# HELP d1_queries_total D1 API calls by operation and status.
+# TYPE d1_queries_total counter
+d1_queries_total{operation="list_databases",status="ok"} 1
+d1_queries_total{operation="query",status="error"} 1
+d1_queries_total{operation="query",status="ok"} 2
+# HELP d1_rows_read_total Rows read by D1 queries, as billed.
+# TYPE d1_rows_read_total counter
+d1_rows_read_total{operation="list_databases"} 0
+d1_rows_read_total{operation="query"} 8
+# HELP d1_rows_written_total Rows written by D1 queries, as billed.
+# TYPE d1_rows_written_total counter
+d1_rows_written_total{operation="list_databases"} 0
+d1_rows_written_total{operation="query"} 2
+# HELP d1_pool_connect_total ConnectionPool.Connect calls by whether the database was cached.
+# TYPE d1_pool_connect_total counter
+d1_pool_connect_total{cache="hit"} 1
+d1_pool_connect_total{cache="miss"} 1
+`
+	names := []string{"d1_queries_total", "d1_rows_read_total", "d1_rows_written_total", "d1_pool_connect_total"}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(collector, "d1_query_duration_seconds"); n != 2 {
+		t.Errorf("duration histograms = %d, want one per operation", n)
+	}
+}
@@ -0,0 +1,170 @@
+package cloudflared1_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestCreateDBWithOptions(t *testing.T) {
+	var bodies []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		writeJSON(w, map[string]interface{}{
+			"result": map[string]interface{}{
+				"uuid":       "11111111-2222-3333-4444-555555555555",
+				"name":       "app",
+				"version":    "production",
+				"created_at": "2024-05-01T12:30:00.123456Z",
+				"file_size":  12288,
+				"num_tables": 0,
+			},
+			"success": true,
+			"errors":  []interface{}{},
+		})
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	info, err := client.CreateDBWithOptions("app", cloudflare_d1_go.CreateDBOptions{
+		PrimaryLocationHint: cloudflare_d1_go.LocationWesternEurope,
+	})
+	if err != nil {
+		t.Fatalf("CreateDBWithOptions failed: %v", err)
+	}
+
+	want := cloudflare_d1_go.DatabaseInfo{
+		UUID:      "11111111-2222-3333-4444-555555555555",
+		Name:      "app",
+		Version:   "production",
+		CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC),
+		FileSize:  12288,
+	}
+	if *info != want {
+		t.Errorf("info = %+v, want %+v", *info, want)
+	}
+
+	// The old CreateDB sends no location hint
+	if _, err := client.CreateDB("plain"); err != nil {
+		t.Fatalf("CreateDB failed: %v", err)
+	}
+
+	wantBodies := []string{
+		`{"name":"app","primary_location_hint":"weur"}`,
+		`{"name":"plain"}`,
+	}
+	if strings.Join(bodies, "\n") != strings.Join(wantBodies, "\n") {
+		t.Errorf("bodies = %q, want %q", bodies, wantBodies)
+	}
+}
+
+func TestCreateDBWithOptionsAPIError(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, errorResponse(7502, "database already exists"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	info, err := client.CreateDBWithOptions("app", cloudflare_d1_go.CreateDBOptions{})
+	if err == nil || !strings.Contains(err.Error(), "database already exists") {
+		t.Errorf("CreateDBWithOptions = %+v, %v, want the API error", info, err)
+	}
+}
+
+// serveDatabaseList mocks the paginated database list with the given names,
+// honouring page, per_page and name, and records every query string.
+func serveDatabaseList(t *testing.T, names []string) *[]string {
+	t.Helper()
+	var queries []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, r.URL.RawQuery)
+
+		var matches []string
+		for _, name := range names {
+			if strings.Contains(name, q.Get("name")) {
+				matches = append(matches, name)
+			}
+		}
+		page, _ := strconv.Atoi(q.Get("page"))
+		perPage, _ := strconv.Atoi(q.Get("per_page"))
+		start := min((page-1)*perPage, len(matches))
+		end := min(start+perPage, len(matches))
+
+		items := []interface{}{}
+		for _, name := range matches[start:end] {
+			items = append(items, map[string]interface{}{"uuid": "uuid-" + name, "name": name})
+		}
+		writeJSON(w, map[string]interface{}{
+			"result":  items,
+			"success": true,
+			"errors":  []interface{}{},
+			"result_info": map[string]interface{}{
+				"page": page, "per_page": perPage, "count": len(items), "total_count": len(matches),
+			},
+		})
+	})
+	return &queries
+}
+
+func TestListAllDBsFollowsPages(t *testing.T) {
+	names := make([]string, 250)
+	for i := range names {
+		names[i] = fmt.Sprintf("db-%03d", i)
+	}
+	queries := serveDatabaseList(t, names)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	dbs, err := client.ListAllDBs()
+	if err != nil {
+		t.Fatalf("ListAllDBs failed: %v", err)
+	}
+	if len(dbs) != 250 || dbs[249].Name != "db-249" || dbs[249].UUID != "uuid-db-249" {
+		t.Errorf("got %d databases, last %+v", len(dbs), dbs[len(dbs)-1])
+	}
+	if len(*queries) != 3 {
+		t.Errorf("queries = %q, want 3 pages", *queries)
+	}
+
+	page, info, err := client.ListDBPaged(2, 10)
+	if err != nil {
+		t.Fatalf("ListDBPaged failed: %v", err)
+	}
+	if len(page) != 10 || page[0].Name != "db-010" {
+		t.Errorf("page = %+v", page)
+	}
+	if info == nil || info.TotalCount != 250 || info.Page != 2 {
+		t.Errorf("info = %+v, want page 2 of 250", info)
+	}
+}
+
+func TestConnectDBFiltersByName(t *testing.T) {
+	names := []string{"app-staging", "other"}
+	for i := 0; i < 150; i++ {
+		names = append(names, fmt.Sprintf("app-%03d", i))
+	}
+	names = append(names, "app")
+	queries := serveDatabaseList(t, names)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	if err := client.ConnectDB("app"); err != nil {
+		t.Fatalf("ConnectDB failed: %v", err)
+	}
+	if client.DatabaseID != "uuid-app" {
+		t.Errorf("DatabaseID = %s, want uuid-app", client.DatabaseID)
+	}
+	for _, q := range *queries {
+		if !strings.Contains(q, "name=app") {
+			t.Errorf("query %q does not filter by name", q)
+		}
+	}
+
+	if err := client.ConnectDB("missing"); err == nil {
+		t.Error("expected error for a missing database")
+	}
+}
@@ -0,0 +1,153 @@
+package cloudflared1_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type department struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+type auditedUser struct {
+	genericUser
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type userWithDept struct {
+	User genericUser `db:"u"`
+	Dept *department `db:"d"`
+}
+
+func scanOne(t *testing.T, row map[string]interface{}, dest interface{}) {
+	t.Helper()
+	columns := make([]string, 0, len(row))
+	for col := range row {
+		columns = append(columns, col)
+	}
+	rows := utils.NewRows([]map[string]interface{}{row}, columns)
+	rows.Next()
+	if err := rows.StructScan(dest); err != nil {
+		t.Fatalf("StructScan failed: %v", err)
+	}
+}
+
+func TestStructScanEmbedded(t *testing.T) {
+	var got auditedUser
+	scanOne(t, map[string]interface{}{"id": float64(1), "name": "Alice", "created_at": "2024-05-01 12:30:00"}, &got)
+
+	want := auditedUser{genericUser{ID: 1, Name: "Alice"}, time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestStructScanEmbeddedPointer(t *testing.T) {
+	// Embedded pointers are allocated, so their type must be exported
+	type Member genericUser
+	var got struct {
+		*Member
+		Role string `db:"role"`
+	}
+	scanOne(t, map[string]interface{}{"id": float64(2), "name": "Bob", "role": "admin"}, &got)
+
+	if got.Member == nil || got.ID != 2 || got.Name != "Bob" || got.Role != "admin" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestStructScanPrefixedNested(t *testing.T) {
+	for name, row := range map[string]map[string]interface{}{
+		"dotted":      {"u.id": float64(1), "u.name": "Alice", "d.id": float64(10), "d.name": "Sales"},
+		"underscored": {"u_id": float64(1), "u_name": "Alice", "d_id": float64(10), "d_name": "Sales"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got userWithDept
+			scanOne(t, row, &got)
+			want := userWithDept{genericUser{ID: 1, Name: "Alice"}, &department{10, "Sales"}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want User %+v, Dept %+v", got, want.User, *want.Dept)
+			}
+		})
+	}
+}
+
+func TestSelectJoinIntoNestedStructs(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult(
+			[]string{"u_id", "u_name", "d_id", "d_name"},
+			[][]interface{}{{1, "Alice", 10, "Sales"}, {2, "Bob", nil, nil}},
+			nil,
+		)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	var got []userWithDept
+	err := client.Select(&got, `SELECT u.id AS u_id, u.name AS u_name, d.id AS d_id, d.name AS d_name
+		FROM users u LEFT JOIN departments d ON d.id = u.department_id`)
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Dept.Name != "Sales" || got[1].User.Name != "Bob" || *got[1].Dept != (department{}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestStructColumnsPrecedence(t *testing.T) {
+	type base struct {
+		ID        int64  `db:"id"`
+		Name      string `db:"name"`
+		UpdatedBy string `db:"updated_by"`
+	}
+	type audit struct {
+		UpdatedBy string `db:"updated_by"`
+		Note      string `db:"note"`
+	}
+	type record struct {
+		base
+		audit
+		Name string `db:"name"` // shadows base.Name
+	}
+
+	var got record
+	scanOne(t, map[string]interface{}{"id": float64(1), "name": "outer", "updated_by": "alice", "note": "n"}, &got)
+	if got.Name != "outer" || got.base.Name != "" {
+		t.Errorf("Name = %q, base.Name = %q; want the outer field to win", got.Name, got.base.Name)
+	}
+	if got.base.UpdatedBy != "alice" || got.audit.UpdatedBy != "" {
+		t.Errorf("base.UpdatedBy = %q, audit.UpdatedBy = %q; want the first embedded struct to win", got.base.UpdatedBy, got.audit.UpdatedBy)
+	}
+
+	var columns []string
+	for _, fc := range utils.StructColumns(reflect.TypeOf(got), nil) {
+		columns = append(columns, fc.Column)
+	}
+	if want := []string{"id", "updated_by", "note", "name"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("columns = %v, want %v", columns, want)
+	}
+}
+
+func TestInsertStructEmbedded(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	user := auditedUser{genericUser{ID: 1, Name: "Alice"}, time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)}
+	if _, err := client.InsertStruct("users", user); err != nil {
+		t.Fatalf("InsertStruct failed: %v", err)
+	}
+	want := sentStatement{
+		SQL:    `INSERT INTO "users" ("id", "name", "created_at") VALUES (?, ?, ?)`,
+		Params: []string{"1", "Alice", "2024-05-01T12:30:00.000000000Z"},
+	}
+	if !reflect.DeepEqual((*sent)[0], want) {
+		t.Errorf("sent %+v, want %+v", (*sent)[0], want)
+	}
+}
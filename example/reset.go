@@ -1,39 +1,69 @@
-package main
-
-import (
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
-	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
-)
-
-func main() {
-	if err := godotenv.Load(".env"); err != nil && !os.IsNotExist(err) {
-		log.Printf("Warning: Error loading .env file: %v", err)
-	}
-
-	accountID := os.Getenv("CLOUDFLARE_ACCOUNT_ID")
-	apiToken := os.Getenv("CLOUDFLARE_API_TOKEN")
-	dbName := os.Getenv("CLOUDFLARE_DB_NAME")
-
-	if accountID == "" || apiToken == "" || dbName == "" {
-		log.Fatal("Please set CLOUDFLARE_ACCOUNT_ID, CLOUDFLARE_API_TOKEN, and CLOUDFLARE_DB_NAME environment variables")
-	}
-
-	client := cloudflare_d1_go.NewClient(accountID, apiToken)
-	if err := client.ConnectDB(dbName); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-
-	tables := []string{"user_departments", "users", "departments", "d1_migrations"}
-	for _, t := range tables {
-		fmt.Printf("Dropping table %s...\n", t)
-		_, err := client.RemoveTable(t)
-		if err != nil {
-			fmt.Printf("Failed to drop table %s: %v\n", t, err)
-		}
-	}
-	fmt.Println("Database reset complete.")
-}
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func main() {
+	if err := godotenv.Load(".env"); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Error loading .env file: %v", err)
+	}
+
+	accountID := os.Getenv("CLOUDFLARE_ACCOUNT_ID")
+	apiToken := os.Getenv("CLOUDFLARE_API_TOKEN")
+	dbName := os.Getenv("CLOUDFLARE_DB_NAME")
+
+	if accountID == "" || apiToken == "" || dbName == "" {
+		log.Fatal("Please set CLOUDFLARE_ACCOUNT_ID, CLOUDFLARE_API_TOKEN, and CLOUDFLARE_DB_NAME environment variables")
+	}
+
+	client, err := cloudflare_d1_go.New(accountID, apiToken, cloudflare_d1_go.ClientOptions{})
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.VerifyToken(); err != nil {
+		log.Fatalf("Invalid credentials: %v", err)
+	}
+	if err := client.ConnectDB(dbName); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	tables, err := client.ListTables()
+	if err != nil {
+		log.Fatalf("Failed to list tables: %v", err)
+	}
+	// d1_migrations is internal to D1, but is the bookkeeping table of this
+	// package, as is the lock table of locked migration runs
+	for _, name := range []string{"d1_migrations", "d1_migrations_lock"} {
+		if exists, _ := client.TableExists(name); exists {
+			tables = append(tables, name)
+		}
+	}
+
+	// Tables referenced by foreign keys can only be dropped after the tables
+	// referencing them, so retry failed drops until no more progress is made
+	for len(tables) > 0 {
+		var failed []string
+		for _, t := range tables {
+			fmt.Printf("Dropping table %s...\n", t)
+			res, err := client.RemoveTable(t)
+			if err == nil {
+				err = res.Err()
+			}
+			if err != nil {
+				fmt.Printf("Failed to drop table %s: %v\n", t, err)
+				failed = append(failed, t)
+			}
+		}
+		if len(failed) == len(tables) {
+			log.Fatalf("Could not drop tables: %v", failed)
+		}
+		tables = failed
+	}
+	fmt.Println("Database reset complete.")
+}
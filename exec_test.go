@@ -0,0 +1,58 @@
+package cloudflared1_test
+
+import (
+	"net/http"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveMeta mocks the raw query endpoint, answering every query with the given meta.
+func serveMeta(t *testing.T, meta map[string]interface{}) {
+	t.Helper()
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult(nil, nil, meta)))
+	})
+}
+
+func TestExecResultInsert(t *testing.T) {
+	serveMeta(t, map[string]interface{}{"last_row_id": float64(42), "changes": float64(1)})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	result, err := client.ExecResult("INSERT INTO users (name) VALUES (?)", "Alice")
+	if err != nil {
+		t.Fatalf("ExecResult failed: %v", err)
+	}
+	if id, _ := result.LastInsertId(); id != 42 {
+		t.Errorf("LastInsertId = %d, want 42", id)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("RowsAffected = %d, want 1", n)
+	}
+}
+
+func TestExecResultUpdate(t *testing.T) {
+	serveMeta(t, map[string]interface{}{"last_row_id": float64(0), "changes": float64(3)})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	result, err := pool.ExecResult("UPDATE users SET age = ? WHERE age > ?", 30, 20)
+	if err != nil {
+		t.Fatalf("ExecResult failed: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n != 3 {
+		t.Errorf("RowsAffected = %d, want 3", n)
+	}
+
+	// Exec keeps returning the rows affected count
+	n, err := pool.Exec("UPDATE users SET age = ? WHERE age > ?", 30, 20)
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Exec = %d, want 3", n)
+	}
+}
@@ -0,0 +1,130 @@
+package cloudflared1_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+const dumpSQL = "CREATE TABLE users (id INTEGER PRIMARY KEY);\nINSERT INTO users VALUES (1);\n"
+
+// exportCall is the decoded body of an export request
+type exportCall struct {
+	OutputFormat    string `json:"output_format"`
+	CurrentBookmark string `json:"current_bookmark"`
+	DumpOptions     struct {
+		NoData   bool     `json:"no_data"`
+		NoSchema bool     `json:"no_schema"`
+		Tables   []string `json:"tables"`
+	} `json:"dump_options"`
+}
+
+// exportResponse wraps the status of an export in the API envelope
+func exportResponse(status map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"result": status, "success": true, "errors": []interface{}{}}
+}
+
+// serveExport answers export calls as still running until pending calls
+// were made, then as complete, and serves the dump from the signed URL
+func serveExport(t *testing.T, pending int, calls *[]exportCall) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "r2.example.com" {
+			if r.Header.Get("Authorization") != "" {
+				t.Error("the download carried the API token")
+			}
+			_, _ = w.Write([]byte(dumpSQL))
+			return
+		}
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/d1/database/db-1/export") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		var call exportCall
+		_ = json.NewDecoder(r.Body).Decode(&call)
+		*calls = append(*calls, call)
+		if len(*calls) <= pending {
+			writeJSON(w, exportResponse(map[string]interface{}{"at_bookmark": "bm-1", "status": "active", "messages": []string{"Generating dump"}}))
+			return
+		}
+		writeJSON(w, exportResponse(map[string]interface{}{
+			"at_bookmark": "bm-1",
+			"status":      "complete",
+			"messages":    []string{"Generating dump", "Uploaded part 1"},
+			"result":      map[string]interface{}{"filename": "main-bm-1.sql", "signed_url": "https://r2.example.com/main-bm-1.sql?sig=abc"},
+		}))
+	})
+}
+
+func TestExportDatabase(t *testing.T) {
+	var calls []exportCall
+	serveExport(t, 2, &calls)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	var dump bytes.Buffer
+	res, err := client.ExportDatabase(cloudflare_d1_go.ExportOptions{
+		Tables:       []string{"users"},
+		NoData:       true,
+		PollInterval: time.Millisecond,
+		Output:       &dump,
+	})
+	if err != nil {
+		t.Fatalf("ExportDatabase failed: %v", err)
+	}
+
+	if len(calls) != 3 {
+		t.Fatalf("made %d export calls, want 3", len(calls))
+	}
+	first := calls[0]
+	if first.OutputFormat != "polling" || first.CurrentBookmark != "" || !first.DumpOptions.NoData || first.DumpOptions.NoSchema || !reflect.DeepEqual(first.DumpOptions.Tables, []string{"users"}) {
+		t.Errorf("first call = %+v", first)
+	}
+	if calls[2].CurrentBookmark != "bm-1" {
+		t.Errorf("polling call = %+v, want the bookmark of the running export", calls[2])
+	}
+
+	if res.Bookmark != "bm-1" || res.Filename != "main-bm-1.sql" || !strings.HasPrefix(res.SignedURL, "https://r2.example.com/") || len(res.Messages) != 2 {
+		t.Errorf("result = %+v", res)
+	}
+	if dump.String() != dumpSQL || res.Written != int64(len(dumpSQL)) {
+		t.Errorf("downloaded %d bytes %q, want the dump", res.Written, dump.String())
+	}
+}
+
+func TestExportDatabaseFailure(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, exportResponse(map[string]interface{}{"at_bookmark": "bm-1", "status": "error", "error": "Table not found: missing"}))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	_, err := client.ExportDatabase(cloudflare_d1_go.ExportOptions{Tables: []string{"missing"}})
+	if err == nil || !strings.Contains(err.Error(), "Table not found: missing") {
+		t.Errorf("err = %v, want the export error", err)
+	}
+}
+
+func TestExportDatabaseContext(t *testing.T) {
+	var calls []exportCall
+	serveExport(t, 1000, &calls)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.ExportDatabaseContext(ctx, cloudflare_d1_go.ExportOptions{PollInterval: 10 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline of the context", err)
+	}
+	if len(calls) < 2 {
+		t.Errorf("made %d export calls, want the export to be polled", len(calls))
+	}
+}
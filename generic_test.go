@@ -0,0 +1,129 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveUsers mocks the raw query endpoint with a users table; queries for
+// "SELECT name FROM users" and "SELECT id FROM users" return a single column
+// and "SELECT * FROM users WHERE 0" returns no rows.
+func serveUsers(t *testing.T) {
+	t.Helper()
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			SQL string `json:"sql"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		switch body.SQL {
+		case "SELECT name FROM users":
+			writeJSON(w, successResponse(queryResult([]string{"name"}, [][]interface{}{{"Alice"}, {"Bob"}}, nil)))
+		case "SELECT id FROM users":
+			writeJSON(w, successResponse(queryResult([]string{"id"}, [][]interface{}{{1}, {2}}, nil)))
+		case "SELECT * FROM users WHERE 0":
+			writeJSON(w, successResponse(queryResult([]string{"id", "name"}, nil, nil)))
+		default:
+			writeJSON(w, successResponse(queryResult([]string{"id", "name"}, [][]interface{}{{1, "Alice"}, {2, "Bob"}}, nil)))
+		}
+	})
+}
+
+type genericUser struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestSelectAll(t *testing.T) {
+	serveUsers(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	users, err := cloudflare_d1_go.SelectAll[genericUser](client, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("SelectAll[struct] failed: %v", err)
+	}
+	if !reflect.DeepEqual(users, []genericUser{{1, "Alice"}, {2, "Bob"}}) {
+		t.Errorf("users = %+v", users)
+	}
+
+	ptrs, err := cloudflare_d1_go.SelectAll[*genericUser](client, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("SelectAll[*struct] failed: %v", err)
+	}
+	if len(ptrs) != 2 || ptrs[1] == nil || ptrs[1].Name != "Bob" {
+		t.Errorf("ptrs = %+v", ptrs)
+	}
+
+	names, err := cloudflare_d1_go.SelectAll[string](client, "SELECT name FROM users")
+	if err != nil {
+		t.Fatalf("SelectAll[string] failed: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"Alice", "Bob"}) {
+		t.Errorf("names = %v", names)
+	}
+
+	ids, err := cloudflare_d1_go.SelectAll[int64](client, "SELECT id FROM users")
+	if err != nil {
+		t.Fatalf("SelectAll[int64] failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2}) {
+		t.Errorf("ids = %v", ids)
+	}
+
+	maps, err := cloudflare_d1_go.SelectAll[map[string]interface{}](client, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("SelectAll[map] failed: %v", err)
+	}
+	if len(maps) != 2 || maps[1]["name"] != "Bob" {
+		t.Errorf("maps = %v", maps)
+	}
+
+	// A primitive needs a single column
+	if _, err := cloudflare_d1_go.SelectAll[string](client, "SELECT * FROM users"); err == nil {
+		t.Error("expected error scanning two columns into []string")
+	}
+
+	empty, err := cloudflare_d1_go.SelectAll[genericUser](client, "SELECT * FROM users WHERE 0")
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("SelectAll without rows = %v, %v, want empty slice", empty, err)
+	}
+}
+
+func TestGetOne(t *testing.T) {
+	serveUsers(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	user, err := cloudflare_d1_go.PoolGetOne[genericUser](pool, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("PoolGetOne failed: %v", err)
+	}
+	if user != (genericUser{1, "Alice"}) {
+		t.Errorf("user = %+v", user)
+	}
+
+	id, err := cloudflare_d1_go.PoolGetOne[int](pool, "SELECT id FROM users")
+	if err != nil || id != 1 {
+		t.Errorf("PoolGetOne[int] = %d, %v", id, err)
+	}
+
+	names, err := cloudflare_d1_go.PoolSelectAll[string](pool, "SELECT name FROM users")
+	if err != nil || len(names) != 2 {
+		t.Errorf("PoolSelectAll[string] = %v, %v", names, err)
+	}
+
+	db, _ := pool.DB("main")
+	client, _ := db.Client()
+	if _, err := cloudflare_d1_go.GetOne[genericUser](client, "SELECT * FROM users WHERE 0"); !errors.Is(err, utils.ErrNoRows) {
+		t.Errorf("GetOne without rows = %v, want ErrNoRows", err)
+	}
+}
@@ -0,0 +1,58 @@
+package cloudflared1_test
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type getUser struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestGetReturnsErrNoRows(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult([]string{"id", "name"}, nil, nil)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var user getUser
+	err := client.Get(&user, "SELECT id, name FROM users WHERE id = ?", 1)
+	if !errors.Is(err, utils.ErrNoRows) {
+		t.Fatalf("expected utils.ErrNoRows, got %v", err)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected error to match sql.ErrNoRows")
+	}
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	if err := pool.Get(&user, "SELECT id, name FROM users WHERE id = ?", 1); !errors.Is(err, utils.ErrNoRows) {
+		t.Errorf("pool.Get: expected utils.ErrNoRows, got %v", err)
+	}
+}
+
+func TestGetAPIErrorIsNotErrNoRows(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, errorResponse(7500, "no such table: users"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var user getUser
+	err := client.Get(&user, "SELECT id, name FROM users WHERE id = ?", 1)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if errors.Is(err, utils.ErrNoRows) {
+		t.Errorf("API error must not match ErrNoRows: %v", err)
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/youfun/cloudflare-d1-go
 
 go 1.24.2
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/mattn/go-sqlite3 v1.14.32
+)
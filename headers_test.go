@@ -0,0 +1,75 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	var got http.Header
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("cf-ray", "8f00aa11bb22cc33-FRA")
+		writeJSON(w, successResponse(queryResult(nil, nil, map[string]interface{}{"changes": 1})))
+	})
+
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		Headers: http.Header{"X-Team": {"billing"}},
+	})
+	client.DatabaseID = "db-1"
+
+	if _, err := client.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if ua := got.Get("User-Agent"); ua != utils.UserAgent() || !strings.HasPrefix(ua, "cloudflare-d1-go/") {
+		t.Errorf("User-Agent = %q, want %q", ua, utils.UserAgent())
+	}
+	if got.Get("X-Team") != "billing" || got.Get("Authorization") != "Bearer api_token" {
+		t.Errorf("headers = %v", got)
+	}
+
+	ctx := utils.WithHeader(context.Background(), "X-Audit", "job-42")
+	ctx = utils.WithHeader(ctx, "User-Agent", "nightly-job/1.0")
+	res, err := client.ExecResultContext(ctx, "DELETE FROM users")
+	if err != nil {
+		t.Fatalf("ExecResultContext: %v", err)
+	}
+	if got.Get("X-Audit") != "job-42" || got.Get("User-Agent") != "nightly-job/1.0" || got.Get("X-Team") != "billing" {
+		t.Errorf("headers = %v", got)
+	}
+	if res.Meta().RayID != "8f00aa11bb22cc33-FRA" {
+		t.Errorf("Meta().RayID = %q", res.Meta().RayID)
+	}
+
+	if _, err := client.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if got.Get("X-Audit") != "" {
+		t.Errorf("X-Audit = %q sent without the context", got.Get("X-Audit"))
+	}
+}
+
+func TestAPIErrorRayID(t *testing.T) {
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("cf-ray", "8a1b2c3d4e5f6789-AMS")
+		writeJSON(w, errorResponse(7500, "no such table: users"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	_, err := client.Exec("DELETE FROM users")
+
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *utils.APIError", err)
+	}
+	if apiErr.RayID != "8a1b2c3d4e5f6789-AMS" {
+		t.Errorf("RayID = %q", apiErr.RayID)
+	}
+}
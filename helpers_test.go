@@ -0,0 +1,81 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// roundTripFunc adapts a function to the http.RoundTripper interface.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// mockTransport serves every request made through http.DefaultClient with
+// handler until the test finishes, so tests never reach the real API.
+func mockTransport(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	orig := http.DefaultClient.Transport
+	http.DefaultClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		handler(rec, r)
+		if err := r.Context().Err(); err != nil {
+			// A real transport fails requests whose context ended
+			return nil, err
+		}
+		return rec.Result(), nil
+	})
+	t.Cleanup(func() { http.DefaultClient.Transport = orig })
+}
+
+// writeJSON encodes v as the response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// queryResult builds a single /raw result item with the given columns, rows and meta.
+func queryResult(columns []string, rows [][]interface{}, meta map[string]interface{}) map[string]interface{} {
+	cols := make([]interface{}, len(columns))
+	for i, c := range columns {
+		cols[i] = c
+	}
+	rawRows := make([]interface{}, len(rows))
+	for i, r := range rows {
+		rawRows[i] = r
+	}
+	return map[string]interface{}{
+		"results": map[string]interface{}{
+			"columns": cols,
+			"rows":    rawRows,
+		},
+		"meta":    meta,
+		"success": true,
+	}
+}
+
+// testAccountID is a well-formed account ID, for the constructors that check it
+const testAccountID = "0123456789abcdef0123456789abcdef"
+
+// successResponse wraps result items in a successful API envelope.
+func successResponse(items ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"result":   items,
+		"success":  true,
+		"errors":   []interface{}{},
+		"messages": []interface{}{},
+	}
+}
+
+// errorResponse builds a failed API envelope carrying a single error.
+func errorResponse(code int, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"result":   nil,
+		"success":  false,
+		"errors":   []interface{}{map[string]interface{}{"code": code, "message": message}},
+		"messages": []interface{}{},
+	}
+}
@@ -0,0 +1,104 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestHTMLErrorPage(t *testing.T) {
+	page := "<!DOCTYPE html><html><head><title>502 Bad Gateway</title></head><body>" + strings.Repeat("<p>cloudflare</p>", 100) + "</body></html>"
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("cf-ray", "8a1b2c3d4e5f6789-AMS")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(page))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	_, err := client.Query("SELECT 1", nil)
+
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("err = %v, want *utils.HTTPError", err)
+	}
+	if httpErr.StatusCode != http.StatusBadGateway || httpErr.CFRay != "8a1b2c3d4e5f6789-AMS" {
+		t.Errorf("HTTPError = %+v", httpErr)
+	}
+	if !strings.HasPrefix(httpErr.Body, "<!DOCTYPE html>") || len(httpErr.Body) >= len(page) {
+		t.Errorf("Body = %q, want a truncated snippet of the page", httpErr.Body)
+	}
+	for _, want := range []string{"502", "8a1b2c3d4e5f6789-AMS", "Bad Gateway"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestEmptyTimeoutBody(t *testing.T) {
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(524)
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	_, err := client.ListDB()
+
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 524 || httpErr.Body != "" {
+		t.Fatalf("err = %v, want an HTTPError for 524 with an empty body", err)
+	}
+	if !strings.Contains(err.Error(), "HTTP 524") {
+		t.Errorf("error %q does not mention the status code", err)
+	}
+}
+
+func TestJSONErrorEnvelopeWithStatus(t *testing.T) {
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, errorResponse(7500, "near \"SELEC\": syntax error"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	res, err := client.Query("SELEC 1", nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	var apiErr *utils.APIError
+	if !errors.As(res.Err(), &apiErr) || apiErr.Code != 7500 {
+		t.Fatalf("res.Err() = %v, want the API error with code 7500", res.Err())
+	}
+	if _, err := client.Exec("SELEC 1"); !errors.As(err, &apiErr) {
+		t.Errorf("Exec error = %v, want *utils.APIError", err)
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("cf-ray", "8f00aa11bb22cc33-FRA")
+		if strings.HasSuffix(r.URL.Path, "/query") {
+			writeJSON(w, successResponse(map[string]interface{}{"results": []interface{}{map[string]interface{}{"n": 1}}, "success": true}))
+			return
+		}
+		writeJSON(w, successResponse(queryResult([]string{"n"}, [][]interface{}{{1}}, nil)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	for _, useQuery := range []bool{false, true} {
+		client.UseQueryEndpoint(useQuery)
+		res, err := client.Query("SELECT 1 AS n", nil)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if res.RayID() != "8f00aa11bb22cc33-FRA" || res.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("query endpoint %v: RayID = %q, Header = %v", useQuery, res.RayID(), res.Header)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package cloudflared1_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// importServer simulates the import flow: init hands out an upload URL, the
+// upload is stored, ingest starts and poll reports it running until polls
+// calls were made
+type importServer struct {
+	mu       sync.Mutex
+	uploaded string
+	actions  []string
+	polls    int
+	// failure is reported by the last poll instead of completing
+	failure string
+	// skipUpload answers init as if the file had been uploaded before
+	skipUpload bool
+}
+
+func (s *importServer) serve(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.Host == "r2.example.com" {
+			if r.Method != http.MethodPut || r.Header.Get("Authorization") != "" {
+				t.Errorf("upload is %s with Authorization %q", r.Method, r.Header.Get("Authorization"))
+			}
+			body, _ := io.ReadAll(r.Body)
+			s.uploaded = string(body)
+			sum := md5.Sum(body)
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+			return
+		}
+
+		var call struct {
+			Action          string `json:"action"`
+			Etag            string `json:"etag"`
+			Filename        string `json:"filename"`
+			CurrentBookmark string `json:"current_bookmark"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&call)
+		s.actions = append(s.actions, call.Action)
+
+		switch call.Action {
+		case "init":
+			status := map[string]interface{}{"filename": call.Etag + ".sql"}
+			if !s.skipUpload {
+				status["upload_url"] = "https://r2.example.com/imports/" + call.Etag + ".sql?sig=abc"
+			}
+			writeJSON(w, exportResponse(status))
+		case "ingest":
+			if call.Filename != call.Etag+".sql" {
+				t.Errorf("ingest of %q, want the file of init", call.Filename)
+			}
+			writeJSON(w, exportResponse(map[string]interface{}{"at_bookmark": "bm-1", "status": "active"}))
+		case "poll":
+			if call.CurrentBookmark != "bm-1" {
+				t.Errorf("poll of bookmark %q, want the one of ingest", call.CurrentBookmark)
+			}
+			s.polls--
+			switch {
+			case s.polls > 0:
+				writeJSON(w, exportResponse(map[string]interface{}{"at_bookmark": "bm-1", "status": "active"}))
+			case s.failure != "":
+				writeJSON(w, exportResponse(map[string]interface{}{"status": "error", "error": s.failure, "messages": []string{"Uploaded part 1"}}))
+			default:
+				writeJSON(w, exportResponse(map[string]interface{}{"status": "complete", "result": map[string]interface{}{"num_queries": 2}}))
+			}
+		default:
+			t.Errorf("unexpected action %q", call.Action)
+		}
+	})
+}
+
+func TestImportSQL(t *testing.T) {
+	server := &importServer{polls: 2}
+	server.serve(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	var phases []cloudflare_d1_go.ImportPhase
+	var uploaded int64
+	// A reader that cannot seek is spooled before the upload
+	err := client.ImportSQL(io.MultiReader(strings.NewReader(dumpSQL)), cloudflare_d1_go.ImportOptions{
+		PollInterval: time.Millisecond,
+		Progress: func(p cloudflare_d1_go.ImportProgress) {
+			if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+				phases = append(phases, p.Phase)
+			}
+			if p.Phase == cloudflare_d1_go.ImportUploading {
+				uploaded = p.BytesUploaded
+				if p.TotalBytes != int64(len(dumpSQL)) {
+					t.Errorf("TotalBytes = %d, want %d", p.TotalBytes, len(dumpSQL))
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("ImportSQL failed: %v", err)
+	}
+
+	if server.uploaded != dumpSQL {
+		t.Errorf("uploaded %q, want the file", server.uploaded)
+	}
+	if got := strings.Join(server.actions, ","); got != "init,ingest,poll,poll" {
+		t.Errorf("actions = %s", got)
+	}
+	want := []cloudflare_d1_go.ImportPhase{cloudflare_d1_go.ImportPreparing, cloudflare_d1_go.ImportUploading, cloudflare_d1_go.ImportIngesting, cloudflare_d1_go.ImportComplete}
+	if !reflect.DeepEqual(phases, want) || uploaded != int64(len(dumpSQL)) {
+		t.Errorf("phases = %v, uploaded %d bytes", phases, uploaded)
+	}
+}
+
+func TestImportSQLUploadedBefore(t *testing.T) {
+	server := &importServer{polls: 1, skipUpload: true}
+	server.serve(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	if err := client.ImportSQL(strings.NewReader(dumpSQL), cloudflare_d1_go.ImportOptions{PollInterval: time.Millisecond}); err != nil {
+		t.Fatalf("ImportSQL failed: %v", err)
+	}
+	if server.uploaded != "" {
+		t.Errorf("uploaded %q again", server.uploaded)
+	}
+}
+
+func TestImportSQLIngestError(t *testing.T) {
+	server := &importServer{polls: 1, failure: `near "INSRT": syntax error at line 2: SQLITE_ERROR`}
+	server.serve(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	err := client.ImportSQL(strings.NewReader("CREATE TABLE t (id INTEGER);\nINSRT INTO t VALUES (1);\n"), cloudflare_d1_go.ImportOptions{PollInterval: time.Millisecond})
+
+	var importErr *cloudflare_d1_go.ImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("err = %v, want *ImportError", err)
+	}
+	if importErr.Line != 2 || !strings.Contains(importErr.Message, "INSRT") || len(importErr.Messages) != 1 {
+		t.Errorf("ImportError = %+v", importErr)
+	}
+}
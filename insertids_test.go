@@ -0,0 +1,151 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveInserts mocks INSERT batches and schema lookups. RETURNING statements
+// get IDs from returning, others the meta in lastRowIDs, both keyed by SQL.
+// It records the SQL of every batched statement.
+func serveInserts(t *testing.T, returning map[string][]int, lastRowIDs map[string][2]int) *[]string {
+	t.Helper()
+	var batched []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			SQL    string   `json:"sql"`
+			Params []string `json:"params"`
+			Batch  []struct {
+				SQL string `json:"sql"`
+			} `json:"batch"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		if body.Batch == nil {
+			// Schema lookup of isAutoincrement
+			schemas := map[string]string{
+				"orders": "CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, total REAL)",
+				"users":  "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+			}
+			writeJSON(w, successResponse(queryResult([]string{"sql"}, [][]interface{}{{schemas[body.Params[0]]}}, nil)))
+			return
+		}
+
+		var items []interface{}
+		for _, stmt := range body.Batch {
+			batched = append(batched, stmt.SQL)
+			if ids, ok := returning[stmt.SQL]; ok {
+				rows := make([][]interface{}, len(ids))
+				for i, id := range ids {
+					rows[i] = []interface{}{id}
+				}
+				items = append(items, queryResult([]string{"id"}, rows, map[string]interface{}{"changes": len(ids)}))
+				continue
+			}
+			meta := lastRowIDs[stmt.SQL]
+			items = append(items, queryResult(nil, nil, map[string]interface{}{"last_row_id": meta[0], "changes": meta[1]}))
+		}
+		writeJSON(w, successResponse(items...))
+	})
+	return &batched
+}
+
+type insertedUser struct {
+	UserID int64  `db:"id"`
+	Name   string `db:"name"`
+}
+
+func TestBatchInsertIDsReturning(t *testing.T) {
+	batched := serveInserts(t, map[string][]int{
+		`INSERT INTO users (name) VALUES (?), (?) RETURNING "id"`: {10, 11},
+		`INSERT INTO users (name) VALUES (?) RETURNING "id"`:      {12},
+	}, nil)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	users := []*insertedUser{{Name: "Alice"}, {Name: "Bob"}, {Name: "Carol"}}
+	ids, err := client.BatchInsertIDs([]utils.Statement{
+		{SQL: "INSERT INTO users (name) VALUES (?), (?)", Params: []interface{}{"Alice", "Bob"}},
+		{SQL: "INSERT INTO users (name) VALUES (?);", Params: []interface{}{"Carol"}},
+	}, cloudflare_d1_go.WriteIDsTo(&users))
+	if err != nil {
+		t.Fatalf("BatchInsertIDs failed: %v (sent %q)", err, *batched)
+	}
+
+	if !reflect.DeepEqual(ids, []int64{10, 11, 12}) {
+		t.Errorf("ids = %v, want [10 11 12]", ids)
+	}
+	for i, want := range []int64{10, 11, 12} {
+		if users[i].UserID != want {
+			t.Errorf("users[%d].UserID = %d, want %d", i, users[i].UserID, want)
+		}
+	}
+}
+
+func TestBatchInsertIDsLastRowID(t *testing.T) {
+	serveInserts(t, nil, map[string][2]int{
+		"INSERT INTO orders (total) VALUES (?), (?), (?)": {7, 3},
+		`INSERT INTO "orders" (total) VALUES (?)`:         {8, 1},
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	type order struct {
+		ID    int
+		Total float64
+	}
+	orders := make([]order, 4)
+	ids, err := pool.BatchInsertIDs([]utils.Statement{
+		{SQL: "INSERT INTO orders (total) VALUES (?), (?), (?)", Params: []interface{}{1, 2, 3}},
+		{SQL: `INSERT INTO "orders" (total) VALUES (?)`, Params: []interface{}{4}},
+	}, cloudflare_d1_go.WithoutReturning(), cloudflare_d1_go.WriteIDsTo(&orders))
+	if err != nil {
+		t.Fatalf("BatchInsertIDs failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(ids, []int64{5, 6, 7, 8}) {
+		t.Errorf("ids = %v, want [5 6 7 8]", ids)
+	}
+	if orders[0].ID != 5 || orders[3].ID != 8 {
+		t.Errorf("orders = %+v", orders)
+	}
+}
+
+func TestBatchInsertIDsUnrecoverable(t *testing.T) {
+	batched := serveInserts(t, nil, nil)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	tests := map[string]utils.Statement{
+		"not AUTOINCREMENT": {SQL: "INSERT INTO users (name) VALUES (?)"},
+		"upsert":            {SQL: "INSERT INTO orders (id, total) VALUES (?, ?) ON CONFLICT(id) DO UPDATE SET total = excluded.total"},
+		"not an INSERT":     {SQL: "UPDATE users SET name = ?"},
+	}
+	for name, stmt := range tests {
+		_, err := client.BatchInsertIDs([]utils.Statement{stmt}, cloudflare_d1_go.WithoutReturning())
+		var recoveryErr *cloudflare_d1_go.IDRecoveryError
+		if !errors.As(err, &recoveryErr) {
+			t.Errorf("%s: err = %v, want *IDRecoveryError", name, err)
+		}
+	}
+
+	// A statement with its own RETURNING clause falls back to last_row_id
+	_, err := client.BatchInsertIDs([]utils.Statement{{SQL: "INSERT INTO users (name) VALUES (?) RETURNING name"}})
+	var recoveryErr *cloudflare_d1_go.IDRecoveryError
+	if !errors.As(err, &recoveryErr) {
+		t.Errorf("own RETURNING: err = %v, want *IDRecoveryError", err)
+	}
+
+	if len(*batched) != 0 {
+		t.Errorf("statements were sent: %q", *batched)
+	}
+}
@@ -0,0 +1,80 @@
+package cloudflared1_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestIterate(t *testing.T) {
+	var users []genericUser
+	for user, err := range utils.Iterate[genericUser](userRows()) {
+		if err != nil {
+			t.Fatalf("Iterate failed: %v", err)
+		}
+		users = append(users, user)
+	}
+	if want := []genericUser{{1, "Alice"}, {2, "Bob"}}; !reflect.DeepEqual(users, want) {
+		t.Errorf("users = %+v, want %+v", users, want)
+	}
+
+	var names []string
+	rows := utils.NewRows([]map[string]interface{}{{"name": "Alice"}, {"name": "Bob"}}, []string{"name"})
+	for name, err := range utils.Iterate[string](rows) {
+		if err != nil {
+			t.Fatalf("Iterate failed: %v", err)
+		}
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(names, []string{"Alice", "Bob"}) {
+		t.Errorf("names = %v", names)
+	}
+}
+
+func TestIterateBreakClosesRows(t *testing.T) {
+	rows := userRows()
+	for user, err := range utils.Iterate[genericUser](rows) {
+		if err != nil || user.Name != "Alice" {
+			t.Fatalf("first row = %+v, %v", user, err)
+		}
+		break
+	}
+	if rows.Next() {
+		t.Error("rows still iterate after the loop ended early")
+	}
+}
+
+func TestIterateStopsAtScanError(t *testing.T) {
+	rows := utils.NewRows([]map[string]interface{}{
+		{"id": float64(1), "name": "Alice"},
+		{"id": "two", "name": "Bob"},
+		{"id": float64(3), "name": "Carol"},
+	}, []string{"id", "name"})
+
+	var got []int64
+	var errs []error
+	for user, err := range utils.Iterate[genericUser](rows) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		got = append(got, user.ID)
+	}
+	if !reflect.DeepEqual(got, []int64{1}) || len(errs) != 1 || !strings.Contains(errs[0].Error(), "index 1") {
+		t.Errorf("ids = %v, errs = %v; want [1] and one error at index 1", got, errs)
+	}
+}
+
+func TestRowsIter(t *testing.T) {
+	var indexes []int
+	var names []interface{}
+	for i, row := range userRows().Iter() {
+		indexes = append(indexes, i)
+		names = append(names, row["name"])
+	}
+	if !reflect.DeepEqual(indexes, []int{0, 1}) || !reflect.DeepEqual(names, []interface{}{"Alice", "Bob"}) {
+		t.Errorf("indexes = %v, names = %v", indexes, names)
+	}
+}
@@ -0,0 +1,128 @@
+package cloudflared1_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type address struct {
+	City    string   `json:"city"`
+	Country string   `json:"country"`
+	Lines   []string `json:"lines,omitempty"`
+}
+
+type profile struct {
+	ID       int64                  `db:"id"`
+	Metadata map[string]interface{} `db:"metadata,json"`
+	Tags     []string               `db:"tags,json"`
+	Address  address                `db:"address,json"`
+	Previous *address               `db:"previous,json"`
+}
+
+// sentRow turns the statement sent by InsertStruct back into a result row,
+// the way D1 would return the inserted columns
+func sentRow(t *testing.T, stmt sentStatement, columns []string) map[string]interface{} {
+	t.Helper()
+	start := strings.Index(stmt.SQL, "VALUES (")
+	values := strings.Split(strings.TrimSuffix(stmt.SQL[start+len("VALUES ("):], ")"), ", ")
+	row := make(map[string]interface{}, len(columns))
+	params := stmt.Params
+	for i, col := range columns {
+		if values[i] == "NULL" {
+			row[col] = nil
+			continue
+		}
+		row[col], params = params[0], params[1:]
+	}
+	return row
+}
+
+func TestJSONColumnRoundTrip(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	want := profile{
+		ID:       1,
+		Metadata: map[string]interface{}{"theme": "dark", "beta": true, "limits": map[string]interface{}{"daily": float64(10)}},
+		Tags:     []string{"admin", "ops"},
+		Address:  address{City: "Lisbon", Country: "PT", Lines: []string{"Rua Augusta 1"}},
+	}
+	if _, err := client.InsertStruct("profiles", want); err != nil {
+		t.Fatalf("InsertStruct failed: %v", err)
+	}
+
+	stmt := (*sent)[0]
+	if stmt.SQL != `INSERT INTO "profiles" ("id", "metadata", "tags", "address", "previous") VALUES (?, ?, ?, ?, NULL)` {
+		t.Errorf("SQL = %s", stmt.SQL)
+	}
+	if stmt.Params[2] != `["admin","ops"]` || stmt.Params[3] != `{"city":"Lisbon","country":"PT","lines":["Rua Augusta 1"]}` {
+		t.Errorf("params = %q, want the fields as JSON", stmt.Params)
+	}
+
+	columns := []string{"id", "metadata", "tags", "address", "previous"}
+	row := sentRow(t, stmt, columns)
+	row["id"] = float64(1)
+	rows := utils.NewRows([]map[string]interface{}{row}, columns)
+	rows.Next()
+
+	var got profile
+	if err := rows.StructScan(&got); err != nil {
+		t.Fatalf("StructScan failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestJSONColumnNull(t *testing.T) {
+	columns := []string{"id", "metadata", "tags", "address", "previous"}
+	rows := utils.NewRows([]map[string]interface{}{
+		{"id": float64(1), "metadata": `{"a":1}`, "tags": `["x"]`, "address": `{"city":"Porto"}`, "previous": `{"city":"Faro"}`},
+		{"id": float64(2), "metadata": nil, "tags": nil, "address": nil, "previous": nil},
+	}, columns)
+
+	got := profile{Metadata: map[string]interface{}{"stale": true}}
+	rows.Next()
+	if err := rows.StructScan(&got); err != nil {
+		t.Fatalf("StructScan failed: %v", err)
+	}
+	if _, ok := got.Metadata["stale"]; ok || got.Previous == nil || got.Previous.City != "Faro" {
+		t.Errorf("got %+v, want the column alone in Metadata and Previous set", got)
+	}
+
+	rows.Next()
+	if err := rows.StructScan(&got); err != nil {
+		t.Fatalf("StructScan failed: %v", err)
+	}
+	if want := (profile{ID: 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestJSONColumnInvalid(t *testing.T) {
+	rows := utils.NewRows([]map[string]interface{}{{"tags": "admin,ops"}}, []string{"tags"})
+	rows.Next()
+	var got profile
+	if err := rows.StructScan(&got); err == nil || !strings.Contains(err.Error(), "field Tags") {
+		t.Errorf("err = %v, want an error for field Tags", err)
+	}
+}
+
+func TestNamedExecJSONColumn(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	p := profile{ID: 3, Tags: []string{"a"}}
+	if _, err := client.NamedExec("UPDATE profiles SET tags = :tags, metadata = :metadata WHERE id = :id", p); err != nil {
+		t.Fatalf("NamedExec failed: %v", err)
+	}
+	if want := []string{`["a"]`, "", "3"}; !reflect.DeepEqual((*sent)[0].Params, want) {
+		t.Errorf("params = %q, want %q", (*sent)[0].Params, want)
+	}
+}
@@ -0,0 +1,102 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// valuesList returns "(?), (?), ..." for n rows and n args
+func valuesList(n int) (string, []interface{}) {
+	tuples := make([]string, n)
+	args := make([]interface{}, n)
+	for i := range tuples {
+		tuples[i] = "(?)"
+		args[i] = i
+	}
+	return strings.Join(tuples, ", "), args
+}
+
+func TestStatementTooLarge(t *testing.T) {
+	requests := countRequests(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	huge := "INSERT INTO notes (body) VALUES ('" + strings.Repeat("x", 150000) + "')"
+	if _, err := client.Exec(huge); !errors.Is(err, cloudflare_d1_go.ErrStatementTooLarge) {
+		t.Errorf("Exec of 150 KB of SQL: err = %v, want ErrStatementTooLarge", err)
+	}
+	values, args := valuesList(101)
+	if _, err := client.Exec("INSERT INTO t (n) VALUES "+values, args...); !errors.Is(err, cloudflare_d1_go.ErrStatementTooLarge) ||
+		!strings.Contains(err.Error(), "101 bound parameters, the limit is 100") {
+		t.Errorf("Exec with 101 params: err = %v, want ErrStatementTooLarge", err)
+	}
+
+	_, err := client.Batch([]utils.Statement{{SQL: "DELETE FROM notes"}, {SQL: huge}})
+	var batchErr *utils.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, cloudflare_d1_go.ErrStatementTooLarge) {
+		t.Errorf("Batch err = %v, want ErrStatementTooLarge for statement 1", err)
+	}
+	if *requests != 0 {
+		t.Errorf("%d requests sent, want none", *requests)
+	}
+
+	// Raised limits let the same statements through
+	client = cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		MaxBoundParams:    200,
+		MaxStatementBytes: 200000,
+	})
+	client.DatabaseID = "db-1"
+	if _, err := client.Exec(huge); err != nil {
+		t.Errorf("Exec with a raised size limit failed: %v", err)
+	}
+	if _, err := client.Exec("INSERT INTO t (n) VALUES "+values, args...); err != nil {
+		t.Errorf("Exec with a raised parameter limit failed: %v", err)
+	}
+}
+
+func TestBulkInsertSplitsByConfiguredLimits(t *testing.T) {
+	requests := serveBulk(t, "")
+
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		MaxBoundParams:    10,
+		MaxStatementBytes: 2000,
+	})
+	client.DatabaseID = "db-1"
+
+	rows := make([][]interface{}, 100)
+	for i := range rows {
+		rows[i] = []interface{}{strings.Repeat("x", 300), i}
+	}
+	n, err := client.BulkInsert("notes", []string{"body", "n"}, rows, cloudflare_d1_go.BulkStatementsPerRequest(8))
+	if err != nil || n != 100 {
+		t.Fatalf("BulkInsert = %d, %v; want 100 rows", n, err)
+	}
+
+	// Ten parameters allow five rows per statement, before 2000 bytes are reached
+	statements := 0
+	for _, request := range *requests {
+		for _, stmt := range request {
+			statements++
+			size := len(stmt.SQL)
+			for _, p := range stmt.Params {
+				size += len(p)
+			}
+			if len(stmt.Params) > 10 || size > 2000 {
+				t.Errorf("statement with %d params and %d bytes is over the limits", len(stmt.Params), size)
+			}
+		}
+	}
+	if len(*requests) != 3 || statements != 20 {
+		t.Errorf("sent %d statements in %d requests, want 20 in 3", statements, len(*requests))
+	}
+
+	rows = [][]interface{}{{strings.Repeat("x", 2500), 1}}
+	if _, err := client.BulkInsert("notes", []string{"body", "n"}, rows); !errors.Is(err, cloudflare_d1_go.ErrStatementTooLarge) {
+		t.Errorf("BulkInsert of a row over the limit: err = %v, want ErrStatementTooLarge", err)
+	}
+}
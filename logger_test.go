@@ -0,0 +1,177 @@
+package cloudflared1_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// entryRecorder collects log entries
+type entryRecorder struct {
+	mu      sync.Mutex
+	entries []cloudflare_d1_go.LogEntry
+}
+
+func (r *entryRecorder) log(ctx context.Context, entry cloudflare_d1_go.LogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+func serveRowsRead(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, map[string]interface{}{"result": []interface{}{map[string]interface{}{"uuid": "db-1", "name": "main"}}, "success": true})
+			return
+		}
+		meta := map[string]interface{}{"rows_read": 3, "rows_written": 1}
+		if strings.Contains(r.URL.Path, "db-missing") {
+			writeJSON(w, errorResponse(7404, "database not found"))
+			return
+		}
+		writeJSON(w, successResponse(queryResult([]string{"id"}, [][]interface{}{{1}}, meta), queryResult(nil, nil, meta)))
+	})
+}
+
+func TestLoggerRedactsParams(t *testing.T) {
+	serveRowsRead(t)
+
+	var rec entryRecorder
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.SetLogger(rec.log)
+
+	var users []genericUser
+	_ = client.Select(&users, "SELECT id FROM users WHERE name = ? AND age > ?", "alice@example.com", 30)
+	_, _ = client.Query("SELECT id FROM users WHERE name = ?", []string{"bob"})
+
+	if len(rec.entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(rec.entries))
+	}
+	entry := rec.entries[0]
+	if entry.Operation != cloudflare_d1_go.OpQuery || entry.SQL != "SELECT id FROM users WHERE name = ? AND age > ?" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.ParamCount != 2 || !reflect.DeepEqual(entry.ParamTypes, []string{"string", "int"}) || entry.Params != nil {
+		t.Errorf("params: count %d, types %v, values %v; want 2, [string int] and no values", entry.ParamCount, entry.ParamTypes, entry.Params)
+	}
+	if entry.RowsRead != 6 || entry.RowsWritten != 2 || entry.Duration <= 0 || entry.Err != nil {
+		t.Errorf("entry = %+v, want 6 rows read, 2 written, a duration and no error", entry)
+	}
+	if !reflect.DeepEqual(rec.entries[1].ParamTypes, []string{"string"}) {
+		t.Errorf("ParamTypes of Query = %v, want [string]", rec.entries[1].ParamTypes)
+	}
+}
+
+func TestLoggerParamValuesAndErrors(t *testing.T) {
+	serveRowsRead(t)
+
+	var rec entryRecorder
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.SetLogger(rec.log, cloudflare_d1_go.LogParamValues())
+
+	_, _ = client.Batch([]utils.Statement{
+		{SQL: "UPDATE users SET age = ? WHERE id = ?", Params: []interface{}{31, int64(1)}},
+		{SQL: "DELETE FROM sessions WHERE user_id = ?", Params: []interface{}{nil}},
+	})
+	_, _ = client.WithDatabase("db-missing").Exec("DELETE FROM users")
+	_, _ = client.ListDB()
+
+	if len(rec.entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(rec.entries))
+	}
+	batch := rec.entries[0]
+	if batch.Operation != cloudflare_d1_go.OpBatch || batch.SQL != "UPDATE users SET age = ? WHERE id = ?; DELETE FROM sessions WHERE user_id = nullif(?, '')" {
+		t.Errorf("batch entry = %+v", batch)
+	}
+	if !reflect.DeepEqual(batch.Params, []string{"31", "1", ""}) || !reflect.DeepEqual(batch.ParamTypes, []string{"int", "int64", "nil"}) {
+		t.Errorf("batch params = %v, types = %v", batch.Params, batch.ParamTypes)
+	}
+	if err := rec.entries[1].Err; err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Errorf("Err = %v, want the API error", err)
+	}
+	if list := rec.entries[2]; list.Operation != cloudflare_d1_go.OpListDatabases || list.SQL != "" {
+		t.Errorf("list entry = %+v", list)
+	}
+}
+
+func TestPoolLoggerCoversConnectAndMigrations(t *testing.T) {
+	serveRowsRead(t)
+
+	var rec entryRecorder
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetLogger(rec.log)
+	if err := pool.Connect("main"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE accounts (id INTEGER)"),
+	}}
+	_, _ = migrations.Exec(pool, source, migrations.Up)
+
+	if len(rec.entries) == 0 || rec.entries[0].Operation != cloudflare_d1_go.OpListDatabases {
+		t.Fatalf("entries = %+v, want the lookup of Connect first", rec.entries)
+	}
+	var found bool
+	for _, entry := range rec.entries {
+		found = found || strings.Contains(entry.SQL, "CREATE TABLE accounts")
+	}
+	if !found {
+		t.Errorf("no entry carries the migration SQL: %+v", rec.entries)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	serveRowsRead(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.SetLogger(cloudflare_d1_go.SlogLogger(logger))
+
+	_, _ = client.Exec("UPDATE users SET token = ?", "s3cret")
+
+	out := buf.String()
+	for _, want := range []string{"level=DEBUG", `msg="d1 query"`, `sql="UPDATE users SET token = ?"`, "param_count=1", "rows_read=6"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log %q does not contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("log %q contains the parameter value", out)
+	}
+}
+
+func TestLoggerMayUsePoolDuringConnect(t *testing.T) {
+	serveRowsRead(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetLogger(func(ctx context.Context, entry cloudflare_d1_go.LogEntry) {
+		// Deadlocks if the lookup of Connect runs while the pool is locked
+		_ = pool.GetCurrentDB()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- pool.Connect("main") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connect did not return, the logger blocked on a pool lock")
+	}
+}
@@ -0,0 +1,146 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Name":       "name",
+		"UserID":     "user_id",
+		"CreatedAt":  "created_at",
+		"HTTPStatus": "http_status",
+		"Address2":   "address2",
+		"ID":         "id",
+	}
+	for in, want := range tests {
+		if got := utils.SnakeCase(in); got != want {
+			t.Errorf("SnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type untaggedUser struct {
+	UserID    int
+	FirstName string
+	Age       int
+}
+
+func TestDefaultMapperScansSnakeCaseAndLegacyColumns(t *testing.T) {
+	rows := utils.NewRows([]map[string]interface{}{
+		{"user_id": float64(1), "firstname": "Alice", "age": float64(30)},
+	}, []string{"user_id", "firstname", "age"})
+
+	rows.Next()
+	var u untaggedUser
+	if err := rows.StructScan(&u); err != nil {
+		t.Fatalf("StructScan failed: %v", err)
+	}
+	if u.UserID != 1 || u.FirstName != "Alice" || u.Age != 30 {
+		t.Errorf("unexpected scan result %+v", u)
+	}
+}
+
+func TestCustomNameMapper(t *testing.T) {
+	upper := utils.NameMapper(strings.ToUpper)
+
+	columns := utils.StructColumns(reflect.TypeOf(untaggedUser{}), upper)
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.Column)
+	}
+	if strings.Join(names, ",") != "USERID,FIRSTNAME,AGE" {
+		t.Errorf("StructColumns = %v", names)
+	}
+
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult(
+			[]string{"USERID", "FIRSTNAME", "AGE"},
+			[][]interface{}{{float64(7), "Bob", float64(25)}},
+			nil,
+		)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.SetNameMapper(upper)
+
+	var users []untaggedUser
+	if err := client.Select(&users, "SELECT USERID, FIRSTNAME, AGE FROM LEGACY_USERS"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(users) != 1 || users[0].UserID != 7 || users[0].FirstName != "Bob" || users[0].Age != 25 {
+		t.Errorf("unexpected users %+v", users)
+	}
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetNameMapper(upper)
+	_ = pool.ConnectWithID("legacy", "db-1")
+
+	var user untaggedUser
+	if err := pool.Get(&user, "SELECT USERID, FIRSTNAME, AGE FROM LEGACY_USERS LIMIT 1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if user.FirstName != "Bob" {
+		t.Errorf("unexpected user %+v", user)
+	}
+}
+
+func TestCustomNameMapperRoundTrip(t *testing.T) {
+	var sent []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			SQL   string          `json:"sql"`
+			Batch []sentStatement `json:"batch"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Batch != nil {
+			for _, stmt := range body.Batch {
+				sent = append(sent, stmt.SQL)
+			}
+			writeJSON(w, successResponse(queryResult(nil, nil, map[string]interface{}{"changes": 2})))
+			return
+		}
+		sent = append(sent, body.SQL)
+		writeJSON(w, successResponse(queryResult(
+			[]string{"USERID", "FIRSTNAME", "AGE"},
+			[][]interface{}{{float64(7), "Bob", float64(25)}},
+			map[string]interface{}{"changes": 1},
+		)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.SetNameMapper(utils.NameMapper(strings.ToUpper))
+
+	if _, err := client.InsertStruct("LEGACY_USERS", untaggedUser{UserID: 7, FirstName: "Bob", Age: 25}); err != nil {
+		t.Fatalf("InsertStruct failed: %v", err)
+	}
+	users := []untaggedUser{{UserID: 8, FirstName: "Carol", Age: 41}, {UserID: 9, FirstName: "Dan", Age: 19}}
+	if _, err := client.BulkInsertStructs("LEGACY_USERS", users); err != nil {
+		t.Fatalf("BulkInsertStructs failed: %v", err)
+	}
+	var user untaggedUser
+	if err := client.Get(&user, "SELECT USERID, FIRSTNAME, AGE FROM LEGACY_USERS WHERE USERID = ?", 7); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	want := []string{
+		`INSERT INTO "LEGACY_USERS" ("USERID", "FIRSTNAME", "AGE") VALUES (?, ?, ?)`,
+		`INSERT INTO "LEGACY_USERS" ("USERID", "FIRSTNAME", "AGE") VALUES (?, ?, ?), (?, ?, ?)`,
+		"SELECT USERID, FIRSTNAME, AGE FROM LEGACY_USERS WHERE USERID = ?",
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %q\nwant %q", sent, want)
+	}
+	if user != (untaggedUser{UserID: 7, FirstName: "Bob", Age: 25}) {
+		t.Errorf("scanned %+v", user)
+	}
+}
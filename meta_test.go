@@ -0,0 +1,112 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// loadFixture decodes a response from testdata
+func loadFixture(t *testing.T, name string) *utils.APIResponse {
+	t.Helper()
+	body, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	var res utils.APIResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("failed to decode fixture: %v", err)
+	}
+	return &res
+}
+
+func TestMetaWithReplicationFields(t *testing.T) {
+	res := loadFixture(t, "meta_replicated.json")
+
+	rows, result, err := res.ResultSet(0)
+	if err != nil {
+		t.Fatalf("ResultSet failed: %v", err)
+	}
+	want := utils.Meta{
+		Duration:       0.31,
+		RowsRead:       120,
+		SizeAfter:      36864,
+		ServedByRegion: "WEUR",
+	}
+	if got := rows.Meta(); got != want {
+		t.Errorf("Rows.Meta() = %+v, want %+v", got, want)
+	}
+	if got := result.Meta(); got != want {
+		t.Errorf("Result.Meta() = %+v, want %+v", got, want)
+	}
+
+	_, result, err = res.ResultSet(1)
+	if err != nil {
+		t.Fatalf("ResultSet failed: %v", err)
+	}
+	want = utils.Meta{
+		Duration:        1.5,
+		RowsRead:        1,
+		RowsWritten:     3,
+		SizeAfter:       40960,
+		ChangedDB:       true,
+		ServedByRegion:  "WEUR",
+		ServedByPrimary: true,
+	}
+	if got := result.Meta(); got != want {
+		t.Errorf("Result.Meta() = %+v, want %+v", got, want)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("RowsAffected = %d, want changes, not rows_written", n)
+	}
+}
+
+func TestMetaWithoutOptionalFields(t *testing.T) {
+	serveFixture(t, "raw_insert.json")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	result, err := client.ExecResult("INSERT INTO users (name) VALUES (?), (?)", "a", "b")
+	if err != nil {
+		t.Fatalf("ExecResult failed: %v", err)
+	}
+	want := utils.Meta{Duration: 0.4, RowsWritten: 2, ChangedDB: true}
+	if got := result.Meta(); got != want {
+		t.Errorf("Result.Meta() = %+v, want %+v", got, want)
+	}
+
+	// A response without meta at all
+	rows, err := (&utils.APIResponse{
+		Success: true,
+		Result:  []interface{}{map[string]interface{}{"results": map[string]interface{}{"columns": []interface{}{}, "rows": []interface{}{}}}},
+	}).ToRows()
+	if err != nil {
+		t.Fatalf("ToRows failed: %v", err)
+	}
+	if got := rows.Meta(); got != (utils.Meta{}) {
+		t.Errorf("Rows.Meta() = %+v, want zero", got)
+	}
+}
+
+func TestSelectMetaThroughQueryEndpoint(t *testing.T) {
+	serveEndpointFixtures(t, "raw_users.json", "query_users.json")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.UseQueryEndpoint(true)
+
+	res, err := client.Query("SELECT name, id, email FROM users", nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	rows, err := res.ToRows()
+	if err != nil {
+		t.Fatalf("ToRows failed: %v", err)
+	}
+	if got := rows.Meta(); got.RowsRead != 2 || got.Duration != 0.2 {
+		t.Errorf("Rows.Meta() = %+v, want rows_read 2 and duration 0.2", got)
+	}
+}
@@ -0,0 +1,74 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type observation struct {
+	op               string
+	err              error
+	read, written    int64
+	positiveDuration bool
+}
+
+// fakeCollector records observations and Connect cache lookups
+type fakeCollector struct {
+	mu           sync.Mutex
+	observations []observation
+	hits, misses int
+}
+
+func (f *fakeCollector) ObserveQuery(op string, duration time.Duration, err error, rowsRead, rowsWritten int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.observations = append(f.observations, observation{op, err, rowsRead, rowsWritten, duration > 0})
+}
+
+func (f *fakeCollector) ObserveConnect(cacheHit bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cacheHit {
+		f.hits++
+	} else {
+		f.misses++
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	serveRowsRead(t)
+
+	var collector fakeCollector
+	pool := cloudflare_d1_go.NewConnectionPoolWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		Metrics: &collector,
+	})
+	_ = pool.Connect("main")
+	_ = pool.Connect("main")
+	_, _ = pool.Batch([]utils.Statement{{SQL: "UPDATE t SET n = 1"}, {SQL: "SELECT n FROM t"}})
+	_ = pool.ConnectWithID("missing", "db-missing")
+	// Without looking the name up again after the failure
+	pool.SetAutoReconnect(false)
+	_, _ = pool.QueryDB("missing", "SELECT 1", nil)
+
+	if collector.hits != 1 || collector.misses != 1 {
+		t.Errorf("cache hits = %d, misses = %d, want 1 and 1", collector.hits, collector.misses)
+	}
+	if len(collector.observations) != 3 {
+		t.Fatalf("observations = %+v, want 3", collector.observations)
+	}
+	if o := collector.observations[0]; o.op != cloudflare_d1_go.OpListDatabases || o.err != nil {
+		t.Errorf("first observation = %+v, want the lookup of Connect", o)
+	}
+	if o := collector.observations[1]; o.op != cloudflare_d1_go.OpBatch || o.read != 6 || o.written != 2 || !o.positiveDuration {
+		t.Errorf("batch observation = %+v", o)
+	}
+	var apiErr *utils.APIError
+	if o := collector.observations[2]; !errors.As(o.err, &apiErr) || apiErr.Code != 7404 {
+		t.Errorf("failed query observation = %+v, want the API error", o)
+	}
+}
@@ -0,0 +1,111 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// ChecksumError reports applied migrations whose source changed since they
+// were applied, so the database no longer matches what the source describes
+type ChecksumError struct {
+	IDs []string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("applied migrations were edited after being applied: %s (restore them, or call RepairChecksums if the edit was intended)",
+		strings.Join(e.IDs, ", "))
+}
+
+// checksum returns m.Checksum, or the SHA-256 of the statements of a
+// migration that was not parsed from a file
+func (m Migration) checksum() string {
+	if m.Checksum != "" {
+		return m.Checksum
+	}
+
+	hash := sha256.New()
+	section := func(dir string, disableTransaction bool, statements []string) {
+		fmt.Fprintf(hash, "-- +migrate %s", dir)
+		if disableTransaction {
+			fmt.Fprint(hash, " notransaction")
+		}
+		fmt.Fprintln(hash)
+		for _, stmt := range statements {
+			fmt.Fprintf(hash, "%s;\n", stmt)
+		}
+	}
+	section("Up", m.DisableTransactionUp, m.Up)
+	section("Down", m.DisableTransactionDown, m.Down)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// verifyChecksums compares the recorded checksums of applied migrations
+// with their source. Migrations applied without a checksum are not checked.
+func verifyChecksums(all []*Migration, checksums map[string]string) error {
+	var edited []string
+	for _, m := range all {
+		if recorded, ok := checksums[m.Id]; ok && recorded != m.checksum() {
+			edited = append(edited, m.Id)
+		}
+	}
+	if len(edited) > 0 {
+		return &ChecksumError{IDs: edited}
+	}
+	return nil
+}
+
+// RepairChecksums records the current checksum of every applied migration of
+// m, accepting edits made to them on purpose. It also fills in the checksums
+// of migrations applied before checksums were recorded. It returns the number
+// of migrations whose checksum was updated.
+func RepairChecksums(client cloudflare_d1_go.Queryer, m MigrationSource) (int, error) {
+	return migSet.RepairChecksums(client, m)
+}
+
+func (ms MigrationSet) RepairChecksums(client cloudflare_d1_go.Queryer, m MigrationSource) (int, error) {
+	table, err := ms.quotedTableName()
+	if err != nil {
+		return 0, fmt.Errorf("invalid migration table name: %w", err)
+	}
+	if err := ms.ensureTable(client, table); err != nil {
+		return 0, fmt.Errorf("failed to ensure migration table: %w", checkCredentials(client, err))
+	}
+	applied, checksums, err := ms.getAppliedMigrations(client, table, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get applied migrations: %w", checkCredentials(client, err))
+	}
+
+	allMigrations, err := m.FindMigrations()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find migrations: %w", err)
+	}
+	if err := validateMigrations(allMigrations); err != nil {
+		return 0, fmt.Errorf("invalid migrations: %w", err)
+	}
+
+	isApplied := make(map[string]bool, len(applied))
+	for _, id := range applied {
+		isApplied[id] = true
+	}
+	var statements []utils.Statement
+	for _, migration := range allMigrations {
+		if sum := migration.checksum(); isApplied[migration.Id] && checksums[migration.Id] != sum {
+			statements = append(statements, utils.Statement{
+				SQL:    fmt.Sprintf("UPDATE %s SET checksum = ? WHERE id = ?;", table),
+				Params: []interface{}{sum, migration.Id},
+			})
+		}
+	}
+	if len(statements) == 0 {
+		return 0, nil
+	}
+	if _, err := client.Batch(statements); err != nil {
+		return 0, fmt.Errorf("failed to update checksums: %w", err)
+	}
+	return len(statements), nil
+}
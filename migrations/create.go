@@ -0,0 +1,120 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// createVersionLayout is the UTC timestamp prefixing the IDs of created
+// migrations
+const createVersionLayout = "20060102150405"
+
+var nonWordRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes an empty migration named after name to dir, e.g.
+// 20240601123000_add_users_index.sql, and returns its path:
+//
+//	path, err := migrations.Create("migrations", "add users index")
+//
+// The ID is the current UTC time. If a .sql file of dir already has that
+// version or a later one, e.g. because it was created within the same second,
+// the ID is one second after the latest, so new migrations always sort last.
+//
+// applied lists the IDs of applied migrations, see GetRecords: Create fails if
+// the new migration would sort before one of them, which would then never run
+// on that database.
+func Create(dir, name string, applied ...string) (string, error) {
+	return CreateFromTemplate(dir, name, "", "", applied...)
+}
+
+// CreateFromTemplate is like Create, with up and down as the initial
+// statements of the migration
+func CreateFromTemplate(dir, name, up, down string, applied ...string) (string, error) {
+	slug := strings.Trim(nonWordRegex.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "", fmt.Errorf("invalid migration name %q", name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	version, err := nextVersion(dir)
+	if err != nil {
+		return "", err
+	}
+
+	content := fmt.Sprintf("-- +migrate Up\n%s\n\n-- +migrate Down\n%s\n", strings.TrimSpace(up), strings.TrimSpace(down))
+	for {
+		id := fmt.Sprintf("%d_%s.sql", version, slug)
+		if err := checkAfterApplied(id, applied); err != nil {
+			return "", err
+		}
+
+		path := filepath.Join(dir, id)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			// Created concurrently with the same name
+			version = versionAfter(version)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.WriteString(content); err != nil {
+			f.Close()
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
+
+// nextVersion returns the version of a migration created now in dir
+func nextVersion(dir string) (int64, error) {
+	version, err := strconv.ParseInt(time.Now().UTC().Format(createVersionLayout), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		m := Migration{Id: entry.Name()}
+		if !strings.HasSuffix(m.Id, ".sql") || !m.isNumeric() {
+			continue
+		}
+		if v := m.VersionInt(); v >= version {
+			version = versionAfter(v)
+		}
+	}
+	return version, nil
+}
+
+// versionAfter returns the version following v: one second later for
+// timestamps, or the next number for other versions
+func versionAfter(v int64) int64 {
+	t, err := time.Parse(createVersionLayout, strconv.FormatInt(v, 10))
+	if err != nil {
+		return v + 1
+	}
+	next, _ := strconv.ParseInt(t.Add(time.Second).Format(createVersionLayout), 10, 64)
+	return next
+}
+
+// checkAfterApplied fails if the migration id sorts before an applied one
+func checkAfterApplied(id string, applied []string) error {
+	m := Migration{Id: id}
+	for _, a := range applied {
+		if m.Less(&Migration{Id: a}) {
+			return fmt.Errorf("migration %s would sort before the applied migration %s and never run", id, a)
+		}
+	}
+	return nil
+}
@@ -1,189 +1,514 @@
-package migrations
-
-import (
-	"fmt"
-	"time"
-
-	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
-)
-
-type MigrationSet struct {
-	TableName string
-}
-
-var migSet = MigrationSet{}
-
-func (ms MigrationSet) getTableName() string {
-	if ms.TableName == "" {
-		return "d1_migrations"
-	}
-	return ms.TableName
-}
-
-// SetTable sets the name of the table used to store migration info.
-func SetTable(name string) {
-	migSet.TableName = name
-}
-
-type MigrationRecord struct {
-	Id        string    `json:"id"`
-	AppliedAt time.Time `json:"applied_at"`
-}
-
-// Exec executes a set of migrations
-func Exec(client *cloudflare_d1_go.Client, m MigrationSource, dir MigrationDirection) (int, error) {
-	return ExecMax(client, m, dir, 0)
-}
-
-// ExecMax executes a set of migrations with a limit
-func ExecMax(client *cloudflare_d1_go.Client, m MigrationSource, dir MigrationDirection, max int) (int, error) {
-	return migSet.ExecMax(client, m, dir, max)
-}
-
-func (ms MigrationSet) ExecMax(client *cloudflare_d1_go.Client, m MigrationSource, dir MigrationDirection, max int) (int, error) {
-	// 1. Ensure migration table exists
-	err := ms.ensureTable(client)
-	if err != nil {
-		return 0, fmt.Errorf("failed to ensure migration table: %w", err)
-	}
-
-	// 2. Get applied migrations
-	applied, err := ms.getAppliedMigrations(client)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get applied migrations: %w", err)
-	}
-
-	// 3. Get all available migrations
-	allMigrations, err := m.FindMigrations()
-	if err != nil {
-		return 0, fmt.Errorf("failed to find migrations: %w", err)
-	}
-
-	// 4. Plan migrations
-	toApply := ms.planMigrations(allMigrations, applied, dir, max)
-
-	// 5. Apply migrations
-	count := 0
-	for _, migration := range toApply {
-		err := ms.applyMigration(client, migration, dir)
-		if err != nil {
-			return count, fmt.Errorf("failed to apply migration %s: %w", migration.Id, err)
-		}
-		count++
-	}
-
-	return count, nil
-}
-
-func (ms MigrationSet) ensureTable(client *cloudflare_d1_go.Client) error {
-	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-		id TEXT PRIMARY KEY,
-		applied_at DATETIME
-	);`, ms.getTableName())
-
-	_, err := client.CreateTable(query)
-	return err
-}
-
-func (ms MigrationSet) getAppliedMigrations(client *cloudflare_d1_go.Client) ([]string, error) {
-	query := fmt.Sprintf("SELECT id FROM %s ORDER BY id ASC;", ms.getTableName())
-	res, err := client.Query(query, nil)
-	if err != nil {
-		// If table doesn't exist yet (should be handled by ensureTable, but just in case)
-		return nil, err
-	}
-
-	// Use ToRows to iterate
-	rows, err := res.ToRows()
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var ids []string
-	for rows.Next() {
-		// We need to scan into a struct or map usually with this client,
-		// but let's see if we can scan into a simple string if it's one column?
-		// The client's StructScan expects a struct.
-		var record struct {
-			Id string `db:"id"`
-		}
-		if err := rows.StructScan(&record); err != nil {
-			return nil, err
-		}
-		ids = append(ids, record.Id)
-	}
-	return ids, nil
-}
-
-func (ms MigrationSet) planMigrations(all []*Migration, applied []string, dir MigrationDirection, max int) []*Migration {
-	appliedMap := make(map[string]bool)
-	for _, id := range applied {
-		appliedMap[id] = true
-	}
-
-	var toApply []*Migration
-
-	if dir == Up {
-		for _, m := range all {
-			if !appliedMap[m.Id] {
-				toApply = append(toApply, m)
-			}
-		}
-	} else {
-		// Down: find applied migrations in reverse order
-		// We need to map applied IDs back to Migration objects
-		migrationMap := make(map[string]*Migration)
-		for _, m := range all {
-			migrationMap[m.Id] = m
-		}
-
-		// Iterate applied in reverse
-		for i := len(applied) - 1; i >= 0; i-- {
-			id := applied[i]
-			if m, ok := migrationMap[id]; ok {
-				toApply = append(toApply, m)
-			}
-		}
-	}
-
-	if max > 0 && len(toApply) > max {
-		toApply = toApply[:max]
-	}
-
-	return toApply
-}
-
-func (ms MigrationSet) applyMigration(client *cloudflare_d1_go.Client, m *Migration, dir MigrationDirection) error {
-	queries := m.Up
-	if dir == Down {
-		queries = m.Down
-	}
-
-	// Execute queries
-	// TODO: Transaction support if D1 supports it via batch?
-	// For now, execute sequentially.
-	for _, q := range queries {
-		_, err := client.Query(q, nil)
-		if err != nil {
-			return err
-		}
-	}
-
-	// Record migration
-	if dir == Up {
-		query := fmt.Sprintf("INSERT INTO %s (id, applied_at) VALUES (?, ?);", ms.getTableName())
-		_, err := client.Query(query, []string{m.Id, time.Now().Format(time.RFC3339)})
-		if err != nil {
-			return err
-		}
-	} else {
-		query := fmt.Sprintf("DELETE FROM %s WHERE id = ?;", ms.getTableName())
-		_, err := client.Query(query, []string{m.Id})
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// MigrationSet runs migrations with its own migrations table and options.
+// The package-level functions use a default set, configured by SetTable and
+// SetSkipChecksumValidation; see NewMigrationSet for sets of their own, e.g.
+// one per schema of a database.
+type MigrationSet struct {
+	TableName string
+
+	// Options apply to every run of the set, except ExecMaxWithOptions
+	// which is given its own
+	Options ExecOptions
+}
+
+var migSet = MigrationSet{}
+
+func (ms MigrationSet) getTableName() string {
+	if ms.TableName == "" {
+		return "d1_migrations"
+	}
+	return ms.TableName
+}
+
+// quotedTableName returns the migrations table name quoted for use in SQL
+func (ms MigrationSet) quotedTableName() (string, error) {
+	return utils.QuoteIdentifier(ms.getTableName())
+}
+
+// SetTable sets the name of the table used to store migration info.
+func SetTable(name string) {
+	migSet.TableName = name
+}
+
+// SetSkipChecksumValidation sets whether edited applied migrations are
+// ignored instead of failing the run, see ChecksumError.
+func SetSkipChecksumValidation(skip bool) {
+	migSet.Options.SkipChecksumValidation = skip
+}
+
+// MigrationRecord is a row of the migrations table, see GetRecords
+type MigrationRecord struct {
+	Id        string    `json:"id"`
+	AppliedAt time.Time `json:"applied_at"`
+	// Checksum is empty for migrations applied before checksums were recorded
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Exec executes a set of migrations.
+// client is usually a *Client or a *ConnectionPool, but any Queryer works,
+// such as the fake of the testd1 package.
+func Exec(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection) (int, error) {
+	return migSet.Exec(client, m, dir)
+}
+
+func (ms MigrationSet) Exec(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection) (int, error) {
+	return ms.ExecMax(client, m, dir, 0)
+}
+
+// Setup returns a function applying all up migrations of m, for the Setup
+// option of ConnectionPool.ConnectOrCreate
+func Setup(m MigrationSource) func(cloudflare_d1_go.Queryer) error {
+	return func(client cloudflare_d1_go.Queryer) error {
+		_, err := Exec(client, m, Up)
+		return err
+	}
+}
+
+// ExecMax executes a set of migrations with a limit
+func ExecMax(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, max int) (int, error) {
+	return migSet.ExecMax(client, m, dir, max)
+}
+
+func (ms MigrationSet) ExecMax(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, max int) (int, error) {
+	applied, err := ms.ExecMaxWithOptions(client, m, dir, max, ms.Options)
+	return len(applied), err
+}
+
+// ExecOptions changes how migrations are run, see ExecMaxWithOptions and
+// MigrationSet.Options
+type ExecOptions struct {
+	// DryRun plans the migrations without executing anything: the
+	// migrations table is only read, and a missing table means no migration
+	// has been applied yet.
+	DryRun bool
+
+	// SkipChecksumValidation runs migrations even if applied ones were
+	// edited since, see ChecksumError
+	SkipChecksumValidation bool
+
+	// Logger receives a record of every migration applied or planned, and
+	// of waits for the lock, and a debug record of every statement run with
+	// the rows it wrote. nil disables logging.
+	Logger *slog.Logger
+
+	// OnMigration is called before and after each migration is applied,
+	// e.g. to report the progress of a long run. It is not called by dry
+	// runs.
+	OnMigration func(MigrationEvent)
+
+	// Lock holds an advisory lock, a row of the <table>_lock table, for the
+	// run, so concurrent runs with Lock set do not apply the same migrations
+	// twice. The lock is best-effort: D1 has no transaction spanning several
+	// requests, so the lock expires LockTTL after its last renewal, and a run
+	// stalled for longer than that can lose it to another run.
+	// Expiry compares the clocks of the machines running migrations.
+	Lock bool
+	// LockTimeout is how long to wait for a lock held by another run before
+	// failing with ErrMigrationLocked; 0 fails at once
+	LockTimeout time.Duration
+	// LockTTL is how long the lock outlives a run that stops renewing it,
+	// e.g. because it crashed; 0 means one minute. It is renewed every
+	// third of LockTTL.
+	LockTTL time.Duration
+	// Now returns the current time used for lock expiry; nil means time.Now
+	Now func() time.Time
+
+	// Strategy decides what happens to pending migrations sorting before
+	// the latest applied one; the zero value, Allow, applies them.
+	// See PlannedMigration.OutOfOrder.
+	Strategy OutOfOrderStrategy
+}
+
+// MigrationEvent is a migration about to be applied, or applied, passed to
+// ExecOptions.OnMigration
+type MigrationEvent struct {
+	Id        string
+	Direction MigrationDirection
+	// Statements is the number of statements of the migration, without its
+	// bookkeeping; the function of a Go migration counts as one
+	Statements int
+
+	// Done is false before the migration runs, and true once it ran, with
+	// its Duration and Err
+	Done     bool
+	Duration time.Duration
+	Err      error
+}
+
+// onMigration calls opts.OnMigration if set
+func (opts ExecOptions) onMigration(event MigrationEvent) {
+	if opts.OnMigration != nil {
+		opts.OnMigration(event)
+	}
+}
+
+// logger returns opts.Logger, or a logger discarding everything
+func (opts ExecOptions) logger() *slog.Logger {
+	if opts.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return opts.Logger
+}
+
+// ExecMaxWithOptions executes a set of migrations with a limit, like ExecMax,
+// and returns the migrations applied with the statements they ran. The last
+// query of each is the bookkeeping INSERT or DELETE on the migrations table,
+// with ? placeholders for its parameters.
+// With opts.DryRun, it returns the migrations that would be applied instead,
+// so the plan can be reviewed or diffed before touching a production database.
+func ExecMaxWithOptions(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, max int, opts ExecOptions) ([]*PlannedMigration, error) {
+	return migSet.ExecMaxWithOptions(client, m, dir, max, opts)
+}
+
+func (ms MigrationSet) ExecMaxWithOptions(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, max int, opts ExecOptions) ([]*PlannedMigration, error) {
+	return ms.exec(client, m, dir, opts, func(all []*Migration, applied []string) ([]*Migration, error) {
+		return ms.planMigrations(all, applied, dir, max), nil
+	})
+}
+
+// exec applies the migrations chosen by plan among all the migrations of m,
+// given the IDs of the applied ones
+func (ms MigrationSet) exec(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, opts ExecOptions, plan func(all []*Migration, applied []string) ([]*Migration, error)) ([]*PlannedMigration, error) {
+	table, err := ms.quotedTableName()
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration table name: %w", err)
+	}
+
+	var lock *migrationLock
+	if opts.Lock && !opts.DryRun {
+		lock, err = ms.acquireLock(client, opts)
+		if errors.Is(err, ErrMigrationLocked) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", checkCredentials(client, err))
+		}
+		defer lock.release()
+	}
+
+	// 1. Ensure migration table exists
+	if !opts.DryRun {
+		err = ms.ensureTable(client, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to ensure migration table: %w", checkCredentials(client, err))
+		}
+	}
+
+	// 2. Get applied migrations
+	applied, checksums, err := ms.getAppliedMigrations(client, table, !opts.DryRun)
+	if err != nil && !(opts.DryRun && isMissingTable(err)) {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", checkCredentials(client, err))
+	}
+
+	// 3. Get all available migrations
+	allMigrations, err := m.FindMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find migrations: %w", err)
+	}
+	// Custom sources are not validated by FindMigrations, so check them here
+	if err := validateMigrations(allMigrations); err != nil {
+		return nil, fmt.Errorf("invalid migrations: %w", err)
+	}
+	if !opts.SkipChecksumValidation {
+		if err := verifyChecksums(allMigrations, checksums); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Plan migrations
+	toApply, err := plan(allMigrations, applied)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDirection(toApply, dir); err != nil {
+		return nil, fmt.Errorf("invalid migrations: %w", err)
+	}
+
+	planned := make([]*PlannedMigration, 0, len(toApply))
+	for _, migration := range toApply {
+		planned = append(planned, ms.plannedMigration(table, migration, dir))
+	}
+	log := opts.logger().With("table", ms.getTableName(), "direction", dir.String())
+	if err := checkOrder(planned, applied, dir, opts.Strategy, log); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		for _, migration := range planned {
+			log.Info("planned migration", "id", migration.Id, "statements", len(migration.Queries))
+		}
+		return planned, nil
+	}
+
+	// 5. Apply migrations
+	for i, migration := range planned {
+		if lock != nil {
+			if err := lock.check(); err != nil {
+				log.Error("lost the migration lock", "id", migration.Id)
+				return planned[:i], err
+			}
+		}
+		event := MigrationEvent{Id: migration.Id, Direction: dir, Statements: len(migration.Queries) - 1}
+		opts.onMigration(event)
+		start := time.Now()
+		err := ms.applyMigration(client, table, migration, dir, log)
+		event.Done, event.Duration, event.Err = true, time.Since(start), err
+		opts.onMigration(event)
+		if err != nil {
+			log.Error("migration failed", "id", migration.Id, "error", err)
+			return planned[:i], fmt.Errorf("failed to apply migration %s: %w", migration.Id, err)
+		}
+		log.Info("applied migration", "id", migration.Id, "duration", event.Duration)
+	}
+
+	return planned, nil
+}
+
+// isMissingTable reports whether err is D1 failing a query on a table that
+// does not exist
+func isMissingTable(err error) bool {
+	return strings.Contains(err.Error(), "no such table")
+}
+
+// checkCredentials replaces err, the failure of the first queries, by the
+// error of VerifyToken if the client can verify its credentials and they
+// are rejected, so a misconfigured token is reported as such
+func checkCredentials(client cloudflare_d1_go.Queryer, err error) error {
+	verifier, ok := client.(interface {
+		VerifyToken() (*cloudflare_d1_go.TokenInfo, error)
+	})
+	if !ok {
+		return err
+	}
+	if _, verifyErr := verifier.VerifyToken(); errors.Is(verifyErr, cloudflare_d1_go.ErrUnauthorized) {
+		return verifyErr
+	}
+	return err
+}
+
+func (ms MigrationSet) ensureTable(client cloudflare_d1_go.Queryer, table string) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id TEXT PRIMARY KEY,
+		applied_at DATETIME,
+		checksum TEXT
+	);`, table)
+
+	res, err := client.Query(query, nil)
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// getAppliedMigrations returns the IDs of the applied migrations and their
+// checksums. Migrations applied before checksums were recorded have none.
+// A table created before the checksum column existed is upgraded if upgrade
+// is set, and read without checksums otherwise.
+func (ms MigrationSet) getAppliedMigrations(client cloudflare_d1_go.Queryer, table string, upgrade bool) ([]string, map[string]string, error) {
+	query := fmt.Sprintf("SELECT id, checksum FROM %s ORDER BY id ASC;", table)
+	ids, checksums, err := ms.readAppliedMigrations(client, query)
+	if err == nil || !isMissingColumn(err) {
+		return ids, checksums, err
+	}
+
+	if !upgrade {
+		return ms.readAppliedMigrations(client, fmt.Sprintf("SELECT id FROM %s ORDER BY id ASC;", table))
+	}
+	if err := ms.upgradeTable(client, table); err != nil {
+		return nil, nil, fmt.Errorf("failed to upgrade migration table: %w", err)
+	}
+	return ms.readAppliedMigrations(client, query)
+}
+
+// readAppliedMigrations runs query, selecting id and optionally checksum
+// from the migrations table
+func (ms MigrationSet) readAppliedMigrations(client cloudflare_d1_go.Queryer, query string) ([]string, map[string]string, error) {
+	res, err := client.Query(query, nil)
+	if err != nil {
+		// If table doesn't exist yet (should be handled by ensureTable, but just in case)
+		return nil, nil, err
+	}
+
+	// Use ToRows to iterate
+	rows, err := res.ToRows()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	checksums := make(map[string]string)
+	for rows.Next() {
+		var record struct {
+			Id       string         `db:"id"`
+			Checksum sql.NullString `db:"checksum"`
+		}
+		if err := rows.StructScan(&record); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, record.Id)
+		if record.Checksum.Valid {
+			checksums[record.Id] = record.Checksum.String
+		}
+	}
+	return ids, checksums, nil
+}
+
+// upgradeTable migrates a migrations table created by an earlier version of
+// this package to the current layout
+func (ms MigrationSet) upgradeTable(client cloudflare_d1_go.Queryer, table string) error {
+	_, err := client.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN checksum TEXT;", table))
+	// A concurrent run may have added the column first
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+	return nil
+}
+
+// isMissingColumn reports whether err is D1 failing a query on a column that
+// does not exist
+func isMissingColumn(err error) bool {
+	return strings.Contains(err.Error(), "no such column")
+}
+
+func (ms MigrationSet) planMigrations(all []*Migration, applied []string, dir MigrationDirection, max int) []*Migration {
+	appliedMap := make(map[string]bool)
+	for _, id := range applied {
+		appliedMap[id] = true
+	}
+
+	var toApply []*Migration
+
+	if dir == Up {
+		for _, m := range all {
+			if !appliedMap[m.Id] {
+				toApply = append(toApply, m)
+			}
+		}
+	} else {
+		// Down: find applied migrations in reverse order
+		// We need to map applied IDs back to Migration objects
+		migrationMap := make(map[string]*Migration)
+		for _, m := range all {
+			migrationMap[m.Id] = m
+		}
+
+		// Iterate applied in reverse
+		for i := len(applied) - 1; i >= 0; i-- {
+			id := applied[i]
+			if m, ok := migrationMap[id]; ok {
+				toApply = append(toApply, m)
+			}
+		}
+	}
+
+	if max > 0 && len(toApply) > max {
+		toApply = toApply[:max]
+	}
+
+	return toApply
+}
+
+// plannedMigration returns the statements m runs in the given direction,
+// followed by the SQL of its bookkeeping statement
+func (ms MigrationSet) plannedMigration(table string, m *Migration, dir MigrationDirection) *PlannedMigration {
+	queries := m.Up
+	disableTransaction := m.DisableTransactionUp
+	if dir == Down {
+		queries = m.Down
+		disableTransaction = m.DisableTransactionDown
+	}
+	if m.goFunc(dir) != nil {
+		// The function and the bookkeeping are separate requests
+		queries = []string{goMigrationPreview}
+		disableTransaction = true
+	}
+
+	return &PlannedMigration{
+		Migration:          m,
+		DisableTransaction: disableTransaction,
+		Queries:            append(append([]string(nil), queries...), ms.bookkeeping(table, m, dir).SQL),
+	}
+}
+
+// bookkeeping returns the statement recording m as applied, or as rolled
+// back for dir == Down, in the migrations table
+func (ms MigrationSet) bookkeeping(table string, m *Migration, dir MigrationDirection) utils.Statement {
+	if dir == Down {
+		return utils.Statement{
+			SQL:    fmt.Sprintf("DELETE FROM %s WHERE id = ?;", table),
+			Params: []interface{}{m.Id},
+		}
+	}
+	return utils.Statement{
+		SQL:    fmt.Sprintf("INSERT INTO %s (id, applied_at, checksum) VALUES (?, ?, ?);", table),
+		Params: []interface{}{m.Id, time.Now(), m.checksum()},
+	}
+}
+
+// applyMigration runs the statements of m and its bookkeeping, logging each
+// statement at debug level to log
+func (ms MigrationSet) applyMigration(client cloudflare_d1_go.Queryer, table string, m *PlannedMigration, dir MigrationDirection, log *slog.Logger) error {
+	// The last query is the bookkeeping statement, sent with its parameters
+	queries := m.Queries[:len(m.Queries)-1]
+	record := ms.bookkeeping(table, m.Migration, dir)
+
+	if fn := m.goFunc(dir); fn != nil {
+		if err := fn(context.Background(), client); err != nil {
+			return err
+		}
+		_, err := client.Batch([]utils.Statement{record})
+		return err
+	}
+
+	if m.DisableTransaction {
+		// Execute queries one request at a time
+		for i, q := range queries {
+			res, err := client.Query(q, nil)
+			if err != nil {
+				return err
+			}
+			if err := res.Err(); err != nil {
+				return fmt.Errorf("migration %s statement %d: %w", m.Id, i+1, err)
+			}
+			_, written := res.RowCounts()
+			log.Debug("ran migration statement", "id", m.Id, "statement", i+1, "sql", q, "rows_written", written)
+		}
+		_, err := client.Batch([]utils.Statement{record})
+		return err
+	}
+
+	// Send the migration and its bookkeeping as one batch, so D1 applies
+	// all of it or nothing.
+	statements := make([]utils.Statement, 0, len(queries)+1)
+	for _, q := range queries {
+		statements = append(statements, utils.Statement{SQL: q})
+	}
+	statements = append(statements, record)
+
+	results, err := client.Batch(statements)
+	if err != nil {
+		return err
+	}
+	for i, q := range queries {
+		var written int64
+		if i < len(results) && results[i].Result != nil {
+			written = results[i].Result.Meta().RowsWritten
+		}
+		log.Debug("ran migration statement", "id", m.Id, "statement", i+1, "sql", q, "rows_written", written)
+	}
+	return nil
+}
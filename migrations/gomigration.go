@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// GoMigrationFunc is the Up or Down code of a Go migration. db is the client
+// migrations run with.
+type GoMigrationFunc func(ctx context.Context, db cloudflare_d1_go.Queryer) error
+
+// goMigrationPreview stands for the code of a Go migration in the queries
+// of a PlannedMigration
+const goMigrationPreview = "-- go migration, cannot preview"
+
+// GoMigration is a migration written in Go, for changes SQL alone cannot
+// express, such as backfilling a column with values computed from other data.
+// Go migrations are ordered by ID with SQL migrations, e.g. by combining a
+// GoMigrationSource with a file source in a CombinedMigrationSource.
+//
+// The function and the bookkeeping of the migrations table are separate
+// requests: a function failing halfway leaves its changes in place without
+// recording the migration, so write functions that can run again.
+type GoMigration struct {
+	Id string
+	// Version identifies the code of Up and Down in the checksum of the
+	// migration: change it when editing them, like the file of an SQL
+	// migration. See ChecksumError.
+	Version string
+
+	Up GoMigrationFunc
+	// Down may be nil: rolling back then only forgets the migration
+	Down GoMigrationFunc
+}
+
+// Migration returns g as a Migration, e.g. to mix it with SQL migrations in
+// a MemoryMigrationSource
+func (g GoMigration) Migration() *Migration {
+	sum := sha256.Sum256([]byte("go migration " + g.Version))
+	return &Migration{
+		Id:       g.Id,
+		Checksum: hex.EncodeToString(sum[:]),
+		UpFunc:   g.Up,
+		DownFunc: g.Down,
+	}
+}
+
+// goFunc returns the function of a Go migration for dir, nil for SQL migrations
+func (m Migration) goFunc(dir MigrationDirection) GoMigrationFunc {
+	if dir == Down {
+		return m.DownFunc
+	}
+	return m.UpFunc
+}
+
+// A set of Go migrations, in-memory.
+type GoMigrationSource struct {
+	Migrations []GoMigration
+}
+
+var _ MigrationSource = (*GoMigrationSource)(nil)
+
+func (g GoMigrationSource) FindMigrations() ([]*Migration, error) {
+	return validatedFind(g)
+}
+
+func (g GoMigrationSource) collectMigrations() ([]*Migration, error) {
+	migrations := make([]*Migration, len(g.Migrations))
+	for i, m := range g.Migrations {
+		migrations[i] = m.Migration()
+	}
+	sort.Sort(byId(migrations))
+	return migrations, nil
+}
@@ -0,0 +1,157 @@
+package migrations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// ErrMigrationLocked is returned when another run holds the migration lock
+// beyond ExecOptions.LockTimeout, or took it over after it expired
+var ErrMigrationLocked = errors.New("migrations are locked by another run")
+
+const (
+	defaultLockTTL    = time.Minute
+	lockRetryInterval = 500 * time.Millisecond
+)
+
+// migrationLock is the row of the lock table held by one run. D1 has no
+// transactions spanning requests, so the lock is advisory: it expires unless
+// renewed, and an expired lock is taken over by the next run that wants it.
+type migrationLock struct {
+	client cloudflare_d1_go.Queryer
+	table  string
+	owner  string
+	ttl    time.Duration
+	now    func() time.Time
+
+	lost atomic.Bool
+	stop chan struct{}
+	done chan struct{}
+}
+
+// lockTableName returns the quoted name of the lock table, the migrations
+// table name followed by _lock
+func (ms MigrationSet) lockTableName() (string, error) {
+	return utils.QuoteIdentifier(ms.getTableName() + "_lock")
+}
+
+// acquireLock takes the migration lock, waiting up to opts.LockTimeout for
+// another run to release it, and keeps renewing it until released
+func (ms MigrationSet) acquireLock(client cloudflare_d1_go.Queryer, opts ExecOptions) (*migrationLock, error) {
+	table, err := ms.lockTableName()
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration lock table name: %w", err)
+	}
+	owner := make([]byte, 16)
+	if _, err := rand.Read(owner); err != nil {
+		return nil, err
+	}
+	l := &migrationLock{
+		client: client,
+		table:  table,
+		owner:  hex.EncodeToString(owner),
+		ttl:    opts.LockTTL,
+		now:    opts.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if l.ttl <= 0 {
+		l.ttl = defaultLockTTL
+	}
+	if l.now == nil {
+		l.now = time.Now
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY,
+		owner TEXT NOT NULL,
+		expires_at INTEGER NOT NULL
+	);`, table)
+	if _, err := client.Exec(query); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(opts.LockTimeout)
+	waiting := false
+	for {
+		acquired, err := l.tryAcquire()
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			go l.heartbeat()
+			return l, nil
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, ErrMigrationLocked
+		}
+		if !waiting {
+			opts.logger().Info("waiting for the migration lock", "table", ms.getTableName(), "timeout", opts.LockTimeout)
+			waiting = true
+		}
+		time.Sleep(min(wait, lockRetryInterval))
+	}
+}
+
+// expiry returns the expiry of a lock taken or renewed now, in Unix milliseconds
+func (l *migrationLock) expiry() int64 {
+	return l.now().Add(l.ttl).UnixMilli()
+}
+
+// tryAcquire inserts the lock row, or takes it over if it expired
+func (l *migrationLock) tryAcquire() (bool, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (id, owner, expires_at) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at
+		WHERE expires_at < ?;`, l.table)
+	n, err := l.client.Exec(query, l.owner, l.expiry(), l.now().UnixMilli())
+	return n > 0, err
+}
+
+// heartbeat renews the lock every third of its TTL until released. A lock
+// found taken over by another run is marked lost.
+func (l *migrationLock) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	query := fmt.Sprintf("UPDATE %s SET expires_at = ? WHERE id = 1 AND owner = ?;", l.table)
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			// A failed renewal is retried at the next tick, the lock
+			// only expires after several of them
+			if n, err := l.client.Exec(query, l.expiry(), l.owner); err == nil && n == 0 {
+				l.lost.Store(true)
+				return
+			}
+		}
+	}
+}
+
+// check returns an error if the lock was taken over by another run
+func (l *migrationLock) check() error {
+	if l.lost.Load() {
+		return fmt.Errorf("%w: the lock expired and was taken over", ErrMigrationLocked)
+	}
+	return nil
+}
+
+// release stops renewing the lock and deletes its row. A lock that cannot be
+// deleted expires after its TTL.
+func (l *migrationLock) release() {
+	close(l.stop)
+	<-l.done
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = 1 AND owner = ?;", l.table)
+	_, _ = l.client.Exec(query, l.owner)
+}
@@ -1,69 +1,92 @@
-package migrations
-
-import (
-	"regexp"
-	"strconv"
-)
-
-type MigrationDirection int
-
-const (
-	Up MigrationDirection = iota
-	Down
-)
-
-type Migration struct {
-	Id   string
-	Up   []string
-	Down []string
-
-	DisableTransactionUp   bool
-	DisableTransactionDown bool
-}
-
-func (m Migration) Less(other *Migration) bool {
-	switch {
-	case m.isNumeric() && other.isNumeric() && m.VersionInt() != other.VersionInt():
-		return m.VersionInt() < other.VersionInt()
-	case m.isNumeric() && !other.isNumeric():
-		return true
-	case !m.isNumeric() && other.isNumeric():
-		return false
-	default:
-		return m.Id < other.Id
-	}
-}
-
-var numberPrefixRegex = regexp.MustCompile(`^(\d+).*$`)
-
-func (m Migration) isNumeric() bool {
-	return len(m.NumberPrefixMatches()) > 0
-}
-
-func (m Migration) NumberPrefixMatches() []string {
-	return numberPrefixRegex.FindStringSubmatch(m.Id)
-}
-
-func (m Migration) VersionInt() int64 {
-	v := m.NumberPrefixMatches()[1]
-	value, err := strconv.ParseInt(v, 10, 64)
-	if err != nil {
-		// Should not happen if regex matched
-		return 0
-	}
-	return value
-}
-
-type PlannedMigration struct {
-	*Migration
-
-	DisableTransaction bool
-	Queries            []string
-}
-
-type MigrationSource interface {
-	// Finds the migrations.
-	//
-	// The resulting slice of migrations should be sorted by Id.
-	FindMigrations() ([]*Migration, error)
-}
+package migrations
+
+import (
+	"regexp"
+	"strconv"
+)
+
+type MigrationDirection int
+
+const (
+	Up MigrationDirection = iota
+	Down
+)
+
+// String returns "up" or "down"
+func (d MigrationDirection) String() string {
+	if d == Down {
+		return "down"
+	}
+	return "up"
+}
+
+type Migration struct {
+	Id   string
+	Up   []string
+	Down []string
+
+	DisableTransactionUp   bool
+	DisableTransactionDown bool
+
+	// Checksum identifies the content of the migration: the SHA-256 of the
+	// file for parsed migrations. When empty, it is computed from the
+	// statements.
+	Checksum string
+
+	// UpFunc and DownFunc make a Go migration, run instead of statements,
+	// see GoMigration
+	UpFunc   GoMigrationFunc
+	DownFunc GoMigrationFunc
+}
+
+func (m Migration) Less(other *Migration) bool {
+	switch {
+	case m.isNumeric() && other.isNumeric() && m.VersionInt() != other.VersionInt():
+		return m.VersionInt() < other.VersionInt()
+	case m.isNumeric() && !other.isNumeric():
+		return true
+	case !m.isNumeric() && other.isNumeric():
+		return false
+	default:
+		return m.Id < other.Id
+	}
+}
+
+var numberPrefixRegex = regexp.MustCompile(`^(\d+).*$`)
+
+func (m Migration) isNumeric() bool {
+	return len(m.NumberPrefixMatches()) > 0
+}
+
+func (m Migration) NumberPrefixMatches() []string {
+	return numberPrefixRegex.FindStringSubmatch(m.Id)
+}
+
+func (m Migration) VersionInt() int64 {
+	v := m.NumberPrefixMatches()[1]
+	value, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		// Should not happen if regex matched
+		return 0
+	}
+	return value
+}
+
+// PlannedMigration is a migration with the statements it runs in one direction
+type PlannedMigration struct {
+	*Migration
+
+	DisableTransaction bool
+	Queries            []string
+
+	// OutOfOrder is set for up migrations sorting before the latest applied
+	// migration, see OutOfOrderStrategy
+	OutOfOrder bool
+}
+
+type MigrationSource interface {
+	// Finds the migrations.
+	//
+	// The resulting slice of migrations should be sorted by Id.
+	FindMigrations() ([]*Migration, error)
+}
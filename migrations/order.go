@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// OutOfOrderStrategy decides what a run does with pending migrations sorting
+// before the latest applied one, e.g. 0006 merged from a branch after 0007
+// was applied. Such a migration may depend on a schema that later migrations
+// already changed.
+type OutOfOrderStrategy int
+
+const (
+	// Allow applies out-of-order migrations like any other
+	Allow OutOfOrderStrategy = iota
+	// Warn applies them and logs a warning for each, see ExecOptions.Logger
+	Warn
+	// Fail applies nothing and returns an *OutOfOrderError
+	Fail
+)
+
+// OutOfOrderError reports pending migrations sorting before the latest
+// applied migration, see OutOfOrderStrategy
+type OutOfOrderError struct {
+	IDs    []string
+	Latest string
+}
+
+func (e *OutOfOrderError) Error() string {
+	return fmt.Sprintf("migrations sort before the latest applied migration %s: %s (rename them to sort last, or allow them with ExecOptions.Strategy)",
+		e.Latest, strings.Join(e.IDs, ", "))
+}
+
+// latestApplied returns the applied migration sorting last, nil if none is
+func latestApplied(applied []string) *Migration {
+	var latest *Migration
+	for _, id := range applied {
+		if m := (&Migration{Id: id}); latest == nil || latest.Less(m) {
+			latest = m
+		}
+	}
+	return latest
+}
+
+// checkOrder marks the planned up migrations sorting before the latest
+// applied one as OutOfOrder, and applies strategy to them
+func checkOrder(planned []*PlannedMigration, applied []string, dir MigrationDirection, strategy OutOfOrderStrategy, log *slog.Logger) error {
+	latest := latestApplied(applied)
+	if dir != Up || latest == nil {
+		return nil
+	}
+
+	var ids []string
+	for _, m := range planned {
+		if m.Less(latest) {
+			m.OutOfOrder = true
+			ids = append(ids, m.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	switch strategy {
+	case Warn:
+		for _, id := range ids {
+			log.Warn("out-of-order migration", "id", id, "latest_applied", latest.Id)
+		}
+	case Fail:
+		return &OutOfOrderError{IDs: ids, Latest: latest.Id}
+	}
+	return nil
+}
@@ -1,124 +1,215 @@
-package migrations
-
-import (
-	"bufio"
-	"bytes"
-	"fmt"
-	"io"
-	"strings"
-)
-
-type ParsedMigration struct {
-	UpStatements   []string
-	DownStatements []string
-
-	DisableTransactionUp   bool
-	DisableTransactionDown bool
-}
-
-var (
-	matchEmptyLines = true
-)
-
-func ParseMigration(id string, r io.ReadSeeker) (*Migration, error) {
-	m := &Migration{
-		Id: id,
-	}
-
-	parsed, err := parseMigration(r)
-	if err != nil {
-		return nil, fmt.Errorf("Error parsing migration (%s): %w", id, err)
-	}
-
-	m.Up = parsed.UpStatements
-	m.Down = parsed.DownStatements
-
-	m.DisableTransactionUp = parsed.DisableTransactionUp
-	m.DisableTransactionDown = parsed.DisableTransactionDown
-
-	return m, nil
-}
-
-func parseMigration(r io.ReadSeeker) (*ParsedMigration, error) {
-	p := &ParsedMigration{}
-
-	scanner := bufio.NewScanner(r)
-	var currentDirection MigrationDirection = Up
-	var buf bytes.Buffer
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if strings.HasPrefix(line, "-- +migrate Up") {
-			if buf.Len() > 0 {
-				appendStatement(p, currentDirection, buf.String())
-				buf.Reset()
-			}
-			currentDirection = Up
-			if strings.Contains(line, "notransaction") {
-				p.DisableTransactionUp = true
-			}
-		} else if strings.HasPrefix(line, "-- +migrate Down") {
-			if buf.Len() > 0 {
-				appendStatement(p, currentDirection, buf.String())
-				buf.Reset()
-			}
-			currentDirection = Down
-			if strings.Contains(line, "notransaction") {
-				p.DisableTransactionDown = true
-			}
-		} else if strings.HasPrefix(line, "-- +migrate StatementBegin") {
-			// For now, we treat StatementBegin/End as just part of the SQL or ignore special handling
-			// since D1 might not support complex PL/SQL blocks the same way, but we keep reading.
-			// Actually, sql-migrate uses this to handle semicolons inside blocks.
-			// For simplicity in this port, we will just accumulate lines until we see StatementEnd or EOF/next section
-			// But a simpler approach for D1 (SQLite) is usually sufficient.
-			// Let's stick to simple semicolon splitting or just whole block if no semicolon found?
-			// Cloudflare D1 API takes a single string or list of strings.
-			// Let's assume we just accumulate lines into the buffer.
-		} else if strings.HasPrefix(line, "-- +migrate StatementEnd") {
-			// End of statement block
-		} else {
-			// Regular line
-			if buf.Len() > 0 {
-				buf.WriteString("\n")
-			}
-			buf.WriteString(line)
-		}
-	}
-
-	if buf.Len() > 0 {
-		appendStatement(p, currentDirection, buf.String())
-	}
-
-	return p, nil
-}
-
-func appendStatement(p *ParsedMigration, dir MigrationDirection, sql string) {
-	// Split by semicolon for SQLite, as D1 client might want individual statements?
-	// The D1 client `Query` takes a single statement usually.
-	// `Exec` in sql-migrate splits by semicolon.
-	// Let's implement simple semicolon splitting.
-
-	stmts := splitSQLStatements(sql)
-	for _, stmt := range stmts {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		if dir == Up {
-			p.UpStatements = append(p.UpStatements, stmt)
-		} else {
-			p.DownStatements = append(p.DownStatements, stmt)
-		}
-	}
-}
-
-func splitSQLStatements(sql string) []string {
-	// This is a very naive splitter. A proper one would handle quotes, comments, etc.
-	// For now, we split by semicolon at end of line or just semicolon.
-	// D1 client might handle multiple statements in one go if passed to batch?
-	// But `Query` usually expects one.
-	// Let's just split by `;`
-	return strings.Split(sql, ";")
-}
+package migrations
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type ParsedMigration struct {
+	UpStatements   []string
+	DownStatements []string
+
+	DisableTransactionUp   bool
+	DisableTransactionDown bool
+}
+
+var (
+	matchEmptyLines = true
+)
+
+// ParseMigration reads a migration in the sql-migrate format. The file must
+// start its statements with a -- +migrate Up marker, and may have one
+// -- +migrate Down marker; see FileMigrationSource.Lenient to relax this.
+func ParseMigration(id string, r io.ReadSeeker) (*Migration, error) {
+	return parseMigrationFile(id, r, true)
+}
+
+// parseMigrationFile is ParseMigration, checking the markers if strict is set
+func parseMigrationFile(id string, r io.Reader, strict bool) (*Migration, error) {
+	m := &Migration{
+		Id: id,
+	}
+
+	hash := sha256.New()
+	parsed, err := parseMigration(io.TeeReader(r, hash), strict)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing migration (%s): %w", id, err)
+	}
+	m.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	m.Up = parsed.UpStatements
+	m.Down = parsed.DownStatements
+
+	m.DisableTransactionUp = parsed.DisableTransactionUp
+	m.DisableTransactionDown = parsed.DisableTransactionDown
+
+	return m, nil
+}
+
+// parseMigration reads the statements of a migration file. Unless strict is
+// false, the file must have exactly one Up marker, at most one Down marker,
+// and no statements before the first marker; all such problems are returned
+// joined.
+func parseMigration(r io.Reader, strict bool) (*ParsedMigration, error) {
+	p := &ParsedMigration{}
+
+	scanner := bufio.NewScanner(r)
+	var currentDirection MigrationDirection = Up
+	var buf bytes.Buffer
+	inBlock := false
+	lineNo := 0
+	markers := make(map[MigrationDirection]int) // line of each direction marker
+	beforeMarkers := false                      // statements precede all markers
+	var errs []error
+
+	// flush appends the statements buffered so far
+	flush := func() {
+		if len(markers) == 0 && hasSQL(buf.String()) {
+			beforeMarkers = true
+		}
+		if buf.Len() > 0 {
+			appendStatements(p, currentDirection, buf.String())
+			buf.Reset()
+		}
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		if strings.HasPrefix(line, "-- +migrate Up") || strings.HasPrefix(line, "-- +migrate Down") {
+			if inBlock {
+				return nil, fmt.Errorf("line %d: %q before the StatementEnd of a StatementBegin block", lineNo, line)
+			}
+			flush()
+			currentDirection = Up
+			if strings.HasPrefix(line, "-- +migrate Down") {
+				currentDirection = Down
+			}
+			if prev, ok := markers[currentDirection]; ok && strict {
+				errs = append(errs, fmt.Errorf("line %d: duplicate %s marker, the first is on line %d", lineNo, currentDirection, prev))
+			} else if !ok {
+				markers[currentDirection] = lineNo
+			}
+			if strings.Contains(line, "notransaction") {
+				if currentDirection == Up {
+					p.DisableTransactionUp = true
+				} else {
+					p.DisableTransactionDown = true
+				}
+			}
+		} else if strings.HasPrefix(line, "-- +migrate StatementBegin") {
+			if inBlock {
+				return nil, fmt.Errorf("line %d: StatementBegin inside another StatementBegin block", lineNo)
+			}
+			flush()
+			inBlock = true
+		} else if strings.HasPrefix(line, "-- +migrate StatementEnd") {
+			if !inBlock {
+				return nil, fmt.Errorf("line %d: StatementEnd without StatementBegin", lineNo)
+			}
+			if len(markers) == 0 {
+				beforeMarkers = true
+			}
+			// The block is one statement, whatever semicolons it contains
+			stmt := strings.TrimSpace(buf.String())
+			stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+			if hasSQL(stmt) {
+				appendStatement(p, currentDirection, stmt)
+			}
+			buf.Reset()
+			inBlock = false
+		} else {
+			// Regular line
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inBlock {
+		return nil, fmt.Errorf("StatementBegin without StatementEnd")
+	}
+	flush()
+
+	if strict {
+		first := lineNo
+		for _, line := range markers {
+			first = min(first, line)
+		}
+		if _, ok := markers[Up]; !ok {
+			errs = append(errs, fmt.Errorf("no -- +migrate Up marker"))
+		} else if beforeMarkers {
+			errs = append(errs, fmt.Errorf("statements before the first marker, on line %d", first))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return p, nil
+}
+
+// appendStatements splits sql into statements and appends those holding
+// more than whitespace and comments
+func appendStatements(p *ParsedMigration, dir MigrationDirection, sql string) {
+	for _, stmt := range splitSQLStatements(sql) {
+		stmt = strings.TrimSpace(stmt)
+		if hasSQL(stmt) {
+			appendStatement(p, dir, stmt)
+		}
+	}
+}
+
+func appendStatement(p *ParsedMigration, dir MigrationDirection, stmt string) {
+	if dir == Up {
+		p.UpStatements = append(p.UpStatements, stmt)
+	} else {
+		p.DownStatements = append(p.DownStatements, stmt)
+	}
+}
+
+// splitSQLStatements splits sql at the semicolons ending statements.
+// Semicolons in string literals, quoted identifiers and comments do not
+// split; use a StatementBegin block for statements such as triggers, whose
+// body holds semicolons of its own.
+func splitSQLStatements(sql string) []string {
+	var stmts []string
+	start := 0
+	for i := 0; i < len(sql); i++ {
+		if sql[i] == ';' {
+			stmts = append(stmts, sql[start:i])
+			start = i + 1
+			continue
+		}
+		if end := utils.SkipLiteral(sql, i); end > i {
+			i = end - 1
+		}
+	}
+	return append(stmts, sql[start:])
+}
+
+// hasSQL reports whether stmt holds more than whitespace and comments
+func hasSQL(stmt string) bool {
+	for i := 0; i < len(stmt); i++ {
+		if strings.HasPrefix(stmt[i:], "--") || strings.HasPrefix(stmt[i:], "/*") {
+			i = utils.SkipLiteral(stmt, i) - 1
+			continue
+		}
+		if !unicode.IsSpace(rune(stmt[i])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"sync"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// ExecAll applies the migrations of m to every database cached by pool,
+// e.g. all tenant databases after pool.WarmCache, migrating up to
+// concurrency databases at a time; values below 1 mean one at a time.
+// A database that fails does not stop the others: applied holds the number
+// of migrations applied to each database, errs the error of each database
+// that failed.
+func ExecAll(pool *cloudflare_d1_go.ConnectionPool, m MigrationSource, dir MigrationDirection, concurrency int) (applied map[string]int, errs map[string]error) {
+	return migSet.ExecAll(pool, m, dir, concurrency)
+}
+
+// ExecAll is the package-level ExecAll using the table and options of ms
+func (ms MigrationSet) ExecAll(pool *cloudflare_d1_go.ConnectionPool, m MigrationSource, dir MigrationDirection, concurrency int) (applied map[string]int, errs map[string]error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	applied = make(map[string]int)
+	errs = make(map[string]error)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for _, name := range pool.ListCachedDatabases() {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			n, err := ms.execDB(pool, name, m, dir)
+			mu.Lock()
+			defer mu.Unlock()
+			applied[name] = n
+			if err != nil {
+				errs[name] = err
+			}
+		}()
+	}
+	wg.Wait()
+	return applied, errs
+}
+
+// execDB applies the migrations of m to the database name of pool
+func (ms MigrationSet) execDB(pool *cloudflare_d1_go.ConnectionPool, name string, m MigrationSource, dir MigrationDirection) (int, error) {
+	db, err := pool.DB(name)
+	if err != nil {
+		return 0, err
+	}
+	return ms.ExecMax(db, m, dir, 0)
+}
@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// MigrationSetOptions configures NewMigrationSet
+type MigrationSetOptions struct {
+	// TableName is the table recording applied migrations; "" means
+	// d1_migrations. The lock table is named after it with a _lock suffix.
+	TableName string
+
+	// ExecOptions apply to every run of the set, see MigrationSet.Options
+	ExecOptions
+}
+
+// NewMigrationSet returns a migration set of its own, independent of the
+// package-level functions and of other sets, e.g. to keep the migrations of
+// two schemas of one database in separate tables:
+//
+//	analytics, err := migrations.NewMigrationSet(migrations.MigrationSetOptions{
+//		TableName: "analytics_migrations",
+//	})
+//	n, err := analytics.Exec(client, analyticsSource, migrations.Up)
+//
+// It fails if the table name is not a valid identifier.
+func NewMigrationSet(opts MigrationSetOptions) (*MigrationSet, error) {
+	ms := &MigrationSet{TableName: opts.TableName, Options: opts.ExecOptions}
+	if _, err := ms.quotedTableName(); err != nil {
+		return nil, fmt.Errorf("invalid migration table name: %w", err)
+	}
+	return ms, nil
+}
+
+// Plan returns the migrations ExecMax would apply, with the statements they
+// would run, without executing anything, see ExecOptions.DryRun
+func Plan(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, max int) ([]*PlannedMigration, error) {
+	return migSet.Plan(client, m, dir, max)
+}
+
+func (ms MigrationSet) Plan(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, max int) ([]*PlannedMigration, error) {
+	opts := ms.Options
+	opts.DryRun = true
+	return ms.ExecMaxWithOptions(client, m, dir, max, opts)
+}
+
+// GetRecords returns the applied migrations, sorted by ID. It only reads the
+// migrations table: a database without one has no records.
+func GetRecords(client cloudflare_d1_go.Queryer) ([]*MigrationRecord, error) {
+	return migSet.GetRecords(client)
+}
+
+func (ms MigrationSet) GetRecords(client cloudflare_d1_go.Queryer) ([]*MigrationRecord, error) {
+	table, err := ms.quotedTableName()
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration table name: %w", err)
+	}
+
+	var rows []struct {
+		Id        string         `db:"id"`
+		AppliedAt time.Time      `db:"applied_at"`
+		Checksum  sql.NullString `db:"checksum"`
+	}
+	err = client.Select(&rows, fmt.Sprintf("SELECT id, applied_at, checksum FROM %s ORDER BY id ASC;", table))
+	if err != nil && isMissingColumn(err) {
+		// Tables created before checksums were recorded
+		err = client.Select(&rows, fmt.Sprintf("SELECT id, applied_at FROM %s ORDER BY id ASC;", table))
+	}
+	if err != nil && isMissingTable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", checkCredentials(client, err))
+	}
+
+	records := make([]*MigrationRecord, len(rows))
+	for i, row := range rows {
+		records[i] = &MigrationRecord{Id: row.Id, AppliedAt: row.AppliedAt, Checksum: row.Checksum.String}
+	}
+	return records, nil
+}
@@ -1,100 +1,152 @@
-package migrations
-
-import (
-	"embed"
-	"fmt"
-	"net/http"
-	"os"
-	"path"
-	"sort"
-	"strings"
-)
-
-type byId []*Migration
-
-func (b byId) Len() int           { return len(b) }
-func (b byId) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
-func (b byId) Less(i, j int) bool { return b[i].Less(b[j]) }
-
-// A hardcoded set of migrations, in-memory.
-type MemoryMigrationSource struct {
-	Migrations []*Migration
-}
-
-var _ MigrationSource = (*MemoryMigrationSource)(nil)
-
-func (m MemoryMigrationSource) FindMigrations() ([]*Migration, error) {
-	migrations := make([]*Migration, len(m.Migrations))
-	copy(migrations, m.Migrations)
-	sort.Sort(byId(migrations))
-	return migrations, nil
-}
-
-// A set of migrations loaded from a directory.
-type FileMigrationSource struct {
-	Dir string
-}
-
-var _ MigrationSource = (*FileMigrationSource)(nil)
-
-func (f FileMigrationSource) FindMigrations() ([]*Migration, error) {
-	filesystem := http.Dir(f.Dir)
-	return findMigrations(filesystem, "/")
-}
-
-// A set of migrations loaded from an go1.16 embed.FS
-type EmbedFileSystemMigrationSource struct {
-	FileSystem embed.FS
-	Root       string
-}
-
-var _ MigrationSource = (*EmbedFileSystemMigrationSource)(nil)
-
-func (f EmbedFileSystemMigrationSource) FindMigrations() ([]*Migration, error) {
-	return findMigrations(http.FS(f.FileSystem), f.Root)
-}
-
-func findMigrations(dir http.FileSystem, root string) ([]*Migration, error) {
-	migrations := make([]*Migration, 0)
-
-	file, err := dir.Open(root)
-	if err != nil {
-		return nil, err
-	}
-
-	files, err := file.Readdir(0)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, info := range files {
-		if strings.HasSuffix(info.Name(), ".sql") {
-			migration, err := migrationFromFile(dir, root, info)
-			if err != nil {
-				return nil, err
-			}
-
-			migrations = append(migrations, migration)
-		}
-	}
-
-	// Make sure migrations are sorted
-	sort.Sort(byId(migrations))
-
-	return migrations, nil
-}
-
-func migrationFromFile(dir http.FileSystem, root string, info os.FileInfo) (*Migration, error) {
-	path := path.Join(root, info.Name())
-	file, err := dir.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("Error while opening %s: %w", info.Name(), err)
-	}
-	defer func() { _ = file.Close() }()
-
-	migration, err := ParseMigration(info.Name(), file)
-	if err != nil {
-		return nil, fmt.Errorf("Error while parsing %s: %w", info.Name(), err)
-	}
-	return migration, nil
-}
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type byId []*Migration
+
+func (b byId) Len() int           { return len(b) }
+func (b byId) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byId) Less(i, j int) bool { return b[i].Less(b[j]) }
+
+// A hardcoded set of migrations, in-memory.
+type MemoryMigrationSource struct {
+	Migrations []*Migration
+}
+
+var _ MigrationSource = (*MemoryMigrationSource)(nil)
+
+func (m MemoryMigrationSource) FindMigrations() ([]*Migration, error) {
+	return validatedFind(m)
+}
+
+func (m MemoryMigrationSource) collectMigrations() ([]*Migration, error) {
+	migrations := make([]*Migration, len(m.Migrations))
+	copy(migrations, m.Migrations)
+	sort.Sort(byId(migrations))
+	return migrations, nil
+}
+
+// A set of migrations loaded from a directory.
+type FileMigrationSource struct {
+	Dir string
+
+	// Lenient accepts files without a -- +migrate Up marker, with repeated
+	// markers or with statements before the first marker, as earlier
+	// versions did: statements before any marker are Up statements
+	Lenient bool
+}
+
+var _ MigrationSource = (*FileMigrationSource)(nil)
+
+func (f FileMigrationSource) FindMigrations() ([]*Migration, error) {
+	return validatedFind(f)
+}
+
+func (f FileMigrationSource) collectMigrations() ([]*Migration, error) {
+	filesystem := http.Dir(f.Dir)
+	return findMigrations(filesystem, "/", !f.Lenient)
+}
+
+// A set of migrations loaded from an go1.16 embed.FS
+type EmbedFileSystemMigrationSource struct {
+	FileSystem embed.FS
+	Root       string
+
+	// Lenient relaxes the checks of the files, see FileMigrationSource.Lenient
+	Lenient bool
+}
+
+var _ MigrationSource = (*EmbedFileSystemMigrationSource)(nil)
+
+func (f EmbedFileSystemMigrationSource) FindMigrations() ([]*Migration, error) {
+	return validatedFind(f)
+}
+
+func (f EmbedFileSystemMigrationSource) collectMigrations() ([]*Migration, error) {
+	return findMigrations(http.FS(f.FileSystem), f.Root, !f.Lenient)
+}
+
+// A set of migrations merged from several sources, e.g. one per module.
+// The merged set is validated as a whole, so an ID defined in two sources is rejected.
+type CombinedMigrationSource struct {
+	Sources []MigrationSource
+}
+
+var _ MigrationSource = (*CombinedMigrationSource)(nil)
+
+func (c CombinedMigrationSource) FindMigrations() ([]*Migration, error) {
+	return validatedFind(c)
+}
+
+func (c CombinedMigrationSource) collectMigrations() ([]*Migration, error) {
+	var migrations []*Migration
+	var errs []error
+	for i, source := range c.Sources {
+		found, err := collect(source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %d: %w", i, err))
+		}
+		migrations = append(migrations, found...)
+	}
+
+	sort.Sort(byId(migrations))
+	return migrations, errors.Join(errs...)
+}
+
+// findMigrations parses the .sql files of root. Files that fail to parse are
+// left out, and their errors returned joined.
+func findMigrations(dir http.FileSystem, root string, strict bool) ([]*Migration, error) {
+	migrations := make([]*Migration, 0)
+
+	file, err := dir.Open(root)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := file.Readdir(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, info := range files {
+		if strings.HasSuffix(info.Name(), ".sql") {
+			migration, err := migrationFromFile(dir, root, info, strict)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			migrations = append(migrations, migration)
+		}
+	}
+
+	// Make sure migrations are sorted
+	sort.Sort(byId(migrations))
+
+	return migrations, errors.Join(errs...)
+}
+
+func migrationFromFile(dir http.FileSystem, root string, info os.FileInfo, strict bool) (*Migration, error) {
+	path := path.Join(root, info.Name())
+	file, err := dir.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error while opening %s: %w", info.Name(), err)
+	}
+	defer func() { _ = file.Close() }()
+
+	migration, err := parseMigrationFile(info.Name(), file, strict)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing %s: %w", info.Name(), err)
+	}
+	return migration, nil
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// collector is implemented by the built-in sources. It returns the migrations
+// of a source without validating them, so validation can report every problem
+// of a combined set at once. Migrations that could not be read are left out
+// and their errors returned along with the others.
+type collector interface {
+	collectMigrations() ([]*Migration, error)
+}
+
+// collect returns the migrations of a source, unvalidated if the source supports it.
+func collect(source MigrationSource) ([]*Migration, error) {
+	if c, ok := source.(collector); ok {
+		return c.collectMigrations()
+	}
+	return source.FindMigrations()
+}
+
+// validatedFind collects the migrations of a built-in source and validates them.
+func validatedFind(c collector) ([]*Migration, error) {
+	migrations, err := c.collectMigrations()
+	if err := errors.Join(err, validateMigrations(migrations)); err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+// ValidateSource checks a migration source without touching any database.
+// It rejects files that fail to parse, empty and duplicate IDs, IDs that differ only by case, numeric
+// prefixes used by more than one migration and, for dir == Up, migrations
+// without any Up statements. All problems are returned joined into one error.
+func ValidateSource(source MigrationSource, dir MigrationDirection) error {
+	migrations, err := collect(source)
+	return errors.Join(err, validateMigrations(migrations), validateDirection(migrations, dir))
+}
+
+// validateMigrations checks a set of migrations for conflicting IDs.
+func validateMigrations(migrations []*Migration) error {
+	var errs []error
+	ids := make(map[string]string)
+	versions := make(map[int64]string)
+
+	for _, m := range migrations {
+		if m.Id == "" {
+			errs = append(errs, fmt.Errorf("migration with empty ID"))
+			continue
+		}
+
+		if (len(m.Up) > 0 && m.UpFunc != nil) || (len(m.Down) > 0 && m.DownFunc != nil) {
+			errs = append(errs, fmt.Errorf("migration %q has both statements and a Go function", m.Id))
+		}
+
+		key := strings.ToLower(m.Id)
+		if prev, ok := ids[key]; ok {
+			if prev == m.Id {
+				errs = append(errs, fmt.Errorf("duplicate migration ID %q", m.Id))
+			} else {
+				errs = append(errs, fmt.Errorf("migration IDs %q and %q differ only by case", prev, m.Id))
+			}
+			continue
+		}
+		ids[key] = m.Id
+
+		if m.isNumeric() {
+			version := m.VersionInt()
+			if prev, ok := versions[version]; ok {
+				errs = append(errs, fmt.Errorf("migrations %q and %q share version %d", prev, m.Id, version))
+				continue
+			}
+			versions[version] = m.Id
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateDirection checks that every migration has statements or a function to run in the given direction.
+// Only Up is enforced: a migration without Down statements simply cannot be rolled back.
+func validateDirection(migrations []*Migration, dir MigrationDirection) error {
+	if dir != Up {
+		return nil
+	}
+
+	var errs []error
+	for _, m := range migrations {
+		if len(m.Up) == 0 && m.UpFunc == nil {
+			errs = append(errs, fmt.Errorf("migration %q has no Up statements", m.Id))
+		}
+	}
+	return errors.Join(errs...)
+}
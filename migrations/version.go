@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"fmt"
+	"sort"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// ExecVersion brings the database to version exactly: afterwards every
+// migration whose numeric prefix is at most version is applied, and no
+// other. dir states which way the database is expected to move: Up applies
+// the missing migrations up to version, Down rolls back the applied ones
+// above it. A database that would have to move the other way is an error,
+// so a deploy cannot roll back a production database by accident.
+//
+// The target must be the version of a migration of m, and every migration of
+// m and every applied migration must have a numeric prefix. A database
+// already at version applies nothing and returns 0, nil.
+func ExecVersion(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, version int64) (int, error) {
+	return migSet.ExecVersion(client, m, dir, version)
+}
+
+func (ms MigrationSet) ExecVersion(client cloudflare_d1_go.Queryer, m MigrationSource, dir MigrationDirection, version int64) (int, error) {
+	applied, err := ms.exec(client, m, dir, ms.Options, func(all []*Migration, applied []string) ([]*Migration, error) {
+		return planVersion(all, applied, dir, version)
+	})
+	return len(applied), err
+}
+
+// planVersion returns the migrations to apply in direction dir to land on
+// version: the missing ones up to it in order for Up, the applied ones above
+// it in reverse order for Down
+func planVersion(all []*Migration, applied []string, dir MigrationDirection, version int64) ([]*Migration, error) {
+	byID := make(map[string]*Migration, len(all))
+	found := false
+	for _, m := range all {
+		if !m.isNumeric() {
+			return nil, fmt.Errorf("cannot migrate to version %d: migration %q has no numeric prefix", version, m.Id)
+		}
+		byID[m.Id] = m
+		found = found || m.VersionInt() == version
+	}
+	if !found {
+		return nil, fmt.Errorf("cannot migrate to version %d: no migration has this version", version)
+	}
+
+	isApplied := make(map[string]bool, len(applied))
+	var downs []*Migration
+	for _, id := range applied {
+		isApplied[id] = true
+		m, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("cannot migrate to version %d: applied migration %q is not in the source", version, id)
+		}
+		if m.VersionInt() > version {
+			downs = append(downs, m)
+		}
+	}
+	// Applied IDs come sorted as strings; roll back by version instead
+	sort.Sort(sort.Reverse(byId(downs)))
+
+	var ups []*Migration
+	for _, m := range all {
+		if !isApplied[m.Id] && m.VersionInt() <= version {
+			ups = append(ups, m)
+		}
+	}
+
+	switch {
+	case dir == Up && len(downs) > 0:
+		return nil, fmt.Errorf("cannot migrate up to version %d: %d later migrations are applied, migrate down instead", version, len(downs))
+	case dir == Down && len(ups) > 0:
+		return nil, fmt.Errorf("cannot migrate down to version %d: %d earlier migrations are not applied, migrate up instead", version, len(ups))
+	case dir == Down:
+		return downs, nil
+	default:
+		return ups, nil
+	}
+}
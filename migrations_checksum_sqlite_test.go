@@ -0,0 +1,70 @@
+//go:build cgo
+
+package cloudflared1_test
+
+import (
+	"errors"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+// TestChecksumsUpgradeTableWithoutChecksums migrates a database whose
+// migrations table was created before checksums were recorded
+func TestChecksumsUpgradeTableWithoutChecksums(t *testing.T) {
+	serveSQLite(t)
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	for _, query := range []string{
+		"CREATE TABLE d1_migrations (id TEXT PRIMARY KEY, applied_at DATETIME)",
+		"INSERT INTO d1_migrations (id, applied_at) VALUES ('1_a', '2024-01-01 00:00:00')",
+		"CREATE TABLE a (id INTEGER)",
+	} {
+		if _, err := client.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_b", "CREATE TABLE b (id INTEGER)"),
+	}}
+
+	// A dry run leaves the old table alone
+	planned, err := migrations.ExecMaxWithOptions(client, source, migrations.Up, 0, migrations.ExecOptions{DryRun: true})
+	if err != nil || len(planned) != 1 {
+		t.Fatalf("dry run = %d migrations, %v, want 1", len(planned), err)
+	}
+	if n, _ := client.Count("SELECT COUNT(*) FROM pragma_table_info('d1_migrations')"); n != 2 {
+		t.Errorf("dry run changed the migrations table, it has %d columns", n)
+	}
+
+	n, err := migrations.Exec(client, source, migrations.Up)
+	if err != nil || n != 1 {
+		t.Fatalf("Exec = %d, %v, want 1, nil", n, err)
+	}
+
+	var records []struct {
+		ID       string  `db:"id"`
+		Checksum *string `db:"checksum"`
+	}
+	if err := client.Select(&records, "SELECT id, checksum FROM d1_migrations ORDER BY id"); err != nil {
+		t.Fatalf("reading the migrations table failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Checksum != nil || records[1].Checksum == nil {
+		t.Fatalf("records = %+v, want a checksum for 2_b only", records)
+	}
+
+	if n, err := migrations.RepairChecksums(client, source); n != 1 || err != nil {
+		t.Errorf("RepairChecksums = %d, %v, want 1, nil", n, err)
+	}
+
+	// From now on, editing 1_a is noticed
+	source.Migrations[0] = memoryMigration("1_a", "CREATE TABLE a (id INTEGER, name TEXT)")
+	var checksumErr *migrations.ChecksumError
+	if _, err := migrations.Exec(client, source, migrations.Up); !errors.As(err, &checksumErr) || checksumErr.IDs[0] != "1_a" {
+		t.Errorf("Exec after editing 1_a = %v, want a ChecksumError", err)
+	}
+}
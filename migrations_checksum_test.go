@@ -0,0 +1,126 @@
+package cloudflared1_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+)
+
+// checksumDB returns a fake database on which the migrations ids are applied
+// with the given checksums, nil meaning NULL. It accepts creating tables a,
+// b and c.
+func checksumDB(ids []string, checksums []interface{}) *testd1.DB {
+	rows := make([][]interface{}, len(ids))
+	for i, id := range ids {
+		rows[i] = []interface{}{id, checksums[i]}
+	}
+	return testd1.New().
+		On(`CREATE TABLE IF NOT EXISTS "d1_migrations" ( id TEXT PRIMARY KEY, applied_at DATETIME, checksum TEXT );`, testd1.Fixture{}).
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{Columns: []string{"id", "checksum"}, Rows: rows}).
+		On(`INSERT INTO "d1_migrations" (id, applied_at, checksum) VALUES (?, ?, ?);`, testd1.Fixture{RowsAffected: 1}).
+		On(`UPDATE "d1_migrations" SET checksum = ? WHERE id = ?;`, testd1.Fixture{RowsAffected: 1}).
+		On("CREATE TABLE a (id INTEGER)", testd1.Fixture{}).
+		On("CREATE TABLE b (id INTEGER)", testd1.Fixture{}).
+		On("CREATE TABLE c (id INTEGER)", testd1.Fixture{})
+}
+
+func TestFileMigrationChecksumIsFileHash(t *testing.T) {
+	dir := t.TempDir()
+	content := "-- +migrate Up\nCREATE TABLE t (id INTEGER);\n\n-- +migrate Down\nDROP TABLE t;\n"
+	if err := os.WriteFile(filepath.Join(dir, "1_init.sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := migrations.FileMigrationSource{Dir: dir}.FindMigrations()
+	if err != nil {
+		t.Fatalf("FindMigrations failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if want := hex.EncodeToString(sum[:]); found[0].Checksum != want {
+		t.Errorf("Checksum = %s, want %s", found[0].Checksum, want)
+	}
+}
+
+func TestExecRecordsAndVerifiesChecksums(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_b", "CREATE TABLE b (id INTEGER)"),
+		memoryMigration("3_c", "CREATE TABLE c (id INTEGER)"),
+	}}
+
+	db := checksumDB(nil, nil)
+	if _, err := migrations.Exec(db, source, migrations.Up); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	var recorded []string
+	for _, call := range db.Calls() {
+		if len(call.Params) == 3 {
+			recorded = append(recorded, call.Params[2])
+		}
+	}
+	if len(recorded) != 3 || len(recorded[0]) != 64 || recorded[0] == recorded[1] {
+		t.Fatalf("recorded checksums %q, want one SHA-256 per migration", recorded)
+	}
+
+	// Migrations applied before checksums existed are not checked
+	db = checksumDB([]string{"1_a", "2_b"}, []interface{}{nil, nil})
+	if n, err := migrations.Exec(db, source, migrations.Up); err != nil || n != 1 {
+		t.Fatalf("Exec = %d, %v, want 1, nil", n, err)
+	}
+
+	// Edited migrations fail the run before anything is applied
+	db = checksumDB([]string{"1_a", "2_b"}, []interface{}{"0000", "1111"})
+	_, err := migrations.Exec(db, source, migrations.Up)
+	var checksumErr *migrations.ChecksumError
+	if !errors.As(err, &checksumErr) || !reflect.DeepEqual(checksumErr.IDs, []string{"1_a", "2_b"}) {
+		t.Fatalf("Exec = %v, want a ChecksumError for 1_a and 2_b", err)
+	}
+	if calls := db.Calls(); len(calls) != 2 {
+		t.Errorf("Exec made %d calls after detecting edits, want 2", len(calls))
+	}
+
+	// The dry run reports them too
+	db.Reset()
+	if _, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{DryRun: true}); !errors.As(err, &checksumErr) {
+		t.Errorf("dry run = %v, want a ChecksumError", err)
+	}
+
+	skip := migrations.MigrationSet{Options: migrations.ExecOptions{SkipChecksumValidation: true}}
+	if n, err := skip.ExecMax(db, source, migrations.Up, 0); err != nil || n != 1 {
+		t.Errorf("ExecMax skipping validation = %d, %v, want 1, nil", n, err)
+	}
+}
+
+func TestRepairChecksums(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_b", "CREATE TABLE b (id INTEGER)"),
+		memoryMigration("3_c", "CREATE TABLE c (id INTEGER)"),
+	}}
+	db := checksumDB([]string{"1_a", "2_b"}, []interface{}{"0000", nil})
+
+	n, err := migrations.RepairChecksums(db, source)
+	if err != nil {
+		t.Fatalf("RepairChecksums failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("repaired %d checksums, want 2", n)
+	}
+	calls := db.Calls()[2:]
+	if len(calls) != 2 || calls[0].Params[1] != "1_a" || calls[1].Params[1] != "2_b" || len(calls[0].Params[0]) != 64 {
+		t.Fatalf("updates = %+v", calls)
+	}
+
+	// Nothing to repair once the checksums match
+	db = checksumDB([]string{"1_a"}, []interface{}{calls[0].Params[0]})
+	if n, err := migrations.RepairChecksums(db, source); n != 0 || err != nil {
+		t.Errorf("RepairChecksums = %d, %v, want 0, nil", n, err)
+	}
+}
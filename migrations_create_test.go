@@ -0,0 +1,104 @@
+package cloudflared1_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+func TestCreateMigration(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+
+	path, err := migrations.Create(dir, "Add users index!")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if name := filepath.Base(path); !regexp.MustCompile(`^\d{14}_add_users_index\.sql$`).MatchString(name) {
+		t.Errorf("created %s", name)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "-- +migrate Up\n") || !strings.Contains(string(content), "\n-- +migrate Down\n") {
+		t.Errorf("content = %q", content)
+	}
+
+	if _, err := migrations.Create(dir, " -- "); err == nil {
+		t.Error("Create accepted a name without letters or digits")
+	}
+}
+
+func TestCreateFromTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := migrations.CreateFromTemplate(dir, "users", "CREATE TABLE users (id INTEGER);", "DROP TABLE users;"); err != nil {
+		t.Fatalf("CreateFromTemplate failed: %v", err)
+	}
+
+	found, err := migrations.FileMigrationSource{Dir: dir}.FindMigrations()
+	if err != nil || len(found) != 1 {
+		t.Fatalf("FindMigrations = %d migrations, %v, want 1", len(found), err)
+	}
+	if want := []string{"CREATE TABLE users (id INTEGER)"}; !reflect.DeepEqual(found[0].Up, want) {
+		t.Errorf("Up = %q, want %q", found[0].Up, want)
+	}
+	if want := []string{"DROP TABLE users"}; !reflect.DeepEqual(found[0].Down, want) {
+		t.Errorf("Down = %q, want %q", found[0].Down, want)
+	}
+}
+
+func TestCreateWithinTheSameSecond(t *testing.T) {
+	dir := t.TempDir()
+
+	// A file already holds the version of the current second
+	taken := time.Now().UTC().Add(time.Minute).Format("20060102150405")
+	if err := os.WriteFile(filepath.Join(dir, taken+"_taken.sql"), []byte("-- +migrate Up\nSELECT 1;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, name := range []string{"first", "second", "third"} {
+		path, err := migrations.CreateFromTemplate(dir, name, "SELECT 1;", "")
+		if err != nil {
+			t.Fatalf("Create(%s) failed: %v", name, err)
+		}
+		ids = append(ids, filepath.Base(path))
+	}
+
+	found, err := migrations.FileMigrationSource{Dir: dir}.FindMigrations()
+	if err != nil {
+		t.Fatalf("FindMigrations failed: %v", err)
+	}
+	var sorted []string
+	for _, m := range found {
+		sorted = append(sorted, m.Id)
+	}
+	if want := append([]string{taken + "_taken.sql"}, ids...); !reflect.DeepEqual(sorted, want) {
+		t.Errorf("migrations sort as %q, want %q", sorted, want)
+	}
+	if err := migrations.ValidateSource(migrations.FileMigrationSource{Dir: dir}, migrations.Up); err != nil {
+		t.Errorf("ValidateSource failed: %v", err)
+	}
+}
+
+func TestCreateRefusesToSortBeforeApplied(t *testing.T) {
+	dir := t.TempDir()
+	applied := []string{"1_init.sql", "99990101000000_future.sql"}
+
+	if _, err := migrations.Create(dir, "late", applied...); err == nil || !strings.Contains(err.Error(), "99990101000000_future.sql") {
+		t.Errorf("Create = %v, want an error naming the applied migration", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("a refused migration left %d files", len(entries))
+	}
+
+	if _, err := migrations.Create(dir, "next", "1_init.sql"); err != nil {
+		t.Errorf("Create after 1_init.sql failed: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+)
+
+func TestDryRunOnlyReadsAppliedMigrations(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_users", "CREATE TABLE users (id INTEGER)", "CREATE INDEX users_id ON users (id)"),
+		{Id: "3_backfill", Up: []string{"UPDATE users SET id = id"}, DisableTransactionUp: true},
+	}}
+	db := testd1.New().
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{
+			Columns: []string{"id"},
+			Rows:    [][]interface{}{{"1_init"}},
+		})
+
+	planned, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ExecMaxWithOptions failed: %v", err)
+	}
+	if len(planned) != 2 {
+		t.Fatalf("planned %d migrations, want 2", len(planned))
+	}
+	if planned[0].Id != "2_users" || planned[0].DisableTransaction {
+		t.Errorf("planned[0] = %s, DisableTransaction %v", planned[0].Id, planned[0].DisableTransaction)
+	}
+	want := []string{
+		"CREATE TABLE users (id INTEGER)",
+		"CREATE INDEX users_id ON users (id)",
+		`INSERT INTO "d1_migrations" (id, applied_at, checksum) VALUES (?, ?, ?);`,
+	}
+	if !reflect.DeepEqual(planned[0].Queries, want) {
+		t.Errorf("planned[0].Queries = %q, want %q", planned[0].Queries, want)
+	}
+	if planned[1].Id != "3_backfill" || !planned[1].DisableTransaction || len(planned[1].Queries) != 2 {
+		t.Errorf("planned[1] = %+v", planned[1])
+	}
+
+	if calls := db.Calls(); len(calls) != 1 {
+		t.Errorf("dry run made %d calls, want only the read of applied migrations: %+v", len(calls), calls)
+	}
+}
+
+func TestDryRunDown(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_users", "CREATE TABLE users (id INTEGER)"),
+	}}
+	db := testd1.New().
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{
+			Columns: []string{"id"},
+			Rows:    [][]interface{}{{"1_init"}, {"2_users"}},
+		})
+
+	planned, err := migrations.ExecMaxWithOptions(db, source, migrations.Down, 1, migrations.ExecOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ExecMaxWithOptions failed: %v", err)
+	}
+	want := []string{"SELECT 1", `DELETE FROM "d1_migrations" WHERE id = ?;`}
+	if len(planned) != 1 || planned[0].Id != "2_users" || !reflect.DeepEqual(planned[0].Queries, want) {
+		t.Errorf("planned = %+v", planned)
+	}
+	if calls := db.Calls(); len(calls) != 1 {
+		t.Errorf("dry run made %d calls, want 1", len(calls))
+	}
+}
+
+func TestDryRunWithoutMigrationsTable(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+	}}
+	db := testd1.New().
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{
+			Err: errors.New("no such table: d1_migrations: SQLITE_ERROR"),
+		})
+
+	planned, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ExecMaxWithOptions failed: %v", err)
+	}
+	if len(planned) != 1 || planned[0].Id != "1_init" {
+		t.Errorf("planned = %+v", planned)
+	}
+
+	// Other failures are still reported
+	db.On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{Err: errors.New("D1_ERROR: overloaded")})
+	if _, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{DryRun: true}); err == nil {
+		t.Error("expected the read failure to be reported")
+	}
+}
+
+func TestExecMaxWithOptionsReturnsAppliedMigrations(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_broken", "CREATE TABLE b (id INTEGER)"),
+	}}
+	db := testd1.New().
+		On(`CREATE TABLE IF NOT EXISTS "d1_migrations" ( id TEXT PRIMARY KEY, applied_at DATETIME, checksum TEXT );`, testd1.Fixture{}).
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{Columns: []string{"id"}}).
+		On("CREATE TABLE a (id INTEGER)", testd1.Fixture{}).
+		On(`INSERT INTO "d1_migrations" (id, applied_at, checksum) VALUES (?, ?, ?);`, testd1.Fixture{RowsAffected: 1})
+
+	applied, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{})
+	if err == nil {
+		t.Fatal("expected 2_broken to fail")
+	}
+	if len(applied) != 1 || applied[0].Id != "1_init" {
+		t.Errorf("applied = %+v, want only 1_init", applied)
+	}
+}
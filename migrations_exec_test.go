@@ -0,0 +1,106 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+// migrationAPI is a fake D1 API for migration runs: statements succeed with
+// no rows, except those containing a key of reject, which D1 rejects with
+// the value as the message of an HTTP 400 error body
+type migrationAPI struct {
+	mu  sync.Mutex
+	sql []string
+}
+
+func serveMigrationAPI(t *testing.T, reject map[string]string) (*cloudflare_d1_go.Client, *migrationAPI) {
+	api := &migrationAPI{}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		// Failed migration runs check the credentials
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/tokens/verify"):
+			writeJSON(w, map[string]interface{}{
+				"success": true,
+				"result":  map[string]interface{}{"id": "tok-1", "status": "active"},
+			})
+			return
+		case strings.HasSuffix(r.URL.Path, "/d1/database"):
+			writeJSON(w, listing())
+			return
+		}
+
+		var body struct {
+			SQL   string `json:"sql"`
+			Batch []struct {
+				SQL string `json:"sql"`
+			} `json:"batch"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body of %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		statements := []string{body.SQL}
+		if body.Batch != nil {
+			statements = statements[:0]
+			for _, stmt := range body.Batch {
+				statements = append(statements, stmt.SQL)
+			}
+		}
+
+		api.mu.Lock()
+		api.sql = append(api.sql, statements...)
+		api.mu.Unlock()
+
+		items := make([]interface{}, len(statements))
+		for i, sql := range statements {
+			for key, message := range reject {
+				if strings.Contains(sql, key) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					_ = json.NewEncoder(w).Encode(errorResponse(7500, message))
+					return
+				}
+			}
+			items[i] = queryResult([]string{"id", "checksum"}, nil, map[string]interface{}{})
+		}
+		writeJSON(w, successResponse(items...))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	return client, api
+}
+
+// ran reports whether a statement containing s was sent
+func (api *migrationAPI) ran(s string) bool {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for _, sql := range api.sql {
+		if strings.Contains(sql, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExecReportsRejectedMigrationTable(t *testing.T) {
+	client, api := serveMigrationAPI(t, map[string]string{
+		`CREATE TABLE IF NOT EXISTS "d1_migrations"`: "not authorized: SQLITE_AUTH",
+	})
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+	}}
+
+	_, err := migrations.Exec(client, source, migrations.Up)
+	if err == nil || !strings.Contains(err.Error(), "failed to ensure migration table") || !strings.Contains(err.Error(), "SQLITE_AUTH") {
+		t.Errorf("Exec = %v, want the rejected CREATE TABLE", err)
+	}
+	if api.ran("SELECT id") {
+		t.Error("applied migrations were read without a migrations table")
+	}
+}
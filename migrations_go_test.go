@@ -0,0 +1,149 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+)
+
+// mixedSource returns SQL migrations 1_users and 3_index around the Go
+// migration 2_backfill, and the list of Go functions run
+func mixedSource(version string) (migrations.MigrationSource, *[]string) {
+	var ran []string
+	backfill := migrations.GoMigration{
+		Id:      "2_backfill",
+		Version: version,
+		Up: func(ctx context.Context, db cloudflare_d1_go.Queryer) error {
+			ran = append(ran, "up")
+			_, err := db.ExecContext(ctx, "UPDATE users SET name = ? WHERE name IS NULL", "anonymous")
+			return err
+		},
+		Down: func(ctx context.Context, db cloudflare_d1_go.Queryer) error {
+			ran = append(ran, "down")
+			return nil
+		},
+	}
+	return migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("3_index", "CREATE INDEX users_name ON users (name)"),
+		backfill.Migration(),
+		memoryMigration("1_users", "CREATE TABLE users (id INTEGER, name TEXT)"),
+	}}, &ran
+}
+
+// mixedDB returns a fake database accepting the statements of mixedSource
+func mixedDB(applied ...[]interface{}) *testd1.DB {
+	return testd1.New().
+		On(`CREATE TABLE IF NOT EXISTS "d1_migrations" ( id TEXT PRIMARY KEY, applied_at DATETIME, checksum TEXT );`, testd1.Fixture{}).
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{Columns: []string{"id", "checksum"}, Rows: applied}).
+		On(`INSERT INTO "d1_migrations" (id, applied_at, checksum) VALUES (?, ?, ?);`, testd1.Fixture{RowsAffected: 1}).
+		On(`DELETE FROM "d1_migrations" WHERE id = ?;`, testd1.Fixture{RowsAffected: 1}).
+		On("CREATE TABLE users (id INTEGER, name TEXT)", testd1.Fixture{}).
+		On("UPDATE users SET name = ? WHERE name IS NULL", testd1.Fixture{RowsAffected: 3}).
+		On("CREATE INDEX users_name ON users (name)", testd1.Fixture{}).
+		On("SELECT 1", testd1.Fixture{})
+}
+
+func TestGoMigrationsRunInOrderWithSQL(t *testing.T) {
+	source, ran := mixedSource("v1")
+	db := mixedDB()
+
+	n, err := migrations.Exec(db, source, migrations.Up)
+	if err != nil || n != 3 {
+		t.Fatalf("Exec = %d, %v, want 3, nil", n, err)
+	}
+	var sql []string
+	for _, call := range db.Calls()[2:] {
+		sql = append(sql, call.SQL)
+		if len(call.Params) == 3 && call.Params[0] == "2_backfill" && len(call.Params[2]) != 64 {
+			t.Errorf("2_backfill recorded checksum %q", call.Params[2])
+		}
+	}
+	insert := `INSERT INTO "d1_migrations" (id, applied_at, checksum) VALUES (?, ?, ?);`
+	want := []string{
+		"CREATE TABLE users (id INTEGER, name TEXT)", insert,
+		"UPDATE users SET name = ? WHERE name IS NULL", insert,
+		"CREATE INDEX users_name ON users (name)", insert,
+	}
+	if !reflect.DeepEqual(sql, want) {
+		t.Errorf("ran %q, want %q", sql, want)
+	}
+	if !reflect.DeepEqual(*ran, []string{"up"}) {
+		t.Errorf("Go functions run: %q", *ran)
+	}
+
+	// Rolling back runs the Down function
+	db = mixedDB([]interface{}{"1_users", nil}, []interface{}{"2_backfill", nil}, []interface{}{"3_index", nil})
+	if n, err := migrations.ExecMax(db, source, migrations.Down, 2); err != nil || n != 2 {
+		t.Fatalf("ExecMax down = %d, %v, want 2, nil", n, err)
+	}
+	if !reflect.DeepEqual(*ran, []string{"up", "down"}) {
+		t.Errorf("Go functions run: %q", *ran)
+	}
+}
+
+func TestGoMigrationsInDryRunAndChecksums(t *testing.T) {
+	source, ran := mixedSource("v1")
+	db := mixedDB()
+
+	planned, err := migrations.Plan(db, source, migrations.Up, 0)
+	if err != nil || len(planned) != 3 {
+		t.Fatalf("Plan = %d migrations, %v, want 3", len(planned), err)
+	}
+	if got := planned[1].Queries[0]; planned[1].Id != "2_backfill" || got != "-- go migration, cannot preview" {
+		t.Errorf("planned %s runs %q", planned[1].Id, got)
+	}
+	if len(*ran) != 0 {
+		t.Errorf("the dry run called Go functions: %q", *ran)
+	}
+
+	// Changing the version of an applied Go migration is an edit
+	applied := mixedDB([]interface{}{"1_users", nil}, []interface{}{"2_backfill", planned[1].Checksum})
+	if n, err := migrations.Exec(applied, source, migrations.Up); err != nil || n != 1 {
+		t.Fatalf("Exec = %d, %v, want 1, nil", n, err)
+	}
+	edited, _ := mixedSource("v2")
+	var checksumErr *migrations.ChecksumError
+	if _, err := migrations.Exec(applied, edited, migrations.Up); !errors.As(err, &checksumErr) || checksumErr.IDs[0] != "2_backfill" {
+		t.Errorf("Exec after changing the version = %v, want a ChecksumError", err)
+	}
+}
+
+func TestGoMigrationSource(t *testing.T) {
+	noop := func(context.Context, cloudflare_d1_go.Queryer) error { return nil }
+	combined := migrations.CombinedMigrationSource{Sources: []migrations.MigrationSource{
+		migrations.GoMigrationSource{Migrations: []migrations.GoMigration{
+			{Id: "4_go", Version: "1", Up: noop},
+			{Id: "2_go", Version: "1", Up: noop},
+		}},
+		migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+			memoryMigration("3_sql", "SELECT 1"),
+		}},
+	}}
+
+	found, err := combined.FindMigrations()
+	if err != nil {
+		t.Fatalf("FindMigrations failed: %v", err)
+	}
+	var ids []string
+	for _, m := range found {
+		ids = append(ids, m.Id)
+	}
+	if !reflect.DeepEqual(ids, []string{"2_go", "3_sql", "4_go"}) {
+		t.Errorf("order = %q", ids)
+	}
+
+	invalid := migrations.GoMigrationSource{Migrations: []migrations.GoMigration{{Id: "5_go", Version: "1"}}}
+	if err := migrations.ValidateSource(invalid, migrations.Up); err == nil {
+		t.Error("ValidateSource accepted a Go migration without Up")
+	}
+	both := memoryMigration("6_both", "SELECT 1")
+	both.UpFunc = noop
+	if _, err := (migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{both}}).FindMigrations(); err == nil {
+		t.Error("FindMigrations accepted a migration with both statements and a Go function")
+	}
+}
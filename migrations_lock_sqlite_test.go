@@ -0,0 +1,112 @@
+//go:build cgo
+
+package cloudflared1_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+// lockedDB serves an SQLite database whose migration lock is held by another
+// run until expiresAt
+func lockedDB(t *testing.T, expiresAt time.Time) *cloudflare_d1_go.Client {
+	t.Helper()
+	serveSQLite(t)
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	if _, err := client.Exec("CREATE TABLE d1_migrations_lock (id INTEGER PRIMARY KEY, owner TEXT NOT NULL, expires_at INTEGER NOT NULL)"); err != nil {
+		t.Fatalf("creating the lock table failed: %v", err)
+	}
+	if _, err := client.Exec("INSERT INTO d1_migrations_lock (id, owner, expires_at) VALUES (1, 'other', ?)", expiresAt.UnixMilli()); err != nil {
+		t.Fatalf("taking the lock failed: %v", err)
+	}
+	return client
+}
+
+func TestLockedMigrationsFail(t *testing.T) {
+	clock := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := lockedDB(t, clock.Add(30*time.Second))
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+	}}
+	opts := migrations.ExecOptions{Lock: true, Now: func() time.Time { return clock }}
+
+	if _, err := migrations.ExecMaxWithOptions(client, source, migrations.Up, 0, opts); !errors.Is(err, migrations.ErrMigrationLocked) {
+		t.Fatalf("ExecMaxWithOptions = %v, want ErrMigrationLocked", err)
+	}
+
+	opts.LockTimeout = 200 * time.Millisecond
+	start := time.Now()
+	if _, err := migrations.ExecMaxWithOptions(client, source, migrations.Up, 0, opts); !errors.Is(err, migrations.ErrMigrationLocked) {
+		t.Fatalf("ExecMaxWithOptions with a timeout = %v, want ErrMigrationLocked", err)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("gave up after %v, want at least the lock timeout", waited)
+	}
+
+	if exists, _ := client.TableExists("a"); exists {
+		t.Error("a locked run applied migrations")
+	}
+}
+
+func TestExpiredMigrationLockIsStolen(t *testing.T) {
+	clock := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := lockedDB(t, clock.Add(30*time.Second))
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+	}}
+
+	// The other run stopped renewing its lock a minute ago
+	later := clock.Add(time.Minute)
+	opts := migrations.ExecOptions{Lock: true, Now: func() time.Time { return later }}
+	applied, err := migrations.ExecMaxWithOptions(client, source, migrations.Up, 0, opts)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("ExecMaxWithOptions = %d migrations, %v, want 1", len(applied), err)
+	}
+
+	// The lock is released at the end
+	if n, err := client.Count("SELECT COUNT(*) FROM d1_migrations_lock"); n != 0 || err != nil {
+		t.Errorf("lock rows = %d, %v, want 0", n, err)
+	}
+}
+
+func TestLockedMigrationsRunOneAtATime(t *testing.T) {
+	serveSQLite(t)
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_b", "CREATE TABLE b (id INTEGER)"),
+	}}
+
+	var wg sync.WaitGroup
+	counts := make([]int, 4)
+	errs := make([]error, 4)
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := migrations.ExecMaxWithOptions(client, source, migrations.Up, 0,
+				migrations.ExecOptions{Lock: true, LockTimeout: 10 * time.Second})
+			counts[i], errs[i] = len(applied), err
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range counts {
+		if errs[i] != nil {
+			t.Errorf("run %d failed: %v", i, errs[i])
+		}
+		total += counts[i]
+	}
+	if total != 2 {
+		t.Errorf("the runs applied %d migrations in total, want 2", total)
+	}
+}
@@ -0,0 +1,102 @@
+package cloudflared1_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+)
+
+// outOfOrderDB returns versionedDB with 0001, 0002 and 0004 applied, so 0003
+// is out of order and 0005 is not
+func outOfOrderDB() (migrations.MigrationSource, *testd1.DB) {
+	source, db := versionedDB(0)
+	db.On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{
+		Columns: []string{"id"},
+		Rows:    [][]interface{}{{"0001_t1"}, {"0002_t2"}, {"0004_t4"}},
+	})
+	return source, db
+}
+
+func TestOutOfOrderAllow(t *testing.T) {
+	source, db := outOfOrderDB()
+
+	planned, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{})
+	if err != nil {
+		t.Fatalf("ExecMaxWithOptions failed: %v", err)
+	}
+	if len(planned) != 2 || !planned[0].OutOfOrder || planned[1].OutOfOrder {
+		t.Errorf("planned %+v, %+v, want 0003 out of order and 0005 not", planned[0], planned[1])
+	}
+	if want := []string{"CREATE TABLE t3 (id INTEGER)", "CREATE TABLE t5 (id INTEGER)"}; !reflect.DeepEqual(migrationSQL(db), want) {
+		t.Errorf("ran %q, want %q", migrationSQL(db), want)
+	}
+}
+
+func TestOutOfOrderWarn(t *testing.T) {
+	source, db := outOfOrderDB()
+	var logs bytes.Buffer
+	opts := migrations.ExecOptions{Strategy: migrations.Warn, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	planned, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, opts)
+	if err != nil || len(planned) != 2 {
+		t.Fatalf("ExecMaxWithOptions = %d migrations, %v, want 2", len(planned), err)
+	}
+	for _, want := range []string{"level=WARN", "out-of-order migration", "id=0003_t3", "latest_applied=0004_t4"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not mention %q:\n%s", want, logs.String())
+		}
+	}
+	if n := strings.Count(logs.String(), "out-of-order migration"); n != 1 {
+		t.Errorf("%d out-of-order warnings, want 1 for 0003_t3", n)
+	}
+}
+
+func TestOutOfOrderFail(t *testing.T) {
+	source, db := outOfOrderDB()
+	opts := migrations.ExecOptions{Strategy: migrations.Fail}
+
+	_, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, opts)
+	var orderErr *migrations.OutOfOrderError
+	if !errors.As(err, &orderErr) || !reflect.DeepEqual(orderErr.IDs, []string{"0003_t3"}) || orderErr.Latest != "0004_t4" {
+		t.Fatalf("ExecMaxWithOptions = %v, want an OutOfOrderError for 0003_t3", err)
+	}
+	if sql := migrationSQL(db); len(sql) != 0 {
+		t.Errorf("a failed run ran %q", sql)
+	}
+
+	// A dry run fails like the run it previews
+	opts.DryRun = true
+	if _, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, opts); !errors.As(err, &orderErr) {
+		t.Errorf("dry run = %v, want an OutOfOrderError", err)
+	}
+
+	// Migrations applied in order and rollbacks are unaffected
+	source, db = versionedDB(3)
+	if _, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{Strategy: migrations.Fail}); err != nil {
+		t.Errorf("in-order run failed: %v", err)
+	}
+	source, db = outOfOrderDB()
+	if _, err := migrations.ExecMaxWithOptions(db, source, migrations.Down, 1, migrations.ExecOptions{Strategy: migrations.Fail}); err != nil {
+		t.Errorf("rollback failed: %v", err)
+	}
+}
+
+func TestOutOfOrderComparesVersionsNumerically(t *testing.T) {
+	// 10_b sorts before 9_a as text, not as a version
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("9_a", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("10_b", "CREATE TABLE b (id INTEGER)"),
+	}}
+	db := mixedDB([]interface{}{"9_a", nil}).On("CREATE TABLE b (id INTEGER)", testd1.Fixture{})
+
+	planned, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, migrations.ExecOptions{Strategy: migrations.Fail})
+	if err != nil || len(planned) != 1 || planned[0].OutOfOrder {
+		t.Errorf("ExecMaxWithOptions = %+v, %v, want 10_b in order", planned, err)
+	}
+}
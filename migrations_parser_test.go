@@ -0,0 +1,163 @@
+package cloudflared1_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+func parseMigrationText(t *testing.T, content string) *migrations.Migration {
+	t.Helper()
+	m, err := migrations.ParseMigration("1_test.sql", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseMigration failed: %v", err)
+	}
+	return m
+}
+
+func TestParseStatementBlock(t *testing.T) {
+	m := parseMigrationText(t, `-- +migrate Up
+CREATE TABLE users (id INTEGER PRIMARY KEY, updated_at TEXT);
+
+-- +migrate StatementBegin
+CREATE TRIGGER users_touch AFTER UPDATE ON users
+BEGIN
+    UPDATE users SET updated_at = datetime('now') WHERE id = NEW.id;
+END;
+-- +migrate StatementEnd
+
+CREATE INDEX users_updated ON users (updated_at);
+
+-- +migrate Down
+DROP TRIGGER users_touch;
+DROP TABLE users;
+`)
+
+	want := []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, updated_at TEXT)",
+		"CREATE TRIGGER users_touch AFTER UPDATE ON users\nBEGIN\n    UPDATE users SET updated_at = datetime('now') WHERE id = NEW.id;\nEND",
+		"CREATE INDEX users_updated ON users (updated_at)",
+	}
+	if !reflect.DeepEqual(m.Up, want) {
+		t.Errorf("Up = %q, want %q", m.Up, want)
+	}
+	if want := []string{"DROP TRIGGER users_touch", "DROP TABLE users"}; !reflect.DeepEqual(m.Down, want) {
+		t.Errorf("Down = %q, want %q", m.Down, want)
+	}
+}
+
+func TestParseSkipsQuotedAndCommentedSemicolons(t *testing.T) {
+	m := parseMigrationText(t, `-- +migrate Up
+CREATE TABLE "odd;name" (v TEXT DEFAULT 'x;y');
+INSERT INTO "odd;name" (v) VALUES ('a;b'), ('it''s; fine');
+-- DELETE FROM "odd;name"; kept for later
+/* a block; comment */ UPDATE "odd;name" SET v = 'c';
+SELECT [odd;column] FROM "odd;name";
+`)
+
+	want := []string{
+		`CREATE TABLE "odd;name" (v TEXT DEFAULT 'x;y')`,
+		`INSERT INTO "odd;name" (v) VALUES ('a;b'), ('it''s; fine')`,
+		"-- DELETE FROM \"odd;name\"; kept for later\n/* a block; comment */ UPDATE \"odd;name\" SET v = 'c'",
+		`SELECT [odd;column] FROM "odd;name"`,
+	}
+	if !reflect.DeepEqual(m.Up, want) {
+		t.Errorf("Up = %q, want %q", m.Up, want)
+	}
+}
+
+func TestParseDropsCommentOnlyStatements(t *testing.T) {
+	m := parseMigrationText(t, `-- +migrate Up
+CREATE TABLE a (id INTEGER); -- the first table; more later
+-- +migrate Down
+-- nothing to undo; a is kept
+`)
+
+	if want := []string{"CREATE TABLE a (id INTEGER)"}; !reflect.DeepEqual(m.Up, want) {
+		t.Errorf("Up = %q, want %q", m.Up, want)
+	}
+	if len(m.Down) != 0 {
+		t.Errorf("Down = %q, want no statements", m.Down)
+	}
+}
+
+func TestParseRejectsUnbalancedStatementBlocks(t *testing.T) {
+	for _, content := range []string{
+		"-- +migrate Up\n-- +migrate StatementBegin\nCREATE TABLE a (id INTEGER);\n",
+		"-- +migrate Up\nCREATE TABLE a (id INTEGER);\n-- +migrate StatementEnd\n",
+		"-- +migrate Up\n-- +migrate StatementBegin\nSELECT 1;\n-- +migrate Down\n",
+	} {
+		if _, err := migrations.ParseMigration("1_test.sql", strings.NewReader(content)); err == nil {
+			t.Errorf("ParseMigration accepted %q", content)
+		}
+	}
+}
+
+func TestParseRequiresDirectionMarkers(t *testing.T) {
+	for _, tt := range []struct {
+		content string
+		want    []string
+	}{
+		{"CREATE TABLE a (id INTEGER);\n", []string{"no -- +migrate Up marker"}},
+		{"-- comments are fine\nCREATE TABLE a (id INTEGER);\n-- +migrate Up\nCREATE TABLE b (id INTEGER);\n",
+			[]string{"statements before the first marker, on line 3"}},
+		{"-- +migrate Up\nSELECT 1;\n-- +migrate Down\nSELECT 2;\n-- +migrate Up\nSELECT 3;\n-- +migrate Down\n",
+			[]string{"line 5: duplicate up marker, the first is on line 1", "line 7: duplicate down marker, the first is on line 3"}},
+	} {
+		_, err := migrations.ParseMigration("1_test.sql", strings.NewReader(tt.content))
+		for _, want := range tt.want {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("ParseMigration(%q) = %v, want an error mentioning %q", tt.content, err, want)
+			}
+		}
+	}
+
+	// Comments before the first marker and Down before Up are fine
+	m := parseMigrationText(t, "-- Creates a\n\n-- +migrate Down\nDROP TABLE a;\n-- +migrate Up\nCREATE TABLE a (id INTEGER);\n")
+	if len(m.Up) != 1 || len(m.Down) != 1 {
+		t.Errorf("Up = %q, Down = %q", m.Up, m.Down)
+	}
+}
+
+func TestFindMigrationsReportsAllProblems(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"1_init.sql":       "-- +migrate Up\nCREATE TABLE a (id INTEGER);\n",
+		"2_no_marker.sql":  "CREATE TABLE b (id INTEGER);\n",
+		"3_twice.sql":      "-- +migrate Up\nSELECT 1;\n-- +migrate Up\nSELECT 2;\n",
+		"4_users.sql":      "-- +migrate Up\nCREATE TABLE users (id INTEGER);\n",
+		"4_Users_copy.sql": "-- +migrate Up\nCREATE TABLE users (id INTEGER);\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := migrations.FileMigrationSource{Dir: dir}.FindMigrations()
+	for _, want := range []string{"2_no_marker.sql", "no -- +migrate Up marker", "3_twice.sql", "duplicate up marker", "share version 4"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("FindMigrations error %v does not mention %q", err, want)
+		}
+	}
+	if err := migrations.ValidateSource(migrations.FileMigrationSource{Dir: dir}, migrations.Up); err == nil || !strings.Contains(err.Error(), "2_no_marker.sql") {
+		t.Errorf("ValidateSource = %v, want the parse errors", err)
+	}
+
+	// Lenient sources read files without markers as Up statements
+	for _, name := range []string{"3_twice.sql", "4_Users_copy.sql"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := migrations.FileMigrationSource{Dir: dir, Lenient: true}.FindMigrations()
+	if err != nil {
+		t.Fatalf("lenient FindMigrations failed: %v", err)
+	}
+	if len(found) != 3 || found[1].Id != "2_no_marker.sql" || len(found[1].Up) != 1 {
+		t.Errorf("lenient FindMigrations = %+v", found)
+	}
+}
@@ -0,0 +1,118 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+// tenantSQL records the SQL run on each database ID
+type tenantSQL struct {
+	mu  sync.Mutex
+	sql map[string][]string
+
+	inflight, maxInflight atomic.Int64
+}
+
+// serveTenantSQL serves databases that accept every statement, except
+// creating tables in database ID failing
+func serveTenantSQL(t *testing.T, failing string) *tenantSQL {
+	t.Helper()
+	rec := &tenantSQL{sql: make(map[string][]string)}
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		n := rec.inflight.Add(1)
+		defer rec.inflight.Add(-1)
+		for max := rec.maxInflight.Load(); n > max && !rec.maxInflight.CompareAndSwap(max, n); max = rec.maxInflight.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		id := strings.Split(r.URL.Path, "/")[7]
+		var body struct {
+			SQL   string `json:"sql"`
+			Batch []struct {
+				SQL string `json:"sql"`
+			} `json:"batch"`
+		}
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+		statements := []string{body.SQL}
+		if len(body.Batch) > 0 {
+			statements = statements[:0]
+			for _, s := range body.Batch {
+				statements = append(statements, s.SQL)
+			}
+		}
+
+		rec.mu.Lock()
+		rec.sql[id] = append(rec.sql[id], statements...)
+		rec.mu.Unlock()
+
+		results := make([]interface{}, len(statements))
+		for i, sql := range statements {
+			if id == failing && strings.Contains(sql, "CREATE TABLE users") {
+				writeJSON(w, errorResponse(7500, "disk full"))
+				return
+			}
+			results[i] = queryResult(nil, nil, nil)
+		}
+		writeJSON(w, successResponse(results...))
+	})
+	return rec
+}
+
+func (rec *tenantSQL) ran(id, sql string) bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for _, s := range rec.sql[id] {
+		if strings.Contains(s, sql) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExecAllMigratesEveryCachedDatabase(t *testing.T) {
+	rec := serveTenantSQL(t, "id-broken")
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	for _, name := range []string{"t1", "t2", "t3", "t4", "broken"} {
+		if err := pool.ConnectWithID(name, "id-"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_users", "CREATE TABLE users (id INTEGER)"),
+		memoryMigration("2_orders", "CREATE TABLE orders (id INTEGER)"),
+	}}
+
+	applied, errs := migrations.ExecAll(pool, source, migrations.Up, 2)
+
+	for _, name := range []string{"t1", "t2", "t3", "t4"} {
+		if applied[name] != 2 || errs[name] != nil {
+			t.Errorf("%s: applied %d, error %v, want 2 migrations", name, applied[name], errs[name])
+		}
+		if !rec.ran("id-"+name, "CREATE TABLE users") || !rec.ran("id-"+name, "CREATE TABLE orders") {
+			t.Errorf("%s did not run both migrations: %q", name, rec.sql["id-"+name])
+		}
+	}
+	if errs["broken"] == nil || !strings.Contains(errs["broken"].Error(), "1_users") || applied["broken"] != 0 {
+		t.Errorf("broken: applied %d, error %v, want the failure of 1_users", applied["broken"], errs["broken"])
+	}
+	if rec.ran("id-broken", "CREATE TABLE orders") {
+		t.Error("migrations after the failed one ran on broken")
+	}
+	if len(errs) != 1 {
+		t.Errorf("errors = %v, want only broken", errs)
+	}
+	if max := rec.maxInflight.Load(); max > 2 {
+		t.Errorf("%d requests in flight, want at most 2", max)
+	}
+}
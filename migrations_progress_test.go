@@ -0,0 +1,120 @@
+package cloudflared1_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+)
+
+func TestOnMigrationReportsProgress(t *testing.T) {
+	failure := errors.New("no such table: missing")
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_users", "CREATE TABLE users (id INTEGER)", "INSERT INTO users (id) VALUES (1), (2)"),
+		memoryMigration("2_posts", "CREATE TABLE posts (id INTEGER)"),
+		memoryMigration("3_broken", "INSERT INTO missing (id) VALUES (1)"),
+	}}
+	db := setDB(testd1.New(), "d1_migrations").
+		On("CREATE TABLE users (id INTEGER)", testd1.Fixture{}).
+		On("INSERT INTO users (id) VALUES (1), (2)", testd1.Fixture{RowsAffected: 2, RowsWritten: 2}).
+		On("CREATE TABLE posts (id INTEGER)", testd1.Fixture{}).
+		On("INSERT INTO missing (id) VALUES (1)", testd1.Fixture{Err: failure})
+
+	var events []string
+	var logs bytes.Buffer
+	opts := migrations.ExecOptions{
+		Logger: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		OnMigration: func(e migrations.MigrationEvent) {
+			if !e.Done {
+				events = append(events, fmt.Sprintf("start %s %s %d", e.Id, e.Direction, e.Statements))
+				return
+			}
+			events = append(events, fmt.Sprintf("done %s %v", e.Id, errors.Is(e.Err, failure)))
+		},
+	}
+
+	applied, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, opts)
+	if !errors.Is(err, failure) || len(applied) != 2 {
+		t.Fatalf("ExecMaxWithOptions = %d migrations, %v, want 2 and the failure", len(applied), err)
+	}
+	want := []string{
+		"start 1_users up 2", "done 1_users false",
+		"start 2_posts up 1", "done 2_posts false",
+		"start 3_broken up 1", "done 3_broken true",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+
+	for _, want := range []string{
+		`level=DEBUG msg="ran migration statement" table=d1_migrations direction=up id=1_users statement=2 sql="INSERT INTO users (id) VALUES (1), (2)" rows_written=2`,
+		`id=2_posts statement=1 sql="CREATE TABLE posts (id INTEGER)" rows_written=0`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs.String())
+		}
+	}
+
+	// Dry runs apply nothing, so report nothing
+	events = nil
+	opts.DryRun = true
+	if _, err := migrations.ExecMaxWithOptions(db, source, migrations.Up, 0, opts); err != nil || len(events) != 0 {
+		t.Errorf("dry run = %v with events %q, want none", err, events)
+	}
+}
+
+func TestOnMigrationReportsRejectedStatementWithoutTransaction(t *testing.T) {
+	client, api := serveMigrationAPI(t, map[string]string{
+		"INSERT INTO missing": "no such table: missing: SQLITE_ERROR",
+	})
+	broken := memoryMigration("3_broken", "UPDATE users SET id = id + 1", "INSERT INTO missing (id) VALUES (1)")
+	broken.DisableTransactionUp = true
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_users", "CREATE TABLE users (id INTEGER)"),
+		memoryMigration("2_posts", "CREATE TABLE posts (id INTEGER)"),
+		broken,
+	}}
+
+	var events []string
+	opts := migrations.ExecOptions{
+		OnMigration: func(e migrations.MigrationEvent) {
+			if !e.Done {
+				events = append(events, fmt.Sprintf("start %s", e.Id))
+				return
+			}
+			events = append(events, fmt.Sprintf("done %s %v", e.Id, e.Err != nil && strings.Contains(e.Err.Error(), "SQLITE_ERROR")))
+		},
+	}
+
+	applied, err := migrations.ExecMaxWithOptions(client, source, migrations.Up, 0, opts)
+	if err == nil || !strings.Contains(err.Error(), "migration 3_broken statement 2") || len(applied) != 2 {
+		t.Fatalf("ExecMaxWithOptions = %d migrations, %v, want 2 and the rejected statement", len(applied), err)
+	}
+	want := []string{
+		"start 1_users", "done 1_users false",
+		"start 2_posts", "done 2_posts false",
+		"start 3_broken", "done 3_broken true",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+
+	// The failed migration is not recorded
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	records := 0
+	for _, sql := range api.sql {
+		if strings.HasPrefix(sql, `INSERT INTO "d1_migrations"`) {
+			records++
+		}
+	}
+	if records != 2 {
+		t.Errorf("recorded %d migrations, want 2", records)
+	}
+}
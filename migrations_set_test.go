@@ -0,0 +1,127 @@
+package cloudflared1_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+)
+
+// setDB returns a fake database accepting migrations recorded in table,
+// on which none are applied yet
+func setDB(db *testd1.DB, table string) *testd1.DB {
+	return db.
+		On(`CREATE TABLE IF NOT EXISTS "`+table+`" ( id TEXT PRIMARY KEY, applied_at DATETIME, checksum TEXT );`, testd1.Fixture{}).
+		On(`SELECT id, checksum FROM "`+table+`" ORDER BY id ASC;`, testd1.Fixture{Columns: []string{"id", "checksum"}}).
+		On(`INSERT INTO "`+table+`" (id, applied_at, checksum) VALUES (?, ?, ?);`, testd1.Fixture{RowsAffected: 1})
+}
+
+func TestMigrationSetsUseTheirOwnTables(t *testing.T) {
+	db := testd1.New().
+		On("CREATE TABLE users (id INTEGER)", testd1.Fixture{}).
+		On("CREATE TABLE events (id INTEGER)", testd1.Fixture{})
+	setDB(db, "app_migrations")
+	setDB(db, "analytics_migrations")
+
+	app, err := migrations.NewMigrationSet(migrations.MigrationSetOptions{TableName: "app_migrations"})
+	if err != nil {
+		t.Fatalf("NewMigrationSet failed: %v", err)
+	}
+	analytics, err := migrations.NewMigrationSet(migrations.MigrationSetOptions{TableName: "analytics_migrations"})
+	if err != nil {
+		t.Fatalf("NewMigrationSet failed: %v", err)
+	}
+
+	appSource := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_users", "CREATE TABLE users (id INTEGER)"),
+	}}
+	analyticsSource := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_events", "CREATE TABLE events (id INTEGER)"),
+	}}
+	if n, err := app.Exec(db, appSource, migrations.Up); n != 1 || err != nil {
+		t.Fatalf("app.Exec = %d, %v, want 1, nil", n, err)
+	}
+	if n, err := analytics.Exec(db, analyticsSource, migrations.Up); n != 1 || err != nil {
+		t.Fatalf("analytics.Exec = %d, %v, want 1, nil", n, err)
+	}
+	for _, call := range db.Calls() {
+		if strings.Contains(call.SQL, "d1_migrations") {
+			t.Errorf("a set used the default table: %s", call.SQL)
+		}
+	}
+
+	if _, err := migrations.NewMigrationSet(migrations.MigrationSetOptions{TableName: "bad\nname"}); err == nil {
+		t.Error("NewMigrationSet accepted a table name with a newline")
+	}
+}
+
+func TestMigrationSetOptions(t *testing.T) {
+	var logs bytes.Buffer
+	set, err := migrations.NewMigrationSet(migrations.MigrationSetOptions{
+		ExecOptions: migrations.ExecOptions{
+			DryRun: true,
+			Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewMigrationSet failed: %v", err)
+	}
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_a", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_b", "CREATE TABLE b (id INTEGER)"),
+	}}
+	db := testd1.New().
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{Columns: []string{"id", "checksum"}})
+
+	// The dry run of the set applies to Exec
+	if n, err := set.Exec(db, source, migrations.Up); n != 2 || err != nil {
+		t.Fatalf("Exec = %d, %v, want 2, nil", n, err)
+	}
+	if calls := db.Calls(); len(calls) != 1 {
+		t.Errorf("a dry-run set made %d calls, want 1", len(calls))
+	}
+	for _, want := range []string{"planned migration", "id=1_a", "id=2_b", "direction=up", "table=d1_migrations"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not mention %q:\n%s", want, logs.String())
+		}
+	}
+
+	planned, err := migrations.Plan(db, source, migrations.Up, 1)
+	if err != nil || len(planned) != 1 || planned[0].Id != "1_a" {
+		t.Errorf("Plan = %+v, %v, want 1_a", planned, err)
+	}
+}
+
+func TestGetRecords(t *testing.T) {
+	appliedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	db := testd1.New().
+		On(`SELECT id, applied_at, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{
+			Columns: []string{"id", "applied_at", "checksum"},
+			Rows: [][]interface{}{
+				{"1_a", appliedAt.Format(time.RFC3339), nil},
+				{"2_b", appliedAt.Add(time.Hour).Format(time.RFC3339), "abc"},
+			},
+		})
+
+	records, err := migrations.GetRecords(db)
+	if err != nil {
+		t.Fatalf("GetRecords failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Id != "1_a" || !records[0].AppliedAt.Equal(appliedAt) ||
+		records[0].Checksum != "" || records[1].Checksum != "abc" {
+		t.Errorf("records = %+v, %+v", records[0], records[1])
+	}
+
+	// A database never migrated has no records
+	db.On(`SELECT id, applied_at, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{
+		Err: errors.New("no such table: d1_migrations: SQLITE_ERROR"),
+	})
+	if records, err := migrations.GetRecords(db); records != nil || err != nil {
+		t.Errorf("GetRecords without a table = %v, %v, want nil, nil", records, err)
+	}
+}
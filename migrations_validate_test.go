@@ -0,0 +1,98 @@
+package cloudflared1_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+func memoryMigration(id string, up ...string) *migrations.Migration {
+	return &migrations.Migration{Id: id, Up: up, Down: []string{"SELECT 1"}}
+}
+
+func TestMemorySourceRejectsConflictingIDs(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_Users", "CREATE TABLE b (id INTEGER)"),
+		memoryMigration("2_users", "CREATE TABLE b (id INTEGER)"),
+	}}
+
+	_, err := source.FindMigrations()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`duplicate migration ID "1_init"`, "differ only by case"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestFileSourceRejectsSharedNumericPrefix(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0003_add_a.sql", "0003_add_b.sql"} {
+		content := "-- +migrate Up\nCREATE TABLE t (id INTEGER);\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := migrations.FileMigrationSource{Dir: dir}.FindMigrations()
+	if err == nil || !strings.Contains(err.Error(), "share version 3") {
+		t.Fatalf("expected shared version error, got %v", err)
+	}
+}
+
+func TestCombinedSourceValidatesAcrossSources(t *testing.T) {
+	combined := migrations.CombinedMigrationSource{Sources: []migrations.MigrationSource{
+		migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+			memoryMigration("2_orders", "CREATE TABLE orders (id INTEGER)"),
+		}},
+		migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+			memoryMigration("1_users", "CREATE TABLE users (id INTEGER)"),
+		}},
+	}}
+
+	found, err := combined.FindMigrations()
+	if err != nil {
+		t.Fatalf("FindMigrations failed: %v", err)
+	}
+	if len(found) != 2 || found[0].Id != "1_users" || found[1].Id != "2_orders" {
+		t.Errorf("unexpected merged order: %v", found)
+	}
+
+	combined.Sources = append(combined.Sources, migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_users", "CREATE TABLE users (id INTEGER)"),
+	}})
+	if _, err := combined.FindMigrations(); err == nil || !strings.Contains(err.Error(), `duplicate migration ID "1_users"`) {
+		t.Fatalf("expected duplicate across sources, got %v", err)
+	}
+}
+
+func TestValidateSourceReportsAllProblems(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init"),
+		memoryMigration("2_a", "CREATE TABLE a (id INTEGER)"),
+		memoryMigration("2_b", "CREATE TABLE b (id INTEGER)"),
+	}}
+
+	err := migrations.ValidateSource(source, migrations.Up)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{`"1_init" has no Up statements`, "share version 2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	// Empty Up sections do not matter when rolling back
+	source.Migrations = source.Migrations[:2]
+	if err := migrations.ValidateSource(source, migrations.Down); err != nil {
+		t.Errorf("unexpected error for Down: %v", err)
+	}
+}
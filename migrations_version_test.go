@@ -0,0 +1,120 @@
+package cloudflared1_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+)
+
+// versionedDB returns five migrations 0001_t1 to 0005_t5, each creating and
+// dropping a table, and a fake database on which 0001 to applied are applied
+func versionedDB(applied int) (migrations.MigrationSource, *testd1.DB) {
+	var source migrations.MemoryMigrationSource
+	var rows [][]interface{}
+	db := testd1.New().
+		On(`CREATE TABLE IF NOT EXISTS "d1_migrations" ( id TEXT PRIMARY KEY, applied_at DATETIME, checksum TEXT );`, testd1.Fixture{}).
+		On(`INSERT INTO "d1_migrations" (id, applied_at, checksum) VALUES (?, ?, ?);`, testd1.Fixture{RowsAffected: 1}).
+		On(`DELETE FROM "d1_migrations" WHERE id = ?;`, testd1.Fixture{RowsAffected: 1})
+	for i := 1; i <= 5; i++ {
+		m := &migrations.Migration{
+			Id:   fmt.Sprintf("%04d_t%d", i, i),
+			Up:   []string{fmt.Sprintf("CREATE TABLE t%d (id INTEGER)", i)},
+			Down: []string{fmt.Sprintf("DROP TABLE t%d", i)},
+		}
+		source.Migrations = append(source.Migrations, m)
+		db.On(m.Up[0], testd1.Fixture{}).On(m.Down[0], testd1.Fixture{})
+		if i <= applied {
+			rows = append(rows, []interface{}{m.Id})
+		}
+	}
+	db.On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{Columns: []string{"id"}, Rows: rows})
+	return source, db
+}
+
+// migrationSQL returns the calls of db after reading the applied
+// migrations, without the bookkeeping statements
+func migrationSQL(db *testd1.DB) []string {
+	var sql []string
+	for _, call := range db.Calls()[2:] {
+		if !strings.Contains(call.SQL, "d1_migrations") {
+			sql = append(sql, call.SQL)
+		}
+	}
+	return sql
+}
+
+func TestExecVersionMigratesDownPastSeveralVersions(t *testing.T) {
+	source, db := versionedDB(5)
+
+	n, err := migrations.ExecVersion(db, source, migrations.Down, 2)
+	if err != nil {
+		t.Fatalf("ExecVersion failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("applied %d migrations, want 3", n)
+	}
+	want := []string{"DROP TABLE t5", "DROP TABLE t4", "DROP TABLE t3"}
+	if got := migrationSQL(db); !reflect.DeepEqual(got, want) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+}
+
+func TestExecVersionMigratesUp(t *testing.T) {
+	source, db := versionedDB(1)
+
+	n, err := migrations.ExecVersion(db, source, migrations.Up, 3)
+	if err != nil {
+		t.Fatalf("ExecVersion failed: %v", err)
+	}
+	want := []string{"CREATE TABLE t2 (id INTEGER)", "CREATE TABLE t3 (id INTEGER)"}
+	if got := migrationSQL(db); n != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("applied %d migrations running %q, want %q", n, got, want)
+	}
+}
+
+func TestExecVersionAtTarget(t *testing.T) {
+	for _, dir := range []migrations.MigrationDirection{migrations.Up, migrations.Down} {
+		source, db := versionedDB(3)
+		n, err := migrations.ExecVersion(db, source, dir, 3)
+		if n != 0 || err != nil {
+			t.Errorf("ExecVersion(%v) = %d, %v, want 0, nil", dir, n, err)
+		}
+		if calls := db.Calls(); len(calls) != 2 {
+			t.Errorf("ExecVersion(%v) made %d calls, want 2", dir, len(calls))
+		}
+	}
+}
+
+func TestExecVersionErrors(t *testing.T) {
+	source, db := versionedDB(3)
+	for _, tt := range []struct {
+		dir     migrations.MigrationDirection
+		version int64
+		want    string
+	}{
+		{migrations.Up, 9, "no migration has this version"},
+		{migrations.Up, 2, "migrate down instead"},
+		{migrations.Down, 4, "migrate up instead"},
+	} {
+		db.Reset()
+		_, err := migrations.ExecVersion(db, source, tt.dir, tt.version)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ExecVersion(%v, %d) = %v, want an error mentioning %q", tt.dir, tt.version, err, tt.want)
+		}
+		if calls := db.Calls(); len(calls) != 2 {
+			t.Errorf("ExecVersion(%v, %d) made %d calls, want 2", tt.dir, tt.version, len(calls))
+		}
+	}
+
+	mixed := migrations.MemoryMigrationSource{Migrations: append(
+		source.(migrations.MemoryMigrationSource).Migrations,
+		memoryMigration("seed_data", "INSERT INTO t1 VALUES (1)"),
+	)}
+	if _, err := migrations.ExecVersion(db, mixed, migrations.Up, 5); err == nil || !strings.Contains(err.Error(), `"seed_data" has no numeric prefix`) {
+		t.Errorf("ExecVersion with a non-numeric ID = %v", err)
+	}
+}
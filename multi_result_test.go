@@ -0,0 +1,87 @@
+package cloudflared1_test
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+type multiUser struct {
+	ID     int64  `db:"id"`
+	Name   string `db:"name"`
+	Active bool   `db:"active"`
+}
+
+func TestMultiStatementResultSets(t *testing.T) {
+	serveFixture(t, "multi_statement.json")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	res, err := client.Query("UPDATE users SET active = 0; SELECT id, name, active FROM users", nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	results, err := res.ToResultAll()
+	if err != nil {
+		t.Fatalf("ToResultAll failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("ToResultAll returned %d results, want 2", len(results))
+	}
+	if n, _ := results[0].RowsAffected(); n != 2 || !results[0].Meta().ChangedDB {
+		t.Errorf("first result: RowsAffected = %d, Meta = %+v", n, results[0].Meta())
+	}
+
+	sets, err := res.ToRowsAll()
+	if err != nil {
+		t.Fatalf("ToRowsAll failed: %v", err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("ToRowsAll returned %d sets, want 2", len(sets))
+	}
+	if sets[0].Next() {
+		t.Error("the UPDATE result set has rows")
+	}
+
+	want := []multiUser{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}}
+	var users []multiUser
+	if err := sets[1].StructScanAll(&users); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("users = %+v, want %+v", users, want)
+	}
+
+	// The first set moves on to the second, which is not affected by the scan above
+	if !sets[0].NextResultSet() {
+		t.Fatal("NextResultSet = false, want the SELECT result set")
+	}
+	if cols, _ := sets[0].Columns(); len(cols) != 3 {
+		t.Errorf("Columns = %v, want id, name, active", cols)
+	}
+	var names []string
+	for sets[0].Next() {
+		var u multiUser
+		if err := sets[0].Scan(&u.ID, &u.Name, &u.Active); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		names = append(names, u.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Alice", "Bob"}) {
+		t.Errorf("names = %v", names)
+	}
+	if sets[0].NextResultSet() {
+		t.Error("NextResultSet = true after the last result set")
+	}
+
+	// The single-result helpers are unchanged
+	rows, err := res.ToRows()
+	if err != nil {
+		t.Fatalf("ToRows failed: %v", err)
+	}
+	if rows.NextResultSet() {
+		t.Error("NextResultSet = true on Rows from ToRows")
+	}
+}
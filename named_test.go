@@ -0,0 +1,118 @@
+package cloudflared1_test
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type namedUser struct {
+	ID        int    `db:"id"`
+	Name      string `db:"name"`
+	Age       int    `db:"age"`
+	CreatedAt string
+}
+
+func TestBindNamed(t *testing.T) {
+	user := namedUser{ID: 7, Name: "Alice", Age: 30, CreatedAt: "2024-01-01"}
+
+	tests := []struct {
+		name      string
+		query     string
+		arg       interface{}
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "struct",
+			query:     "INSERT INTO users (name, age) VALUES (:name, :age)",
+			arg:       user,
+			wantQuery: "INSERT INTO users (name, age) VALUES (?, ?)",
+			wantArgs:  []interface{}{"Alice", 30},
+		},
+		{
+			name:      "pointer to struct with mapped field",
+			query:     "UPDATE users SET created_at = :created_at WHERE id = :id",
+			arg:       &user,
+			wantQuery: "UPDATE users SET created_at = ? WHERE id = ?",
+			wantArgs:  []interface{}{"2024-01-01", 7},
+		},
+		{
+			name:      "map and repeated name",
+			query:     "SELECT * FROM users WHERE age > :age AND age < :age + 10",
+			arg:       map[string]interface{}{"age": 20},
+			wantQuery: "SELECT * FROM users WHERE age > ? AND age < ? + 10",
+			wantArgs:  []interface{}{20, 20},
+		},
+		{
+			name:      "literals, casts and comments are skipped",
+			query:     "SELECT ':skip', \"col:skip\", id::text FROM users -- :skip\nWHERE /* :skip */ name = :name AND note = 'it''s :skip'",
+			arg:       user,
+			wantQuery: "SELECT ':skip', \"col:skip\", id::text FROM users -- :skip\nWHERE /* :skip */ name = ? AND note = 'it''s :skip'",
+			wantArgs:  []interface{}{"Alice"},
+		},
+		{
+			name:      "no placeholders",
+			query:     "SELECT 1",
+			arg:       map[string]interface{}{},
+			wantQuery: "SELECT 1",
+			wantArgs:  []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := utils.BindNamed(tt.query, tt.arg)
+			if err != nil {
+				t.Fatalf("BindNamed failed: %v", err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBindNamedErrors(t *testing.T) {
+	if _, _, err := utils.BindNamed("SELECT :missing", map[string]interface{}{"other": 1}); err == nil {
+		t.Error("expected error for missing name")
+	}
+	if _, _, err := utils.BindNamed("SELECT :id", 42); err == nil {
+		t.Error("expected error for unsupported argument type")
+	}
+	if _, _, err := utils.BindNamed("SELECT :id", (*namedUser)(nil)); err == nil {
+		t.Error("expected error for nil pointer")
+	}
+}
+
+func TestNamedExecAndSelect(t *testing.T) {
+	bodies := recordBodies(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	if _, err := client.NamedExec("INSERT INTO users (name, age) VALUES (:name, :age)", namedUser{Name: "Alice", Age: 30}); err != nil {
+		t.Fatalf("NamedExec failed: %v", err)
+	}
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	var users []namedUser
+	if err := pool.NamedSelect(&users, "SELECT * FROM users WHERE age = :age", map[string]interface{}{"age": 25}); err != nil {
+		t.Fatalf("NamedSelect failed: %v", err)
+	}
+
+	want := []string{
+		`{"sql":"INSERT INTO users (name, age) VALUES (?, ?)","params":["Alice","30"]}`,
+		`{"sql":"SELECT * FROM users WHERE age = ?","params":["25"]}`,
+	}
+	if !reflect.DeepEqual(*bodies, want) {
+		t.Errorf("bodies = %q, want %q", *bodies, want)
+	}
+}
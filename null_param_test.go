@@ -0,0 +1,57 @@
+//go:build cgo
+
+package cloudflared1_test
+
+import (
+	"database/sql"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type note struct {
+	ID   int64   `db:"id"`
+	Note *string `db:"note"`
+}
+
+func TestNullParamRoundTrip(t *testing.T) {
+	serveSQLite(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	if _, err := client.Exec("CREATE TABLE notes (id INTEGER PRIMARY KEY, note TEXT)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+
+	nulls := []interface{}{nil, (*string)(nil), sql.NullString{}, NullEnum{}}
+	for i, arg := range nulls {
+		if _, err := client.Exec("INSERT INTO notes (id, note) VALUES (?, ?)", i+1, arg); err != nil {
+			t.Fatalf("INSERT of %T failed: %v", arg, err)
+		}
+	}
+	if _, err := client.Query("INSERT INTO notes (id, note) VALUES (?, ?), (?, ?)", []string{"5", utils.Null, "6", ""}); err != nil {
+		t.Fatalf("INSERT with utils.Null failed: %v", err)
+	}
+
+	var notes []note
+	if err := client.Select(&notes, "SELECT id, note FROM notes ORDER BY id"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(notes) != 6 {
+		t.Fatalf("got %d notes, want 6", len(notes))
+	}
+	for _, n := range notes[:5] {
+		if n.Note != nil {
+			t.Errorf("note %d = %q, want NULL", n.ID, *n.Note)
+		}
+	}
+	if notes[5].Note == nil || *notes[5].Note != "" {
+		t.Errorf("note 6 = %v, want the empty string", notes[5].Note)
+	}
+
+	var count int
+	if err := client.Get(&count, "SELECT count(*) FROM notes WHERE note IS ?", nil); err != nil || count != 5 {
+		t.Errorf("count of NULL notes = %d, %v; want 5", count, err)
+	}
+}
@@ -0,0 +1,110 @@
+package cloudflared1_test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// scanValue scans a one-column row holding src into dest
+func scanValue(t *testing.T, src interface{}, dest interface{}) {
+	t.Helper()
+	rows := utils.NewRows([]map[string]interface{}{{"v": src}}, []string{"v"})
+	rows.Next()
+	if err := rows.Scan(dest); err != nil {
+		t.Fatalf("Scan(%T) of %v failed: %v", dest, src, err)
+	}
+}
+
+func TestScanNullIntoPointers(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	str, i, i64, f, b, tm := "alice", 7, int64(1)<<40, 2.5, true, created
+
+	tests := []struct {
+		name string
+		src  interface{}
+		dest func() interface{} // a new **T
+		want interface{}        // the *T expected
+	}{
+		{"*string", "alice", func() interface{} { return new(*string) }, &str},
+		{"*int", float64(7), func() interface{} { return new(*int) }, &i},
+		{"*int64", float64(int64(1) << 40), func() interface{} { return new(*int64) }, &i64},
+		{"*float64", 2.5, func() interface{} { return new(*float64) }, &f},
+		{"*bool", float64(1), func() interface{} { return new(*bool) }, &b},
+		{"*time.Time", "2024-05-01 12:30:00", func() interface{} { return new(*time.Time) }, &tm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := tt.dest()
+			scanValue(t, tt.src, dest)
+			if got := reflect.ValueOf(dest).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("non-NULL: got %v, want %v", reflect.ValueOf(got).Elem(), reflect.ValueOf(tt.want).Elem())
+			}
+
+			// NULL sets a pointer that held a value back to nil
+			scanValue(t, nil, dest)
+			if got := reflect.ValueOf(dest).Elem(); !got.IsNil() {
+				t.Errorf("NULL: got %v, want nil", got.Elem())
+			}
+		})
+	}
+}
+
+func TestScanNullTypes(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		src      interface{}
+		dest     func() interface{}
+		want     interface{}
+		wantNull interface{}
+	}{
+		{"NullString", "alice", func() interface{} { return &sql.NullString{} }, sql.NullString{String: "alice", Valid: true}, sql.NullString{}},
+		{"NullInt64", float64(42), func() interface{} { return &sql.NullInt64{} }, sql.NullInt64{Int64: 42, Valid: true}, sql.NullInt64{}},
+		{"NullInt32", "42", func() interface{} { return &sql.NullInt32{} }, sql.NullInt32{Int32: 42, Valid: true}, sql.NullInt32{}},
+		{"NullFloat64", 1.5, func() interface{} { return &sql.NullFloat64{} }, sql.NullFloat64{Float64: 1.5, Valid: true}, sql.NullFloat64{}},
+		{"NullBool", float64(1), func() interface{} { return &sql.NullBool{} }, sql.NullBool{Bool: true, Valid: true}, sql.NullBool{}},
+		{"NullTime", "2024-05-01T12:30:00Z", func() interface{} { return &sql.NullTime{} }, sql.NullTime{Time: created, Valid: true}, sql.NullTime{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := tt.dest()
+			scanValue(t, tt.src, dest)
+			if got := reflect.ValueOf(dest).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("non-NULL: got %+v, want %+v", got, tt.want)
+			}
+			scanValue(t, nil, dest)
+			if got := reflect.ValueOf(dest).Elem().Interface(); !reflect.DeepEqual(got, tt.wantNull) {
+				t.Errorf("NULL: got %+v, want %+v", got, tt.wantNull)
+			}
+		})
+	}
+}
+
+func TestStructScanNullFields(t *testing.T) {
+	type profile struct {
+		Name      string         `db:"name"`
+		Age       int            `db:"age"`
+		Email     *string        `db:"email"`
+		Bio       sql.NullString `db:"bio"`
+		DeletedAt *time.Time     `db:"deleted_at"`
+	}
+	rows := utils.NewRows([]map[string]interface{}{
+		{"name": nil, "age": nil, "email": nil, "bio": nil, "deleted_at": nil},
+	}, []string{"name", "age", "email", "bio", "deleted_at"})
+	rows.Next()
+
+	stale := "old@example.com"
+	p := profile{Name: "x", Age: 1, Email: &stale, Bio: sql.NullString{String: "x", Valid: true}}
+	if err := rows.StructScan(&p); err != nil {
+		t.Fatalf("StructScan failed: %v", err)
+	}
+	// Non-pointer fields keep turning NULL into the zero value
+	if p != (profile{}) {
+		t.Errorf("profile = %+v, want all zero", p)
+	}
+}
@@ -0,0 +1,100 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// countRequests mocks the raw query endpoint and counts the requests made
+func countRequests(t *testing.T) *int {
+	n := new(int)
+	var mu sync.Mutex
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*n++
+		mu.Unlock()
+		writeJSON(w, successResponse(queryResult(nil, nil, nil), queryResult(nil, nil, nil)))
+	})
+	return n
+}
+
+func TestParamCountCheckedBeforeSending(t *testing.T) {
+	requests := countRequests(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	_, err := client.Exec("INSERT INTO users (id, name, email) VALUES (?, ?, ?)", 1, "Alice")
+	var countErr *utils.ParamCountError
+	if !errors.As(err, &countErr) || countErr.Placeholders != 3 || countErr.Params != 2 {
+		t.Fatalf("err = %v, want a ParamCountError for 3 placeholders and 2 params", err)
+	}
+	if err.Error() != "query has 3 placeholders but 2 parameters were provided" {
+		t.Errorf("err = %q", err)
+	}
+	if _, err := client.Query("SELECT * FROM users WHERE id = ?", nil); !errors.As(err, &countErr) {
+		t.Errorf("Query err = %v, want a ParamCountError", err)
+	}
+	if *requests != 0 {
+		t.Errorf("%d requests sent, want none", *requests)
+	}
+
+	// Question marks in literals, identifiers and comments are not placeholders
+	for _, query := range []string{
+		"SELECT '?' AS q, \"a?\" FROM users WHERE id = ?",
+		"SELECT * FROM users -- where name = ?\nWHERE id = ?",
+		"SELECT * FROM users /* name = ? AND\n email = ? */ WHERE id = ?",
+	} {
+		if _, err := client.Exec(query, 1); err != nil {
+			t.Errorf("Exec(%q) failed: %v", query, err)
+		}
+	}
+	if *requests != 3 {
+		t.Errorf("%d requests sent, want 3", *requests)
+	}
+}
+
+func TestParamCountInBatch(t *testing.T) {
+	requests := countRequests(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	_, err := client.Batch([]utils.Statement{
+		{SQL: "UPDATE users SET name = ? WHERE id = ?", Params: []interface{}{"Alice", 1}},
+		{SQL: "DELETE FROM sessions WHERE user_id = ?"},
+	})
+	var batchErr *utils.BatchError
+	var countErr *utils.ParamCountError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.As(err, &countErr) {
+		t.Fatalf("err = %v, want a ParamCountError for statement 1", err)
+	}
+	if *requests != 0 {
+		t.Errorf("%d requests sent, want none", *requests)
+	}
+}
+
+func TestParamCountCheckDisabled(t *testing.T) {
+	requests := countRequests(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.SetCheckParamCount(false)
+
+	if _, err := client.Exec("SELECT ?, ?", 1); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	// Named placeholders can't be counted and are sent as they are
+	client.SetCheckParamCount(true)
+	if _, err := client.Exec("SELECT :a, :b", 1); err != nil {
+		t.Fatalf("Exec with named placeholders failed: %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("%d requests sent, want 2", *requests)
+	}
+}
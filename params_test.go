@@ -0,0 +1,109 @@
+package cloudflared1_test
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// recordBodies mocks the raw query endpoint and records every request body.
+func recordBodies(t *testing.T) *[]string {
+	t.Helper()
+	var bodies []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, strings.TrimSpace(string(b)))
+		writeJSON(w, successResponse(queryResult(nil, nil, nil), queryResult(nil, nil, nil)))
+	})
+	return &bodies
+}
+
+func TestEmptyParamsAreSentAsEmptyArray(t *testing.T) {
+	bodies := recordBodies(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	_, _ = client.Query("SELECT 1", nil)
+	_, _ = client.Query("SELECT 1", []string{})
+	_, _ = client.CreateTable("CREATE TABLE t (id INTEGER)")
+	_, _ = client.Exec("DELETE FROM t")
+	_, _ = client.Batch([]utils.Statement{{SQL: "SELECT 1"}, {SQL: "SELECT ?", Params: []interface{}{2}}})
+
+	want := []string{
+		`{"sql":"SELECT 1","params":[]}`,
+		`{"sql":"SELECT 1","params":[]}`,
+		`{"sql":"CREATE TABLE t (id INTEGER)","params":[]}`,
+		`{"sql":"DELETE FROM t","params":[]}`,
+		`{"batch":[{"sql":"SELECT 1","params":[]},{"sql":"SELECT ?","params":["2"]}]}`,
+	}
+	if len(*bodies) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(*bodies), len(want))
+	}
+	for i := range want {
+		if (*bodies)[i] != want[i] {
+			t.Errorf("request %d body = %s, want %s", i, (*bodies)[i], want[i])
+		}
+	}
+}
+
+func TestBindParamsSendsBytesAsBlob(t *testing.T) {
+	query, params, err := utils.BindParams(
+		"INSERT INTO files (name, data, thumb) VALUES (?, ?, ?5) -- ? in a comment\nON CONFLICT DO UPDATE SET note = '?', data = ?2",
+		[]interface{}{"a.png", []byte{0x89, 'P', 0}, 3, 4, []byte("hi")},
+	)
+	if err != nil {
+		t.Fatalf("BindParams failed: %v", err)
+	}
+	wantQuery := "INSERT INTO files (name, data, thumb) VALUES (?, unhex(?), unhex(?5)) -- ? in a comment\nON CONFLICT DO UPDATE SET note = '?', data = unhex(?2)"
+	if query != wantQuery {
+		t.Errorf("query = %q, want %q", query, wantQuery)
+	}
+	if want := []string{"a.png", "895000", "3", "4", "6869"}; strings.Join(params, ",") != strings.Join(want, ",") {
+		t.Errorf("params = %v, want %v", params, want)
+	}
+
+	query, _, _ = utils.BindParams("SELECT ?", []interface{}{"text"})
+	if query != "SELECT ?" {
+		t.Errorf("query without bytes was rewritten to %q", query)
+	}
+}
+
+func TestBindNullsKeepsParams(t *testing.T) {
+	params := []string{"a", utils.Null}
+	query, sent := utils.BindNulls("UPDATE t SET x = ? WHERE y IS ?", params)
+	if query != "UPDATE t SET x = ? WHERE y IS nullif(?, '')" || sent[1] != "" {
+		t.Errorf("BindNulls = %q, %q", query, sent)
+	}
+	if params[1] != utils.Null {
+		t.Errorf("BindNulls modified the params passed in: %q", params)
+	}
+}
+
+func TestConvertParamsDereferencesPointers(t *testing.T) {
+	n, name := 42, "Alice"
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	pn := &n
+	price := money(1250)
+
+	params, err := utils.ConvertParams(&n, &name, &created, &pn, &price, (*int)(nil), (*time.Time)(nil), (**int)(nil))
+	if err != nil {
+		t.Fatalf("ConvertParams failed: %v", err)
+	}
+	want := []string{"42", "Alice", "2024-05-01T12:30:00.000000000Z", "42", "1250", utils.Null, utils.Null, utils.Null}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %q, want %q", params, want)
+	}
+
+	data := []byte{1, 2}
+	query, params, err := utils.BindParams("SELECT ?", []interface{}{&data})
+	if err != nil || query != "SELECT unhex(?)" || params[0] != "0102" {
+		t.Errorf("BindParams(*[]byte) = %q, %q, %v", query, params, err)
+	}
+}
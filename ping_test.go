@@ -0,0 +1,141 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveDatabases mocks a D1 account with the databases in ids, keyed by name.
+// Queries against an ID not in ids fail like queries against a deleted database.
+func serveDatabases(t *testing.T, ids map[string]string) {
+	t.Helper()
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/client/v4/accounts/account_id/d1/database")
+		if path == "" {
+			var items []interface{}
+			for name, id := range ids {
+				if strings.Contains(name, r.URL.Query().Get("name")) {
+					items = append(items, map[string]interface{}{"uuid": id, "name": name})
+				}
+			}
+			writeJSON(w, successResponse(items...))
+			return
+		}
+
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/raw")
+		for name, known := range ids {
+			if known != id {
+				continue
+			}
+			if strings.HasSuffix(path, "/raw") {
+				writeJSON(w, successResponse(queryResult([]string{"1"}, [][]interface{}{{1}}, nil)))
+			} else {
+				writeJSON(w, map[string]interface{}{
+					"result":  map[string]interface{}{"uuid": id, "name": name, "num_tables": 3},
+					"success": true,
+				})
+			}
+			return
+		}
+		writeJSON(w, errorResponse(7404, "The database "+id+" could not be found"))
+	})
+}
+
+func TestGetDatabase(t *testing.T) {
+	serveDatabases(t, map[string]string{"app": "id-1"})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	info, err := client.GetDatabase("id-1")
+	if err != nil {
+		t.Fatalf("GetDatabase failed: %v", err)
+	}
+	if info.UUID != "id-1" || info.Name != "app" || info.NumTables != 3 {
+		t.Errorf("info = %+v", info)
+	}
+
+	if _, err := client.GetDatabase("deleted"); err == nil || !strings.Contains(err.Error(), "could not be found") {
+		t.Errorf("GetDatabase of a deleted database = %v, want API error", err)
+	}
+}
+
+func TestClientPing(t *testing.T) {
+	serveDatabases(t, map[string]string{"app": "id-1"})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	if err := client.Ping(); err == nil {
+		t.Error("Ping without a database succeeded")
+	}
+
+	client.DatabaseID = "id-1"
+	if err := client.Ping(); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+
+	client.DatabaseID = "deleted"
+	if err := client.Ping(); err == nil {
+		t.Error("Ping of a deleted database succeeded")
+	}
+}
+
+func TestPoolPingReconnects(t *testing.T) {
+	ids := map[string]string{"app": "id-1"}
+	serveDatabases(t, ids)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	if err := pool.Connect("app"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := pool.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if pool.LastHealthCheck().IsZero() {
+		t.Error("LastHealthCheck not recorded")
+	}
+
+	// The database was recreated under a new ID, which the pool looks up
+	ids["app"] = "id-2"
+	if err := pool.Ping(); err != nil {
+		t.Fatalf("Ping after the ID changed failed: %v", err)
+	}
+	if id := pool.GetDatabaseID("app"); id != "id-2" {
+		t.Errorf("database ID = %s, want id-2", id)
+	}
+
+	// The database was deleted
+	delete(ids, "app")
+	if err := pool.Ping(); err == nil {
+		t.Fatal("Ping of a deleted database succeeded")
+	}
+	if pool.IsCached("app") {
+		t.Error("stale cache entry was kept")
+	}
+}
+
+func TestPoolPingKeepsEntry(t *testing.T) {
+	serveDatabases(t, map[string]string{"app": "id-1"})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("app", "stale")
+
+	// A cancelled check says nothing about the database
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pool.PingContext(ctx); err == nil {
+		t.Fatal("Ping with a cancelled context succeeded")
+	}
+	if !pool.IsCached("app") {
+		t.Error("cancelled Ping dropped the cache entry")
+	}
+
+	pool.SetAutoReconnect(false)
+	if err := pool.Ping(); err == nil {
+		t.Fatal("Ping of the stale ID succeeded")
+	}
+	if !pool.IsCached("app") {
+		t.Error("Ping dropped the cache entry with auto reconnect disabled")
+	}
+}
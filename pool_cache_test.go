@@ -0,0 +1,134 @@
+package cloudflared1_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// recordDatabaseIDs mocks the raw query endpoint and records the database ID of every query.
+func recordDatabaseIDs(t *testing.T) *[]string {
+	t.Helper()
+	var ids []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/raw"), "/")
+		ids = append(ids, parts[len(parts)-1])
+		writeJSON(w, successResponse(queryResult(nil, nil, nil)))
+	})
+	return &ids
+}
+
+func TestUpdateCacheEntryIsUsedByLaterQueries(t *testing.T) {
+	ids := recordDatabaseIDs(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	if err := pool.ConnectWithID("main", "id-1"); err != nil {
+		t.Fatalf("ConnectWithID failed: %v", err)
+	}
+	if _, err := pool.Query("SELECT 1", nil); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	if err := pool.UpdateCacheEntry("main", "id-2"); err != nil {
+		t.Fatalf("UpdateCacheEntry failed: %v", err)
+	}
+	if _, err := pool.Query("SELECT 1", nil); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, err := pool.QueryDB("main", "SELECT 1", nil); err != nil {
+		t.Fatalf("QueryDB failed: %v", err)
+	}
+
+	want := []string{"id-1", "id-2", "id-2"}
+	if strings.Join(*ids, ",") != strings.Join(want, ",") {
+		t.Errorf("queried database IDs = %v, want %v", *ids, want)
+	}
+	if got := pool.GetDatabaseID("main"); got != "id-2" {
+		t.Errorf("GetDatabaseID = %q, want id-2", got)
+	}
+}
+
+func TestRenameCacheEntryMovesCurrentDatabase(t *testing.T) {
+	ids := recordDatabaseIDs(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("old", "id-1")
+
+	if err := pool.RenameCacheEntry("old", "new"); err != nil {
+		t.Fatalf("RenameCacheEntry failed: %v", err)
+	}
+	if pool.IsCached("old") {
+		t.Error("old name should no longer be cached")
+	}
+	if pool.GetCurrentDB() != "new" {
+		t.Errorf("current database = %q, want new", pool.GetCurrentDB())
+	}
+	if info := pool.GetCacheInfo("new"); info == nil || info.Name != "new" || info.DatabaseID != "id-1" {
+		t.Errorf("unexpected cache info %+v", info)
+	}
+
+	if _, err := pool.Query("SELECT 1", nil); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(*ids) != 1 || (*ids)[0] != "id-1" {
+		t.Errorf("queried database IDs = %v, want [id-1]", *ids)
+	}
+}
+
+func TestCacheEntryValidation(t *testing.T) {
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("a", "id-a")
+	_ = pool.ConnectWithID("b", "id-b")
+
+	if err := pool.UpdateCacheEntry("missing", "id"); err == nil {
+		t.Error("expected error updating an uncached entry")
+	}
+	if err := pool.UpdateCacheEntry("a", ""); err == nil {
+		t.Error("expected error updating to an empty ID")
+	}
+	if err := pool.RenameCacheEntry("a", "b"); err == nil {
+		t.Error("expected error renaming onto an existing entry")
+	}
+	if err := pool.RenameCacheEntry("missing", "c"); err == nil {
+		t.Error("expected error renaming an uncached entry")
+	}
+	if err := pool.RenameCacheEntry("a", ""); err == nil {
+		t.Error("expected error renaming to an empty name")
+	}
+	if err := pool.ConnectWithID("", "id"); err == nil {
+		t.Error("expected error connecting with an empty name")
+	}
+}
+
+func TestCacheEvents(t *testing.T) {
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+
+	var events []cloudflare_d1_go.CacheEvent
+	pool.OnCacheEvent(func(e cloudflare_d1_go.CacheEvent) {
+		// Hooks run without the pool lock held, so calling back in must not deadlock
+		_ = pool.IsCached(e.Name)
+		events = append(events, e)
+	})
+
+	_ = pool.ConnectWithID("a", "id-1")
+	_ = pool.UpdateCacheEntry("a", "id-2")
+	_ = pool.RenameCacheEntry("a", "b")
+	pool.ClearCache("b")
+
+	want := []cloudflare_d1_go.CacheEvent{
+		{Type: cloudflare_d1_go.CacheEntrySet, Name: "a", DatabaseID: "id-1"},
+		{Type: cloudflare_d1_go.CacheEntryUpdated, Name: "a", DatabaseID: "id-2", OldDatabaseID: "id-1"},
+		{Type: cloudflare_d1_go.CacheEntryRenamed, Name: "b", OldName: "a", DatabaseID: "id-2"},
+		{Type: cloudflare_d1_go.CacheEntryRemoved, Name: "b", DatabaseID: "id-2"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
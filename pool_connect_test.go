@@ -0,0 +1,85 @@
+package cloudflared1_test
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// connectConcurrently calls Connect("prod") from n goroutines at once and
+// returns their errors
+func connectConcurrently(pool *cloudflare_d1_go.ConnectionPool, n int) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = pool.Connect("prod")
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+func TestConcurrentConnectSharesLookup(t *testing.T) {
+	var lookups atomic.Int64
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		writeJSON(w, successResponse(map[string]interface{}{"uuid": "db-1", "name": "prod"}))
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var events atomic.Int64
+	pool.OnCacheEvent(func(cloudflare_d1_go.CacheEvent) { events.Add(1) })
+
+	for i, err := range connectConcurrently(pool, 50) {
+		if err != nil {
+			t.Errorf("Connect %d failed: %v", i, err)
+		}
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d ListDB requests, want 1", n)
+	}
+	if n := events.Load(); n != 1 {
+		t.Errorf("%d cache events, want 1", n)
+	}
+	if id := pool.GetDatabaseID("prod"); id != "db-1" {
+		t.Errorf("database ID = %q, want db-1", id)
+	}
+}
+
+func TestConcurrentConnectSharesError(t *testing.T) {
+	var lookups atomic.Int64
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(w, errorResponse(10000, "Authentication error"))
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	for i, err := range connectConcurrently(pool, 50) {
+		if err == nil || !strings.Contains(err.Error(), "Authentication error") {
+			t.Errorf("Connect %d = %v, want the error of the shared lookup", i, err)
+		}
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d ListDB requests, want 1", n)
+	}
+
+	// A failed lookup is not remembered
+	_ = pool.Connect("prod")
+	if n := lookups.Load(); n != 2 {
+		t.Errorf("%d ListDB requests after retrying, want 2", n)
+	}
+}
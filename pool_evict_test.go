@@ -0,0 +1,165 @@
+package cloudflared1_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveTenants serves a database id-<name> for every name. Queries on
+// id-busy signal started and wait for release.
+func serveTenants(t *testing.T, started, release chan struct{}) {
+	t.Helper()
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			name := r.URL.Query().Get("name")
+			writeJSON(w, successResponse(map[string]interface{}{"uuid": "id-" + name, "name": name}))
+			return
+		}
+		if strings.Contains(r.URL.Path, "/id-busy/") {
+			started <- struct{}{}
+			<-release
+		}
+		writeJSON(w, successResponse(queryResult([]string{"n"}, [][]interface{}{{1}}, nil)))
+	})
+}
+
+func TestPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	serveTenants(t, nil, nil)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetMaxCachedDatabases(3)
+	var evicted, removed []string
+	pool.OnEvict(func(e cloudflare_d1_go.CacheEvent) { evicted = append(evicted, e.Name) })
+	pool.OnCacheEvent(func(e cloudflare_d1_go.CacheEvent) {
+		if e.Type == cloudflare_d1_go.CacheEntryRemoved {
+			removed = append(removed, e.Name)
+		}
+	})
+
+	handle := func(name string) *cloudflare_d1_go.PoolDB {
+		db, err := pool.DB(name)
+		if err != nil {
+			t.Fatalf("DB(%s) failed: %v", name, err)
+		}
+		return db
+	}
+	a := handle("a")
+	handle("b")
+	c := handle("c")
+	_, _ = a.Exec("SELECT 1") // b is now the least recently used
+	handle("d")
+	_, _ = c.Exec("SELECT 1") // a is now the least recently used
+	handle("e")
+	_ = pool.Connect("d") // c is now the least recently used
+	handle("f")
+
+	if want := []string{"b", "a", "c"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+	if !reflect.DeepEqual(removed, evicted) {
+		t.Errorf("removal events %v, want %v", removed, evicted)
+	}
+	if got := pool.ListCachedDatabases(); len(got) != 3 {
+		t.Errorf("cached %v, want 3 databases", got)
+	}
+	if stats := pool.Stats(); stats.Evictions != 3 {
+		t.Errorf("Stats().Evictions = %d, want 3", stats.Evictions)
+	}
+
+	// Lowering the limit evicts right away, except the current database d
+	pool.SetMaxCachedDatabases(1)
+	if want := []string{"b", "a", "c", "e", "f"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+}
+
+func TestPoolDoesNotEvictDatabasesInUse(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	serveTenants(t, started, release)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetMaxCachedDatabases(1)
+	if err := pool.Connect("current"); err != nil {
+		t.Fatal(err)
+	}
+	busy, err := pool.DB("busy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error)
+	go func() {
+		_, err := busy.Exec("SELECT 1")
+		errs <- err
+	}()
+	<-started
+
+	// Neither the current database nor the one with a query in flight can go
+	if _, err := pool.DB("other"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"current", "busy", "other"} {
+		if !pool.IsCached(name) {
+			t.Errorf("%s was evicted while in use", name)
+		}
+	}
+
+	close(release)
+	if err := <-errs; err != nil {
+		t.Errorf("in-flight query failed: %v", err)
+	}
+	if _, err := pool.DB("next"); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.ListCachedDatabases(); len(got) != 2 || !pool.IsCached("current") || !pool.IsCached("next") {
+		t.Errorf("cached %v, want current and next", got)
+	}
+	var n int
+	if err := pool.Get(&n, "SELECT 1"); err != nil {
+		t.Errorf("query on the current database failed: %v", err)
+	}
+}
+
+func TestPoolDoesNotEvictDatabasesBeingReconnected(t *testing.T) {
+	serveTenants(t, nil, nil)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetMaxCachedDatabases(1)
+	// Slow hooks widen the window between caching an entry and using it
+	pool.OnCacheEvent(func(cloudflare_d1_go.CacheEvent) { time.Sleep(time.Millisecond) })
+
+	var handles []*cloudflare_d1_go.PoolDB
+	for _, name := range []string{"a", "b", "c"} {
+		db, err := pool.DB(name)
+		if err != nil {
+			t.Fatalf("DB(%s) failed: %v", name, err)
+		}
+		handles = append(handles, db)
+	}
+
+	var wg sync.WaitGroup
+	var failures atomic.Int32
+	for _, db := range handles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := db.Exec("SELECT 1"); err != nil {
+					if failures.Add(1) == 1 {
+						t.Errorf("Exec failed: %v", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := failures.Load(); n != 0 {
+		t.Errorf("%d of 150 queries failed", n)
+	}
+}
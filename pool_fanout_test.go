@@ -0,0 +1,162 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type shardRow struct {
+	ID    int    `db:"id"`
+	Shard string `db:"shard"`
+}
+
+// serveShards serves the databases a, b and c, with id-<name> as ID and
+// rows tagged with their name, and a database broken whose queries fail.
+// Databases answer in reverse order, so c finishes first.
+func serveShards(t *testing.T) (maxInflight *atomic.Int64) {
+	t.Helper()
+	rows := map[string][][]interface{}{
+		"id-a": {{1, "a"}, {2, "a"}},
+		"id-b": {{3, "b"}},
+		"id-c": {{4, "c"}, {5, "c"}, {6, "c"}},
+	}
+	delays := map[string]time.Duration{"id-a": 80 * time.Millisecond, "id-b": 40 * time.Millisecond}
+	var inflight atomic.Int64
+	maxInflight = &atomic.Int64{}
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			var items []interface{}
+			for _, name := range []string{"a", "b", "c", "broken"} {
+				items = append(items, map[string]interface{}{"uuid": "id-" + name, "name": name})
+			}
+			writeJSON(w, successResponse(items...))
+			return
+		}
+
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for max := maxInflight.Load(); n > max && !maxInflight.CompareAndSwap(max, n); max = maxInflight.Load() {
+		}
+
+		id := strings.Split(r.URL.Path, "/")[7]
+		time.Sleep(delays[id])
+		if id == "id-broken" {
+			writeJSON(w, errorResponse(7500, "no such table: items"))
+			return
+		}
+		writeJSON(w, successResponse(queryResult([]string{"id", "shard"}, rows[id], nil)))
+	})
+	return maxInflight
+}
+
+func TestPoolSelectMany(t *testing.T) {
+	serveShards(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var got []shardRow
+	if err := pool.SelectMany([]string{"a", "b", "c"}, &got, "SELECT id, shard FROM items"); err != nil {
+		t.Fatalf("SelectMany failed: %v", err)
+	}
+	want := []shardRow{{1, "a"}, {2, "a"}, {3, "b"}, {4, "c"}, {5, "c"}, {6, "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	// Grouped by the order of the names, not by completion
+	if err := pool.SelectMany([]string{"c", "a"}, &got, "SELECT id, shard FROM items"); err != nil {
+		t.Fatalf("SelectMany failed: %v", err)
+	}
+	want = []shardRow{{4, "c"}, {5, "c"}, {6, "c"}, {1, "a"}, {2, "a"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	if err := pool.SelectMany([]string{"a"}, got, "SELECT 1"); err == nil {
+		t.Error("SelectMany accepted a slice that is not a pointer")
+	}
+}
+
+func TestPoolSelectManyPartialFailure(t *testing.T) {
+	serveShards(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var got []shardRow
+	err := pool.SelectMany([]string{"a", "broken", "missing", "c"}, &got, "SELECT id, shard FROM items")
+
+	var fanOut *cloudflare_d1_go.FanOutError
+	if !errors.As(err, &fanOut) {
+		t.Fatalf("error = %v, want a *FanOutError", err)
+	}
+	if len(fanOut.Failed) != 2 || fanOut.Failed[0].Database != "broken" || fanOut.Failed[1].Database != "missing" {
+		t.Errorf("failed = %+v, want broken and missing", fanOut.Failed)
+	}
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) || !strings.Contains(err.Error(), "database broken: ") {
+		t.Errorf("error %q does not carry the API error of broken", err)
+	}
+	if !errors.Is(err, cloudflare_d1_go.ErrDatabaseNotFound) {
+		t.Errorf("error %q does not report missing as not found", err)
+	}
+	if want := []shardRow{{1, "a"}, {2, "a"}, {4, "c"}, {5, "c"}, {6, "c"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want those of a and c", got)
+	}
+}
+
+func TestPoolSelectManyConcurrency(t *testing.T) {
+	maxInflight := serveShards(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_, _ = pool.WarmCache(nil)
+	pool.SetFanOutConcurrency(2)
+	names := []string{"a", "b", "c", "a", "b", "c"}
+	var got []shardRow
+	if err := pool.SelectMany(names, &got, "SELECT id, shard FROM items"); err != nil {
+		t.Fatalf("SelectMany failed: %v", err)
+	}
+	if len(got) != 12 {
+		t.Errorf("got %d rows, want 12", len(got))
+	}
+	if max := maxInflight.Load(); max != 2 {
+		t.Errorf("%d queries in flight at most, want 2", max)
+	}
+}
+
+func TestPoolSelectManyFunc(t *testing.T) {
+	serveShards(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var order []string
+	counts := map[string]int{}
+	err := pool.SelectManyFunc([]string{"a", "b", "c", "broken"}, func(dbName string, rows *utils.Rows) error {
+		order = append(order, dbName)
+		var part []shardRow
+		if err := rows.StructScanAll(&part); err != nil {
+			return err
+		}
+		counts[dbName] = len(part)
+		if dbName == "b" {
+			return errors.New("rejected by the callback")
+		}
+		return nil
+	}, "SELECT id, shard FROM items")
+
+	// Delivered as they finished
+	if want := []string{"c", "b", "a"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("callback order = %v, want %v", order, want)
+	}
+	if want := map[string]int{"a": 2, "b": 1, "c": 3}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("rows per database = %v, want %v", counts, want)
+	}
+	var fanOut *cloudflare_d1_go.FanOutError
+	if !errors.As(err, &fanOut) || len(fanOut.Failed) != 2 || fanOut.Failed[0].Database != "b" || fanOut.Failed[1].Database != "broken" {
+		t.Errorf("error = %v, want failures of b and broken", err)
+	}
+}
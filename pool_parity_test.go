@@ -0,0 +1,186 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// databaseMethods is the method set that Client, ConnectionPool and PoolDB
+// all offer for one database. Adding a query method to Client without adding
+// it to the pool and its handles, or changing a signature on one side only,
+// breaks this build.
+type databaseMethods interface {
+	Query(query string, params []string) (*utils.APIResponse, error)
+	QueryContext(ctx context.Context, query string, params []string) (*utils.APIResponse, error)
+	Select(dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	Get(dest interface{}, query string, args ...interface{}) error
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (int64, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error)
+	ExecResult(query string, args ...interface{}) (*utils.Result, error)
+	ExecResultContext(ctx context.Context, query string, args ...interface{}) (*utils.Result, error)
+	Count(query string, args ...interface{}) (int64, error)
+	CountContext(ctx context.Context, query string, args ...interface{}) (int64, error)
+	CountTable(table, where string, args ...interface{}) (int64, error)
+	CountTableContext(ctx context.Context, table, where string, args ...interface{}) (int64, error)
+	Exists(query string, args ...interface{}) (bool, error)
+	ExistsContext(ctx context.Context, query string, args ...interface{}) (bool, error)
+	QueryToCSV(w io.Writer, query string, args ...interface{}) error
+	QueryToCSVContext(ctx context.Context, w io.Writer, query string, args ...interface{}) error
+	NamedExec(query string, arg interface{}) (int64, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (int64, error)
+	NamedSelect(dest interface{}, query string, arg interface{}) error
+	NamedSelectContext(ctx context.Context, dest interface{}, query string, arg interface{}) error
+	InsertStruct(table string, v interface{}, opts ...cloudflare_d1_go.InsertOption) (*utils.Result, error)
+	InsertStructContext(ctx context.Context, table string, v interface{}, opts ...cloudflare_d1_go.InsertOption) (*utils.Result, error)
+	UpdateStruct(table string, v interface{}, keyColumns ...string) (int64, error)
+	UpdateStructContext(ctx context.Context, table string, v interface{}, keyColumns ...string) (int64, error)
+	UpdateStructWithOptions(table string, v interface{}, opts cloudflare_d1_go.UpdateOptions) (int64, error)
+	UpdateStructWithOptionsContext(ctx context.Context, table string, v interface{}, opts cloudflare_d1_go.UpdateOptions) (int64, error)
+	BulkInsert(table string, columns []string, rows [][]interface{}, opts ...cloudflare_d1_go.BulkOption) (int64, error)
+	BulkInsertContext(ctx context.Context, table string, columns []string, rows [][]interface{}, opts ...cloudflare_d1_go.BulkOption) (int64, error)
+	BulkInsertStructs(table string, slice interface{}, opts ...cloudflare_d1_go.BulkOption) (int64, error)
+	BulkInsertStructsContext(ctx context.Context, table string, slice interface{}, opts ...cloudflare_d1_go.BulkOption) (int64, error)
+	Batch(statements []utils.Statement) ([]utils.BatchResult, error)
+	BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error)
+	BatchInsertIDs(statements []utils.Statement, opts ...cloudflare_d1_go.InsertIDsOption) ([]int64, error)
+	BatchInsertIDsContext(ctx context.Context, statements []utils.Statement, opts ...cloudflare_d1_go.InsertIDsOption) ([]int64, error)
+	CreateTable(createQuery string) (*utils.APIResponse, error)
+	RemoveTable(tableName string) (*utils.APIResponse, error)
+	ListTables(opts ...cloudflare_d1_go.ListTablesOption) ([]string, error)
+	TableExists(name string) (bool, error)
+	DescribeTable(name string) ([]cloudflare_d1_go.ColumnInfo, error)
+	DeclareColumnTypes(rows *utils.Rows, table string) error
+	ListIndexes(table string) ([]cloudflare_d1_go.IndexInfo, error)
+	Ping() error
+	PingContext(ctx context.Context) error
+}
+
+// sharedMethods adds the configuration that Client and ConnectionPool both
+// offer
+type sharedMethods interface {
+	databaseMethods
+	SetNameMapper(mapper utils.NameMapper)
+	SetTrimSemicolons(enabled bool)
+	SetCheckParamCount(enabled bool)
+	UseQueryEndpoint(enabled bool)
+	SetLogger(logger cloudflare_d1_go.Logger, opts ...cloudflare_d1_go.LogOption)
+	Usage() cloudflare_d1_go.Usage
+	ResetUsage() cloudflare_d1_go.Usage
+	ReportUsage(interval time.Duration, fn func(cloudflare_d1_go.Usage)) *cloudflare_d1_go.UsageReporter
+}
+
+var (
+	_ sharedMethods = (*cloudflare_d1_go.Client)(nil)
+	_ sharedMethods = (*cloudflare_d1_go.ConnectionPool)(nil)
+
+	_ databaseMethods = (*cloudflare_d1_go.PoolDB)(nil)
+)
+
+// clientOnlyMethods are the Client methods a PoolDB deliberately does not
+// offer; PoolDB.Client reaches them
+var clientOnlyMethods = map[string]bool{
+	// Account-level calls and calls naming another database
+	"ConnectDB": true, "CreateDB": true, "CreateDBWithOptions": true, "DeleteDB": true,
+	"GetDatabase": true, "ListDB": true, "ListAllDBs": true, "ListDBPaged": true,
+	"VerifyToken": true, "VerifyTokenContext": true,
+	"QueryDB": true, "QueryDBContext": true, "BatchDB": true, "BatchDBContext": true,
+	"CreateTableWithID": true, "RemoveTableWithID": true,
+	"WithDatabase": true, "WithDatabaseName": true,
+	// Configuration, which a handle takes from its pool
+	"SetNameMapper": true, "SetTrimSemicolons": true, "SetCheckParamCount": true, "SetStrictScan": true,
+	"UseQueryEndpoint": true, "SetLogger": true, "WithCache": true, "DryRun": true,
+	"InvalidateAll": true, "InvalidateQuery": true,
+	"Usage": true, "ResetUsage": true, "ReportUsage": true,
+	// Sessions, database/sql rows, backups and Time Travel
+	"NewSession": true, "SQLRows": true, "SQLRowsContext": true,
+	"ExportDatabase": true, "ExportDatabaseContext": true, "ImportSQL": true, "ImportSQLContext": true,
+	"BookmarkAt": true, "BookmarkAtContext": true, "TimeTravelInfo": true, "TimeTravelInfoContext": true,
+	"RestoreToBookmark": true, "RestoreToBookmarkContext": true,
+	"RestoreToTimestamp": true, "RestoreToTimestampContext": true,
+}
+
+// TestPoolDBHasClientMethods fails when a Client method is added without
+// adding it to PoolDB, or to clientOnlyMethods
+func TestPoolDBHasClientMethods(t *testing.T) {
+	clientType := reflect.TypeOf((*cloudflare_d1_go.Client)(nil))
+	handleType := reflect.TypeOf((*cloudflare_d1_go.PoolDB)(nil))
+
+	// Method types include the receiver, so compare the parameters after it
+	signature := func(m reflect.Method) string {
+		var params, results []string
+		for i := 1; i < m.Type.NumIn(); i++ {
+			params = append(params, m.Type.In(i).String())
+		}
+		for i := 0; i < m.Type.NumOut(); i++ {
+			results = append(results, m.Type.Out(i).String())
+		}
+		return fmt.Sprintf("(%s) (%s) variadic=%v", strings.Join(params, ", "), strings.Join(results, ", "), m.Type.IsVariadic())
+	}
+
+	for i := 0; i < clientType.NumMethod(); i++ {
+		method := clientType.Method(i)
+		if clientOnlyMethods[method.Name] {
+			continue
+		}
+		handleMethod, ok := handleType.MethodByName(method.Name)
+		if !ok {
+			t.Errorf("PoolDB has no %s; add it, or list it in clientOnlyMethods", method.Name)
+			continue
+		}
+		if want, got := signature(method), signature(handleMethod); got != want {
+			t.Errorf("PoolDB.%s%s, want %s like Client", method.Name, got, want)
+		}
+	}
+	for name := range clientOnlyMethods {
+		if _, ok := clientType.MethodByName(name); !ok {
+			t.Errorf("clientOnlyMethods lists %s, which Client does not have", name)
+		}
+	}
+}
+
+func TestPoolDB(t *testing.T) {
+	var paths []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeJSON(w, successResponse(queryResult(nil, nil, nil)))
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	_ = pool.ConnectWithID("logs", "db-2")
+
+	db, err := pool.DB("logs")
+	if err != nil {
+		t.Fatalf("DB failed: %v", err)
+	}
+	if db.DatabaseID() != "db-2" {
+		t.Errorf("DatabaseID = %q, want db-2", db.DatabaseID())
+	}
+	if _, err := db.Exec("DELETE FROM logs"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if len(paths) != 1 || !strings.Contains(paths[0], "/database/db-2/") {
+		t.Errorf("request paths = %v, want one request to db-2", paths)
+	}
+
+	if _, err := pool.DB("missing"); err == nil {
+		t.Error("expected error for a database that is not cached")
+	}
+
+	_ = pool.Close(context.Background())
+	if _, err := pool.DB("main"); !errors.Is(err, cloudflare_d1_go.ErrPoolClosed) {
+		t.Errorf("DB after Close = %v, want ErrPoolClosed", err)
+	}
+}
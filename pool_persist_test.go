@@ -0,0 +1,146 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestPoolSaveAndLoadCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "secret_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	_ = pool.ConnectWithID("logs", "db-2")
+	cachedAt := pool.GetCacheInfo("main").CachedAt
+
+	if err := pool.SaveCache(path); err != nil {
+		t.Fatalf("SaveCache failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("file mode = %o, want 600", mode)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret_token") {
+		t.Errorf("cache file contains the API token: %s", data)
+	}
+
+	restored := cloudflare_d1_go.NewConnectionPool("account_id", "secret_token")
+	if err := restored.LoadCache(path); err != nil {
+		t.Fatalf("LoadCache failed: %v", err)
+	}
+	if id := restored.GetDatabaseID("logs"); id != "db-2" {
+		t.Errorf("logs ID = %q, want db-2", id)
+	}
+	if got := restored.GetCacheInfo("main").CachedAt; !got.Equal(cachedAt) {
+		t.Errorf("CachedAt = %v, want %v", got, cachedAt)
+	}
+
+	other := cloudflare_d1_go.NewConnectionPool("other_account", "secret_token")
+	if err := other.LoadCache(path); err == nil {
+		t.Error("LoadCache accepted the cache of another account")
+	}
+}
+
+func TestPoolLoadCacheExpired(t *testing.T) {
+	ids := map[string]string{"app": "id-2"}
+	serveDatabases(t, ids)
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("app", "id-1")
+	if err := pool.SaveCache(path); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	restored := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	restored.SetCacheAge(time.Millisecond)
+	if err := restored.LoadCache(path); err != nil {
+		t.Fatalf("LoadCache failed: %v", err)
+	}
+	if restored.IsCached("app") {
+		t.Error("an entry older than the cache age is reported as cached")
+	}
+	if err := restored.Connect("app"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if id := restored.GetDatabaseID("app"); id != "id-2" {
+		t.Errorf("database ID after Connect = %q, want id-2", id)
+	}
+}
+
+func TestPoolLoadCacheErrors(t *testing.T) {
+	dir := t.TempDir()
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+
+	err := pool.LoadCache(filepath.Join(dir, "missing.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadCache of a missing file = %v, want fs.ErrNotExist", err)
+	}
+
+	for name, content := range map[string]string{
+		"corrupt":  `{"version": 1, "databases": [`,
+		"version":  `{"version": 99, "account_id": "account_id"}`,
+		"empty ID": `{"version": 1, "account_id": "account_id", "databases": [{"name": "app"}]}`,
+	} {
+		path := filepath.Join(dir, "cache.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := pool.LoadCache(path); err == nil {
+			t.Errorf("%s: LoadCache succeeded", name)
+		}
+	}
+}
+
+func TestPoolSaveCacheErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("app", "db-1")
+
+	// The parent of the path is a file, not a directory
+	if err := pool.SaveCache(filepath.Join(file, "cache.json")); err == nil {
+		t.Error("SaveCache into a file succeeded")
+	}
+
+	var saveErrs []error
+	pool.SetAutoSave(filepath.Join(file, "cache.json"), func(err error) { saveErrs = append(saveErrs, err) })
+	_ = pool.ConnectWithID("logs", "db-2")
+	if len(saveErrs) != 1 {
+		t.Errorf("auto-save errors = %v, want one", saveErrs)
+	}
+}
+
+func TestPoolAutoSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetAutoSave(path, func(err error) { t.Errorf("auto-save failed: %v", err) })
+
+	_ = pool.ConnectWithID("app", "db-1")
+	restored := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	if err := restored.LoadCache(path); err != nil {
+		t.Fatalf("LoadCache failed: %v", err)
+	}
+	if id := restored.GetDatabaseID("app"); id != "db-1" {
+		t.Errorf("auto-saved ID = %q, want db-1", id)
+	}
+
+	pool.ClearCache("app")
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "db-1") {
+		t.Errorf("removed entry still saved: %s", data)
+	}
+}
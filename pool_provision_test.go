@@ -0,0 +1,160 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+// provisioner fakes the database API for ConnectOrCreate. Created databases
+// answer pings with 7404 notReady times first.
+type provisioner struct {
+	mu       sync.Mutex
+	ids      map[string]string
+	notReady int
+	creates  int
+	pings    int
+	queries  []string
+}
+
+func serveProvisioner(t *testing.T, p *provisioner) {
+	t.Helper()
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		path := strings.TrimPrefix(r.URL.Path, "/client/v4/accounts/account_id/d1/database")
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodGet:
+			var items []interface{}
+			if id, ok := p.ids[r.URL.Query().Get("name")]; ok {
+				items = append(items, map[string]interface{}{"uuid": id, "name": r.URL.Query().Get("name")})
+			}
+			writeJSON(w, successResponse(items...))
+		case path == "":
+			var req struct {
+				Name string `json:"name"`
+			}
+			_ = json.Unmarshal(body, &req)
+			p.creates++
+			p.ids[req.Name] = "id-" + req.Name
+			writeJSON(w, map[string]interface{}{
+				"result":  map[string]interface{}{"uuid": "id-" + req.Name, "name": req.Name},
+				"success": true,
+			})
+		case strings.Contains(string(body), "SELECT 1"):
+			p.pings++
+			if p.notReady > 0 {
+				p.notReady--
+				writeJSON(w, errorResponse(7404, "The database could not be found"))
+				return
+			}
+			writeJSON(w, successResponse(queryResult([]string{"1"}, [][]interface{}{{1}}, nil)))
+		default:
+			p.queries = append(p.queries, path+" "+string(body))
+			var batch struct {
+				Batch []json.RawMessage `json:"batch"`
+			}
+			_ = json.Unmarshal(body, &batch)
+			results := make([]interface{}, max(len(batch.Batch), 1))
+			for i := range results {
+				results[i] = queryResult(nil, nil, nil)
+			}
+			writeJSON(w, successResponse(results...))
+		}
+	})
+}
+
+func TestPoolConnectOrCreateExisting(t *testing.T) {
+	p := &provisioner{ids: map[string]string{"tenant": "id-existing"}}
+	serveProvisioner(t, p)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var setups int
+	created, err := pool.ConnectOrCreate("tenant", cloudflare_d1_go.ConnectOrCreateOptions{
+		Setup: func(cloudflare_d1_go.Queryer) error { setups++; return nil },
+	})
+	if err != nil || created {
+		t.Fatalf("ConnectOrCreate = %v, %v, want an existing database", created, err)
+	}
+	if p.creates != 0 || setups != 0 {
+		t.Errorf("%d creates and %d setups, want none", p.creates, setups)
+	}
+	if id := pool.GetDatabaseID("tenant"); id != "id-existing" {
+		t.Errorf("database ID = %q, want id-existing", id)
+	}
+}
+
+func TestPoolConnectOrCreateMissing(t *testing.T) {
+	p := &provisioner{ids: map[string]string{}}
+	serveProvisioner(t, p)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	opts := cloudflare_d1_go.ConnectOrCreateOptions{
+		CreateDBOptions: cloudflare_d1_go.CreateDBOptions{PrimaryLocationHint: cloudflare_d1_go.LocationWesternEurope},
+		Setup: migrations.Setup(migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+			memoryMigration("1_init", "CREATE TABLE accounts (id INTEGER)"),
+		}}),
+	}
+	created, err := pool.ConnectOrCreate("tenant", opts)
+	if err != nil || !created {
+		t.Fatalf("ConnectOrCreate = %v, %v, want a created database", created, err)
+	}
+	if id := pool.GetDatabaseID("tenant"); id != "id-tenant" {
+		t.Errorf("database ID = %q, want id-tenant", id)
+	}
+	var migrated bool
+	for _, q := range p.queries {
+		migrated = migrated || strings.HasPrefix(q, "/id-tenant/") && strings.Contains(q, "CREATE TABLE accounts")
+	}
+	if !migrated {
+		t.Errorf("migration not applied to the new database: %v", p.queries)
+	}
+
+	// Now it exists
+	created, err = pool.ConnectOrCreate("tenant", opts)
+	if err != nil || created || p.creates != 1 {
+		t.Errorf("second ConnectOrCreate = %v, %v after %d creates, want the cached database", created, err, p.creates)
+	}
+}
+
+func TestPoolConnectOrCreateSlowProvisioning(t *testing.T) {
+	p := &provisioner{ids: map[string]string{}, notReady: 2}
+	serveProvisioner(t, p)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	created, err := pool.ConnectOrCreate("tenant", cloudflare_d1_go.ConnectOrCreateOptions{})
+	if err != nil || !created {
+		t.Fatalf("ConnectOrCreate = %v, %v, want a created database", created, err)
+	}
+	if p.pings != 3 {
+		t.Errorf("%d pings, want 3", p.pings)
+	}
+	var n int
+	if err := pool.Get(&n, "SELECT 1"); err != nil || n != 1 {
+		t.Errorf("Get on the new database = %d, %v", n, err)
+	}
+
+	// Never ready
+	p.notReady = 1000
+	setupErr := errors.New("not called")
+	created, err = pool.ConnectOrCreate("other", cloudflare_d1_go.ConnectOrCreateOptions{
+		ReadyTimeout: 250 * time.Millisecond,
+		Setup:        func(cloudflare_d1_go.Queryer) error { return setupErr },
+	})
+	if err == nil || !created || errors.Is(err, setupErr) {
+		t.Errorf("ConnectOrCreate of a database never ready = %v, %v, want created and a readiness error", created, err)
+	}
+	if pool.IsCached("other") {
+		t.Error("a database that never became ready was cached")
+	}
+}
@@ -0,0 +1,86 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestPoolFollowsRecreatedDatabase(t *testing.T) {
+	ids := map[string]string{"app": "id-1"}
+	serveDatabases(t, ids)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var events []cloudflare_d1_go.CacheEvent
+	pool.OnCacheEvent(func(e cloudflare_d1_go.CacheEvent) { events = append(events, e) })
+	if err := pool.Connect("app"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	db, _ := pool.DB("app")
+	events = nil
+
+	// Deleted and created again under the same name
+	ids["app"] = "id-2"
+	var n int
+	if err := pool.Get(&n, "SELECT 1"); err != nil || n != 1 {
+		t.Fatalf("Get after the ID changed = %d, %v", n, err)
+	}
+	if id := pool.GetDatabaseID("app"); id != "id-2" {
+		t.Errorf("database ID = %s, want id-2", id)
+	}
+	if len(events) != 1 || events[0].Type != cloudflare_d1_go.CacheEntryUpdated || events[0].OldDatabaseID != "id-1" {
+		t.Errorf("events = %+v, want one update from id-1", events)
+	}
+	if _, err := db.Exec("SELECT 1"); err != nil {
+		t.Errorf("handle Exec after the ID changed failed: %v", err)
+	}
+
+	pool.SetAutoReconnect(false)
+	ids["app"] = "id-3"
+	if _, err := pool.Exec("SELECT 1"); err == nil {
+		t.Error("Exec looked the database up with auto-reconnect disabled")
+	}
+}
+
+func TestPoolStartRefresh(t *testing.T) {
+	serveDatabases(t, map[string]string{"app": "id-1"})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("app", "id-1")
+	_ = pool.ConnectWithID("gone", "id-deleted")
+	pool.SetCacheAge(time.Millisecond)
+
+	removed := make(chan string, 2)
+	pool.OnCacheEvent(func(e cloudflare_d1_go.CacheEvent) {
+		if e.Type == cloudflare_d1_go.CacheEntryRemoved {
+			removed <- e.Name
+		}
+	})
+
+	before := time.Now()
+	refresher := pool.StartRefresh(context.Background(), time.Millisecond)
+	select {
+	case name := <-removed:
+		if name != "gone" {
+			t.Errorf("removed %s, want gone", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("deleted database was not evicted")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for info := pool.GetCacheInfo("app"); info == nil || info.LastHealthCheck.Before(before); info = pool.GetCacheInfo("app") {
+		if time.Now().After(deadline) {
+			t.Fatalf("app entry = %+v, want it renewed by the refresher", info)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := refresher.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := pool.Close(context.Background()); err != nil {
+		t.Errorf("pool Close failed: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package cloudflared1_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestPoolStats(t *testing.T) {
+	serveTwoDatabases(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	start := time.Now()
+	_ = pool.Connect("main") // miss
+	_ = pool.Connect("main") // hit
+	_ = pool.Connect("logs") // miss
+	_ = pool.Connect("main") // hit
+	var name string
+	for i := 0; i < 3; i++ {
+		if err := pool.Get(&name, "SELECT 'main'"); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	logs, _ := pool.DB("logs")
+	if err := logs.Get(&name, "SELECT 'logs'"); err != nil {
+		t.Fatalf("handle Get failed: %v", err)
+	}
+
+	stats := pool.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Evictions != 0 || stats.ActiveDatabases != 2 {
+		t.Errorf("stats = %+v, want 2 hits, 2 misses, no evictions and 2 databases", stats)
+	}
+	if main := stats.Databases["main"]; main.QueryCount != 3 || main.LastUsedAt.Before(start) {
+		t.Errorf("main stats = %+v, want 3 queries used after %v", main, start)
+	}
+	if got := stats.Databases["logs"].QueryCount; got != 1 {
+		t.Errorf("logs queries = %d, want 1", got)
+	}
+	info := pool.GetCacheInfo("main")
+	if info.QueryCount != 3 || !info.LastUsedAt.Equal(stats.Databases["main"].LastUsedAt) {
+		t.Errorf("GetCacheInfo = %+v, want the usage of Stats", info)
+	}
+
+	// Counters survive a rename but not a removal
+	if err := pool.RenameCacheEntry("logs", "audit"); err != nil {
+		t.Fatal(err)
+	}
+	if got := pool.Stats().Databases["audit"].QueryCount; got != 1 {
+		t.Errorf("renamed entry queries = %d, want 1", got)
+	}
+	pool.ClearCache("audit")
+	pool.SetCacheAge(time.Nanosecond)
+	_ = pool.Connect("main") // expired: eviction and miss
+
+	stats = pool.Stats()
+	if stats.Evictions != 2 || stats.Misses != 3 || stats.ActiveDatabases != 1 {
+		t.Errorf("stats = %+v, want 2 evictions, 3 misses and 1 database", stats)
+	}
+	if got := stats.Databases["main"].QueryCount; got != 3 {
+		t.Errorf("main queries after expiry = %d, want 3", got)
+	}
+}
+
+func TestPoolStatsConcurrent(t *testing.T) {
+	serveTwoDatabases(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	main, _ := pool.DB("main")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			var name string
+			_ = main.Get(&name, "SELECT 'main'")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = pool.Stats()
+		}()
+	}
+	wg.Wait()
+
+	if got := pool.Stats().Databases["main"].QueryCount; got != 20 {
+		t.Errorf("queries = %d, want 20", got)
+	}
+}
@@ -0,0 +1,71 @@
+package cloudflared1_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestPoolWarmCache(t *testing.T) {
+	names := make([]string, 250)
+	for i := range names {
+		names[i] = fmt.Sprintf("tenant-%03d", i)
+	}
+	names = append(names, "admin")
+	queries := serveDatabaseList(t, names)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var events int
+	pool.OnCacheEvent(func(cloudflare_d1_go.CacheEvent) { events++ })
+	before := time.Now()
+	n, err := pool.WarmCache(func(name string) bool { return strings.HasPrefix(name, "tenant-") })
+	if err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if n != 250 || events != 250 || len(pool.ListCachedDatabases()) != 250 {
+		t.Errorf("cached %d with %d events, want 250", n, events)
+	}
+	if len(*queries) != 3 {
+		t.Errorf("queries = %q, want one list of 3 pages", *queries)
+	}
+	if pool.IsCached("admin") {
+		t.Error("a database not matching the filter was cached")
+	}
+	info := pool.GetCacheInfo("tenant-249")
+	if info == nil || info.DatabaseID != "uuid-tenant-249" || info.CachedAt.Before(before) {
+		t.Errorf("cache info = %+v, want uuid-tenant-249 cached now", info)
+	}
+
+	// Warm entries are hits for Connect
+	if err := pool.Connect("tenant-042"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.Stats(); stats.Hits != 1 || stats.Misses != 0 || len(*queries) != 3 {
+		t.Errorf("stats = %+v after %d requests, want a hit without a request", stats, len(*queries))
+	}
+}
+
+func TestPoolWarmCacheExpiryAndEviction(t *testing.T) {
+	serveDatabaseList(t, []string{"a", "b", "c", "d"})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	pool.SetMaxCachedDatabases(2)
+	n, err := pool.WarmCache(nil)
+	if err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if n != 4 || len(pool.ListCachedDatabases()) != 2 || !pool.IsCached("c") || !pool.IsCached("d") {
+		t.Errorf("cached %d, left %v, want the last two of 4", n, pool.ListCachedDatabases())
+	}
+	if stats := pool.Stats(); stats.Evictions != 2 {
+		t.Errorf("evictions = %d, want 2", stats.Evictions)
+	}
+
+	pool.SetCacheAge(time.Nanosecond)
+	if pool.IsCached("c") {
+		t.Error("a warmed entry did not expire")
+	}
+}
@@ -0,0 +1,137 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveTwoDatabases mocks the databases "main" (db-1) and "logs" (db-2).
+// Queries name the database they are meant for, as in SELECT 'logs', and
+// a query reaching the other database is reported.
+func serveTwoDatabases(t *testing.T) (lookups *atomic.Int64) {
+	t.Helper()
+	ids := map[string]string{"main": "db-1", "logs": "db-2"}
+	lookups = &atomic.Int64{}
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			lookups.Add(1)
+			writeJSON(w, successResponse(
+				map[string]interface{}{"uuid": "db-1", "name": "main"},
+				map[string]interface{}{"uuid": "db-2", "name": "logs"},
+			))
+			return
+		}
+		var body struct {
+			SQL string `json:"sql"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		name := strings.Trim(strings.TrimPrefix(body.SQL, "SELECT "), "'")
+		if id := strings.Split(r.URL.Path, "/")[7]; id != ids[name] {
+			t.Errorf("query for %s reached %s", name, id)
+		}
+		writeJSON(w, successResponse(queryResult([]string{"name"}, [][]interface{}{{name}}, nil)))
+	})
+	return lookups
+}
+
+func TestPoolDBConcurrentHandles(t *testing.T) {
+	serveTwoDatabases(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		name := []string{"main", "logs"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, err := pool.DB(name)
+			if err != nil {
+				t.Errorf("DB(%s) failed: %v", name, err)
+				return
+			}
+			for j := 0; j < 5; j++ {
+				var got string
+				if err := db.Get(&got, fmt.Sprintf("SELECT '%s'", name)); err != nil || got != name {
+					t.Errorf("Get on %s = %q, %v", name, got, err)
+				}
+				// Connecting elsewhere does not move the handle
+				_ = pool.ConnectWithID("other", "db-3")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPoolDBConnectsOnDemand(t *testing.T) {
+	lookups := serveTwoDatabases(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	db, err := pool.DB("logs")
+	if err != nil {
+		t.Fatalf("DB failed: %v", err)
+	}
+	if db.DatabaseID() != "db-2" || lookups.Load() != 1 {
+		t.Errorf("DatabaseID = %q after %d lookups, want db-2 after one", db.DatabaseID(), lookups.Load())
+	}
+	if _, err := pool.Query("SELECT 'logs'", nil); err == nil {
+		t.Error("DB made logs the current database of the pool")
+	}
+
+	// A dropped cache entry is looked up again
+	pool.ClearCache("logs")
+	if _, err := db.Exec("SELECT 'logs'"); err != nil {
+		t.Fatalf("Exec after ClearCache failed: %v", err)
+	}
+	if lookups.Load() != 2 {
+		t.Errorf("%d lookups, want a second one after ClearCache", lookups.Load())
+	}
+
+	pool.SetAutoReconnect(false)
+	pool.ClearCache("logs")
+	if _, err := db.Exec("SELECT 'logs'"); err == nil {
+		t.Error("Exec reconnected with auto-reconnect disabled")
+	}
+}
+
+func TestPoolDBMethods(t *testing.T) {
+	lookups := serveTwoDatabases(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	if err := pool.ConnectWithID("main", "db-1"); err != nil {
+		t.Fatalf("ConnectWithID failed: %v", err)
+	}
+
+	// logs is connected on demand
+	var names []string
+	if err := pool.SelectDB("logs", &names, "SELECT 'logs'"); err != nil || len(names) != 1 || names[0] != "logs" {
+		t.Errorf("SelectDB = %v, %v", names, err)
+	}
+	var name string
+	if err := pool.GetDB("logs", &name, "SELECT 'logs'"); err != nil || name != "logs" {
+		t.Errorf("GetDB = %q, %v", name, err)
+	}
+	if _, err := pool.ExecDB("main", "SELECT 'main'"); err != nil {
+		t.Errorf("ExecDB failed: %v", err)
+	}
+	if lookups.Load() != 1 {
+		t.Errorf("%d lookups, want one for logs", lookups.Load())
+	}
+
+	// The current database is still main
+	if err := pool.Get(&name, "SELECT 'main'"); err != nil || name != "main" {
+		t.Errorf("Get on the current database = %q, %v", name, err)
+	}
+
+	pool.SetAutoReconnect(false)
+	_, err := pool.ExecDB("archive", "SELECT 'archive'")
+	if err == nil || !strings.Contains(err.Error(), "call Connect") || !strings.Contains(err.Error(), "logs, main") {
+		t.Errorf("ExecDB on an unknown database = %v, want a hint naming the connected databases", err)
+	}
+}
@@ -0,0 +1,162 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveEndpointFixtures answers the raw and query endpoints each with its own
+// testdata file, so a test fails if a request goes to the wrong endpoint.
+func serveEndpointFixtures(t *testing.T, raw, query string) {
+	t.Helper()
+	bodies := map[string][]byte{}
+	for endpoint, name := range map[string]string{"/raw": raw, "/query": query} {
+		body, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		bodies[endpoint] = body
+	}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		for endpoint, body := range bodies {
+			if strings.HasSuffix(r.URL.Path, endpoint) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write(body)
+				return
+			}
+		}
+		writeJSON(w, errorResponse(7400, "unexpected path "+r.URL.Path))
+	})
+}
+
+type endpointUser struct {
+	ID    int64  `db:"id"`
+	Name  string `db:"name"`
+	Email string `db:"email"`
+}
+
+func TestQueryEndpointScansLikeRaw(t *testing.T) {
+	serveEndpointFixtures(t, "raw_users.json", "query_users.json")
+
+	want := []endpointUser{{ID: 1, Name: "Alice", Email: "alice@example.com"}, {ID: 2, Name: "Bob"}}
+	for _, useQuery := range []bool{false, true} {
+		client := cloudflare_d1_go.NewClient("account_id", "api_token")
+		client.DatabaseID = "db-1"
+		client.UseQueryEndpoint(useQuery)
+
+		var users []endpointUser
+		if err := client.Select(&users, "SELECT name, id, email FROM users"); err != nil {
+			t.Fatalf("query endpoint %v: Select failed: %v", useQuery, err)
+		}
+		if !reflect.DeepEqual(users, want) {
+			t.Errorf("query endpoint %v: Select = %+v, want %+v", useQuery, users, want)
+		}
+
+		// Columns keep the order of the response, so positional scans work
+		res, err := client.Query("SELECT name, id, email FROM users", nil)
+		if err != nil {
+			t.Fatalf("query endpoint %v: Query failed: %v", useQuery, err)
+		}
+		rows, err := res.ToRows()
+		if err != nil {
+			t.Fatalf("query endpoint %v: ToRows failed: %v", useQuery, err)
+		}
+		if cols, _ := rows.Columns(); strings.Join(cols, ",") != "name,id,email" {
+			t.Errorf("query endpoint %v: Columns = %v, want name,id,email", useQuery, cols)
+		}
+		rows.Next()
+		var u endpointUser
+		if err := rows.Scan(&u.Name, &u.ID, &u.Email); err != nil {
+			t.Fatalf("query endpoint %v: Scan failed: %v", useQuery, err)
+		}
+		if u != want[0] {
+			t.Errorf("query endpoint %v: Scan = %+v, want %+v", useQuery, u, want[0])
+		}
+	}
+}
+
+func TestQueryEndpointExecResult(t *testing.T) {
+	serveEndpointFixtures(t, "raw_insert.json", "query_insert.json")
+
+	for _, useQuery := range []bool{false, true} {
+		pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+		_ = pool.ConnectWithID("main", "db-1")
+		pool.UseQueryEndpoint(useQuery)
+
+		res, err := pool.ExecResult("INSERT INTO users (name) VALUES (?), (?)", "Carol", "Dave")
+		if err != nil {
+			t.Fatalf("query endpoint %v: ExecResult failed: %v", useQuery, err)
+		}
+		affected, _ := res.RowsAffected()
+		id, _ := res.LastInsertId()
+		if affected != 2 || id != 8 {
+			t.Errorf("query endpoint %v: RowsAffected = %d, LastInsertId = %d, want 2 and 8", useQuery, affected, id)
+		}
+	}
+}
+
+func TestQueryEndpointBatch(t *testing.T) {
+	var paths []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		writeJSON(w, successResponse(
+			map[string]interface{}{"results": []interface{}{}, "meta": map[string]interface{}{"changes": 1}, "success": true},
+			map[string]interface{}{"results": []interface{}{map[string]interface{}{"n": 1}}, "success": true},
+		))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	client.UseQueryEndpoint(true)
+
+	results, err := client.Batch([]utils.Statement{{SQL: "DELETE FROM t WHERE id = 1"}, {SQL: "SELECT COUNT(*) AS n FROM t"}})
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if n, _ := results[0].Result.RowsAffected(); n != 1 {
+		t.Errorf("RowsAffected = %d, want 1", n)
+	}
+	var count []struct {
+		N int `db:"n"`
+	}
+	if err := results[1].Rows.StructScanAll(&count); err != nil || len(count) != 1 || count[0].N != 1 {
+		t.Errorf("StructScanAll = %+v, %v", count, err)
+	}
+	if len(paths) != 1 || paths[0] != "/client/v4/accounts/account_id/d1/database/db-1/query" {
+		t.Errorf("paths = %v, want the query endpoint", paths)
+	}
+}
+
+func TestObjectRowsDecodedWithoutClient(t *testing.T) {
+	// A /query response decoded by encoding/json loses the column order
+	body, err := os.ReadFile("testdata/query_users.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	var res utils.APIResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	rows, err := res.ToRows()
+	if err != nil {
+		t.Fatalf("ToRows failed: %v", err)
+	}
+	if cols, _ := rows.Columns(); strings.Join(cols, ",") != "email,id,name" {
+		t.Errorf("Columns = %v, want sorted names", cols)
+	}
+	var users []endpointUser
+	if err := rows.StructScanAll(&users); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if len(users) != 2 || users[1].Name != "Bob" {
+		t.Errorf("users = %+v", users)
+	}
+}
@@ -0,0 +1,120 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+)
+
+func TestRemoveTableQuotesName(t *testing.T) {
+	tests := map[string]string{
+		`users; DROP TABLE accounts`: `DROP TABLE IF EXISTS \"users; DROP TABLE accounts\"`,
+		`we"ird`:                     `DROP TABLE IF EXISTS \"we\"\"ird\"`,
+		"order items":                `DROP TABLE IF EXISTS \"order items\"`,
+		"用户":                         `DROP TABLE IF EXISTS \"用户\"`,
+	}
+	for name, want := range tests {
+		bodies := recordBodies(t)
+
+		client := cloudflare_d1_go.NewClient("account_id", "api_token")
+		client.DatabaseID = "db-1"
+		if _, err := client.RemoveTable(name); err != nil {
+			t.Fatalf("RemoveTable(%q) failed: %v", name, err)
+		}
+
+		pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+		_ = pool.ConnectWithID("main", "db-1")
+		if _, err := pool.RemoveTable(name); err != nil {
+			t.Fatalf("pool.RemoveTable(%q) failed: %v", name, err)
+		}
+
+		body := `{"sql":"` + want + `","params":[]}`
+		if len(*bodies) != 2 || (*bodies)[0] != body || (*bodies)[1] != body {
+			t.Errorf("RemoveTable(%q) sent %q, want %s", name, *bodies, body)
+		}
+	}
+}
+
+func TestRemoveTableRejectsInvalidName(t *testing.T) {
+	bodies := recordBodies(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	for _, name := range []string{"", "bad\x00name"} {
+		if _, err := client.RemoveTable(name); err == nil {
+			t.Errorf("RemoveTable(%q) succeeded, want error", name)
+		}
+		if _, err := pool.RemoveTableDB("main", name); err == nil {
+			t.Errorf("RemoveTableDB(%q) succeeded, want error", name)
+		}
+	}
+	if len(*bodies) != 0 {
+		t.Errorf("invalid names were sent: %q", *bodies)
+	}
+}
+
+func TestCreateDBEncodesName(t *testing.T) {
+	var names []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Errorf("invalid JSON body %s: %v", b, err)
+		}
+		names = append(names, body.Name)
+		writeJSON(w, successResponse(map[string]interface{}{"name": body.Name}))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	want := []string{`my "db"`, `back\slash`, "数据库"}
+	for _, name := range want {
+		if _, err := client.CreateDB(name); err != nil {
+			t.Fatalf("CreateDB(%q) failed: %v", name, err)
+		}
+	}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+}
+
+func TestMigrationTableNameIsQuoted(t *testing.T) {
+	client, rec := cloudflare_d1_go.NewDryRunClient()
+
+	set := migrations.MigrationSet{TableName: `app "migrations"`}
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+	}}
+	if _, err := set.ExecMax(client, source, migrations.Up, 0); err != nil {
+		t.Fatalf("ExecMax failed: %v", err)
+	}
+
+	uses := 0
+	for _, stmt := range rec.Statements() {
+		if !strings.Contains(stmt.SQL, "migrations") {
+			continue
+		}
+		uses++
+		if !strings.Contains(stmt.SQL, `"app ""migrations"""`) {
+			t.Errorf("table name not quoted in %q", stmt.SQL)
+		}
+	}
+	// CREATE TABLE, SELECT and INSERT all name the table
+	if uses != 3 {
+		t.Errorf("table name used in %d statements, want 3", uses)
+	}
+
+	bad := migrations.MigrationSet{TableName: "bad\nname"}
+	if _, err := bad.ExecMax(client, source, migrations.Up, 0); err == nil {
+		t.Error("expected error for an invalid table name")
+	}
+}
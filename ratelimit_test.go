@@ -0,0 +1,115 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestRateLimiterSpacesConcurrentQueries(t *testing.T) {
+	requests := countRequests(t)
+
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		RateLimiter: utils.NewRateLimiter(50, 2),
+	})
+	client.DatabaseID = "db-1"
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = client.Exec("SELECT 1")
+		}()
+	}
+	wg.Wait()
+
+	// Two requests go out at once, the other six 20ms apart
+	if elapsed := time.Since(start); elapsed < 110*time.Millisecond {
+		t.Errorf("8 queries took %v, want at least 120ms at 50 per second with a burst of 2", elapsed)
+	}
+	if *requests != 8 {
+		t.Errorf("%d requests, want 8", *requests)
+	}
+}
+
+func TestRateLimiterSharedByPool(t *testing.T) {
+	countRequests(t)
+
+	pool := cloudflare_d1_go.NewConnectionPoolWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		RateLimiter: utils.NewRateLimiter(20, 1),
+	})
+	start := time.Now()
+	for _, id := range []string{"db-1", "db-2", "db-1", "db-2"} {
+		if err := pool.ConnectWithID("db", id); err != nil {
+			t.Fatalf("ConnectWithID failed: %v", err)
+		}
+		_, _ = pool.Exec("SELECT 1")
+	}
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("4 queries on two databases took %v, want at least 150ms at 20 per second", elapsed)
+	}
+}
+
+func TestRateLimiterRespectsContext(t *testing.T) {
+	requests := countRequests(t)
+
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		RateLimiter: utils.NewRateLimiter(1, 1),
+	})
+	client.DatabaseID = "db-1"
+
+	if _, err := client.Exec("SELECT 1"); err != nil {
+		t.Fatalf("first Exec failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.ExecContext(ctx, "SELECT 1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline of the context", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("ExecContext returned after %v, want it to give up with the context", elapsed)
+	}
+	if *requests != 1 {
+		t.Errorf("%d requests, want 1", *requests)
+	}
+}
+
+func TestRateLimiterWithoutRateDoesNotLimit(t *testing.T) {
+	for _, rate := range []float64{0, -5} {
+		limiter := utils.NewRateLimiter(rate, 1)
+		if limiter != nil {
+			t.Errorf("NewRateLimiter(%v, 1) = %+v, want nil for no limit", rate, limiter)
+		}
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Fatalf("Wait failed: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("10 waits at rate %v took %v, want no wait", rate, elapsed)
+		}
+	}
+
+	requests := countRequests(t)
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		RateLimiter: utils.NewRateLimiter(0, 1),
+	})
+	client.DatabaseID = "db-1"
+	for i := 0; i < 5; i++ {
+		if _, err := client.Exec("SELECT 1"); err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+	}
+	if *requests != 5 {
+		t.Errorf("%d requests, want 5", *requests)
+	}
+}
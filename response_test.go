@@ -0,0 +1,117 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestResponseSizeLimit(t *testing.T) {
+	rows := make([][]interface{}, 1000)
+	for i := range rows {
+		rows[i] = []interface{}{i, strings.Repeat("x", 100)}
+	}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult([]string{"id", "payload"}, rows, nil)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	orig := utils.MaxResponseSize
+	t.Cleanup(func() { utils.MaxResponseSize = orig })
+
+	utils.MaxResponseSize = 10 << 10
+	if _, err := client.Query("SELECT * FROM big", nil); !errors.Is(err, utils.ErrResponseTooLarge) {
+		t.Fatalf("Query = %v, want ErrResponseTooLarge", err)
+	}
+
+	utils.MaxResponseSize = 1 << 20
+	res, err := client.Query("SELECT * FROM big", nil)
+	if err != nil {
+		t.Fatalf("Query under the limit failed: %v", err)
+	}
+	r, err := res.ToRows()
+	if err != nil {
+		t.Fatalf("ToRows failed: %v", err)
+	}
+	count := 0
+	for r.Next() {
+		count++
+	}
+	if count != 1000 {
+		t.Errorf("got %d rows, want 1000", count)
+	}
+}
+
+func TestInvalidResponseBody(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html>Bad Gateway</html>"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	_, err := client.Query("SELECT 1", nil)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 502") {
+		t.Fatalf("Query = %v, want decode error mentioning HTTP 502", err)
+	}
+}
+
+func TestScanJoinsColumnErrors(t *testing.T) {
+	rows := utils.NewRows([]map[string]interface{}{
+		{"id": "abc", "age": "old", "name": "Alice"},
+	}, []string{"id", "age", "name"})
+	rows.Next()
+
+	var id int64
+	var age float64
+	var name string
+	err := rows.Scan(&id, &age, &name)
+	if err == nil {
+		t.Fatal("expected scan error")
+	}
+	for _, want := range []string{`name "id"`, `name "age"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention column %s", err, want)
+		}
+	}
+	if name != "Alice" {
+		t.Errorf("name = %q, valid columns must still be scanned", name)
+	}
+
+	var user struct {
+		ID  int64   `db:"id"`
+		Age float64 `db:"age"`
+	}
+	err = rows.StructScan(&user)
+	if err == nil || !strings.Contains(err.Error(), "field ID") || !strings.Contains(err.Error(), "field Age") {
+		t.Errorf("StructScan = %v, want errors for both fields", err)
+	}
+}
+
+func TestAPIErrorsAreJoined(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		resp := errorResponse(7500, "syntax error")
+		resp["errors"] = append(resp["errors"].([]interface{}), map[string]interface{}{"code": 7400, "message": "quota exceeded"})
+		writeJSON(w, resp)
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	_, err := client.Exec("SELEC 1")
+	if err == nil {
+		t.Fatal("expected API error")
+	}
+	for _, want := range []string{"syntax error", "quota exceeded"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveHTTPTest sends every request made through http.DefaultClient to an
+// httptest server running handler, until the test finishes
+func serveHTTPTest(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	redirectTo(t, srv.URL)
+	return srv
+}
+
+// redirectTo rewrites the host of every request made through http.DefaultClient
+func redirectTo(t *testing.T, rawURL string) {
+	t.Helper()
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("invalid url: %v", err)
+	}
+	orig := http.DefaultClient.Transport
+	transport := &http.Transport{}
+	http.DefaultClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return transport.RoundTrip(r)
+	})
+	t.Cleanup(func() {
+		transport.CloseIdleConnections()
+		http.DefaultClient.Transport = orig
+	})
+}
+
+// failThenSucceed answers the first failures requests with fail and the
+// rest with an empty successful query result. It counts the requests in calls.
+func failThenSucceed(failures int32, calls *int32, fail http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			fail(w, r)
+			return
+		}
+		writeJSON(w, successResponse(queryResult([]string{"n"}, [][]interface{}{{1}}, nil)))
+	}
+}
+
+func status(code int, headers ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(`{"result":null,"success":false,"errors":[{"code":10000,"message":"` + http.StatusText(code) + `"}]}`))
+	}
+}
+
+func retryingClient(attempts int) *cloudflare_d1_go.Client {
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		Retry: utils.RetryPolicy{MaxAttempts: attempts, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	})
+	client.DatabaseID = "db-1"
+	return client
+}
+
+func TestRetryGetOnServerErrors(t *testing.T) {
+	var calls int32
+	serveHTTPTest(t, failThenSucceed(2, &calls, status(http.StatusServiceUnavailable)))
+
+	res, err := retryingClient(3).ListDB()
+	if err != nil {
+		t.Fatalf("ListDB failed: %v", err)
+	}
+	if err := res.Err(); err != nil {
+		t.Errorf("ListDB returned %v after retries", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	serveHTTPTest(t, failThenSucceed(5, &calls, status(http.StatusBadGateway)))
+
+	res, err := retryingClient(3).ListDB()
+	if err != nil {
+		t.Fatalf("ListDB failed: %v", err)
+	}
+	if res.Err() == nil {
+		t.Error("ListDB succeeded, want the error of the last attempt")
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryQueryOnlyWhenRetryable(t *testing.T) {
+	var calls int32
+	serveHTTPTest(t, failThenSucceed(2, &calls, status(http.StatusInternalServerError)))
+	client := retryingClient(3)
+
+	// A query may have run before the error, so it is not retried by default
+	res, err := client.Query("UPDATE counters SET n = n + 1", nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if res.Err() == nil || calls != 1 {
+		t.Errorf("calls = %d, error = %v, want 1 call that failed", calls, res.Err())
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	res, err = client.QueryContext(utils.WithRetryable(context.Background()), "SELECT 1 AS n", nil)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if err := res.Err(); err != nil || calls != 3 {
+		t.Errorf("calls = %d, error = %v, want success on the third call", calls, err)
+	}
+}
+
+func TestRetryQueryOnRateLimitWithRetryAfter(t *testing.T) {
+	var calls int32
+	serveHTTPTest(t, failThenSucceed(1, &calls, status(http.StatusTooManyRequests, "Retry-After", "0")))
+
+	if _, err := retryingClient(3).Exec("UPDATE counters SET n = n + 1"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+
+	// Without Retry-After the request is not known to be rejected before it ran
+	atomic.StoreInt32(&calls, 0)
+	serveHTTPTest(t, failThenSucceed(1, &calls, status(http.StatusTooManyRequests)))
+	if _, err := retryingClient(3).Exec("UPDATE counters SET n = n + 1"); err == nil {
+		t.Error("Exec succeeded, want the rate limit error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryAfterLongerThanMaxDelay(t *testing.T) {
+	var calls int32
+	serveHTTPTest(t, failThenSucceed(1, &calls, status(http.StatusTooManyRequests, "Retry-After", "60")))
+
+	start := time.Now()
+	if _, err := retryingClient(3).ListDB(); err != nil {
+		t.Fatalf("ListDB failed: %v", err)
+	}
+	if calls != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("calls = %d after %v, want 1 call without waiting", calls, time.Since(start))
+	}
+}
+
+func TestRetryQueryWhenConnectionRefused(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	redirectTo(t, srv.URL)
+
+	var calls int32
+	base := http.DefaultClient.Transport
+	http.DefaultClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return base.RoundTrip(r)
+	})
+
+	if _, err := retryingClient(3).Exec("UPDATE counters SET n = n + 1"); err == nil {
+		t.Fatal("Exec succeeded against a closed server")
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestZeroRetryPolicyDoesNotRetry(t *testing.T) {
+	var calls int32
+	serveHTTPTest(t, failThenSucceed(1, &calls, status(http.StatusServiceUnavailable)))
+
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{})
+	res, err := client.ListDB()
+	if err != nil {
+		t.Fatalf("ListDB failed: %v", err)
+	}
+	if res.Err() == nil || calls != 1 {
+		t.Errorf("calls = %d, error = %v, want 1 call that failed", calls, res.Err())
+	}
+}
@@ -0,0 +1,78 @@
+package cloudflared1_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// badSecondRow returns three rows of which the second has an id that does
+// not convert to an integer
+func badSecondRow() *utils.Rows {
+	return utils.NewRows([]map[string]interface{}{
+		{"id": float64(1), "name": "Alice"},
+		{"id": "two", "name": "Bob"},
+		{"id": float64(3), "name": "Carol"},
+	}, []string{"id", "name"})
+}
+
+func TestRowsErrAfterIgnoredStructScanError(t *testing.T) {
+	rows := badSecondRow()
+	var ids []int64
+	for rows.Next() {
+		var user genericUser
+		_ = rows.StructScan(&user)
+		ids = append(ids, user.ID)
+	}
+	if len(ids) != 2 {
+		t.Errorf("scanned %d rows, want the loop to stop after the failing second row", len(ids))
+	}
+	if err := rows.Err(); err == nil || !strings.Contains(err.Error(), "field ID") {
+		t.Errorf("Err() = %v, want the StructScan error", err)
+	}
+
+	_ = rows.Close()
+	if rows.Err() == nil {
+		t.Error("Close discarded the error")
+	}
+}
+
+func TestRowsErrAfterIgnoredScanError(t *testing.T) {
+	rows := badSecondRow()
+	var n int
+	for rows.Next() {
+		var id int64
+		var name string
+		_ = rows.Scan(&id, &name)
+		n++
+	}
+	if err := rows.Err(); n != 2 || err == nil || !strings.Contains(err.Error(), `name "id"`) {
+		t.Errorf("scanned %d rows, Err() = %v; want 2 and the Scan error", n, err)
+	}
+}
+
+func TestRowsErrKeepsFirstError(t *testing.T) {
+	rows := badSecondRow()
+	rows.Next()
+	var id int64
+	_ = rows.Scan(&id, new(interface{}))
+	rows.Next()
+	first := rows.Scan(&id, new(interface{}))
+	_ = rows.Scan(new(bool), new(int))
+	if first == nil || rows.Err() != first {
+		t.Errorf("Err() = %v, want the first error %v", rows.Err(), first)
+	}
+}
+
+func TestStructScanAllStopsAtError(t *testing.T) {
+	rows := badSecondRow()
+	var users []genericUser
+	err := rows.StructScanAll(&users)
+	if err == nil || !strings.Contains(err.Error(), "index 1") || rows.Err() == nil {
+		t.Errorf("StructScanAll err = %v, Err() = %v; want an error at index 1 recorded in Err", err, rows.Err())
+	}
+	if rows.Next() {
+		t.Error("Next returned true after the error")
+	}
+}
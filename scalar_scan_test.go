@@ -0,0 +1,93 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveScalars answers each query with the result named in its SQL
+func serveScalars(t *testing.T) {
+	results := map[string]map[string]interface{}{
+		"count":  queryResult([]string{"COUNT(*)"}, [][]interface{}{{42}}, nil),
+		"names":  queryResult([]string{"name"}, [][]interface{}{{"Alice"}, {"Bob"}}, nil),
+		"emails": queryResult([]string{"email"}, [][]interface{}{{"alice@example.com"}, {nil}}, nil),
+		"avg":    queryResult([]string{"avg"}, [][]interface{}{{31.5}}, nil),
+		"active": queryResult([]string{"active"}, [][]interface{}{{1}}, nil),
+		"users":  queryResult([]string{"id", "name"}, [][]interface{}{{1, "Alice"}}, nil),
+	}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var stmt sentStatement
+		_ = json.NewDecoder(r.Body).Decode(&stmt)
+		for name, result := range results {
+			if strings.HasSuffix(stmt.SQL, "FROM "+name) {
+				writeJSON(w, successResponse(result))
+				return
+			}
+		}
+		t.Errorf("unexpected query %s", stmt.SQL)
+	})
+}
+
+func TestGetScalar(t *testing.T) {
+	serveScalars(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var count int64
+	if err := client.Get(&count, "SELECT COUNT(*) FROM count"); err != nil || count != 42 {
+		t.Errorf("Get(*int64) = %d, %v; want 42", count, err)
+	}
+	var small uint8
+	if err := client.Get(&small, "SELECT COUNT(*) FROM count"); err != nil || small != 42 {
+		t.Errorf("Get(*uint8) = %d, %v; want 42", small, err)
+	}
+	var avg float64
+	if err := client.Get(&avg, "SELECT avg FROM avg"); err != nil || avg != 31.5 {
+		t.Errorf("Get(*float64) = %v, %v; want 31.5", avg, err)
+	}
+	var active bool
+	if err := client.Get(&active, "SELECT active FROM active"); err != nil || !active {
+		t.Errorf("Get(*bool) = %v, %v; want true", active, err)
+	}
+	var raw []byte
+	if err := client.Get(&raw, "SELECT name FROM names"); err != nil || string(raw) != "Alice" {
+		t.Errorf("Get(*[]byte) = %q, %v; want Alice", raw, err)
+	}
+
+	var name string
+	err := client.Get(&name, "SELECT * FROM users")
+	if err == nil || !strings.Contains(err.Error(), "exactly one column, got 2") {
+		t.Errorf("Get(*string) of two columns: err = %v", err)
+	}
+}
+
+func TestSelectScalars(t *testing.T) {
+	serveScalars(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var names []string
+	if err := client.Select(&names, "SELECT name FROM names"); err != nil || !reflect.DeepEqual(names, []string{"Alice", "Bob"}) {
+		t.Errorf("Select(*[]string) = %v, %v", names, err)
+	}
+
+	var emails []*string
+	if err := client.Select(&emails, "SELECT email FROM emails"); err != nil {
+		t.Fatalf("Select(*[]*string) failed: %v", err)
+	}
+	if len(emails) != 2 || emails[0] == nil || *emails[0] != "alice@example.com" || emails[1] != nil {
+		t.Errorf("emails = %v, want the address and nil for NULL", emails)
+	}
+
+	email := new(string)
+	if err := client.Get(&email, "SELECT email FROM emails"); err != nil || *email != "alice@example.com" {
+		t.Errorf("Get(**string) = %v, %v", email, err)
+	}
+}
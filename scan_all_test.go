@@ -0,0 +1,61 @@
+package cloudflared1_test
+
+import (
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func userRows() *utils.Rows {
+	return utils.NewRows([]map[string]interface{}{
+		{"id": float64(1), "name": "Alice"},
+		{"id": float64(2), "name": "Bob"},
+	}, []string{"id", "name"})
+}
+
+func TestStructScanAllValues(t *testing.T) {
+	var users []genericUser
+	if err := userRows().StructScanAll(&users); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if len(users) != 2 || cap(users) != 2 || users[0] != (genericUser{ID: 1, Name: "Alice"}) || users[1] != (genericUser{ID: 2, Name: "Bob"}) {
+		t.Errorf("users = %v (cap %d)", users, cap(users))
+	}
+}
+
+func TestStructScanAllPointers(t *testing.T) {
+	var users []*genericUser
+	if err := userRows().StructScanAll(&users); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if len(users) != 2 || users[0] == nil || users[1] == nil || users[0] == users[1] {
+		t.Fatalf("users = %v, want two distinct pointers", users)
+	}
+	if *users[0] != (genericUser{ID: 1, Name: "Alice"}) || *users[1] != (genericUser{ID: 2, Name: "Bob"}) {
+		t.Errorf("users = %+v, %+v", *users[0], *users[1])
+	}
+}
+
+func TestStructScanAllReusesDestination(t *testing.T) {
+	users := make([]genericUser, 3, 10)
+	users[0] = genericUser{ID: 9, Name: "stale"}
+	earlier := users
+
+	if err := userRows().StructScanAll(&users); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	if len(users) != 2 || users[0].Name != "Alice" || users[1].Name != "Bob" {
+		t.Errorf("users = %v, want only the two new rows", users)
+	}
+	if earlier[0].Name != "stale" {
+		t.Errorf("the earlier slice was overwritten: %v", earlier)
+	}
+
+	// A scan with no rows left empties the destination
+	rows := userRows()
+	for rows.Next() {
+	}
+	if err := rows.StructScanAll(&users); err != nil || users == nil || len(users) != 0 {
+		t.Errorf("users = %v, %v; want an empty slice", users, err)
+	}
+}
@@ -0,0 +1,87 @@
+package cloudflared1_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type benchUser struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	Email     *string   `db:"email"`
+	Age       int       `db:"age"`
+	Score     float64   `db:"score"`
+	Active    bool      `db:"active"`
+	CreatedAt time.Time `db:"created_at"`
+	Nickname  string
+}
+
+func benchRows(n int) ([]map[string]interface{}, []string) {
+	columns := []string{"id", "name", "email", "age", "score", "active", "created_at", "nickname"}
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = map[string]interface{}{
+			"id": float64(i), "name": fmt.Sprintf("user%d", i), "email": nil, "age": float64(30),
+			"score": 1.5, "active": float64(1), "created_at": "2024-05-01 12:30:00", "nickname": "n",
+		}
+	}
+	return rows, columns
+}
+
+func BenchmarkStructScanAll10k(b *testing.B) {
+	data, columns := benchRows(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var users []benchUser
+		if err := utils.NewRows(data, columns).StructScanAll(&users); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestConcurrentSelectsOfDifferentTypes(t *testing.T) {
+	serveUsers(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	type plainUser struct {
+		ID   int64
+		Name string
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			var users []genericUser
+			errs <- client.Select(&users, "SELECT * FROM users")
+		}()
+		go func() {
+			defer wg.Done()
+			var users []auditedUser
+			errs <- client.Select(&users, "SELECT * FROM users")
+		}()
+		go func() {
+			defer wg.Done()
+			var users []*plainUser
+			if err := client.Select(&users, "SELECT * FROM users"); err != nil || len(users) != 2 || users[1].Name != "Bob" {
+				errs <- fmt.Errorf("Select = %+v, %v", users, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
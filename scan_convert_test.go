@@ -0,0 +1,95 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type userID int64
+
+type accountStatus string
+
+type ratio float32
+
+type level int8
+
+func TestScanNamedAndSizedTypes(t *testing.T) {
+	var (
+		id  userID
+		st  accountStatus
+		r   ratio
+		i32 int32
+		u64 uint64
+		i8  int8
+		raw json.RawMessage
+	)
+	scanValue(t, float64(42), &id)
+	scanValue(t, "active", &st)
+	scanValue(t, 0.5, &r)
+	scanValue(t, float64(-7), &i32)
+	scanValue(t, "18446744073709551615", &u64)
+	scanValue(t, "-128", &i8)
+	scanValue(t, `{"a":1}`, &raw)
+
+	if id != 42 || st != "active" || r != 0.5 || i32 != -7 || u64 != 1<<64-1 || i8 != -128 || string(raw) != `{"a":1}` {
+		t.Errorf("got %v %q %v %v %v %v %s", id, st, r, i32, u64, i8, raw)
+	}
+}
+
+func TestScanConversionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+		dest interface{}
+		want string
+	}{
+		{"int8 overflow", float64(300), new(int8), "overflows int8"},
+		{"int32 overflow", "3000000000", new(int32), "overflows int32"},
+		{"named int8 overflow", float64(-129), new(level), "overflows cloudflared1_test.level"},
+		{"uint from negative number", float64(-1), new(uint), "negative"},
+		{"uint from negative text", "-5", new(uint32), "negative"},
+		{"uint8 overflow", float64(256), new(uint8), "overflows uint8"},
+		{"float32 overflow", 1e300, new(float32), "overflows float32"},
+		{"int from text", "abc", new(int), "cannot convert"},
+		{"unsupported pair", "x", new(map[string]int), "unsupported Scan pair"},
+		{"struct", float64(1), new(struct{ A int }), "unsupported Scan pair"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := utils.NewRows([]map[string]interface{}{{"v": tt.src}}, []string{"v"})
+			rows.Next()
+			err := rows.Scan(tt.dest)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Scan(%T) of %v: err = %v, want one containing %q", tt.dest, tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructScanNamedTypes(t *testing.T) {
+	type account struct {
+		ID     userID        `db:"id"`
+		Status accountStatus `db:"status"`
+		Quota  uint16        `db:"quota"`
+	}
+	rows := utils.NewRows([]map[string]interface{}{{"id": float64(9), "status": "locked", "quota": float64(70000)}}, []string{"id", "status", "quota"})
+	rows.Next()
+
+	var got account
+	if err := rows.StructScan(&got); err == nil || !strings.Contains(err.Error(), "overflows uint16") {
+		t.Fatalf("err = %v, want quota to overflow uint16", err)
+	}
+
+	rows = utils.NewRows([]map[string]interface{}{{"id": float64(9), "status": "locked", "quota": float64(500)}}, []string{"id", "status", "quota"})
+	rows.Next()
+	if err := rows.StructScan(&got); err != nil {
+		t.Fatalf("StructScan failed: %v", err)
+	}
+	if want := (account{9, "locked", 500}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
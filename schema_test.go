@@ -0,0 +1,150 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveSchema mocks the PRAGMA statements used by DescribeTable and ListIndexes
+// and records the SQL of every statement.
+func serveSchema(t *testing.T) *[]string {
+	t.Helper()
+	var statements []string
+
+	answer := func(sql string) interface{} {
+		statements = append(statements, sql)
+		switch sql {
+		case `PRAGMA table_info("users")`:
+			return queryResult(
+				[]string{"cid", "name", "type", "notnull", "dflt_value", "pk"},
+				[][]interface{}{
+					{0, "id", "INTEGER", 0, nil, 1},
+					{1, "email", "TEXT", 1, nil, 0},
+					{2, "status", "TEXT", 1, "'active'", 0},
+				}, nil)
+		case `PRAGMA index_list("users")`:
+			return queryResult(
+				[]string{"seq", "name", "unique", "origin", "partial"},
+				[][]interface{}{{0, "idx_users_status_email", 0, "c", 1}, {1, "sqlite_autoindex_users_1", 1, "u", 0}}, nil)
+		case `PRAGMA index_info("idx_users_status_email")`:
+			return queryResult([]string{"seqno", "cid", "name"}, [][]interface{}{{0, 2, "status"}, {1, 1, "email"}}, nil)
+		case `PRAGMA index_info("sqlite_autoindex_users_1")`:
+			return queryResult([]string{"seqno", "cid", "name"}, [][]interface{}{{0, 1, "email"}}, nil)
+		}
+		return queryResult(nil, nil, nil)
+	}
+
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			SQL   string `json:"sql"`
+			Batch []struct {
+				SQL string `json:"sql"`
+			} `json:"batch"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		if body.Batch == nil {
+			writeJSON(w, successResponse(answer(body.SQL)))
+			return
+		}
+		var items []interface{}
+		for _, stmt := range body.Batch {
+			items = append(items, answer(stmt.SQL))
+		}
+		writeJSON(w, successResponse(items...))
+	})
+	return &statements
+}
+
+func TestDescribeTable(t *testing.T) {
+	serveSchema(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	columns, err := client.DescribeTable("users")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+
+	active := "'active'"
+	want := []cloudflare_d1_go.ColumnInfo{
+		{Name: "id", Type: "INTEGER", PrimaryKey: 1},
+		{Name: "email", Type: "TEXT", NotNull: true},
+		{Name: "status", Type: "TEXT", NotNull: true, DefaultValue: &active},
+	}
+	if !reflect.DeepEqual(columns, want) {
+		t.Errorf("DescribeTable = %+v, want %+v", columns, want)
+	}
+
+	if _, err := client.DescribeTable("missing"); err == nil {
+		t.Error("expected error for a missing table")
+	}
+}
+
+func TestDescribeTableQuotesName(t *testing.T) {
+	statements := serveSchema(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	_, _ = pool.DescribeTable(`users"); DROP TABLE accounts; --`)
+	want := `PRAGMA table_info("users""); DROP TABLE accounts; --")`
+	if len(*statements) != 1 || (*statements)[0] != want {
+		t.Errorf("statements = %q, want %q", *statements, want)
+	}
+
+	if _, err := pool.DescribeTable(""); err == nil {
+		t.Error("expected error for an empty table name")
+	}
+}
+
+func TestListIndexes(t *testing.T) {
+	serveSchema(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	indexes, err := pool.ListIndexes("users")
+	if err != nil {
+		t.Fatalf("ListIndexes failed: %v", err)
+	}
+	want := []cloudflare_d1_go.IndexInfo{
+		{Name: "idx_users_status_email", Origin: "c", Partial: true, Columns: []string{"status", "email"}},
+		{Name: "sqlite_autoindex_users_1", Unique: true, Origin: "u", Columns: []string{"email"}},
+	}
+	if !reflect.DeepEqual(indexes, want) {
+		t.Errorf("ListIndexes = %+v, want %+v", indexes, want)
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"users":             `"users"`,
+		`we"ird`:            `"we""ird"`,
+		"order items":       `"order items"`,
+		"用户":                `"用户"`,
+		`a"; DROP TABLE b;`: `"a""; DROP TABLE b;"`,
+	}
+	for name, want := range tests {
+		got, err := utils.QuoteIdentifier(name)
+		if err != nil {
+			t.Errorf("QuoteIdentifier(%q) failed: %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("QuoteIdentifier(%q) = %s, want %s", name, got, want)
+		}
+	}
+
+	for _, name := range []string{"", "bad\x00name", "line\nbreak"} {
+		if _, err := utils.QuoteIdentifier(name); err == nil {
+			t.Errorf("QuoteIdentifier(%q) succeeded, want error", name)
+		}
+	}
+}
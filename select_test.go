@@ -0,0 +1,92 @@
+package cloudflared1_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestSelectIntoStructs(t *testing.T) {
+	serveUsers(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var users []genericUser
+	if err := client.Select(&users, "SELECT * FROM users"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if !reflect.DeepEqual(users, []genericUser{{1, "Alice"}, {2, "Bob"}}) {
+		t.Errorf("users = %+v", users)
+	}
+}
+
+func TestSelectIntoStructPointers(t *testing.T) {
+	serveUsers(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var users []*genericUser
+	if err := client.Select(&users, "SELECT * FROM users"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("got %d users, want 2", len(users))
+	}
+	for i, want := range []genericUser{{1, "Alice"}, {2, "Bob"}} {
+		if users[i] == nil || *users[i] != want {
+			t.Errorf("users[%d] = %+v, want %+v", i, users[i], want)
+		}
+	}
+	if users[0] == users[1] {
+		t.Error("elements share one allocation")
+	}
+}
+
+func TestSelectIntoMaps(t *testing.T) {
+	serveUsers(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	var rows []map[string]interface{}
+	if err := pool.Select(&rows, "SELECT * FROM users"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"id": float64(1), "name": "Alice"},
+		{"id": float64(2), "name": "Bob"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestSelectUnsupportedDestination(t *testing.T) {
+	serveUsers(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var user genericUser
+	var byID map[string]string
+	for _, dest := range []interface{}{&user, []genericUser{}, &byID} {
+		err := client.Select(dest, "SELECT * FROM users")
+		if err == nil {
+			t.Errorf("Select(%T) succeeded, want error", dest)
+			continue
+		}
+		if !strings.Contains(err.Error(), "*[]T, *[]*T") || !strings.Contains(err.Error(), "*[]map[string]interface{}") {
+			t.Errorf("Select(%T) error %q does not name the accepted forms", dest, err)
+		}
+	}
+
+	// Single values need a one-column result
+	var names []string
+	if err := client.Select(&names, "SELECT * FROM users"); err == nil || !strings.Contains(err.Error(), "exactly one column, got 2") {
+		t.Errorf("Select(*[]string) of two columns: err = %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package cloudflared1_test
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveFixture mocks the raw query endpoint, answering every query with a file from testdata.
+func serveFixture(t *testing.T, name string) {
+	t.Helper()
+	body, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
+
+func TestTrailingSemicolonsAreTrimmed(t *testing.T) {
+	bodies := recordBodies(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	_, _ = client.Query("SELECT 1;\n", nil)
+	_, _ = client.Exec("DELETE FROM t ; ;  \r\n")
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	_, _ = pool.Query("SELECT 2;", nil)
+
+	// Disabled: the query is sent as written
+	client.SetTrimSemicolons(false)
+	_, _ = client.Query("SELECT 3;", nil)
+
+	want := []string{
+		`{"sql":"SELECT 1","params":[]}`,
+		`{"sql":"DELETE FROM t","params":[]}`,
+		`{"sql":"SELECT 2","params":[]}`,
+		`{"sql":"SELECT 3;","params":[]}`,
+	}
+	if !reflect.DeepEqual(*bodies, want) {
+		t.Errorf("bodies = %q, want %q", *bodies, want)
+	}
+}
+
+func TestDoubleResultSelect(t *testing.T) {
+	serveFixture(t, "double_result_select.json")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var users []struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	if err := client.Select(&users, "SELECT id, name FROM users;"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(users) != 2 || users[1].Name != "Bob" {
+		t.Errorf("users = %+v, want Alice and Bob", users)
+	}
+}
+
+func TestDoubleResultInsert(t *testing.T) {
+	serveFixture(t, "double_result_insert.json")
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	result, err := client.ExecResult("INSERT INTO users (name) VALUES (?);", "Alice")
+	if err != nil {
+		t.Fatalf("ExecResult failed: %v", err)
+	}
+	if id, _ := result.LastInsertId(); id != 42 {
+		t.Errorf("LastInsertId = %d, want 42", id)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("RowsAffected = %d, want 1", n)
+	}
+}
@@ -0,0 +1,93 @@
+package cloudflared1_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveBookmarks answers every query with the next bookmark and records the
+// session header each request carried
+func serveBookmarks(t *testing.T) *[]string {
+	var mu sync.Mutex
+	var sent []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.Header.Get(cloudflare_d1_go.SessionHeader))
+		w.Header().Set(cloudflare_d1_go.SessionHeader, fmt.Sprintf("0000000%d-00000001-00004fc1-aa", len(sent)))
+		mu.Unlock()
+		writeJSON(w, successResponse(queryResult([]string{"id"}, [][]interface{}{{1}}, nil)))
+	})
+	return &sent
+}
+
+func TestSessionSendsBookmarks(t *testing.T) {
+	sent := serveBookmarks(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	session := client.NewSession(cloudflare_d1_go.SessionFirstPrimary)
+	if session.Bookmark() != "" {
+		t.Errorf("Bookmark() = %q before the first query, want empty", session.Bookmark())
+	}
+
+	if _, err := session.Exec("INSERT INTO users (name) VALUES (?)", "alice"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	var users []genericUser
+	if err := session.Select(&users, "SELECT id FROM users"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	_, _ = session.Batch([]utils.Statement{{SQL: "SELECT 1"}})
+
+	want := []string{"first-primary", "00000001-00000001-00004fc1-aa", "00000002-00000001-00004fc1-aa"}
+	for i, header := range want {
+		if (*sent)[i] != header {
+			t.Errorf("request %d sent %q, want %q", i, (*sent)[i], header)
+		}
+	}
+	if session.Bookmark() != "00000003-00000001-00004fc1-aa" {
+		t.Errorf("Bookmark() = %q, want the bookmark of the last answer", session.Bookmark())
+	}
+
+	// Queries outside the session carry no bookmark
+	_, _ = client.Exec("SELECT 1")
+	if last := (*sent)[len(*sent)-1]; last != "" {
+		t.Errorf("client sent %q outside the session", last)
+	}
+}
+
+func TestSessionResumesFromBookmark(t *testing.T) {
+	sent := serveBookmarks(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	session := client.WithDatabase("db-1").NewSession("00000005-00000001-00004fc1-aa")
+	if session.Bookmark() != "00000005-00000001-00004fc1-aa" {
+		t.Errorf("Bookmark() = %q, want the bookmark the session started with", session.Bookmark())
+	}
+
+	_, _ = session.Query("SELECT 1", nil)
+	if (*sent)[0] != "00000005-00000001-00004fc1-aa" {
+		t.Errorf("sent %q, want the stored bookmark", (*sent)[0])
+	}
+	// The server answered with an older bookmark, which must not move the session back
+	if session.Bookmark() != "00000005-00000001-00004fc1-aa" {
+		t.Errorf("Bookmark() = %q after an older answer", session.Bookmark())
+	}
+}
+
+func TestSessionDefaultsToUnconstrained(t *testing.T) {
+	sent := serveBookmarks(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	var user genericUser
+	_ = client.NewSession("").Get(&user, "SELECT id FROM users LIMIT 1")
+	if (*sent)[0] != cloudflare_d1_go.SessionFirstUnconstrained {
+		t.Errorf("sent %q, want %q", (*sent)[0], cloudflare_d1_go.SessionFirstUnconstrained)
+	}
+}
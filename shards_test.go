@@ -0,0 +1,170 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// movedKeys counts the keys of 0 to n-1 that change returns as moved
+func movedKeys(t *testing.T, shards *cloudflare_d1_go.Shards, change cloudflare_d1_go.ShardChange, n int) int {
+	t.Helper()
+	moved := 0
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("tenant-%d", i)
+		from, to, ok := change.Moved(key)
+		if to != shards.ShardName(key) {
+			t.Fatalf("Moved(%s) = %s, but the key is routed to %s", key, to, shards.ShardName(key))
+		}
+		if ok {
+			if from == to {
+				t.Fatalf("Moved(%s) reports a move from %s to itself", key, from)
+			}
+			moved++
+		}
+	}
+	return moved
+}
+
+func TestShardsModulo(t *testing.T) {
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	byNumber := func(key string) uint32 {
+		n, _ := strconv.Atoi(key)
+		return uint32(n)
+	}
+	shards, err := cloudflare_d1_go.NewShards(pool, []string{"a", "b", "c"}, byNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"0": "a", "1": "b", "2": "c", "3": "a", "7": "b"} {
+		if got := shards.ShardName(key); got != want {
+			t.Errorf("ShardName(%s) = %s, want %s", key, got, want)
+		}
+	}
+
+	var hooked []cloudflare_d1_go.ShardChange
+	shards.OnChange(func(c cloudflare_d1_go.ShardChange) { hooked = append(hooked, c) })
+	change, err := shards.AddShard("d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooked) != 1 || hooked[0].Added != "d" {
+		t.Errorf("hook calls = %+v, want the addition of d", hooked)
+	}
+	if from, to, moved := change.Moved("5"); from != "c" || to != "b" || !moved {
+		t.Errorf("Moved(5) = %s, %s, %v, want c to b", from, to, moved)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(shards.Names(), want) {
+		t.Errorf("Names = %v, want %v", shards.Names(), want)
+	}
+
+	// Modulo moves most keys
+	shards, _ = cloudflare_d1_go.NewShards(pool, []string{"a", "b", "c"}, nil)
+	change, _ = shards.AddShard("d")
+	if moved := movedKeys(t, shards, change, 1000); moved < 500 {
+		t.Errorf("%d of 1000 keys moved with modulo, want most", moved)
+	}
+}
+
+func TestShardsConsistentHashing(t *testing.T) {
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	shards, err := cloudflare_d1_go.NewShards(pool, []string{"a", "b", "c", "d"}, nil, cloudflare_d1_go.ConsistentHashing(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[shards.ShardName(fmt.Sprintf("tenant-%d", i))]++
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if counts[name] < 1500 || counts[name] > 3500 {
+			t.Errorf("%s got %d of 10000 keys, want about 2500", name, counts[name])
+		}
+	}
+
+	// Adding a fifth database moves about a fifth of the keys, all to it
+	change, err := shards.AddShard("e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := movedKeys(t, shards, change, 10000)
+	if moved < 1000 || moved > 3000 {
+		t.Errorf("%d of 10000 keys moved, want about 2000", moved)
+	}
+	for i := 0; i < 10000; i++ {
+		if _, to, ok := change.Moved(fmt.Sprintf("tenant-%d", i)); ok && to != "e" {
+			t.Fatalf("a key moved to %s, not the new database", to)
+		}
+	}
+
+	// Removing it moves the same keys back
+	change, err = shards.RemoveShard("e")
+	if err != nil || change.Removed != "e" {
+		t.Fatalf("RemoveShard = %+v, %v", change, err)
+	}
+	if back := movedKeys(t, shards, change, 10000); back != moved {
+		t.Errorf("%d keys moved back, want %d", back, moved)
+	}
+}
+
+func TestShardsValidation(t *testing.T) {
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	for _, names := range [][]string{nil, {"a", "a"}, {"a", ""}} {
+		if _, err := cloudflare_d1_go.NewShards(pool, names, nil); err == nil {
+			t.Errorf("NewShards(%q) succeeded", names)
+		}
+	}
+	if _, err := cloudflare_d1_go.NewShards(nil, []string{"a"}, nil); err == nil {
+		t.Error("NewShards without a pool succeeded")
+	}
+
+	shards, _ := cloudflare_d1_go.NewShards(pool, []string{"a"}, nil)
+	if _, err := shards.AddShard("a"); err == nil {
+		t.Error("AddShard of an existing shard succeeded")
+	}
+	if _, err := shards.RemoveShard("b"); err == nil {
+		t.Error("RemoveShard of an unknown shard succeeded")
+	}
+	if _, err := shards.RemoveShard("a"); err == nil {
+		t.Error("RemoveShard of the last shard succeeded")
+	}
+}
+
+func TestShardsQueries(t *testing.T) {
+	serveShards(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	shards, err := cloudflare_d1_go.NewShards(pool, []string{"a", "b", "c"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := shards.ForKey("tenant-1")
+	if err != nil {
+		t.Fatalf("ForKey failed: %v", err)
+	}
+	if db.Name() != shards.ShardName("tenant-1") {
+		t.Errorf("ForKey returned %s, want %s", db.Name(), shards.ShardName("tenant-1"))
+	}
+
+	var rows []shardRow
+	if err := shards.SelectFromAll(&rows, "SELECT id, shard FROM items"); err != nil {
+		t.Fatalf("SelectFromAll failed: %v", err)
+	}
+	if len(rows) != 6 || rows[0].Shard != "a" || rows[5].Shard != "c" {
+		t.Errorf("rows = %v, want those of a, b and c", rows)
+	}
+
+	if _, err := shards.AddShard("broken"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = shards.ExecOnAll("UPDATE items SET n = 0")
+	var fanOut *cloudflare_d1_go.FanOutError
+	if !errors.As(err, &fanOut) || len(fanOut.Failed) != 1 || fanOut.Failed[0].Database != "broken" {
+		t.Errorf("ExecOnAll error = %v, want the failure of broken", err)
+	}
+}
@@ -0,0 +1,204 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// blockingCloser never finishes closing until its context is done.
+type blockingCloser struct{}
+
+func (blockingCloser) Close(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// checkGoroutines fails the test if the goroutine count does not return to baseline.
+func checkGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: %d running, %d before", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(started)
+			<-release
+		}
+		writeJSON(w, successResponse(queryResult(nil, nil, map[string]interface{}{"changes": float64(1)})))
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	execErr := make(chan error, 1)
+	go func() {
+		_, err := pool.Exec("UPDATE users SET age = ?", 30)
+		execErr <- err
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		shutdownErr <- cloudflare_d1_go.Shutdown(ctx, pool)
+	}()
+
+	// Shutdown must wait for the in-flight request
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before the request drained: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-execErr; err != nil {
+		t.Fatalf("in-flight Exec failed: %v", err)
+	}
+
+	// No request may reach the API after shutdown
+	if _, err := pool.Query("SELECT 1", nil); !errors.Is(err, cloudflare_d1_go.ErrPoolClosed) {
+		t.Errorf("Query after shutdown = %v, want ErrPoolClosed", err)
+	}
+	if err := pool.ConnectWithID("other", "db-2"); !errors.Is(err, cloudflare_d1_go.ErrPoolClosed) {
+		t.Errorf("ConnectWithID after shutdown = %v, want ErrPoolClosed", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("API saw %d requests, want 1", n)
+	}
+
+	// Closing twice is harmless
+	if err := pool.Close(context.Background()); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+
+	checkGoroutines(t, baseline)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	started := make(chan struct{})
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	queryErr := make(chan error, 1)
+	go func() {
+		_, err := pool.Query("SELECT 1", nil)
+		queryErr <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := cloudflare_d1_go.Shutdown(ctx, pool, blockingCloser{})
+
+	var shutdownErr *cloudflare_d1_go.ShutdownError
+	if !errors.As(err, &shutdownErr) {
+		t.Fatalf("Shutdown = %v, want *ShutdownError", err)
+	}
+	if len(shutdownErr.Failed) != 2 {
+		t.Fatalf("got %d failed components, want 2: %v", len(shutdownErr.Failed), err)
+	}
+	if got := shutdownErr.Failed[0].Component; got != "*cloudflared1.ConnectionPool" {
+		t.Errorf("first failed component = %q", got)
+	}
+	if got := shutdownErr.Failed[1].Component; got != "cloudflared1_test.blockingCloser" {
+		t.Errorf("second failed component = %q", got)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown error %v does not wrap context.DeadlineExceeded", err)
+	}
+
+	// The request still in flight at the deadline is cancelled
+	if err := <-queryErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("in-flight Query = %v, want context.Canceled", err)
+	}
+
+	checkGoroutines(t, baseline)
+}
+
+func TestShutdownStopsBackgroundWork(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	var requests int32
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if strings.HasSuffix(r.URL.Path, "/raw") {
+			writeJSON(w, successResponse(queryResult([]string{"1"}, [][]interface{}{{1}}, map[string]interface{}{"rows_read": 1})))
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"result":  map[string]interface{}{"uuid": "db-1", "name": "main"},
+			"success": true,
+		})
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	pool.SetCacheAge(time.Millisecond)
+	pool.SetAutoSave(filepath.Join(t.TempDir(), "cache.json"), func(err error) { t.Errorf("auto save failed: %v", err) })
+
+	var reports int32
+	pool.ReportUsage(5*time.Millisecond, func(cloudflare_d1_go.Usage) { atomic.AddInt32(&reports, 1) })
+	pool.StartRefresh(context.Background(), 5*time.Millisecond)
+	if _, err := pool.Exec("UPDATE users SET age = 1"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+
+	// Wait until the refresher and the reporter did some work
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&requests) < 3 || atomic.LoadInt32(&reports) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("background work did not run: %d requests, %d reports", atomic.LoadInt32(&requests), atomic.LoadInt32(&reports))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := cloudflare_d1_go.Shutdown(ctx, pool); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	// Nothing runs after shutdown: no requests, no reports
+	requestsAtShutdown, reportsAtShutdown := atomic.LoadInt32(&requests), atomic.LoadInt32(&reports)
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&requests); n != requestsAtShutdown {
+		t.Errorf("API saw %d requests after shutdown", n-requestsAtShutdown)
+	}
+	if n := atomic.LoadInt32(&reports); n != reportsAtShutdown {
+		t.Errorf("usage was reported %d times after shutdown", n-reportsAtShutdown)
+	}
+
+	checkGoroutines(t, baseline)
+}
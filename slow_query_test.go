@@ -0,0 +1,121 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveSlow answers queries after a delay taken from the SQL: queries
+// containing "slow" wait 50ms. serverMs is reported as the D1 duration.
+func serveSlow(t *testing.T, serverMs float64) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "slow") {
+			time.Sleep(50 * time.Millisecond)
+		}
+		meta := map[string]interface{}{"duration": serverMs, "rows_read": 5000}
+		writeJSON(w, successResponse(queryResult([]string{"n"}, [][]interface{}{{1}}, meta)))
+	})
+}
+
+type slowRecorder struct {
+	mu      sync.Mutex
+	queries []cloudflare_d1_go.SlowQuery
+}
+
+func (s *slowRecorder) hook(ctx context.Context, q cloudflare_d1_go.SlowQuery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, q)
+}
+
+func TestSlowQueryByWallClock(t *testing.T) {
+	serveSlow(t, 0.1)
+
+	var rec slowRecorder
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		SlowQueryThreshold: 20 * time.Millisecond,
+		SlowQueryHook:      rec.hook,
+	})
+	client.DatabaseID = "db-1"
+
+	_, _ = client.Exec("SELECT 'fast'")
+	_, _ = client.Exec("SELECT 'slow'")
+
+	if len(rec.queries) != 1 {
+		t.Fatalf("hook called %d times, want once", len(rec.queries))
+	}
+	q := rec.queries[0]
+	if q.SQL != "SELECT 'slow'" || q.Duration < 50*time.Millisecond || q.RowsRead != 5000 {
+		t.Errorf("SlowQuery = %+v", q)
+	}
+}
+
+func TestSlowQueryByReportedDuration(t *testing.T) {
+	serveSlow(t, 250)
+
+	var rec slowRecorder
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		SlowQueryThreshold: 100 * time.Millisecond,
+		SlowQueryHook:      rec.hook,
+	})
+	client.DatabaseID = "db-1"
+
+	_, _ = client.Exec("SELECT 'fast'")
+	if len(rec.queries) != 1 || rec.queries[0].ServerDuration != 250*time.Millisecond {
+		t.Errorf("slow queries = %+v, want one with a server duration of 250ms", rec.queries)
+	}
+}
+
+func TestSlowQueryDisabledByDefault(t *testing.T) {
+	serveSlow(t, 250)
+
+	var rec slowRecorder
+	client := cloudflare_d1_go.NewClientWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		SlowQueryHook: rec.hook,
+	})
+	client.DatabaseID = "db-1"
+
+	_, _ = client.Exec("SELECT 'slow'")
+	if len(rec.queries) != 0 {
+		t.Errorf("hook called without a threshold: %+v", rec.queries)
+	}
+}
+
+func TestSlowQueryHookMayUsePool(t *testing.T) {
+	serveSlow(t, 0.1)
+
+	var pool *cloudflare_d1_go.ConnectionPool
+	called := false
+	pool = cloudflare_d1_go.NewConnectionPoolWithOptions("account_id", "api_token", cloudflare_d1_go.ClientOptions{
+		SlowQueryThreshold: 20 * time.Millisecond,
+		SlowQueryHook: func(ctx context.Context, q cloudflare_d1_go.SlowQuery) {
+			called = true
+			// Deadlocks if the hook runs while the pool is locked
+			pool.SetNameMapper(nil)
+			_ = pool.ConnectWithID("other", "db-2")
+		},
+	})
+	_ = pool.ConnectWithID("main", "db-1")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = pool.Exec("SELECT 'slow'")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Exec did not return, the hook blocked on a pool lock")
+	}
+	if !called {
+		t.Error("hook was not called")
+	}
+}
@@ -0,0 +1,124 @@
+package cloudflared1_test
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+var updateSnapshots = flag.Bool("update", false, "rewrite testdata/*.golden from the current output")
+
+// snapshotUser is the representative struct used for generated SQL snapshots
+type snapshotUser struct {
+	ID        int64  `db:"id"`
+	Name      string `db:"name"`
+	Email     string `db:"email"`
+	CreatedAt string
+}
+
+// checkSnapshot compares got with testdata/<name>.golden and rewrites the file with -update
+func checkSnapshot(t *testing.T, name, got string) {
+	t.Helper()
+	path := "testdata/" + name + ".golden"
+	if *updateSnapshots {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to update snapshot: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read snapshot, run go test -run %s -update: %v", t.Name(), err)
+	}
+	if got != string(want) {
+		t.Errorf("generated SQL differs from %s, run go test -run %s -update and review the diff\n--- got\n%s", path, t.Name(), got)
+	}
+}
+
+func TestGeneratedSQLSnapshot(t *testing.T) {
+	client, rec := cloudflare_d1_go.NewDryRunClient()
+	user := snapshotUser{ID: 7, Name: "Alice", Email: "alice@example.com", CreatedAt: "2024-01-01 00:00:00"}
+
+	var out strings.Builder
+	run := func(name string, fn func() error) {
+		rec.Reset()
+		_ = fn()
+		fmt.Fprintf(&out, "## %s\n%s\n", name, rec.String())
+	}
+
+	run("Select", func() error {
+		var users []snapshotUser
+		return client.Select(&users, "SELECT * FROM users WHERE id > ?", 5)
+	})
+	run("Get", func() error {
+		return client.Get(&user, "SELECT * FROM users WHERE id = ?;", user.ID)
+	})
+	run("ExecResult", func() error {
+		_, err := client.ExecResult("UPDATE users SET name = ? WHERE id = ?", user.Name, user.ID)
+		return err
+	})
+	run("NamedExec", func() error {
+		_, err := client.NamedExec("INSERT INTO users (id, name, email, created_at) VALUES (:id, :name, :email, :created_at)", user)
+		return err
+	})
+	run("Batch", func() error {
+		_, err := client.Batch([]utils.Statement{
+			{SQL: "DELETE FROM users WHERE id = ?", Params: []interface{}{user.ID}},
+			{SQL: "SELECT COUNT(*) FROM users"},
+		})
+		return err
+	})
+	run("RemoveTable", func() error {
+		_, err := client.RemoveTable("users")
+		return err
+	})
+	run("ListTables", func() error {
+		_, err := client.ListTables()
+		return err
+	})
+	run("TableExists", func() error {
+		_, err := client.TableExists("users")
+		return err
+	})
+	run("DescribeTable", func() error {
+		_, err := client.DescribeTable("users")
+		return err
+	})
+	run("ListIndexes", func() error {
+		_, err := client.ListIndexes("users")
+		return err
+	})
+
+	checkSnapshot(t, "generated_sql", out.String())
+}
+
+func TestDryRunDoesNotSend(t *testing.T) {
+	var requests int
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	})
+
+	base := cloudflare_d1_go.NewClient("account_id", "api_token")
+	base.DatabaseID = "db-1"
+	client, rec := base.DryRun()
+
+	if _, err := client.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if _, err := client.ListDB(); err == nil {
+		t.Error("ListDB on a dry-run client should fail")
+	}
+	if requests != 0 {
+		t.Errorf("dry-run client sent %d requests", requests)
+	}
+	if got := rec.Statements(); len(got) != 1 || got[0].SQL != "DELETE FROM users" {
+		t.Errorf("recorded %+v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	_ "github.com/youfun/cloudflare-d1-go/d1driver"
+)
+
+func TestSQLRowsValueMapping(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult(
+			[]string{"i", "f", "s", "b", "n"},
+			[][]interface{}{{5, 1.5, "text", []interface{}{104, 105}, nil}},
+			nil,
+		)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	rows, err := client.SQLRows("SELECT i, f, s, b, n FROM t WHERE id = ?", 1)
+	if err != nil {
+		t.Fatalf("SQLRows failed: %v", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatalf("no row: %v", rows.Err())
+	}
+	values := make([]interface{}, 5)
+	ptrs := make([]interface{}, 5)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	want := []interface{}{int64(5), 1.5, "text", []byte("hi"), nil}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %#v, want %#v", values, want)
+	}
+
+	if rows.Next() {
+		t.Error("expected a single row")
+	}
+	if err := rows.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestSQLRowsContextCancel(t *testing.T) {
+	data := make([][]interface{}, 1000)
+	for i := range data {
+		data[i] = []interface{}{i}
+	}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult([]string{"id"}, data, nil)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-2"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rows, err := client.SQLRowsContext(ctx, "SELECT id FROM t")
+	if err != nil {
+		t.Fatalf("SQLRowsContext failed: %v", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		count++
+		if count == 1 {
+			cancel()
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if !errors.Is(rows.Err(), context.Canceled) {
+		t.Errorf("rows.Err = %v, want context.Canceled", rows.Err())
+	}
+	if count == len(data) {
+		t.Error("iteration continued after the context was cancelled")
+	}
+}
@@ -0,0 +1,86 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// misspelledUser has a typo in the tag of Name and a field the users
+// table lacks
+type misspelledUser struct {
+	ID    int64  `db:"id"`
+	Name  string `db:"nmae"`
+	Email string `db:"email"`
+	Cache string `db:"-"`
+}
+
+func TestStrictScan(t *testing.T) {
+	serveUsers(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	var users []misspelledUser
+	if err := client.Select(&users, "SELECT * FROM users"); err != nil || len(users) != 2 || users[0].Name != "" {
+		t.Fatalf("lenient Select = %+v, %v; want the rows with Name left empty", users, err)
+	}
+
+	client.SetStrictScan(true)
+	err := client.Select(&users, "SELECT * FROM users")
+	var unmatched *utils.UnmatchedError
+	if !errors.As(err, &unmatched) {
+		t.Fatalf("err = %v, want *utils.UnmatchedError", err)
+	}
+	if !reflect.DeepEqual(unmatched.Columns, []string{"name"}) || !reflect.DeepEqual(unmatched.Fields, []string{"Name", "Email"}) {
+		t.Errorf("Columns = %v, Fields = %v; want [name] and [Name Email]", unmatched.Columns, unmatched.Fields)
+	}
+	if !strings.Contains(err.Error(), "columns without a field: name") || !strings.Contains(err.Error(), "fields without a column: Name, Email") {
+		t.Errorf("error %q does not list the columns and fields", err)
+	}
+
+	var user misspelledUser
+	if err := client.Get(&user, "SELECT * FROM users"); !errors.As(err, &unmatched) {
+		t.Errorf("Get err = %v, want *utils.UnmatchedError", err)
+	}
+
+	var matching []genericUser
+	if err := client.Select(&matching, "SELECT * FROM users"); err != nil || len(matching) != 2 {
+		t.Errorf("strict Select of a matching struct = %+v, %v", matching, err)
+	}
+	var names []string
+	if err := client.Select(&names, "SELECT name FROM users"); err != nil {
+		t.Errorf("strict Select of single values failed: %v", err)
+	}
+}
+
+func TestPoolStrictScan(t *testing.T) {
+	serveUsers(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	pool.SetStrictScan(true)
+
+	var users []misspelledUser
+	var unmatched *utils.UnmatchedError
+	if err := pool.Select(&users, "SELECT * FROM users"); !errors.As(err, &unmatched) {
+		t.Errorf("err = %v, want *utils.UnmatchedError", err)
+	}
+}
+
+func TestSkippedTagIsNotAColumn(t *testing.T) {
+	rows := utils.NewRows([]map[string]interface{}{{"id": float64(1), "-": "x", "cache": "y"}}, []string{"id", "-", "cache"})
+	rows.Next()
+	var user misspelledUser
+	if err := rows.StructScan(&user); err != nil || user.Cache != "" {
+		t.Errorf("StructScan = %+v, %v; want Cache left alone", user, err)
+	}
+
+	if _, _, err := utils.BindNamed("UPDATE users SET cache = :cache", user); err == nil {
+		t.Error("BindNamed bound a field tagged db:\"-\"")
+	}
+}
@@ -0,0 +1,212 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// sentStatement is the body of a single statement request
+type sentStatement struct {
+	SQL    string   `json:"sql"`
+	Params []string `json:"params"`
+}
+
+// serveStatements records the statements sent and answers each with
+// last_row_id 42 and the given number of changes
+func serveStatements(t *testing.T, changes int) *[]sentStatement {
+	var sent []sentStatement
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var stmt sentStatement
+		_ = json.NewDecoder(r.Body).Decode(&stmt)
+		sent = append(sent, stmt)
+		writeJSON(w, successResponse(queryResult(nil, nil, map[string]interface{}{"last_row_id": 42, "changes": changes})))
+	})
+	return &sent
+}
+
+type insertUser struct {
+	ID        int64      `db:"id,omitempty"`
+	Name      string     `db:"name"`
+	Email     *string    `db:"email"`
+	Nickname  string     // no tag, mapped like StructScan maps it
+	CreatedAt time.Time  `db:"created_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+	Cache     string     `db:"-"`
+	internal  string
+}
+
+func TestInsertStruct(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	email := "alice@example.com"
+	user := insertUser{
+		Name:      "Alice",
+		Email:     &email,
+		Nickname:  "al",
+		CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Cache:     "not a column",
+		internal:  "unexported",
+	}
+
+	res, err := client.InsertStruct("users", &user)
+	if err != nil {
+		t.Fatalf("InsertStruct failed: %v", err)
+	}
+	if id, _ := res.LastInsertId(); id != 42 {
+		t.Errorf("LastInsertId = %d, want 42", id)
+	}
+
+	want := sentStatement{
+		SQL:    `INSERT INTO "users" ("name", "email", "nickname", "created_at", "deleted_at") VALUES (?, ?, ?, ?, NULL)`,
+		Params: []string{"Alice", "alice@example.com", "al", "2024-05-01T12:30:00.000000000Z"},
+	}
+	if !reflect.DeepEqual((*sent)[0], want) {
+		t.Errorf("sent %+v\nwant %+v", (*sent)[0], want)
+	}
+
+	// A set omitempty field is inserted
+	user.ID = 7
+	user.Email = nil
+	_, _ = client.InsertStruct("users", user)
+	if got := (*sent)[1].SQL; got != `INSERT INTO "users" ("id", "name", "email", "nickname", "created_at", "deleted_at") VALUES (?, ?, NULL, ?, ?, NULL)` {
+		t.Errorf("SQL = %s", got)
+	}
+}
+
+func TestInsertStructOptions(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	type setting struct {
+		Key   string `db:"key"`
+		Value string `db:"value"`
+	}
+
+	tests := []struct {
+		opt  cloudflare_d1_go.InsertOption
+		want string
+	}{
+		{cloudflare_d1_go.InsertOrIgnore(), `INSERT OR IGNORE INTO "settings" ("key", "value") VALUES (?, ?)`},
+		{cloudflare_d1_go.InsertOrReplace(), `INSERT OR REPLACE INTO "settings" ("key", "value") VALUES (?, ?)`},
+		{cloudflare_d1_go.OnConflictUpdate("key"), `INSERT INTO "settings" ("key", "value") VALUES (?, ?) ON CONFLICT ("key") DO UPDATE SET "value" = excluded."value"`},
+		{cloudflare_d1_go.OnConflictUpdate("key", "value"), `INSERT INTO "settings" ("key", "value") VALUES (?, ?) ON CONFLICT ("key", "value") DO NOTHING`},
+	}
+	for i, tt := range tests {
+		if _, err := client.InsertStruct("settings", setting{Key: "theme", Value: "dark"}, tt.opt); err != nil {
+			t.Fatalf("InsertStruct failed: %v", err)
+		}
+		if got := (*sent)[i].SQL; got != tt.want {
+			t.Errorf("SQL = %s\nwant  %s", got, tt.want)
+		}
+	}
+}
+
+func TestInsertStructErrors(t *testing.T) {
+	serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	var nilUser *insertUser
+	for name, v := range map[string]interface{}{"nil pointer": nilUser, "not a struct": 42} {
+		if _, err := client.InsertStruct("users", v); err == nil {
+			t.Errorf("%s: InsertStruct succeeded", name)
+		}
+	}
+	if _, err := client.InsertStruct("", insertUser{}); err == nil {
+		t.Error("InsertStruct succeeded without a table name")
+	}
+}
+
+func TestUpdateStruct(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	user := insertUser{ID: 7, Name: "Alice", Nickname: "al", CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)}
+
+	n, err := client.UpdateStruct("users", &user)
+	if err != nil || n != 1 {
+		t.Fatalf("UpdateStruct = %d, %v; want 1 row", n, err)
+	}
+	want := sentStatement{
+		SQL:    `UPDATE "users" SET "name" = ?, "email" = NULL, "nickname" = ?, "created_at" = ?, "deleted_at" = NULL WHERE "id" = ?`,
+		Params: []string{"Alice", "al", "2024-05-01T12:30:00.000000000Z", "7"},
+	}
+	if !reflect.DeepEqual((*sent)[0], want) {
+		t.Errorf("sent %+v\nwant %+v", (*sent)[0], want)
+	}
+
+	_, _ = client.UpdateStruct("users", user, "name", "nickname")
+	if got := (*sent)[1].SQL; got != `UPDATE "users" SET "id" = ?, "email" = NULL, "created_at" = ?, "deleted_at" = NULL WHERE "name" = ? AND "nickname" = ?` {
+		t.Errorf("SQL with composite key = %s", got)
+	}
+}
+
+func TestUpdateStructPartial(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	user := insertUser{ID: 7, Name: "Alice", Nickname: "al"}
+
+	_, _ = client.UpdateStructWithOptions("users", user, cloudflare_d1_go.UpdateOptions{OnlyNonZero: true})
+	if got := (*sent)[0]; got.SQL != `UPDATE "users" SET "name" = ?, "nickname" = ? WHERE "id" = ?` || !reflect.DeepEqual(got.Params, []string{"Alice", "al", "7"}) {
+		t.Errorf("partial update sent %+v", got)
+	}
+
+	_, _ = client.UpdateStructWithOptions("users", user, cloudflare_d1_go.UpdateOptions{Columns: []string{"nickname", "email"}})
+	if got := (*sent)[1].SQL; got != `UPDATE "users" SET "email" = NULL, "nickname" = ? WHERE "id" = ?` {
+		t.Errorf("SQL with column list = %s", got)
+	}
+}
+
+func TestUpdateStructNoRows(t *testing.T) {
+	serveStatements(t, 0)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	n, err := client.UpdateStruct("users", insertUser{ID: 99, Name: "Nobody"})
+	if err != nil || n != 0 {
+		t.Errorf("UpdateStruct = %d, %v; want 0 rows and no error", n, err)
+	}
+}
+
+func TestUpdateStructErrors(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	type noKey struct {
+		Name string `db:"name"`
+	}
+
+	tests := map[string]func() error{
+		"missing key column": func() error { _, err := client.UpdateStruct("users", noKey{Name: "Alice"}); return err },
+		"unknown key column": func() error { _, err := client.UpdateStruct("users", insertUser{ID: 1}, "uuid"); return err },
+		"NULL key":           func() error { _, err := client.UpdateStruct("users", insertUser{ID: 1}, "email"); return err },
+		"unknown column": func() error {
+			_, err := client.UpdateStructWithOptions("users", insertUser{ID: 1}, cloudflare_d1_go.UpdateOptions{Columns: []string{"age"}})
+			return err
+		},
+		"nothing to set": func() error {
+			_, err := client.UpdateStructWithOptions("users", insertUser{ID: 1}, cloudflare_d1_go.UpdateOptions{OnlyNonZero: true})
+			return err
+		},
+	}
+	for name, update := range tests {
+		if err := update(); err == nil {
+			t.Errorf("%s: UpdateStruct succeeded", name)
+		}
+	}
+	if len(*sent) != 0 {
+		t.Errorf("sent %d statements, want none", len(*sent))
+	}
+}
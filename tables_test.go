@@ -0,0 +1,74 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+// serveTables mocks sqlite_master with a fixed set of tables
+func serveTables(t *testing.T) {
+	t.Helper()
+	tables := []string{"_cf_KV", "d1_migrations", "departments", "sqlite_sequence", "users"}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			SQL    string   `json:"sql"`
+			Params []string `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		var rows [][]interface{}
+		for _, name := range tables {
+			if len(body.Params) == 0 || body.Params[0] == name {
+				rows = append(rows, []interface{}{name})
+			}
+		}
+		writeJSON(w, successResponse(queryResult([]string{"name"}, rows, nil)))
+	})
+}
+
+func TestListTables(t *testing.T) {
+	serveTables(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	tables, err := client.ListTables()
+	if err != nil {
+		t.Fatalf("ListTables failed: %v", err)
+	}
+	if !reflect.DeepEqual(tables, []string{"departments", "users"}) {
+		t.Errorf("ListTables = %v", tables)
+	}
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	all, err := pool.ListTables(cloudflare_d1_go.IncludeInternalTables())
+	if err != nil {
+		t.Fatalf("ListTables failed: %v", err)
+	}
+	if len(all) != 5 {
+		t.Errorf("ListTables(IncludeInternalTables()) = %v, want all 5 tables", all)
+	}
+}
+
+func TestTableExists(t *testing.T) {
+	serveTables(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	for name, want := range map[string]bool{"users": true, "orders": false} {
+		got, err := pool.TableExists(name)
+		if err != nil {
+			t.Fatalf("TableExists(%s) failed: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("TableExists(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package cloudflared1_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := map[string]int{
+		"SELECT 1":                              0,
+		"SELECT * FROM t WHERE a = ? AND b = ?": 2,
+		"SELECT ?2, ?1":                         2,
+		"SELECT ?3, ?":                          4,
+		"SELECT '?', \"?\" FROM t -- ?\nWHERE a=?": 1,
+		"SELECT /* ? */ json_extract(c, '$.a')":    0,
+		"SELECT [col?] FROM t WHERE a = ?":         1,
+	}
+	for query, want := range tests {
+		got, err := utils.CountPlaceholders(query)
+		if err != nil || got != want {
+			t.Errorf("CountPlaceholders(%q) = %d, %v, want %d", query, got, err, want)
+		}
+	}
+
+	for _, query := range []string{"SELECT :name", "SELECT @name", "SELECT $name", "SELECT ?0"} {
+		if _, err := utils.CountPlaceholders(query); err == nil {
+			t.Errorf("CountPlaceholders(%q) succeeded, want error", query)
+		}
+	}
+}
+
+func TestBatchTemplateJSON(t *testing.T) {
+	tmpl, err := cloudflare_d1_go.NewBatchTemplate(
+		"DELETE FROM daily_totals WHERE day = ?",
+		"INSERT INTO daily_totals (day, total) SELECT ?, SUM(amount) FROM orders WHERE day = ?1",
+	)
+	if err != nil {
+		t.Fatalf("NewBatchTemplate failed: %v", err)
+	}
+	if tmpl.Statements[0].NumParams != 1 || tmpl.Statements[1].NumParams != 1 || tmpl.Fingerprint == "" {
+		t.Errorf("template = %+v", tmpl)
+	}
+
+	data, err := json.Marshal(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded cloudflare_d1_go.BatchTemplate
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if loaded.Fingerprint != tmpl.Fingerprint || len(loaded.Statements) != 2 {
+		t.Errorf("loaded = %+v, want %+v", loaded, tmpl)
+	}
+
+	edited := strings.Replace(string(data), "DELETE FROM", "DELETE  FROM", 1)
+	if err := json.Unmarshal([]byte(edited), &loaded); err == nil || !strings.Contains(err.Error(), "fingerprint") {
+		t.Errorf("edited template: err = %v, want fingerprint mismatch", err)
+	}
+
+	if _, err := cloudflare_d1_go.NewBatchTemplate("SELECT 1", "SELECT :day"); err == nil {
+		t.Error("expected error for a named placeholder")
+	}
+}
+
+func TestBatchTemplateExecute(t *testing.T) {
+	bodies := recordBodies(t)
+
+	tmpl, err := cloudflare_d1_go.NewBatchTemplate(
+		"DELETE FROM daily_totals WHERE day = ?",
+		"INSERT INTO daily_totals (day, total) VALUES (?, ?)",
+	)
+	if err != nil {
+		t.Fatalf("NewBatchTemplate failed: %v", err)
+	}
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	for _, db := range []cloudflare_d1_go.BatchExecutor{client, pool} {
+		results, err := tmpl.Execute(db, [][]interface{}{{"2024-05-01"}, {"2024-05-01", 42}})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("got %d results, want 2", len(results))
+		}
+	}
+
+	want := `{"batch":[{"sql":"DELETE FROM daily_totals WHERE day = ?","params":["2024-05-01"]},{"sql":"INSERT INTO daily_totals (day, total) VALUES (?, ?)","params":["2024-05-01","42"]}]}`
+	if len(*bodies) != 2 || (*bodies)[0] != want || (*bodies)[1] != want {
+		t.Errorf("bodies = %q, want twice %s", *bodies, want)
+	}
+}
+
+func TestBatchTemplateArityIsCheckedFirst(t *testing.T) {
+	bodies := recordBodies(t)
+
+	tmpl, _ := cloudflare_d1_go.NewBatchTemplate("SELECT ?", "SELECT ?, ?")
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	_, err := tmpl.Execute(client, [][]interface{}{{1}, {2}})
+	var batchErr *utils.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Errorf("err = %v, want BatchError for statement 1", err)
+	}
+	if _, err := tmpl.Execute(client, [][]interface{}{{1}}); err == nil {
+		t.Error("expected error for a missing parameter list")
+	}
+	if len(*bodies) != 0 {
+		t.Errorf("requests were sent: %q", *bodies)
+	}
+}
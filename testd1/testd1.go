@@ -0,0 +1,286 @@
+// Package testd1 provides an in-memory fake of a D1 database for unit tests
+// of code that takes a cloudflared1.Queryer. Queries are answered from
+// fixtures registered by SQL text, and every query is recorded:
+//
+//	db := testd1.New()
+//	db.On("SELECT id, name FROM users WHERE id = ?", testd1.Fixture{
+//		Columns: []string{"id", "name"},
+//		Rows:    [][]interface{}{{1, "Alice"}},
+//	})
+//	handler := NewHandler(db) // takes a cloudflared1.Queryer
+//
+// No network access or SQL engine is involved: a query without a fixture fails.
+package testd1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// ErrNoFixture is returned for a query no fixture was registered for
+var ErrNoFixture = errors.New("testd1: no fixture for query")
+
+// Fixture is the canned answer to a query
+type Fixture struct {
+	Columns []string
+	// Rows holds one value per column. Values are converted like a D1
+	// response is decoded, e.g. integers become float64 and nil is NULL.
+	Rows         [][]interface{}
+	RowsAffected int64
+	LastInsertID int64
+	// RowsWritten is reported as rows_written in the meta of the result
+	RowsWritten int64
+	// Err fails the query. In a batch, it fails the whole batch.
+	Err error
+}
+
+// Call is a query received by the fake
+type Call struct {
+	SQL    string
+	Params []string // as sent to D1, see utils.ConvertParams
+}
+
+// DB is a fake cloudflared1.Queryer. It is safe for concurrent use.
+type DB struct {
+	mu       sync.Mutex
+	fixtures map[string]Fixture
+	calls    []Call
+}
+
+var _ cloudflare_d1_go.Queryer = (*DB)(nil)
+
+// New returns a fake without fixtures
+func New() *DB {
+	return &DB{fixtures: make(map[string]Fixture)}
+}
+
+// On registers the answer to query, replacing an earlier fixture for it.
+// Queries match when they are equal after collapsing whitespace and removing
+// trailing semicolons; parameters are not compared.
+func (db *DB) On(query string, f Fixture) *DB {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.fixtures[normalize(query)] = f
+	return db
+}
+
+// Calls returns the queries received so far, in order. A batch records one
+// call per statement.
+func (db *DB) Calls() []Call {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]Call(nil), db.calls...)
+}
+
+// Reset forgets the recorded calls. Fixtures are kept.
+func (db *DB) Reset() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.calls = nil
+}
+
+// normalize collapses whitespace and strips trailing semicolons
+func normalize(query string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(query), " "), ";")
+}
+
+// answer records a call and builds the result item of its fixture
+func (db *DB) answer(query string, params []string) (interface{}, error) {
+	if params == nil {
+		params = []string{}
+	}
+
+	db.mu.Lock()
+	db.calls = append(db.calls, Call{SQL: query, Params: params})
+	f, ok := db.fixtures[normalize(query)]
+	db.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoFixture, query)
+	}
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return f.item()
+}
+
+// item encodes the fixture like an item of a D1 raw response and decodes it
+// again, so values scan exactly as they would from the API
+func (f Fixture) item() (interface{}, error) {
+	columns := f.Columns
+	if columns == nil {
+		columns = []string{}
+	}
+	rows := f.Rows
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("testd1: fixture row %d has %d values for %d columns", i, len(row), len(columns))
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"results": map[string]interface{}{"columns": columns, "rows": rows},
+		"meta": map[string]interface{}{
+			"changes":      f.RowsAffected,
+			"last_row_id":  f.LastInsertID,
+			"rows_read":    len(rows),
+			"rows_written": f.RowsWritten,
+		},
+		"success": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("testd1: invalid fixture: %w", err)
+	}
+	var item interface{}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Query answers query from its fixture
+func (db *DB) Query(query string, params []string) (*utils.APIResponse, error) {
+	return db.QueryContext(context.Background(), query, params)
+}
+
+// QueryContext is Query with a context; a done context fails the query
+func (db *DB) QueryContext(ctx context.Context, query string, params []string) (*utils.APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, err := db.answer(query, params)
+	if err != nil {
+		return nil, err
+	}
+	return &utils.APIResponse{Result: []interface{}{item}, Success: true}, nil
+}
+
+// Select scans the rows of the fixture into dest, like Client.Select
+func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
+	return db.SelectContext(context.Background(), dest, query, args...)
+}
+
+// SelectContext is Select with a context
+func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	rows, err := db.queryRows(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return rows.StructScanAll(dest)
+}
+
+// Get scans the first row of the fixture into dest, like Client.Get.
+// It returns utils.ErrNoRows if the fixture has no rows.
+func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
+	return db.GetContext(context.Background(), dest, query, args...)
+}
+
+// GetContext is Get with a context
+func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	rows, err := db.queryRows(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return utils.ErrNoRows
+	}
+	return rows.ScanInto(dest)
+}
+
+// queryRows converts args like the client does and returns the rows of the fixture
+func (db *DB) queryRows(ctx context.Context, query string, args ...interface{}) (*utils.Rows, error) {
+	params, err := utils.ConvertParams(args...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.QueryContext(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	return res.ToRows()
+}
+
+// Exec returns the RowsAffected of the fixture
+func (db *DB) Exec(query string, args ...interface{}) (int64, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is Exec with a context
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := db.ExecResultContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ExecResult returns the RowsAffected and LastInsertID of the fixture
+func (db *DB) ExecResult(query string, args ...interface{}) (*utils.Result, error) {
+	return db.ExecResultContext(context.Background(), query, args...)
+}
+
+// ExecResultContext is ExecResult with a context
+func (db *DB) ExecResultContext(ctx context.Context, query string, args ...interface{}) (*utils.Result, error) {
+	params, err := utils.ConvertParams(args...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.QueryContext(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	return res.ToResult()
+}
+
+// Batch answers every statement from its fixture. Like D1, the batch fails
+// as a whole if any statement fails; all statements are recorded either way.
+func (db *DB) Batch(statements []utils.Statement) ([]utils.BatchResult, error) {
+	return db.BatchContext(context.Background(), statements)
+}
+
+// BatchContext is Batch with a context
+func (db *DB) BatchContext(ctx context.Context, statements []utils.Statement) ([]utils.BatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]interface{}, len(statements))
+	var failed error
+	for i, stmt := range statements {
+		params, err := utils.ConvertParams(stmt.Params...)
+		if err != nil {
+			return nil, &utils.BatchError{Index: i, Err: err}
+		}
+		if items[i], err = db.answer(stmt.SQL, params); err != nil && failed == nil {
+			failed = &utils.BatchError{Index: i, Err: err}
+		}
+	}
+	if failed != nil {
+		return nil, failed
+	}
+
+	res := &utils.APIResponse{Result: items, Success: true}
+	results := make([]utils.BatchResult, len(statements))
+	for i := range statements {
+		rows, result, err := res.ResultSet(i)
+		if err != nil {
+			return nil, &utils.BatchError{Index: i, Err: err}
+		}
+		results[i] = utils.BatchResult{Rows: rows, Result: result}
+	}
+	return results, nil
+}
@@ -0,0 +1,137 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/testd1"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// countActive is application code under test that only needs a Queryer
+func countActive(db cloudflare_d1_go.Queryer, minAge int) (int, error) {
+	var users []genericUser
+	if err := db.Select(&users, "SELECT id, name FROM users WHERE active = ? AND age >= ?", true, minAge); err != nil {
+		return 0, err
+	}
+	return len(users), nil
+}
+
+func TestTestD1AnswersFromFixtures(t *testing.T) {
+	db := testd1.New().
+		On("SELECT id, name FROM users WHERE active = ? AND age >= ?", testd1.Fixture{
+			Columns: []string{"id", "name"},
+			Rows:    [][]interface{}{{1, "Alice"}, {2, "Bob"}},
+		}).
+		On("UPDATE users SET active = 0 WHERE id = ?", testd1.Fixture{RowsAffected: 1}).
+		On("INSERT INTO users (name) VALUES (?)", testd1.Fixture{RowsAffected: 1, LastInsertID: 42})
+
+	n, err := countActive(db, 18)
+	if err != nil {
+		t.Fatalf("countActive failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("countActive = %d, want 2", n)
+	}
+
+	var user genericUser
+	if err := db.Get(&user, "SELECT id, name FROM users  WHERE active = ? AND age >= ?;", true, 99); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if user != (genericUser{ID: 1, Name: "Alice"}) {
+		t.Errorf("Get = %+v", user)
+	}
+
+	if affected, err := db.Exec("UPDATE users SET active = 0 WHERE id = ?", 2); err != nil || affected != 1 {
+		t.Errorf("Exec = %d, %v, want 1 row affected", affected, err)
+	}
+	res, err := db.ExecResult("INSERT INTO users (name) VALUES (?)", "Carol")
+	if err != nil {
+		t.Fatalf("ExecResult failed: %v", err)
+	}
+	if id, _ := res.LastInsertId(); id != 42 {
+		t.Errorf("LastInsertId = %d, want 42", id)
+	}
+
+	want := []testd1.Call{
+		{SQL: "SELECT id, name FROM users WHERE active = ? AND age >= ?", Params: []string{"1", "18"}},
+		{SQL: "SELECT id, name FROM users  WHERE active = ? AND age >= ?;", Params: []string{"1", "99"}},
+		{SQL: "UPDATE users SET active = 0 WHERE id = ?", Params: []string{"2"}},
+		{SQL: "INSERT INTO users (name) VALUES (?)", Params: []string{"Carol"}},
+	}
+	if got := db.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls = %+v, want %+v", got, want)
+	}
+}
+
+func TestTestD1Errors(t *testing.T) {
+	boom := errors.New("boom")
+	db := testd1.New().
+		On("SELECT * FROM empty", testd1.Fixture{Columns: []string{"id"}}).
+		On("DELETE FROM locked", testd1.Fixture{Err: boom}).
+		On("INSERT INTO t VALUES (1)", testd1.Fixture{RowsAffected: 1})
+
+	var row struct {
+		ID int `db:"id"`
+	}
+	if err := db.Get(&row, "SELECT * FROM empty"); !errors.Is(err, utils.ErrNoRows) {
+		t.Errorf("Get on no rows returned %v, want ErrNoRows", err)
+	}
+	if _, err := db.Exec("DELETE FROM locked"); !errors.Is(err, boom) {
+		t.Errorf("Exec returned %v, want the fixture error", err)
+	}
+	if _, err := db.Exec("DROP TABLE t"); !errors.Is(err, testd1.ErrNoFixture) || !strings.Contains(err.Error(), "DROP TABLE t") {
+		t.Errorf("Exec without fixture returned %v, want ErrNoFixture", err)
+	}
+
+	_, err := db.Batch([]utils.Statement{{SQL: "INSERT INTO t VALUES (1)"}, {SQL: "DELETE FROM locked"}})
+	var batchErr *utils.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, boom) {
+		t.Errorf("Batch returned %v, want a BatchError for statement 1", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (1)"); !errors.Is(err, context.Canceled) {
+		t.Errorf("ExecContext with a cancelled context returned %v", err)
+	}
+}
+
+func TestMigrationsRunThroughQueryer(t *testing.T) {
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+	}}
+
+	db := testd1.New().
+		On(`CREATE TABLE IF NOT EXISTS "d1_migrations" ( id TEXT PRIMARY KEY, applied_at DATETIME, checksum TEXT );`, testd1.Fixture{}).
+		On(`SELECT id, checksum FROM "d1_migrations" ORDER BY id ASC;`, testd1.Fixture{Columns: []string{"id"}}).
+		On("CREATE TABLE a (id INTEGER)", testd1.Fixture{}).
+		On(`INSERT INTO "d1_migrations" (id, applied_at, checksum) VALUES (?, ?, ?);`, testd1.Fixture{RowsAffected: 1})
+
+	n, err := migrations.Exec(db, source, migrations.Up)
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("applied %d migrations, want 1", n)
+	}
+	if calls := db.Calls(); len(calls) != 4 || calls[3].Params[0] != "1_init" {
+		t.Errorf("Calls = %+v", calls)
+	}
+
+	// The pool is a Queryer too
+	bodies := recordBodies(t)
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	if _, err := migrations.Exec(pool, source, migrations.Up); err != nil {
+		t.Fatalf("Exec through the pool failed: %v", err)
+	}
+	if len(*bodies) != 3 {
+		t.Errorf("pool sent %d requests, want 3", len(*bodies))
+	}
+}
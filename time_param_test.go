@@ -0,0 +1,92 @@
+//go:build cgo
+
+package cloudflared1_test
+
+import (
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// setTimeFormat changes the time parameter format for the rest of the test
+func setTimeFormat(t *testing.T, layout string) {
+	utils.SetTimeFormat(layout)
+	t.Cleanup(func() { utils.SetTimeFormat(utils.DefaultTimeFormat) })
+}
+
+func newEventsClient(t *testing.T) *cloudflare_d1_go.Client {
+	serveSQLite(t)
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	if _, err := client.Exec("CREATE TABLE events (id INTEGER PRIMARY KEY, at TEXT, at_unix INTEGER)"); err != nil {
+		t.Fatalf("CREATE TABLE failed: %v", err)
+	}
+	return client
+}
+
+func TestTimeParamRoundTrip(t *testing.T) {
+	client := newEventsClient(t)
+
+	berlin := time.FixedZone("CEST", 2*60*60)
+	first := time.Date(2024, 5, 1, 14, 30, 0, 900000000, berlin)
+	second := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	if _, err := client.Exec("INSERT INTO events (id, at) VALUES (1, ?), (2, ?)", first, second); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	var got []time.Time
+	if err := client.Select(&got, "SELECT at FROM events ORDER BY at"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(got) != 2 || !got[0].Equal(second) || !got[1].Equal(first) {
+		t.Errorf("got %v, want %v then %v in time order", got, second, first)
+	}
+	if got[1].Location() != time.UTC {
+		t.Errorf("location = %v, want times stored in UTC", got[1].Location())
+	}
+}
+
+func TestTimeParamUnix(t *testing.T) {
+	client := newEventsClient(t)
+	setTimeFormat(t, utils.TimeFormatUnix)
+
+	at := time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC)
+	if _, err := client.Exec("INSERT INTO events (id, at_unix) VALUES (1, ?)", at); err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+
+	var unix int64
+	if err := client.Get(&unix, "SELECT at_unix FROM events WHERE typeof(at_unix) = 'integer'"); err != nil || unix != at.Unix() {
+		t.Fatalf("at_unix = %d, %v; want the integer %d", unix, err, at.Unix())
+	}
+	var got time.Time
+	if err := client.Get(&got, "SELECT at_unix FROM events"); err != nil || !got.Equal(at) {
+		t.Errorf("got %v, %v; want %v", got, err, at)
+	}
+}
+
+func TestMigrationsUseTimeFormat(t *testing.T) {
+	for _, layout := range []string{utils.DefaultTimeFormat, utils.TimeFormatUnix} {
+		client := newEventsClient(t)
+		setTimeFormat(t, layout)
+
+		source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+			memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+		}}
+		before := time.Now().Truncate(time.Second)
+		if _, err := migrations.Exec(client, source, migrations.Up); err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+
+		var applied time.Time
+		if err := client.Get(&applied, `SELECT applied_at FROM "d1_migrations"`); err != nil {
+			t.Fatalf("%s: reading applied_at failed: %v", layout, err)
+		}
+		if applied.Before(before) || applied.After(time.Now()) {
+			t.Errorf("%s: applied_at = %v, want the time of Exec", layout, applied)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cloudflared1_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+type event struct {
+	ID        int64     `db:"id"`
+	StartedAt time.Time `db:"started_at"`
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC),
+		time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+	} {
+		params, err := utils.ConvertParams(int64(1), want)
+		if err != nil {
+			t.Fatalf("ConvertParams failed: %v", err)
+		}
+		rows := utils.NewRows([]map[string]interface{}{{"id": float64(1), "started_at": params[1]}}, []string{"id", "started_at"})
+		rows.Next()
+
+		var got event
+		if err := rows.StructScan(&got); err != nil {
+			t.Fatalf("StructScan of %q failed: %v", params[1], err)
+		}
+		if !got.StartedAt.Equal(want) {
+			t.Errorf("StartedAt = %v, want %v", got.StartedAt, want)
+		}
+	}
+}
+
+func TestTimeFormats(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name string
+		src  interface{}
+		want time.Time
+	}{
+		{"RFC 3339", "2024-05-01T12:30:15Z", want},
+		{"RFC 3339 with offset", "2024-05-01T14:30:15+02:00", want},
+		{"RFC 3339 with fraction", "2024-05-01T12:30:15.25Z", want.Add(250 * time.Millisecond)},
+		{"CURRENT_TIMESTAMP", "2024-05-01 12:30:15", want},
+		{"fractional seconds", "2024-05-01 12:30:15.500", want.Add(500 * time.Millisecond)},
+		{"date only", "2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"unix epoch", float64(want.Unix()), want},
+		{"fractional epoch", float64(want.Unix()) + 0.5, want.Add(500 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got time.Time
+			scanValue(t, tt.src, &got)
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeUnparseable(t *testing.T) {
+	rows := utils.NewRows([]map[string]interface{}{{"v": "yesterday"}}, []string{"v"})
+	rows.Next()
+	var got time.Time
+	err := rows.Scan(&got)
+	if err == nil || !strings.Contains(err.Error(), `"yesterday"`) || !strings.Contains(err.Error(), "RegisterTimeLayout") {
+		t.Errorf("err = %v, want an error naming the value and RegisterTimeLayout", err)
+	}
+}
+
+func TestRegisterTimeLayout(t *testing.T) {
+	utils.RegisterTimeLayout("02/01/2006 15:04")
+
+	var got time.Time
+	scanValue(t, "01/05/2024 12:30", &got)
+	if want := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
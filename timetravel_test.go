@@ -0,0 +1,95 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestTimeTravel(t *testing.T) {
+	var requests []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/time_travel/bookmark"):
+			bookmark := "00000085-0000024c-00004c6d-8e61117bf38d7adb71b934ebbf891683"
+			if r.URL.Query().Get("timestamp") != "" {
+				bookmark = "00000080-00000001-00004c6d-aa"
+			}
+			writeJSON(w, exportResponse(map[string]interface{}{"bookmark": bookmark}))
+		case strings.HasSuffix(r.URL.Path, "/time_travel/restore"):
+			writeJSON(w, exportResponse(map[string]interface{}{
+				"bookmark":          "00000080-00000001-00004c6d-aa",
+				"previous_bookmark": "00000085-0000024c-00004c6d-8e61117bf38d7adb71b934ebbf891683",
+				"message":           "Database restored successfully",
+			}))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	info, err := client.TimeTravelInfo()
+	if err != nil || info.Bookmark != "00000085-0000024c-00004c6d-8e61117bf38d7adb71b934ebbf891683" {
+		t.Fatalf("TimeTravelInfo() = %+v, %v", info, err)
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	if info, err := client.BookmarkAt(at); err != nil || info.Bookmark != "00000080-00000001-00004c6d-aa" {
+		t.Errorf("BookmarkAt() = %+v, %v", info, err)
+	}
+
+	res, err := client.RestoreToTimestamp(at)
+	if err != nil {
+		t.Fatalf("RestoreToTimestamp failed: %v", err)
+	}
+	if res.Bookmark != "00000080-00000001-00004c6d-aa" || res.PreviousBookmark != info.Bookmark || res.Message == "" {
+		t.Errorf("RestoreResult = %+v", res)
+	}
+	if _, err := client.RestoreToBookmark(res.PreviousBookmark); err != nil {
+		t.Fatalf("RestoreToBookmark failed: %v", err)
+	}
+
+	want := []string{
+		"GET /client/v4/accounts/account_id/d1/database/db-1/time_travel/bookmark?",
+		"GET /client/v4/accounts/account_id/d1/database/db-1/time_travel/bookmark?timestamp=2024-05-01T10%3A00%3A00Z",
+		"POST /client/v4/accounts/account_id/d1/database/db-1/time_travel/restore?timestamp=2024-05-01T10%3A00%3A00Z",
+		"POST /client/v4/accounts/account_id/d1/database/db-1/time_travel/restore?bookmark=00000085-0000024c-00004c6d-8e61117bf38d7adb71b934ebbf891683",
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("request %d = %s, want %s", i, requests[i], want[i])
+		}
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, errorResponse(7500, "Bookmark is outside the Time Travel window"))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	if _, err := client.RestoreToBookmark("bm"); err == nil || !strings.Contains(err.Error(), "no database connected") {
+		t.Errorf("err = %v, want the missing connection", err)
+	}
+
+	client.DatabaseID = "db-1"
+	if _, err := client.RestoreToBookmark(""); err == nil {
+		t.Error("restoring to an empty bookmark succeeded")
+	}
+	if _, err := client.RestoreToTimestamp(time.Now().AddDate(0, -2, 0)); err == nil || !strings.Contains(err.Error(), "outside the Time Travel window") {
+		t.Errorf("err = %v, want the API error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.TimeTravelInfoContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
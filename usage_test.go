@@ -0,0 +1,134 @@
+package cloudflared1_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveMetered answers every statement with an empty result set that read
+// 3 rows and wrote 1.
+func serveMetered(t *testing.T) {
+	t.Helper()
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Batch []json.RawMessage `json:"batch"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		n := len(body.Batch)
+		if n == 0 {
+			n = 1
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = queryResult([]string{"id"}, nil, map[string]interface{}{"rows_read": 3, "rows_written": 1})
+		}
+		writeJSON(w, successResponse(items...))
+	})
+}
+
+func TestClientUsage(t *testing.T) {
+	serveMetered(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	_, _ = client.Query("SELECT id FROM users", nil)
+	_, _ = client.Batch([]utils.Statement{{SQL: "SELECT 1"}, {SQL: "SELECT 2"}})
+
+	usage := client.Usage()
+	if usage.Queries != 3 || usage.RowsRead != 9 || usage.RowsWritten != 3 {
+		t.Errorf("usage = %+v, want 3 queries, 9 rows read, 3 rows written", usage)
+	}
+	if usage.BytesSent == 0 || usage.BytesReceived == 0 {
+		t.Errorf("usage = %+v, want bytes in both directions", usage)
+	}
+
+	if before := client.ResetUsage(); before != usage {
+		t.Errorf("ResetUsage = %+v, want %+v", before, usage)
+	}
+	if after := client.Usage(); after != (cloudflare_d1_go.Usage{}) {
+		t.Errorf("usage after reset = %+v, want zero", after)
+	}
+}
+
+func TestUsageIncludesMigrations(t *testing.T) {
+	serveMetered(t)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE a (id INTEGER)"),
+	}}
+	if _, err := migrations.Exec(client, source, migrations.Up); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+
+	// CREATE TABLE for the migrations table, SELECT applied IDs,
+	// then the migration and its bookkeeping INSERT in one batch
+	if usage := client.Usage(); usage.Queries != 4 || usage.RowsRead != 12 {
+		t.Errorf("usage = %+v, want 4 queries and 12 rows read", usage)
+	}
+}
+
+func TestPoolUsage(t *testing.T) {
+	serveMetered(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+	_ = pool.ConnectWithID("other", "db-2")
+
+	_, _ = pool.Query("SELECT 1", nil)
+	_, _ = pool.QueryDB("main", "SELECT 1", nil)
+	db, _ := pool.DB("other")
+	_, _ = db.Query("SELECT 1", nil)
+
+	if usage := pool.Usage(); usage.Queries != 3 || usage.RowsWritten != 3 {
+		t.Errorf("pool usage = %+v, want 3 queries and 3 rows written", usage)
+	}
+	client, _ := db.Client()
+	if usage := client.Usage(); usage != pool.Usage() {
+		t.Errorf("client from DB reports %+v, want the pool's %+v", usage, pool.Usage())
+	}
+}
+
+func TestReportUsage(t *testing.T) {
+	serveMetered(t)
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	reports := make(chan cloudflare_d1_go.Usage, 100)
+	pool.ReportUsage(5*time.Millisecond, func(u cloudflare_d1_go.Usage) { reports <- u })
+
+	_, _ = pool.Query("SELECT 1", nil)
+	select {
+	case u := <-reports:
+		if u.Queries > 1 {
+			t.Errorf("report = %+v, want at most 1 query", u)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no periodic report")
+	}
+
+	// Close stops the reporter with a final report
+	_, _ = pool.Query("SELECT 1", nil)
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	var last cloudflare_d1_go.Usage
+	for len(reports) > 0 {
+		last = <-reports
+	}
+	if last.Queries != 2 {
+		t.Errorf("final report = %+v, want 2 queries", last)
+	}
+}
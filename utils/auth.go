@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// AuthProvider adds the credentials of a client to each request
+type AuthProvider interface {
+	Apply(req *http.Request) error
+}
+
+// BearerToken authenticates with an API token, like the token passed to
+// cloudflared1.New
+type BearerToken string
+
+// Apply sets the Authorization header
+func (t BearerToken) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// APIKey authenticates with a legacy global API key and the email address
+// of its account
+type APIKey struct {
+	Key   string
+	Email string
+}
+
+// Apply sets the X-Auth-Key and X-Auth-Email headers
+func (k APIKey) Apply(req *http.Request) error {
+	req.Header.Set("X-Auth-Key", k.Key)
+	req.Header.Set("X-Auth-Email", k.Email)
+	return nil
+}
+
+// TokenFunc authenticates with an API token it returns for each request,
+// retries included, so short-lived tokens from a secret store can be
+// rotated without recreating the client. It receives the context of the
+// request and should cache the token itself.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Apply calls f and sets the Authorization header
+func (f TokenFunc) Apply(req *http.Request) error {
+	token, err := f(req.Context())
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("token func returned an empty token")
+	}
+	return BearerToken(token).Apply(req)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "fmt"
+
+// Statement is a single SQL statement together with its parameters.
+// A slice of statements can be sent to D1 in one request with Client.Batch.
+type Statement struct {
+	SQL    string
+	Params []interface{}
+}
+
+// BatchResult holds the outcome of one statement of a batch.
+// Rows is empty for statements that do not return rows.
+type BatchResult struct {
+	Rows   *Rows
+	Result *Result
+}
+
+// BatchError reports which statement of a batch failed.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch statement %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of sending a request while a
+// CircuitBreaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets one probe request through, after the cool-down
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker fails requests fast while the API is failing. After
+// failures consecutive requests that failed at the transport level, such as
+// timeouts and refused connections, or with a 5xx response, it opens and
+// requests fail with ErrCircuitOpen without being sent. Once coolDown has
+// passed, one probe request is let through: if it succeeds the breaker
+// closes, otherwise it opens for another coolDown.
+//
+// Responses carrying SQL errors, such as syntax errors or constraint
+// violations, show the API is up and count as successes. A CircuitBreaker
+// is safe for concurrent use; set the same one on several clients to share
+// it between them.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker that opens after
+// failures consecutive failures, for coolDown. failures below 1 is 1.
+func NewCircuitBreaker(failures int, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: max(failures, 1), coolDown: coolDown}
+}
+
+// State returns the current state. An open breaker whose cool-down has
+// passed is reported half-open.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.coolDown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow returns ErrCircuitOpen if a request may not be sent now. Every
+// allowed request must be followed by success, failure or abandon.
+func (b *CircuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.coolDown {
+			return ErrCircuitOpen
+		}
+		b.state, b.probing = CircuitHalfOpen, true
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// success records a request the API answered
+func (b *CircuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != CircuitOpen {
+		b.state, b.failures, b.probing = CircuitClosed, 0, false
+	}
+}
+
+// failure records a request that failed at the transport level or with a
+// 5xx response
+func (b *CircuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitClosed:
+		b.failures++
+		if b.failures < b.threshold {
+			return
+		}
+	case CircuitOpen:
+		return
+	}
+	b.state, b.openedAt, b.probing = CircuitOpen, time.Now(), false
+}
+
+// abandon records a request whose outcome says nothing about the API, such
+// as one cancelled by its caller
+func (b *CircuitBreaker) abandon() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ColumnType describes a column of a result, with the methods of
+// sql.ColumnType that apply to D1
+type ColumnType struct {
+	name         string
+	databaseType string
+	nullable     bool
+	nullableOK   bool
+	scanType     reflect.Type
+}
+
+// Name returns the name of the column
+func (c *ColumnType) Name() string {
+	return c.name
+}
+
+// DatabaseTypeName returns the upper cased declared type of the column, such
+// as "INTEGER" or "VARCHAR(20)", if declared with Rows.SetDeclaredColumns.
+// Otherwise it is inferred from the values: "INTEGER", "REAL", "TEXT" or
+// "BLOB", or "" if the column holds only NULL or values of several types.
+func (c *ColumnType) DatabaseTypeName() string {
+	return c.databaseType
+}
+
+// Nullable reports whether the column may be NULL. ok is false if that is
+// unknown: the column was not declared and none of its values is NULL.
+func (c *ColumnType) Nullable() (nullable, ok bool) {
+	return c.nullable, c.nullableOK
+}
+
+// ScanType returns a Go type the column scans into: int64, float64, string,
+// []byte, bool or time.Time, or interface{} if the type is unknown
+func (c *ColumnType) ScanType() reflect.Type {
+	return c.scanType
+}
+
+// DecimalSize always reports ok false: SQLite does not enforce a precision
+// or scale, so D1 reports none
+func (c *ColumnType) DecimalSize() (precision, scale int64, ok bool) {
+	return 0, 0, false
+}
+
+// Length always reports ok false, SQLite does not enforce lengths
+func (c *ColumnType) Length() (length int64, ok bool) {
+	return 0, false
+}
+
+// DeclaredColumn is the declared type of a column, as PRAGMA table_info
+// reports it
+type DeclaredColumn struct {
+	Type    string
+	NotNull bool
+}
+
+// SetDeclaredColumns sets the declared types ColumnTypes reports, by column
+// name. Columns missing from declared, such as expressions, have their type
+// inferred from their values.
+func (r *Rows) SetDeclaredColumns(declared map[string]DeclaredColumn) {
+	r.declared = declared
+}
+
+var (
+	anyType   = reflect.TypeOf((*interface{})(nil)).Elem()
+	int64Type = reflect.TypeOf(int64(0))
+)
+
+// ColumnTypes returns the types of the columns. D1 responses carry no type
+// information, so each type is either declared with SetDeclaredColumns or
+// inferred from the non-NULL values of the column in all rows.
+func (r *Rows) ColumnTypes() ([]*ColumnType, error) {
+	types := make([]*ColumnType, len(r.columns))
+	for i, col := range r.columns {
+		if decl, ok := r.declared[col]; ok {
+			dbType := strings.ToUpper(strings.TrimSpace(decl.Type))
+			types[i] = &ColumnType{name: col, databaseType: dbType, nullable: !decl.NotNull, nullableOK: true, scanType: affinityType(dbType)}
+			continue
+		}
+		types[i] = r.inferColumnType(col)
+	}
+	return types, nil
+}
+
+// inferColumnType infers the type of column col from its values
+func (r *Rows) inferColumnType(col string) *ColumnType {
+	ct := &ColumnType{name: col, scanType: anyType}
+	for _, row := range r.rows {
+		var dbType string
+		var scanType reflect.Type
+		switch v := row[col].(type) {
+		case nil:
+			ct.nullable, ct.nullableOK = true, true
+			continue
+		case float64:
+			dbType, scanType = "INTEGER", int64Type
+			if v != math.Trunc(v) {
+				dbType, scanType = "REAL", reflect.TypeOf(float64(0))
+			}
+		case string:
+			dbType, scanType = "TEXT", reflect.TypeOf("")
+		case []interface{}:
+			// D1 returns BLOB columns as arrays of bytes
+			dbType, scanType = "BLOB", reflect.TypeOf([]byte(nil))
+		case bool:
+			dbType, scanType = "BOOLEAN", reflect.TypeOf(false)
+		default:
+			return &ColumnType{name: col, scanType: anyType}
+		}
+
+		switch {
+		case ct.databaseType == "":
+			ct.databaseType, ct.scanType = dbType, scanType
+		case ct.databaseType == "INTEGER" && dbType == "REAL":
+			ct.databaseType, ct.scanType = dbType, scanType
+		case ct.databaseType == "REAL" && dbType == "INTEGER":
+		case ct.databaseType != dbType:
+			// SQLite columns may hold values of several types
+			return &ColumnType{name: col, scanType: anyType, nullable: ct.nullable, nullableOK: ct.nullableOK}
+		}
+	}
+	return ct
+}
+
+// affinityType returns the Go type of a declared SQLite column type, by the
+// type affinity rules of SQLite, with the common date and boolean types
+// scanned into time.Time and bool
+func affinityType(dbType string) reflect.Type {
+	switch {
+	case strings.Contains(dbType, "INT"):
+		return int64Type
+	case strings.Contains(dbType, "CHAR"), strings.Contains(dbType, "CLOB"), strings.Contains(dbType, "TEXT"):
+		return reflect.TypeOf("")
+	case dbType == "":
+		return anyType
+	case strings.Contains(dbType, "BLOB"):
+		return reflect.TypeOf([]byte(nil))
+	case strings.Contains(dbType, "REAL"), strings.Contains(dbType, "FLOA"), strings.Contains(dbType, "DOUB"):
+		return reflect.TypeOf(float64(0))
+	case strings.HasPrefix(dbType, "BOOL"):
+		return reflect.TypeOf(false)
+	case strings.HasPrefix(dbType, "DATE"), strings.HasPrefix(dbType, "TIMESTAMP"):
+		return reflect.TypeOf(time.Time{})
+	}
+	// NUMERIC affinity
+	return reflect.TypeOf(float64(0))
+}
@@ -1,72 +1,125 @@
-package utils
-
-import (
-	"encoding/json"
-	"fmt"
-	"time"
-)
-
-// ConvertParams converts variadic parameters to string array for D1 API
-// Supports basic types (int, float, bool, string), time.Time, and JSON serialization
-func ConvertParams(args ...interface{}) ([]string, error) {
-	if len(args) == 0 {
-		return []string{}, nil
-	}
-
-	result := make([]string, len(args))
-
-	for i, arg := range args {
-		if arg == nil {
-			result[i] = ""
-			continue
-		}
-
-		switch v := arg.(type) {
-		case string:
-			result[i] = v
-		case int:
-			result[i] = fmt.Sprintf("%d", v)
-		case int8:
-			result[i] = fmt.Sprintf("%d", v)
-		case int16:
-			result[i] = fmt.Sprintf("%d", v)
-		case int32:
-			result[i] = fmt.Sprintf("%d", v)
-		case int64:
-			result[i] = fmt.Sprintf("%d", v)
-		case uint:
-			result[i] = fmt.Sprintf("%d", v)
-		case uint8:
-			result[i] = fmt.Sprintf("%d", v)
-		case uint16:
-			result[i] = fmt.Sprintf("%d", v)
-		case uint32:
-			result[i] = fmt.Sprintf("%d", v)
-		case uint64:
-			result[i] = fmt.Sprintf("%d", v)
-		case float32:
-			result[i] = fmt.Sprintf("%v", v)
-		case float64:
-			result[i] = fmt.Sprintf("%v", v)
-		case bool:
-			if v {
-				result[i] = "1"
-			} else {
-				result[i] = "0"
-			}
-		case time.Time:
-			result[i] = v.Format("2006-01-02 15:04:05")
-		case []byte:
-			result[i] = string(v)
-		default:
-			// Complex types use JSON serialization
-			b, err := json.Marshal(arg)
-			if err != nil {
-				return nil, fmt.Errorf("无法转换参数 #%d (类型:%T): %v", i, arg, err)
-			}
-			result[i] = string(b)
-		}
-	}
-
-	return result, nil
-}
+package utils
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Null is the parameter value for SQL NULL in the string parameters of
+// Client.Query. D1 binds every string parameter as text, so the client
+// sends an empty string and compares it to one with nullif, which yields
+// NULL; see BindNulls.
+const Null = "\x00NULL\x00"
+
+// ConvertParams converts variadic parameters to string array for D1 API
+// Supports basic types (int, float, bool, string), time.Time, and JSON serialization.
+// A driver.Valuer is converted by the value it returns. []byte is passed as
+// text; BindParams sends it as a BLOB instead. Pointers of any depth are
+// converted by the value they point to. nil, nil pointers and Valuers
+// returning nil, such as an invalid sql.NullString, become Null.
+func ConvertParams(args ...interface{}) ([]string, error) {
+	if len(args) == 0 {
+		return []string{}, nil
+	}
+
+	result := make([]string, len(args))
+
+	for i, arg := range args {
+		arg, err := paramValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("无法转换参数 #%d (类型:%T): %v", i, args[i], err)
+		}
+		if arg == nil {
+			result[i] = Null
+			continue
+		}
+
+		switch v := arg.(type) {
+		case string:
+			result[i] = v
+		case int:
+			result[i] = fmt.Sprintf("%d", v)
+		case int8:
+			result[i] = fmt.Sprintf("%d", v)
+		case int16:
+			result[i] = fmt.Sprintf("%d", v)
+		case int32:
+			result[i] = fmt.Sprintf("%d", v)
+		case int64:
+			result[i] = fmt.Sprintf("%d", v)
+		case uint:
+			result[i] = fmt.Sprintf("%d", v)
+		case uint8:
+			result[i] = fmt.Sprintf("%d", v)
+		case uint16:
+			result[i] = fmt.Sprintf("%d", v)
+		case uint32:
+			result[i] = fmt.Sprintf("%d", v)
+		case uint64:
+			result[i] = fmt.Sprintf("%d", v)
+		case float32:
+			result[i] = fmt.Sprintf("%v", v)
+		case float64:
+			result[i] = fmt.Sprintf("%v", v)
+		case bool:
+			if v {
+				result[i] = "1"
+			} else {
+				result[i] = "0"
+			}
+		case time.Time:
+			result[i] = FormatTime(v)
+		case []byte:
+			result[i] = string(v)
+		default:
+			// Complex types use JSON serialization
+			b, err := json.Marshal(arg)
+			if err != nil {
+				return nil, fmt.Errorf("无法转换参数 #%d (类型:%T): %v", i, arg, err)
+			}
+			result[i] = string(b)
+		}
+	}
+
+	return result, nil
+}
+
+// DriverValue returns the value of v if it implements driver.Valuer, as
+// database/sql does before handing arguments to a driver, and v otherwise.
+// A nil pointer whose Value method has a value receiver is nil.
+func DriverValue(v interface{}) (interface{}, error) {
+	valuer, ok := v.(driver.Valuer)
+	if !ok {
+		return v, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() &&
+		rv.Type().Elem().Implements(valuerType) {
+		return nil, nil
+	}
+	return valuer.Value()
+}
+
+// paramValue returns the value sent for a parameter: the value of a
+// driver.Valuer, with pointers of any depth dereferenced. A nil pointer is
+// nil.
+func paramValue(arg interface{}) (interface{}, error) {
+	for {
+		v, err := DriverValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr {
+			return v, nil
+		}
+		if rv.IsNil() {
+			return nil, nil
+		}
+		arg = rv.Elem().Interface()
+	}
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrNoRows is returned by Get when the query returned no rows.
+// It is the same value as database/sql.ErrNoRows, so errors.Is matches either.
+var ErrNoRows = sql.ErrNoRows
+
+// ParamCountError is returned before sending a query whose placeholders do
+// not match the number of parameters, see Client.SetCheckParamCount
+type ParamCountError struct {
+	Placeholders int
+	Params       int
+}
+
+func (e *ParamCountError) Error() string {
+	return fmt.Sprintf("query has %d placeholders but %d parameters were provided", e.Placeholders, e.Params)
+}
+
+// UnmatchedError is returned by StructScan of Rows set to strict, see
+// Rows.SetStrict, when columns and struct fields do not match up
+type UnmatchedError struct {
+	// Type is the struct scanned into
+	Type reflect.Type
+	// Columns are the result columns that match no field
+	Columns []string
+	// Fields are the names of the struct fields that match no column
+	Fields []string
+}
+
+func (e *UnmatchedError) Error() string {
+	var parts []string
+	if len(e.Columns) > 0 {
+		parts = append(parts, fmt.Sprintf("columns without a field: %s", strings.Join(e.Columns, ", ")))
+	}
+	if len(e.Fields) > 0 {
+		parts = append(parts, fmt.Sprintf("fields without a column: %s", strings.Join(e.Fields, ", ")))
+	}
+	return fmt.Sprintf("sql: strict scan into %s: %s", e.Type, strings.Join(parts, "; "))
+}
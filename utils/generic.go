@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ScanAll scans the remaining rows into a slice of T.
+// T is a struct or a pointer to a struct, scanned with StructScan,
+// map[string]interface{}, scanned with MapScan, or any other type,
+// for which the rows must have exactly one column.
+func ScanAll[T any](r *Rows) ([]T, error) {
+	result := make([]T, 0, r.remaining())
+	for r.Next() {
+		var v T
+		if err := scanInto(r, &v); err != nil {
+			return nil, fmt.Errorf("scan failed at index %d: %w", len(result), err)
+		}
+		result = append(result, v)
+	}
+	return result, r.Err()
+}
+
+// ScanOne scans the first row into a T, see ScanAll.
+// It returns ErrNoRows if there are no rows.
+func ScanOne[T any](r *Rows) (T, error) {
+	var v T
+	if !r.Next() {
+		return v, ErrNoRows
+	}
+	err := scanInto(r, &v)
+	return v, err
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// scanInto scans the current row into dest, which points to a T of ScanAll
+func scanInto(r *Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest).Elem()
+	t := v.Type()
+
+	if t.Kind() == reflect.Ptr && isStructTarget(t.Elem()) {
+		elem := reflect.New(t.Elem())
+		if err := r.StructScan(elem.Interface()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if isStructTarget(t) {
+		return r.StructScan(dest)
+	}
+	if t == mapType {
+		m := make(map[string]interface{}, len(r.columns))
+		v.Set(reflect.ValueOf(m))
+		return r.MapScan(m)
+	}
+
+	if len(r.columns) != 1 {
+		return fmt.Errorf("scanning into %s needs exactly one column, got %d", t, len(r.columns))
+	}
+	return r.Scan(dest)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isStructTarget reports whether t is scanned field by field rather than as a single value
+func isStructTarget(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// ScanInto scans the current row into dest, a pointer to any type ScanAll
+// accepts: a struct or struct pointer, scanned with StructScan, a
+// map[string]interface{}, or a single value such as an int64 or a string,
+// for which the row must have exactly one column.
+func (r *Rows) ScanInto(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("dest must be a non-nil pointer, got %T", dest)
+	}
+	if r.current < 0 || r.current >= len(r.rows) {
+		return errors.New("sql: Rows is closed")
+	}
+	return scanInto(r, dest)
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// modulePath is the path this module is imported by
+const modulePath = "github.com/youfun/cloudflare-d1-go"
+
+// UserAgent returns the User-Agent header sent with every request,
+// "cloudflare-d1-go/" followed by the version of this module the program
+// was built with, so Cloudflare support can identify the traffic.
+// ClientOptions.Headers may replace it.
+func UserAgent() string {
+	return userAgent()
+}
+
+var userAgent = sync.OnceValue(func() string {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath && info.Main.Version != "" {
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				if dep.Replace != nil && dep.Replace.Version != "" {
+					version = dep.Replace.Version
+				}
+			}
+		}
+	}
+	return "cloudflare-d1-go/" + version
+})
+
+type headerKey struct{}
+
+// WithHeader returns a context that adds a header to the requests made
+// with it, e.g. an audit tag for one call. Headers added by several calls
+// accumulate.
+func WithHeader(ctx context.Context, name, value string) context.Context {
+	header := contextHeader(ctx).Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Add(name, value)
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+// contextHeader returns the headers added to ctx with WithHeader
+func contextHeader(ctx context.Context) http.Header {
+	header, _ := ctx.Value(headerKey{}).(http.Header)
+	return header
+}
+
+// setHeaders sets the headers of a request: the defaults and credentials,
+// then those of the requester and those of the context, which replace
+// defaults of the same name. The credentials are those of q.Auth, or
+// apiToken if it is nil.
+func (q Requester) setHeaders(ctx context.Context, req *http.Request, apiToken string) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent())
+	var auth AuthProvider = BearerToken(apiToken)
+	if q.Auth != nil {
+		auth = q.Auth
+	}
+	if err := auth.Apply(req); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	for _, header := range []http.Header{q.Header, contextHeader(ctx)} {
+		for name, values := range header {
+			req.Header.Del(name)
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// QuoteIdentifier quotes a table, column or index name for use in SQL text,
+// e.g. where SQLite does not accept bound parameters such as in PRAGMA or DDL.
+// The name is wrapped in double quotes with embedded double quotes doubled,
+// so it can never end the identifier early. Empty names and names containing
+// control characters are rejected.
+func QuoteIdentifier(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("identifier must not be empty")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("identifier %q contains a control character", name)
+		}
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`, nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Iter returns an iterator over the remaining rows, yielding the index of
+// each row and its values as MapScan stores them:
+//
+//	for i, row := range rows.Iter() {
+//		fmt.Println(i, row["name"])
+//	}
+//
+// The rows are closed when the loop ends, also when it ends early.
+func (r *Rows) Iter() iter.Seq2[int, map[string]interface{}] {
+	return func(yield func(int, map[string]interface{}) bool) {
+		defer r.Close()
+		for i := 0; r.Next(); i++ {
+			row := make(map[string]interface{}, len(r.columns))
+			_ = r.MapScan(row)
+			if !yield(i, row) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate returns an iterator over the remaining rows of r, each scanned
+// into a T as ScanAll does:
+//
+//	for user, err := range utils.Iterate[User](rows) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(user.Name)
+//	}
+//
+// A row that fails to scan is yielded as the zero T with the error, and
+// ends the iteration. The rows are closed when the loop ends, also when it
+// ends early.
+func Iterate[T any](r *Rows) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer r.Close()
+		for i := 0; r.Next(); i++ {
+			var v T
+			if err := scanInto(r, &v); err != nil {
+				var zero T
+				yield(zero, fmt.Errorf("scan failed at index %d: %w", i, err))
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := r.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// NameMapper maps a struct field name to a column name.
+// It is consulted for every field that has no db tag, by scanning and by any
+// helper that derives column names from a struct.
+type NameMapper func(fieldName string) string
+
+// SnakeCase maps Go field names to snake_case column names,
+// e.g. "Name" to "name", "UserID" to "user_id" and "CreatedAt" to "created_at".
+func SnakeCase(fieldName string) string {
+	runes := []rune(fieldName)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word at a lower→upper transition, or at the last
+			// upper case letter of an acronym followed by a lower case letter.
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// LowerCase maps Go field names to lower case without separators, e.g. "UserID" to "userid".
+func LowerCase(fieldName string) string {
+	return strings.ToLower(fieldName)
+}
+
+// DefaultMapper is the NameMapper used when none is configured.
+// When scanning with the default mapper, a column named after the lower cased
+// field name ("userid") is still accepted if no snake_case column ("user_id")
+// exists, which keeps structs written for earlier versions working.
+var DefaultMapper NameMapper = SnakeCase
+
+// FieldColumn links a struct field to the column it maps to.
+type FieldColumn struct {
+	// Column is the db tag of the field, or the mapped field name if it has no tag
+	Column string
+	// Index is the index path of the field, for use with reflect.Value.FieldByIndex
+	Index []int
+	// Prefix is the db tag path of the nested struct the field belongs to,
+	// e.g. "u" for the fields of a struct field tagged `db:"u"`, and empty
+	// otherwise. Column starts with it: "u.name".
+	Prefix string
+	// Tagged reports whether Column comes from a db tag
+	Tagged bool
+	// OmitEmpty reports whether the db tag has the omitempty option, e.g.
+	// `db:"id,omitempty"`: a zero field is left out of generated statements
+	// such as those of InsertStruct, so the database fills in the value
+	OmitEmpty bool
+	// JSON reports whether the db tag has the json option, e.g.
+	// `db:"metadata,json"`: the field is stored as JSON text, see JSONValue
+	// and ScanJSON
+	JSON  bool
+	Field reflect.StructField
+}
+
+// StructColumns returns the column mapping of the exported fields of struct type t.
+// Fields tagged `db:"-"` are left out. A nil mapper means DefaultMapper.
+//
+// The fields of an embedded struct without a db tag are promoted, as in Go.
+// A struct field tagged with a name, such as `db:"u"`, is a prefix: its
+// fields map to the columns "u.name" or "u_name", which is how JOIN results
+// are usually aliased. Structs scanned as one value, such as time.Time,
+// sql.Scanner implementations and fields with the json option, are columns.
+//
+// When two fields map to the same column, the one nested least deeply wins,
+// so a field of t shadows a promoted one; at the same depth, the field
+// declared first wins.
+func StructColumns(t reflect.Type, mapper NameMapper) []FieldColumn {
+	if mapper == nil {
+		mapper = DefaultMapper
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := structFields(t)
+	columns := make([]FieldColumn, 0, len(fields))
+	depths := make([]int, 0, len(fields))
+	seen := make(map[string]int, len(fields))
+	for _, sf := range fields {
+		fc := sf.FieldColumn
+		if !fc.Tagged {
+			fc.Column = mapper(fc.Field.Name)
+		}
+		if fc.Prefix != "" {
+			fc.Column = fc.Prefix + "." + fc.Column
+		}
+
+		if j, ok := seen[fc.Column]; ok {
+			if depths[j] <= sf.depth {
+				continue
+			}
+			// Shadowed by a field nested less deeply, dropped below
+			columns[j].Column = ""
+		}
+		seen[fc.Column] = len(columns)
+		columns = append(columns, fc)
+		depths = append(depths, sf.depth)
+	}
+
+	kept := columns[:0]
+	for _, fc := range columns {
+		if fc.Column != "" {
+			kept = append(kept, fc)
+		}
+	}
+	return kept
+}
+
+// structField is a field found by structFields, before name mapping: Column
+// holds the db tag name, and is empty for untagged fields
+type structField struct {
+	FieldColumn
+	depth int
+}
+
+// structFieldCache holds the result of structFields by struct type, so tags
+// are parsed once per type rather than once per row scanned
+var structFieldCache sync.Map // reflect.Type → []structField
+
+// structFields returns the fields of struct type t that map to columns,
+// walking embedded and prefixed nested structs, in declaration order
+func structFields(t reflect.Type) []structField {
+	if cached, ok := structFieldCache.Load(t); ok {
+		return cached.([]structField)
+	}
+
+	var fields []structField
+	var walk func(t reflect.Type, index []int, prefix string, depth int)
+	walk = func(t reflect.Type, index []int, prefix string, depth int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("db")
+			if tag == "-" || !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+				// Only the fields of unexported embedded structs are settable
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			fieldIndex := append(append([]int(nil), index...), i)
+
+			fc := FieldColumn{Column: name, Index: fieldIndex, Tagged: name != "", Field: field, Prefix: prefix}
+			for _, option := range strings.Split(options, ",") {
+				fc.OmitEmpty = fc.OmitEmpty || option == "omitempty"
+				fc.JSON = fc.JSON || option == "json"
+			}
+
+			if ft := indirectType(field.Type); !fc.JSON && isStructTarget(ft) {
+				switch {
+				case field.Anonymous && name == "":
+					walk(ft, fieldIndex, prefix, depth+1)
+					continue
+				case name != "":
+					walk(ft, fieldIndex, joinPrefix(prefix, name), depth+1)
+					continue
+				}
+			}
+			if field.IsExported() {
+				fields = append(fields, structField{fc, depth})
+			}
+		}
+	}
+	walk(t, nil, "", 0)
+
+	cached, _ := structFieldCache.LoadOrStore(t, fields)
+	return cached.([]structField)
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func joinPrefix(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// FieldByIndex returns the field of struct v at index, allocating nil
+// embedded or nested struct pointers on the way. v must be settable.
+func FieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// JSONValue returns the value stored for a field with the json option: the
+// field marshaled to JSON text, or nil, stored as NULL, for a nil pointer,
+// map, slice or interface
+func JSONValue(field reflect.Value) (interface{}, error) {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if field.IsNil() {
+			return nil, nil
+		}
+	}
+	b, err := json.Marshal(field.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// ScanJSON unmarshals the JSON text src into field, which must be settable.
+// NULL sets the field to its zero value, nil for a pointer, map or slice.
+func ScanJSON(field reflect.Value, src interface{}) error {
+	field.Set(reflect.Zero(field.Type()))
+	var data []byte
+	switch s := src.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(s)
+	case []byte:
+		data = s
+	default:
+		return fmt.Errorf("cannot unmarshal %T as JSON, want text", src)
+	}
+	return json.Unmarshal(data, field.Addr().Interface())
+}
@@ -0,0 +1,57 @@
+package utils
+
+// Meta is the execution metadata D1 reports for a statement.
+// Fields D1 did not report are zero.
+type Meta struct {
+	Duration    float64 // execution time in milliseconds
+	RowsRead    int64   // rows scanned, billed as reads
+	RowsWritten int64   // rows written, billed as writes
+	SizeAfter   int64   // size of the database in bytes after the statement
+	ChangedDB   bool
+	// ServedByRegion and ServedByPrimary tell which instance answered, and
+	// are only reported by databases with read replication
+	ServedByRegion  string
+	ServedByPrimary bool
+	// RayID is the cf-ray header of the response, which Cloudflare support
+	// asks for
+	RayID string
+}
+
+// metaFromItem reads the "meta" object of a result item
+func metaFromItem(queryResult map[string]interface{}) Meta {
+	m, _ := queryResult["meta"].(map[string]interface{})
+	number := func(key string) float64 {
+		f, _ := m[key].(float64)
+		return f
+	}
+
+	meta := Meta{
+		Duration:    number("duration"),
+		RowsRead:    int64(number("rows_read")),
+		RowsWritten: int64(number("rows_written")),
+		SizeAfter:   int64(number("size_after")),
+	}
+	meta.ChangedDB, _ = m["changed_db"].(bool)
+	meta.ServedByRegion, _ = m["served_by_region"].(string)
+	meta.ServedByPrimary, _ = m["served_by_primary"].(bool)
+	return meta
+}
+
+// Metas returns the metadata of every result set in the response, one per
+// statement, including statements that failed. It is empty for responses
+// without query results, such as database management calls.
+func (r *APIResponse) Metas() []Meta {
+	results, ok := r.Result.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	metas := make([]Meta, 0, len(results))
+	for _, item := range results {
+		queryResult, _ := item.(map[string]interface{})
+		meta := metaFromItem(queryResult)
+		meta.RayID = r.RayID()
+		metas = append(metas, meta)
+	}
+	return metas
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BindNamed rewrites :name placeholders in query to positional ? markers and
+// returns the values to bind, in placeholder order. arg is a struct (or a
+// pointer to one), whose fields are matched by db tag or DefaultMapper, or a
+// map[string]interface{}.
+//
+// Example:
+//
+//	query, args, err := BindNamed("INSERT INTO users (name, age) VALUES (:name, :age)", user)
+//	// query: INSERT INTO users (name, age) VALUES (?, ?)
+//	// args:  []interface{}{user.Name, user.Age}
+//
+// Placeholders inside string literals, quoted identifiers and comments are left
+// alone, and :: casts are not treated as placeholders.
+func BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return BindNamedMapper(query, arg, nil)
+}
+
+// BindNamedMapper is BindNamed with the mapper used for untagged struct fields.
+// A nil mapper means DefaultMapper.
+func BindNamedMapper(query string, arg interface{}, mapper NameMapper) (string, []interface{}, error) {
+	rewritten, names := compileNamed(query)
+
+	lookup, err := namedLookup(arg, mapper)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		val, ok := lookup(name)
+		if !ok {
+			return "", nil, fmt.Errorf("could not find name %s in %T", name, arg)
+		}
+		args[i] = val
+	}
+	return rewritten, args, nil
+}
+
+// compileNamed replaces every :name placeholder with ? and returns the names in order.
+func compileNamed(query string) (string, []string) {
+	var b strings.Builder
+	var names []string
+
+	for i := 0; i < len(query); {
+		if j := SkipLiteral(query, i); j > i {
+			b.WriteString(query[i:j])
+			i = j
+			continue
+		}
+
+		c := query[i]
+		switch {
+		case c == ':' && strings.HasPrefix(query[i:], "::"):
+			b.WriteString("::")
+			i += 2
+		case c == ':' && i+1 < len(query) && isNameByte(query[i+1]):
+			j := i + 1
+			for j < len(query) && isNameByte(query[j]) {
+				j++
+			}
+			names = append(names, query[i+1:j])
+			b.WriteByte('?')
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), names
+}
+
+// SkipLiteral returns the index after the string literal, quoted identifier
+// or comment starting at query[i], or i if none starts there. Identifiers
+// may be quoted with double quotes, backquotes or brackets like in SQLite.
+// A doubled quote is an escaped quote and simply reopens the literal on the
+// next call. An unterminated one extends to the end of the query.
+func SkipLiteral(query string, i int) int {
+	c := query[i]
+	switch {
+	case c == '\'' || c == '"' || c == '`' || c == '[':
+		closing := c
+		if c == '[' {
+			closing = ']'
+		}
+		end := strings.IndexByte(query[i+1:], closing)
+		if end < 0 {
+			return len(query)
+		}
+		return i + end + 2
+	case c == '-' && strings.HasPrefix(query[i:], "--"):
+		end := strings.IndexByte(query[i:], '\n')
+		if end < 0 {
+			return len(query)
+		}
+		return i + end
+	case c == '/' && strings.HasPrefix(query[i:], "/*"):
+		end := strings.Index(query[i+2:], "*/")
+		if end < 0 {
+			return len(query)
+		}
+		return i + end + 4
+	}
+	return i
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// namedLookup returns a function resolving placeholder names against arg.
+func namedLookup(arg interface{}, mapper NameMapper) (func(string) (interface{}, bool), error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			val, ok := m[name]
+			return val, ok
+		}, nil
+	}
+
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("named argument must not be nil")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("named argument must be a struct or map[string]interface{}, got %T", arg)
+	}
+
+	fields := make(map[string]FieldColumn)
+	legacy := make(map[string]FieldColumn)
+	for _, fc := range StructColumns(v.Type(), mapper) {
+		fields[fc.Column] = fc
+		if !fc.Tagged && fc.Prefix == "" && mapper == nil {
+			// Match StructScan, which accepts the lower cased field name as well
+			legacy[LowerCase(fc.Field.Name)] = fc
+		}
+	}
+
+	return func(name string) (interface{}, bool) {
+		fc, ok := fields[name]
+		if !ok {
+			fc, ok = legacy[name]
+		}
+		if !ok {
+			return nil, false
+		}
+		field, err := v.FieldByIndexErr(fc.Index)
+		if err != nil {
+			// A field of a nil embedded struct pointer
+			return nil, true
+		}
+		if fc.JSON {
+			// As ConvertParams would marshal it, but nil rather than "null" for
+			// nil maps and slices. Errors are left to ConvertParams to report.
+			if value, err := JSONValue(field); err == nil {
+				return value, true
+			}
+		}
+		return field.Interface(), true
+	}, nil
+}
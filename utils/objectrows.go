@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DoObjectRowsRequestCounted is DoRequestCounted for endpoints that return
+// rows as JSON objects, such as the /query endpoint of D1. The rows are
+// converted to the columns and rows shape of the /raw endpoint, with the
+// columns in the order of the response, so ToRows, ResultSet and Scan work
+// as they do for /raw responses.
+func DoObjectRowsRequestCounted(ctx context.Context, method, url, payload, apiToken string) (*APIResponse, int64, error) {
+	return Requester{}.DoObjectRowsCounted(ctx, method, url, payload, apiToken)
+}
+
+// DoObjectRowsCounted is DoObjectRowsRequestCounted with the settings of q
+func (q Requester) DoObjectRowsCounted(ctx context.Context, method, url, payload, apiToken string) (*APIResponse, int64, error) {
+	var res struct {
+		APIResponse
+		// Shadows APIResponse.Result, so the rows are decoded in order
+		Result []objectRowsItem `json:"result"`
+	}
+	n, err := q.do(ctx, method, url, payload, apiToken, &res)
+	if err != nil {
+		return nil, n, err
+	}
+
+	apiRes := res.APIResponse
+	if res.Result != nil {
+		items := make([]interface{}, len(res.Result))
+		for i, item := range res.Result {
+			items[i] = item.rawItem()
+		}
+		apiRes.Result = items
+	}
+	return &apiRes, n, nil
+}
+
+// objectRowsItem is a result item whose rows are objects
+type objectRowsItem struct {
+	Results objectRows             `json:"results"`
+	Meta    map[string]interface{} `json:"meta"`
+	Success bool                   `json:"success"`
+}
+
+// rawItem returns the item in the shape of a /raw result item
+func (item objectRowsItem) rawItem() map[string]interface{} {
+	columns := make([]interface{}, len(item.Results.columns))
+	for i, c := range item.Results.columns {
+		columns[i] = c
+	}
+	rows := make([]interface{}, len(item.Results.rows))
+	for i, r := range item.Results.rows {
+		rows[i] = r
+	}
+	return map[string]interface{}{
+		"results": map[string]interface{}{"columns": columns, "rows": rows},
+		"meta":    item.Meta,
+		"success": item.Success,
+	}
+}
+
+// objectRows is an array of row objects decoded in column order.
+// Decoding into maps would lose the order of the keys, so the columns are
+// taken from the keys of the first row, as they appear in the response.
+type objectRows struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+func (o *objectRows) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("results must be an array of rows, got %v", tok)
+	}
+
+	index := map[string]int{}
+	for dec.More() {
+		if tok, err := dec.Token(); err != nil {
+			return err
+		} else if tok != json.Delim('{') {
+			return fmt.Errorf("row %d must be an object, got %v", len(o.rows), tok)
+		}
+
+		first := len(o.rows) == 0
+		row := make([]interface{}, len(o.columns))
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+
+			i, ok := index[key]
+			switch {
+			case ok:
+				row[i] = value
+			case first:
+				index[key] = len(o.columns)
+				o.columns = append(o.columns, key)
+				row = append(row, value)
+			default:
+				return fmt.Errorf("row %d has column %q, which the first row does not have", len(o.rows), key)
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		o.rows = append(o.rows, row)
+	}
+	_, err = dec.Token()
+	return err
+}
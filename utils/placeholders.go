@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CountPlaceholders returns how many parameters query expects, following
+// SQLite's numbering: ? takes the number after the largest one used so far and
+// ?NNN takes NNN. Placeholders in string literals, quoted identifiers and
+// comments are ignored. Named placeholders (:name, @name, $name) are
+// rejected, since D1 binds parameters by position; see BindNamed.
+func CountPlaceholders(query string) (int, error) {
+	largest := 0
+	for i := 0; i < len(query); {
+		if j := SkipLiteral(query, i); j > i {
+			i = j
+			continue
+		}
+
+		c := query[i]
+		switch {
+		case c == '?':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				largest++
+			} else {
+				n, err := strconv.Atoi(query[i+1 : j])
+				if err != nil || n < 1 {
+					return 0, fmt.Errorf("invalid placeholder %s", query[i:j])
+				}
+				largest = max(largest, n)
+			}
+			i = j
+		case (c == ':' || c == '@' || c == '$') && i+1 < len(query) && isNameByte(query[i+1]) &&
+			(i == 0 || query[i-1] != ':'):
+			return 0, fmt.Errorf("named placeholder at offset %d is not supported, use ? or BindNamed", i)
+		default:
+			i++
+		}
+	}
+	return largest, nil
+}
+
+// BindParams converts args like ConvertParams and adapts query to values
+// that a text parameter cannot carry. A []byte argument, or a pointer to or
+// driver.Valuer returning one, is sent hex encoded and its placeholders are wrapped in
+// unhex(), so it is stored as a BLOB rather than as text. NULL arguments are
+// bound as BindNulls does. query is returned unchanged if no argument needs
+// this.
+func BindParams(query string, args []interface{}) (string, []string, error) {
+	params, err := ConvertParams(args...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var wrap map[int]string
+	for i, arg := range args {
+		v, err := paramValue(arg)
+		if err != nil {
+			return "", nil, err
+		}
+		if b, ok := v.([]byte); ok {
+			if wrap == nil {
+				wrap = make(map[int]string)
+			}
+			wrap[i+1] = "unhex(%s)"
+			params[i] = hex.EncodeToString(b)
+		}
+	}
+	if wrap != nil {
+		query = wrapPlaceholders(query, wrap)
+	}
+	query, params = BindNulls(query, params)
+	return query, params, nil
+}
+
+// BindNulls binds the Null parameters of a query as SQL NULL: they are sent
+// as empty strings and their placeholders are wrapped in nullif() with an
+// empty string literal. params is not modified; query and params are returned unchanged
+// if no parameter is Null.
+//
+// As in SQL, NULL never compares equal to anything, so `WHERE col = ?` with
+// a NULL parameter matches no rows; use `WHERE col IS ?` to match NULLs.
+func BindNulls(query string, params []string) (string, []string) {
+	var wrap map[int]string
+	for i, p := range params {
+		if p != Null {
+			continue
+		}
+		if wrap == nil {
+			wrap = make(map[int]string)
+			params = append([]string(nil), params...)
+		}
+		wrap[i+1] = "nullif(%s, '')"
+		params[i] = ""
+	}
+	if wrap == nil {
+		return query, params
+	}
+	return wrapPlaceholders(query, wrap), params
+}
+
+// wrapPlaceholders returns query with each placeholder whose parameter
+// number is in wrap replaced by the format given there, %s standing for the
+// placeholder. The placeholders keep their text, so the numbering of the
+// others is unchanged.
+func wrapPlaceholders(query string, wrap map[int]string) string {
+	var b strings.Builder
+	largest := 0
+	for i := 0; i < len(query); {
+		if j := SkipLiteral(query, i); j > i {
+			b.WriteString(query[i:j])
+			i = j
+			continue
+		}
+		if query[i] != '?' {
+			b.WriteByte(query[i])
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+		n := largest + 1
+		if j > i+1 {
+			n, _ = strconv.Atoi(query[i+1 : j])
+		}
+		largest = max(largest, n)
+		if format, ok := wrap[n]; ok {
+			fmt.Fprintf(&b, format, query[i:j])
+		} else {
+			b.WriteString(query[i:j])
+		}
+		i = j
+	}
+	return b.String()
+}
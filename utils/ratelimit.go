@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out requests with a token bucket: it allows bursts of
+// up to burst requests and refills at perSecond requests per second.
+// Cloudflare limits the API to 1200 requests per five minutes per account,
+// i.e. 4 per second. A RateLimiter is safe for concurrent use; set the same
+// one on several clients to share the budget between them.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing perSecond requests per
+// second with bursts of burst requests. A burst below 1 is 1. A perSecond of
+// 0 or less means no limit: NewRateLimiter returns nil, which never blocks.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if !(perSecond > 0) {
+		return nil
+	}
+	b := float64(max(burst, 1))
+	return &RateLimiter{rate: perSecond, burst: b, tokens: b, last: time.Now()}
+}
+
+// Wait blocks until a request may be sent, or returns the error of ctx if
+// it is done first. A nil RateLimiter never blocks.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	// Take a token now, going into debt if there is none, and wait until
+	// the debt is paid off
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	if err := sleep(ctx, wait); err != nil {
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
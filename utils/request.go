@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 )
 
 type APIResponse struct {
@@ -15,44 +19,429 @@ type APIResponse struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 	} `json:"errors"`
+	// ResultInfo is set by paginated endpoints such as the database list
+	ResultInfo *ResultInfo `json:"result_info,omitempty"`
+	// Header holds the HTTP response headers, such as cf-ray
+	Header http.Header `json:"-"`
 }
 
+// RayID returns the cf-ray header of the response, which identifies the
+// request to Cloudflare support and in traces
+func (r *APIResponse) RayID() string {
+	return r.Header.Get("cf-ray")
+}
+
+func (r *APIResponse) setHeader(header http.Header) {
+	r.Header = header
+}
+
+// ResultInfo describes the page returned by a paginated endpoint
+type ResultInfo struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Count      int `json:"count"`
+	TotalCount int `json:"total_count"`
+}
+
+// MaxResponseSize caps the number of bytes read from an API response body.
+// Larger responses fail with ErrResponseTooLarge instead of exhausting memory.
+// Zero or a negative value disables the limit.
+var MaxResponseSize int64 = 64 << 20
+
+// ErrResponseTooLarge is returned when a response body exceeds MaxResponseSize
+var ErrResponseTooLarge = errors.New("response body exceeds MaxResponseSize")
+
 func DoRequest(method, url, payload, apiToken string) (*APIResponse, error) {
-	req, err := http.NewRequest(method, url, strings.NewReader(payload))
+	return DoRequestContext(context.Background(), method, url, payload, apiToken)
+}
+
+// DoRequestContext is DoRequest with a context that can cancel the HTTP call
+func DoRequestContext(ctx context.Context, method, url, payload, apiToken string) (*APIResponse, error) {
+	apiRes, _, err := DoRequestCounted(ctx, method, url, payload, apiToken)
+	return apiRes, err
+}
+
+// DoRequestCounted is DoRequestContext that also returns the number of
+// response body bytes read, for usage accounting
+func DoRequestCounted(ctx context.Context, method, url, payload, apiToken string) (*APIResponse, int64, error) {
+	return Requester{}.DoCounted(ctx, method, url, payload, apiToken)
+}
+
+// Requester sends API requests with the settings of a client.
+// The zero value sends each request once through http.DefaultClient, like DoRequest.
+type Requester struct {
+	Retry RetryPolicy
+	// HTTPClient sends the requests; nil means http.DefaultClient
+	HTTPClient *http.Client
+	// Timeout limits each attempt, including reading the response body.
+	// Zero leaves the limit to the context and the HTTP client.
+	Timeout time.Duration
+	// Header holds extra headers sent with every request
+	Header http.Header
+	// Auth adds the credentials to each attempt; nil means the API token
+	// passed to DoCounted is sent as a bearer token
+	Auth AuthProvider
+	// Limiter delays each attempt, retries included, to stay within a
+	// request rate; nil means no limit
+	Limiter *RateLimiter
+	// Breaker fails attempts fast while the API keeps failing; nil means
+	// every attempt is sent
+	Breaker *CircuitBreaker
+}
+
+// DoCounted is DoRequestCounted with the settings of q
+func (q Requester) DoCounted(ctx context.Context, method, url, payload, apiToken string) (*APIResponse, int64, error) {
+	var apiRes APIResponse
+	n, err := q.do(ctx, method, url, payload, apiToken, &apiRes)
+	if err != nil {
+		return nil, n, err
+	}
+	return &apiRes, n, nil
+}
+
+// do sends a request, retrying as the policy of q allows, and decodes the
+// response body into dest. It returns the number of response body bytes read.
+func (q Requester) do(ctx context.Context, method, url, payload, apiToken string, dest interface{}) (int64, error) {
+	httpClient := q.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	var res *http.Response
+	for attempt := 1; ; attempt++ {
+		if err := q.Limiter.Wait(ctx); err != nil {
+			return 0, err
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if q.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, q.Timeout)
+		}
+
+		req, err := http.NewRequestWithContext(attemptCtx, method, url, strings.NewReader(payload))
+		if err != nil {
+			cancel()
+			return 0, err
+		}
+
+		if err := q.setHeaders(ctx, req, apiToken); err != nil {
+			cancel()
+			return 0, err
+		}
+
+		if err := q.Breaker.allow(); err != nil {
+			cancel()
+			return 0, err
+		}
+		res, err = httpClient.Do(req)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			q.Breaker.abandon()
+		case err != nil || res.StatusCode >= 500:
+			q.Breaker.failure()
+		default:
+			q.Breaker.success()
+		}
+		delay, retry := q.Retry.retryDelay(ctx, attempt, method, res, err)
+		if !retry {
+			if err != nil {
+				cancel()
+				return 0, err
+			}
+			// The body is read after the loop, within the attempt's timeout
+			defer cancel()
+			break
+		}
+
+		discard(res)
+		cancel()
+		if err := sleep(ctx, delay); err != nil {
+			return 0, err
+		}
+	}
+	defer res.Body.Close()
+
+	if dest, ok := dest.(interface{ setHeader(http.Header) }); ok {
+		dest.setHeader(res.Header)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return decodeErrorResponse(res, dest)
+	}
+
+	// Decode while reading, so large result sets are not buffered twice
+	counter := &countingReader{r: res.Body}
+	var body io.Reader = counter
+	if MaxResponseSize > 0 {
+		body = &limitedReader{r: counter, limit: MaxResponseSize}
+	}
+
+	if err := json.NewDecoder(body).Decode(dest); err != nil {
+		if errors.Is(err, ErrResponseTooLarge) {
+			return counter.n, err
+		}
+		return counter.n, fmt.Errorf("failed to decode response (HTTP %d): %w", res.StatusCode, err)
+	}
+
+	return counter.n, nil
+}
+
+// maxErrorBodySize caps the bytes read from a non-2xx response body
+const maxErrorBodySize = 64 << 10
+
+// maxErrorSnippet is the number of body bytes an HTTPError keeps
+const maxErrorSnippet = 256
+
+// HTTPError is returned for a non-2xx response whose body is not the JSON
+// error envelope of the API, such as a Cloudflare HTML error page or the
+// empty body of a 524 timeout
+type HTTPError struct {
+	StatusCode int
+	// CFRay is the cf-ray header, which Cloudflare support asks for
+	CFRay string
+	// Body is the start of the response body
+	Body string
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("unexpected HTTP %d response", e.StatusCode)
+	if e.CFRay != "" {
+		msg += " (cf-ray " + e.CFRay + ")"
+	}
+	if e.Body == "" {
+		return msg + ": empty body"
+	}
+	return msg + ": " + e.Body
+}
+
+// decodeErrorResponse decodes a non-2xx response into dest if it carries the
+// error envelope of the API, so the errors are reported by APIResponse.Err as
+// usual. Any other body is returned as *HTTPError.
+func decodeErrorResponse(res *http.Response, dest interface{}) (int64, error) {
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	n := int64(len(body))
+	if err != nil {
+		return n, fmt.Errorf("failed to read HTTP %d response: %w", res.StatusCode, err)
+	}
+
+	var envelope struct {
+		Success *bool             `json:"success"`
+		Errors  []json.RawMessage `json:"errors"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Success != nil && !*envelope.Success && len(envelope.Errors) > 0 {
+		if err := json.Unmarshal(body, dest); err == nil {
+			return n, nil
+		}
+	}
+
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > maxErrorSnippet {
+		snippet = strings.ToValidUTF8(snippet[:maxErrorSnippet], "") + "..."
+	}
+	return n, &HTTPError{StatusCode: res.StatusCode, CFRay: res.Header.Get("cf-ray"), Body: snippet}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedReader fails with ErrResponseTooLarge once the body exceeds limit bytes.
+// Unlike io.LimitReader it reports the overflow instead of silently truncating.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// Read at most one byte past the limit, which is enough to detect an overflow
+	if room := l.limit - l.read + 1; int64(len(p)) > room {
+		p = p[:room]
+	}
+
+	n, err := l.r.Read(p)
+	if l.read+int64(n) > l.limit {
+		n = int(l.limit - l.read)
+		l.read = l.limit
+		return n, fmt.Errorf("%w (%d bytes)", ErrResponseTooLarge, l.limit)
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// ToRows converts the APIResponse to a Rows object.
+// It expects the result to contain "results" map with "rows" and optional "columns".
+// If the response holds several result sets, the one chosen by primaryIndex is used.
+func (r *APIResponse) ToRows() (*Rows, error) {
+	results, err := r.resultItems()
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiToken)
+	if len(results) == 0 {
+		return NewRows(nil, nil), nil
+	}
+
+	return r.rowsFromItem(results[primaryIndex(results)])
+}
 
-	res, err := http.DefaultClient.Do(req)
+// ToResult converts the APIResponse to a Result object.
+// It expects the result to contain "meta" information.
+// If the response holds several result sets, the one chosen by primaryIndex is used.
+func (r *APIResponse) ToResult() (*Result, error) {
+	results, err := r.resultItems()
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
-	body, err := ioutil.ReadAll(res.Body)
+	if len(results) == 0 {
+		return NewResult(0, 0), nil
+	}
+
+	return r.resultFromItem(results[primaryIndex(results)])
+}
+
+// ToRowsAll converts every result set in the response to Rows, one per
+// statement in the order the statements were sent, for SQL that holds
+// several statements. Each Rows can be scanned on its own; NextResultSet on
+// one of them moves on to the sets that follow it.
+func (r *APIResponse) ToRowsAll() ([]*Rows, error) {
+	results, err := r.successfulItems()
 	if err != nil {
 		return nil, err
 	}
 
-	var apiRes APIResponse
-	if err := json.Unmarshal(body, &apiRes); err != nil {
+	sets := make([]*Rows, len(results))
+	for i, item := range results {
+		if sets[i], err = r.rowsFromItem(item); err != nil {
+			return nil, fmt.Errorf("result set %d: %w", i, err)
+		}
+	}
+	for i := range sets {
+		sets[i].rest = sets[i+1:]
+	}
+	return sets, nil
+}
+
+// ToResultAll converts every result set in the response to a Result, one per
+// statement in the order the statements were sent.
+func (r *APIResponse) ToResultAll() ([]*Result, error) {
+	results, err := r.successfulItems()
+	if err != nil {
 		return nil, err
 	}
 
-	return &apiRes, nil
+	all := make([]*Result, len(results))
+	for i, item := range results {
+		if all[i], err = r.resultFromItem(item); err != nil {
+			return nil, fmt.Errorf("result set %d: %w", i, err)
+		}
+	}
+	return all, nil
 }
 
-// ToRows converts the APIResponse to a Rows object.
-// It expects the result to contain "results" map with "rows" and optional "columns".
-func (r *APIResponse) ToRows() (*Rows, error) {
-	if !r.Success {
-		if len(r.Errors) > 0 {
-			return nil, fmt.Errorf("api error: %s", r.Errors[0].Message)
+// ResultSet returns the rows and the execution result of the i-th statement
+// in the response. Responses to batched requests carry one result set per
+// statement, in the order the statements were sent.
+func (r *APIResponse) ResultSet(i int) (*Rows, *Result, error) {
+	results, err := r.resultItems()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if i < 0 || i >= len(results) {
+		return nil, nil, fmt.Errorf("result set %d out of range: response has %d", i, len(results))
+	}
+
+	if item, ok := results[i].(map[string]interface{}); ok {
+		if success, ok := item["success"].(bool); ok && !success {
+			return nil, nil, fmt.Errorf("statement was not successful")
+		}
+	}
+
+	rows, err := r.rowsFromItem(results[i])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := r.resultFromItem(results[i])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rows, result, nil
+}
+
+// APIError is an error reported by the Cloudflare API, such as an invalid
+// token (code 10000) or an unknown database (code 7404)
+type APIError struct {
+	Code    int
+	Message string
+	// RayID is the cf-ray header of the response, which Cloudflare support
+	// asks for
+	RayID string
+}
+
+func (e *APIError) Error() string {
+	return "api error: " + e.Message
+}
+
+// Err returns the error reported by the API, or nil if the request succeeded.
+// Each error is an *APIError; if the API reported several, they are joined.
+func (r *APIResponse) Err() error {
+	if r.Success {
+		return nil
+	}
+	if len(r.Errors) == 0 {
+		return &APIError{Message: "unknown", RayID: r.RayID()}
+	}
+
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = &APIError{Code: e.Code, Message: e.Message, RayID: r.RayID()}
+	}
+	return errors.Join(errs...)
+}
+
+// RowCounts sums rows_read and rows_written over every result set in the
+// response, including statements that failed. It is zero for responses
+// without query results, such as database management calls.
+func (r *APIResponse) RowCounts() (read, written int64) {
+	results, ok := r.Result.([]interface{})
+	if !ok {
+		return 0, 0
+	}
+
+	for _, item := range results {
+		queryResult, ok := item.(map[string]interface{})
+		if !ok {
+			continue
 		}
-		return nil, fmt.Errorf("api error: unknown")
+		metaData, ok := queryResult["meta"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if f, ok := metaData["rows_read"].(float64); ok {
+			read += int64(f)
+		}
+		if f, ok := metaData["rows_written"].(float64); ok {
+			written += int64(f)
+		}
+	}
+	return read, written
+}
+
+// resultItems checks the response for API errors and returns the per-statement result items.
+func (r *APIResponse) resultItems() ([]interface{}, error) {
+	if err := r.Err(); err != nil {
+		return nil, err
 	}
 
 	// r.Result is usually []interface{} for queries
@@ -61,21 +450,94 @@ func (r *APIResponse) ToRows() (*Rows, error) {
 		return nil, fmt.Errorf("unexpected result format: not an array")
 	}
 
-	if len(results) == 0 {
-		return NewRows(nil, nil), nil
+	return results, nil
+}
+
+// successfulItems is resultItems that also fails if any statement was not successful.
+func (r *APIResponse) successfulItems() ([]interface{}, error) {
+	results, err := r.resultItems()
+	if err != nil {
+		return nil, err
 	}
 
-	// We take the first result set
-	queryResult, ok := results[0].(map[string]interface{})
+	for i, item := range results {
+		if item, ok := item.(map[string]interface{}); ok {
+			if success, ok := item["success"].(bool); ok && !success {
+				return nil, fmt.Errorf("statement %d was not successful", i)
+			}
+		}
+	}
+	return results, nil
+}
+
+// primaryIndex picks the result set that answers a single query.
+// D1 returns an extra, empty result set for the empty statement after a
+// trailing semicolon, so the last result set that returned columns or rows,
+// or read or wrote rows, wins. If every result set is empty, the first one is used.
+func primaryIndex(results []interface{}) int {
+	for i := len(results) - 1; i >= 0; i-- {
+		if !isEmptyItem(results[i]) {
+			return i
+		}
+	}
+	return 0
+}
+
+// isEmptyItem reports whether a result item looks like the answer to an empty statement.
+func isEmptyItem(item interface{}) bool {
+	queryResult, ok := item.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	if resultsData, ok := queryResult["results"].(map[string]interface{}); ok {
+		if cols, ok := resultsData["columns"].([]interface{}); ok && len(cols) > 0 {
+			return false
+		}
+		if rows, ok := resultsData["rows"].([]interface{}); ok && len(rows) > 0 {
+			return false
+		}
+	}
+	if objects, ok := queryResult["results"].([]interface{}); ok && len(objects) > 0 {
+		return false
+	}
+
+	if metaData, ok := queryResult["meta"].(map[string]interface{}); ok {
+		// changes and last_row_id are connection state in SQLite and carry over
+		// to an empty statement, so only the per-statement counters are reliable
+		for _, key := range []string{"rows_read", "rows_written"} {
+			if f, ok := metaData[key].(float64); ok && f != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rowsFromItem converts a single result item of a query response to Rows.
+func (r *APIResponse) rowsFromItem(item interface{}) (*Rows, error) {
+	queryResult, ok := item.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("unexpected result item format")
 	}
 
+	rows, err := rowsFromResults(queryResult["results"])
+	if err != nil {
+		return nil, err
+	}
+	rows.meta = metaFromItem(queryResult)
+	rows.meta.RayID = r.RayID()
+	return rows, nil
+}
+
+// rowsFromResults converts the "results" of a result item to Rows.
+func rowsFromResults(results interface{}) (*Rows, error) {
 	// Check for "results" map
-	resultsData, ok := queryResult["results"].(map[string]interface{})
+	resultsData, ok := results.(map[string]interface{})
 	if !ok {
-		// Maybe it's directly in queryResult?
-		// But based on d1_test.go, it's in "results"
+		if objects, ok := results.([]interface{}); ok {
+			return rowsFromObjects(objects)
+		}
 		return nil, fmt.Errorf("missing results map")
 	}
 
@@ -96,6 +558,12 @@ func (r *APIResponse) ToRows() (*Rows, error) {
 		}
 	}
 
+	// The columns array is authoritative. Without it, object rows are
+	// ordered like those of rowsFromObjects, and array rows are an error.
+	if columns == nil && allObjects(rowsRaw) {
+		return rowsFromObjects(rowsRaw)
+	}
+
 	rows := make([]map[string]interface{}, len(rowsRaw))
 	for i, row := range rowsRaw {
 		rowMap := make(map[string]interface{})
@@ -124,28 +592,53 @@ func (r *APIResponse) ToRows() (*Rows, error) {
 	return NewRows(rows, columns), nil
 }
 
-// ToResult converts the APIResponse to a Result object.
-// It expects the result to contain "meta" information.
-func (r *APIResponse) ToResult() (*Result, error) {
-	if !r.Success {
-		if len(r.Errors) > 0 {
-			return nil, fmt.Errorf("api error: %s", r.Errors[0].Message)
+// allObjects reports whether rows holds at least one row and only rows that
+// are objects
+func allObjects(rows []interface{}) bool {
+	for _, row := range rows {
+		if _, ok := row.(map[string]interface{}); !ok {
+			return false
 		}
-		return nil, fmt.Errorf("api error: unknown")
 	}
+	return len(rows) > 0
+}
 
-	// r.Result is usually []interface{} for queries
-	results, ok := r.Result.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected result format: not an array")
+// rowsFromObjects converts object rows that come without a columns array,
+// such as those of a /query endpoint response that was decoded without
+// DoObjectRowsRequestCounted. The key order is lost in decoding, so the
+// columns are the keys of all rows sorted by name, which at least keeps
+// Columns and Scan the same from one run to the next.
+func rowsFromObjects(objects []interface{}) (*Rows, error) {
+	rows := make([]map[string]interface{}, len(objects))
+	for i, object := range objects {
+		row, ok := object.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("row %d has unexpected type: %T", i, object)
+		}
+		rows[i] = row
 	}
+	return NewRows(rows, sortedKeys(rows)), nil
+}
 
-	if len(results) == 0 {
-		return NewResult(0, 0), nil
+// sortedKeys returns the keys of all rows, sorted by name
+func sortedKeys(rows []map[string]interface{}) []string {
+	names := map[string]bool{}
+	for _, row := range rows {
+		for name := range row {
+			names[name] = true
+		}
 	}
+	columns := make([]string, 0, len(names))
+	for name := range names {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return columns
+}
 
-	// We take the first result set
-	queryResult, ok := results[0].(map[string]interface{})
+// resultFromItem converts a single result item of a query response to a Result.
+func (r *APIResponse) resultFromItem(item interface{}) (*Result, error) {
+	queryResult, ok := item.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("unexpected result item format")
 	}
@@ -166,19 +659,6 @@ func (r *APIResponse) ToResult() (*Result, error) {
 		}
 	}
 
-	if val, ok := metaData["rows_read"]; ok {
-		// Note: D1 returns rows_read and rows_written.
-		// For UPDATE/DELETE, rows_written might be more appropriate for RowsAffected?
-		// Or maybe "changes"?
-		// Let's check if there is "changes" or similar.
-		// D1 API docs say: meta: { changed_db: bool, changes: int, duration: float, last_row_id: int, rows_read: int, rows_written: int, size_after: int }
-		// So "changes" seems to be the one.
-		if f, ok := val.(float64); ok {
-			// Just reading for now, but let's look for "changes"
-			_ = f
-		}
-	}
-
 	if val, ok := metaData["changes"]; ok {
 		if f, ok := val.(float64); ok {
 			rowsAffected = int64(f)
@@ -190,11 +670,14 @@ func (r *APIResponse) ToResult() (*Result, error) {
 		}
 	}
 
-	return NewResult(lastInsertId, rowsAffected), nil
+	result := NewResult(lastInsertId, rowsAffected)
+	result.meta = metaFromItem(queryResult)
+	result.meta.RayID = r.RayID()
+	return result, nil
 }
 
 // StructScanAll converts the APIResponse directly to a slice of structs.
-// dest must be a pointer to a slice, for example &[]User{}.
+// dest is &[]User{}, &[]*User{} or &[]map[string]interface{}{}, see Rows.StructScanAll.
 // This is similar to sqlx.NamedQuery().StructScan() pattern but simpler.
 //
 // Example:
@@ -225,7 +708,7 @@ func (r *APIResponse) StructScanAll(dest interface{}) error {
 //	var user User
 //	err := res.Get(&user)
 //
-// If no rows are returned, it will return an error.
+// If no rows are returned, it returns ErrNoRows.
 func (r *APIResponse) Get(dest interface{}) error {
 	// Convert to Rows first
 	rows, err := r.ToRows()
@@ -236,9 +719,9 @@ func (r *APIResponse) Get(dest interface{}) error {
 
 	// Check if there's at least one row
 	if !rows.Next() {
-		return fmt.Errorf("sql: no rows in result set")
+		return ErrNoRows
 	}
 
-	// Scan the first row into the destination struct
-	return rows.StructScan(dest)
+	// Scan the first row into the destination
+	return rows.ScanInto(dest)
 }
@@ -1,31 +1,38 @@
-package utils
-
-// Result implements sql.Result interface
-type Result struct {
-	lastInsertId int64
-	rowsAffected int64
-}
-
-// NewResult creates a new Result instance
-func NewResult(lastInsertId, rowsAffected int64) *Result {
-	return &Result{
-		lastInsertId: lastInsertId,
-		rowsAffected: rowsAffected,
-	}
-}
-
-// LastInsertId returns the integer generated by the database
-// in response to a command. Typically this will be from an
-// "auto increment" column when inserting a new row. Not all
-// databases support this feature, and the syntax of such
-// statements varies.
-func (r *Result) LastInsertId() (int64, error) {
-	return r.lastInsertId, nil
-}
-
-// RowsAffected returns the number of rows affected by an
-// update, insert, or delete. Not every database or database
-// driver may support this.
-func (r *Result) RowsAffected() (int64, error) {
-	return r.rowsAffected, nil
-}
+package utils
+
+// Result implements sql.Result interface
+type Result struct {
+	lastInsertId int64
+	rowsAffected int64
+	meta         Meta
+}
+
+// NewResult creates a new Result instance
+func NewResult(lastInsertId, rowsAffected int64) *Result {
+	return &Result{
+		lastInsertId: lastInsertId,
+		rowsAffected: rowsAffected,
+	}
+}
+
+// LastInsertId returns the integer generated by the database
+// in response to a command. Typically this will be from an
+// "auto increment" column when inserting a new row. Not all
+// databases support this feature, and the syntax of such
+// statements varies.
+func (r *Result) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+// RowsAffected returns the number of rows affected by an
+// update, insert, or delete. Not every database or database
+// driver may support this.
+func (r *Result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// Meta returns the execution metadata D1 reported for the statement,
+// such as its duration and the rows it read and wrote
+func (r *Result) Meta() Meta {
+	return r.meta
+}
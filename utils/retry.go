@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how often a request is retried after a rate limit
+// (429) or a transient server error (500, 502, 503, 504).
+// The zero value does not retry.
+//
+// GET requests are retried on any of those responses and on network errors.
+// Other requests, such as POSTed queries, may already have run when the
+// response is lost, so they are only retried when the context is marked with
+// WithRetryable, or when the error shows the request never reached D1: a
+// refused connection, a failed DNS lookup, or a 429 with a Retry-After header.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Zero or one disables retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled for every
+	// further retry. Zero means 200ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts. A Retry-After header asking
+	// for longer ends the retries. Zero means 10s.
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, by which a wait is randomly
+	// shortened, so clients that failed together do not retry together
+	Jitter float64
+}
+
+const (
+	defaultRetryBaseDelay = 200 * time.Millisecond
+	defaultRetryMaxDelay  = 10 * time.Second
+)
+
+type retryableKey struct{}
+
+// WithRetryable marks requests made with ctx as safe to retry, e.g. for
+// queries that only read or that can be run twice without harm
+func WithRetryable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableKey{}, true)
+}
+
+// isRetryable reports whether ctx was marked with WithRetryable
+func isRetryable(ctx context.Context) bool {
+	retryable, _ := ctx.Value(retryableKey{}).(bool)
+	return retryable
+}
+
+// retryDelay decides whether the attempt-th attempt, which ended with res or
+// err, is retried, and how long to wait before the next one
+func (p RetryPolicy) retryDelay(ctx context.Context, attempt int, method string, res *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+	safe := method == http.MethodGet || method == http.MethodHead || isRetryable(ctx)
+
+	if err != nil {
+		if safe || notSent(err) {
+			return p.backoff(attempt), true
+		}
+		return 0, false
+	}
+
+	after, hasAfter := retryAfter(res.Header.Get("Retry-After"))
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		if !safe && !hasAfter {
+			return 0, false
+		}
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !safe {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if !hasAfter {
+		return p.backoff(attempt), true
+	}
+	if after > p.maxDelay() {
+		return 0, false
+	}
+	return after, true
+}
+
+// backoff returns the wait after the attempt-th attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	if delay <= 0 {
+		delay = defaultRetryBaseDelay
+	}
+	for i := 1; i < attempt && delay < p.maxDelay(); i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay() {
+		delay = p.maxDelay()
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+func (p RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return defaultRetryMaxDelay
+	}
+	return p.MaxDelay
+}
+
+// notSent reports whether err shows the request never reached the server
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.As(err, &dnsErr)
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as HTTP date
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// discard drains and closes the body of a response that is not used, so the
+// connection can be reused for the next attempt
+func discard(res *http.Response) {
+	if res == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	res.Body.Close()
+}
+
+// sleep waits for d, or returns the error of ctx if it ends first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,245 +1,567 @@
-package utils
-
-import (
-	"database/sql"
-	"errors"
-	"fmt"
-	"reflect"
-	"strings"
-)
-
-// Rows simulates sql.Rows and sqlx.Rows behavior
-type Rows struct {
-	rows    []map[string]interface{}
-	columns []string
-	current int
-	lastErr error
-}
-
-// NewRows creates a new Rows instance
-func NewRows(rows []map[string]interface{}, columns []string) *Rows {
-	// If columns are not provided, try to infer from the first row (unreliable order)
-	// But for D1, we hope to get columns from the response.
-	// If not, Scan() might be problematic without strict order.
-	if len(columns) == 0 && len(rows) > 0 {
-		for k := range rows[0] {
-			columns = append(columns, k)
-		}
-	}
-	return &Rows{
-		rows:    rows,
-		columns: columns,
-		current: -1,
-	}
-}
-
-// Next prepares the next result row for reading with the Scan method.
-func (r *Rows) Next() bool {
-	r.current++
-	return r.current < len(r.rows)
-}
-
-// Err returns the error, if any, that was encountered during iteration.
-func (r *Rows) Err() error {
-	return r.lastErr
-}
-
-// Columns returns the column names.
-func (r *Rows) Columns() ([]string, error) {
-	return r.columns, nil
-}
-
-// Close closes the Rows, preventing further enumeration.
-func (r *Rows) Close() error {
-	r.rows = nil
-	return nil
-}
-
-// Scan copies the columns in the current row into the values pointed at by dest.
-// The number of values in dest must be the same as the number of columns in Rows.
-func (r *Rows) Scan(dest ...interface{}) error {
-	if r.current < 0 || r.current >= len(r.rows) {
-		return errors.New("sql: Rows is closed")
-	}
-
-	row := r.rows[r.current]
-	if len(dest) != len(r.columns) {
-		return fmt.Errorf("sql: expected %d destination arguments in Scan, not %d", len(r.columns), len(dest))
-	}
-
-	for i, colName := range r.columns {
-		val, ok := row[colName]
-		if !ok {
-			// If column not found in row (should not happen if consistent), treat as nil?
-			val = nil
-		}
-
-		if err := convertAssign(dest[i], val); err != nil {
-			return fmt.Errorf("sql: Scan error on column index %d, name %q: %v", i, colName, err)
-		}
-	}
-
-	return nil
-}
-
-// StructScan scans the current row into a struct.
-// It uses the "db" struct tag to map column names to fields.
-func (r *Rows) StructScan(dest interface{}) error {
-	if r.current < 0 || r.current >= len(r.rows) {
-		return errors.New("sql: Rows is closed")
-	}
-
-	v := reflect.ValueOf(dest)
-	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
-		return errors.New("sql: StructScan requires a pointer to a struct")
-	}
-
-	v = v.Elem()
-	t := v.Type()
-	row := r.rows[r.current]
-
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		tag := field.Tag.Get("db")
-		if tag == "" {
-			tag = strings.ToLower(field.Name)
-		}
-
-		if val, ok := row[tag]; ok {
-			if err := convertAssign(v.Field(i).Addr().Interface(), val); err != nil {
-				return fmt.Errorf("sql: StructScan error on field %s: %v", field.Name, err)
-			}
-		}
-	}
-
-	return nil
-}
-
-// StructScanAll scans all remaining rows into a destination slice.
-// dest must be a pointer to a slice, for example &[]User{}.
-//
-// Example:
-//
-//	var users []User
-//	err := rows.StructScanAll(&users)
-//
-// The method will iterate through all rows starting from the current position
-// and append each scanned struct to the destination slice.
-func (r *Rows) StructScanAll(dest interface{}) error {
-	// Validate that dest is a pointer
-	destValue := reflect.ValueOf(dest)
-	if destValue.Kind() != reflect.Ptr {
-		return fmt.Errorf("dest must be a pointer, got %T", dest)
-	}
-
-	// Get the slice from the pointer
-	sliceValue := destValue.Elem()
-	if sliceValue.Kind() != reflect.Slice {
-		return fmt.Errorf("dest must be a pointer to a slice, got %T", dest)
-	}
-
-	// Get the element type of the slice
-	elemType := sliceValue.Type().Elem()
-
-	// Iterate through all rows
-	count := 0
-	for r.Next() {
-		// Create a new instance of the element type
-		elemPtr := reflect.New(elemType)
-		elemInterface := elemPtr.Interface()
-
-		// Scan the current row into the element
-		if err := r.StructScan(elemInterface); err != nil {
-			return fmt.Errorf("StructScan failed at index %d: %w", count, err)
-		}
-
-		// Append the element to the slice
-		sliceValue.Set(reflect.Append(sliceValue, elemPtr.Elem()))
-		count++
-	}
-
-	return nil
-}
-
-// convertAssign copies to dest the value in src.
-// This is a simplified version of database/sql/convert.go
-func convertAssign(dest, src interface{}) error {
-	// Common case: dest is *string, *int, etc.
-	switch d := dest.(type) {
-	case *string:
-		if src == nil {
-			*d = ""
-			return nil
-		}
-		*d = fmt.Sprintf("%v", src)
-		return nil
-	case *int:
-		if src == nil {
-			*d = 0
-			return nil
-		}
-		// JSON numbers are often float64
-		if f, ok := src.(float64); ok {
-			*d = int(f)
-			return nil
-		}
-		// Or string
-		if s, ok := src.(string); ok {
-			var i int
-			if _, err := fmt.Sscanf(s, "%d", &i); err == nil {
-				*d = i
-				return nil
-			}
-		}
-		return fmt.Errorf("cannot convert %T to int", src)
-	case *int64:
-		if src == nil {
-			*d = 0
-			return nil
-		}
-		if f, ok := src.(float64); ok {
-			*d = int64(f)
-			return nil
-		}
-		return fmt.Errorf("cannot convert %T to int64", src)
-	case *float64:
-		if src == nil {
-			*d = 0
-			return nil
-		}
-		if f, ok := src.(float64); ok {
-			*d = f
-			return nil
-		}
-		return fmt.Errorf("cannot convert %T to float64", src)
-	case *bool:
-		if src == nil {
-			*d = false
-			return nil
-		}
-		if b, ok := src.(bool); ok {
-			*d = b
-			return nil
-		}
-		// 0/1
-		if f, ok := src.(float64); ok {
-			*d = f != 0
-			return nil
-		}
-		return fmt.Errorf("cannot convert %T to bool", src)
-	case *interface{}:
-		*d = src
-		return nil
-	case sql.Scanner:
-		return d.Scan(src)
-	}
-
-	// Reflection fallback for other types
-	v := reflect.ValueOf(dest)
-	if v.Kind() != reflect.Ptr {
-		return errors.New("destination not a pointer")
-	}
-
-	// TODO: Add more robust conversion if needed
-	return nil
-}
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rows simulates sql.Rows and sqlx.Rows behavior
+type Rows struct {
+	rows    []map[string]interface{}
+	columns []string
+	current int
+	lastErr error
+	mapper  NameMapper
+	strict  bool
+	// scanType and scanFields are the struct type StructScan scanned into
+	// last and its StructColumns, reused for the following rows
+	scanType   reflect.Type
+	scanFields []FieldColumn
+	// declared holds the declared column types, see SetDeclaredColumns
+	declared map[string]DeclaredColumn
+	meta     Meta
+	// rest holds the result sets that follow this one, see NextResultSet
+	rest []*Rows
+}
+
+// NewRows creates a new Rows instance.
+// columns gives the order of the values for Scan and Columns. If it is empty,
+// the keys of the rows are used, sorted by name, since maps have no order.
+func NewRows(rows []map[string]interface{}, columns []string) *Rows {
+	if len(columns) == 0 && len(rows) > 0 {
+		columns = sortedKeys(rows)
+	}
+	return &Rows{
+		rows:    rows,
+		columns: columns,
+		current: -1,
+	}
+}
+
+// Next prepares the next result row for reading with the Scan method.
+// It returns false once a Scan or StructScan has failed, see Err.
+func (r *Rows) Next() bool {
+	if r.lastErr != nil {
+		return false
+	}
+	r.current++
+	return r.current < len(r.rows)
+}
+
+// Err returns the error, if any, that was encountered during iteration:
+// the first error of Scan or StructScan, which also ends the iteration, so
+// a loop that ignores their errors can check Err after it.
+func (r *Rows) Err() error {
+	return r.lastErr
+}
+
+// Columns returns the column names.
+func (r *Rows) Columns() ([]string, error) {
+	return r.columns, nil
+}
+
+// Meta returns the execution metadata D1 reported for the query,
+// such as its duration and the rows it read
+func (r *Rows) Meta() Meta {
+	return r.meta
+}
+
+// NextResultSet advances to the next result set of a multi-statement query,
+// like sql.Rows.NextResultSet. It reports whether there is one; the Rows
+// then reads the rows, columns and metadata of that set from the start.
+// Only Rows returned by APIResponse.ToRowsAll have further result sets.
+func (r *Rows) NextResultSet() bool {
+	if len(r.rest) == 0 {
+		return false
+	}
+
+	next := r.rest[0]
+	r.rows, r.columns, r.meta = next.rows, next.columns, next.meta
+	r.current = -1
+	r.lastErr = nil
+	r.rest = r.rest[1:]
+	return true
+}
+
+// SetNameMapper sets the mapper used by StructScan for fields without a db tag.
+// A nil mapper means DefaultMapper.
+func (r *Rows) SetNameMapper(mapper NameMapper) {
+	r.mapper = mapper
+	r.scanType, r.scanFields = nil, nil
+}
+
+// SetStrict makes StructScan fail with an *UnmatchedError when a column of
+// the result matches no struct field or a struct field matches no column,
+// instead of leaving them out. It catches misspelled db tags.
+func (r *Rows) SetStrict(strict bool) {
+	r.strict = strict
+}
+
+// Close closes the Rows, preventing further enumeration.
+func (r *Rows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+// Scan copies the columns in the current row into the values pointed at by dest.
+// The number of values in dest must be the same as the number of columns in Rows.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if r.current < 0 || r.current >= len(r.rows) {
+		return errors.New("sql: Rows is closed")
+	}
+
+	row := r.rows[r.current]
+	if len(dest) != len(r.columns) {
+		return fmt.Errorf("sql: expected %d destination arguments in Scan, not %d", len(r.columns), len(dest))
+	}
+
+	// Every column is scanned, so that one call reports all failing columns
+	var errs []error
+	for i, colName := range r.columns {
+		val, ok := row[colName]
+		if !ok {
+			// If column not found in row (should not happen if consistent), treat as nil?
+			val = nil
+		}
+
+		if err := convertAssign(dest[i], val); err != nil {
+			errs = append(errs, fmt.Errorf("sql: Scan error on column index %d, name %q: %w", i, colName, err))
+		}
+	}
+
+	return r.fail(errors.Join(errs...))
+}
+
+// StructScan scans the current row into a struct.
+// It uses the "db" struct tag to map column names to fields, and the name mapper
+// (see SetNameMapper) for fields without a tag.
+func (r *Rows) StructScan(dest interface{}) error {
+	if r.current < 0 || r.current >= len(r.rows) {
+		return errors.New("sql: Rows is closed")
+	}
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("sql: StructScan requires a pointer to a struct")
+	}
+
+	v = v.Elem()
+	row := r.rows[r.current]
+
+	if r.scanType != v.Type() {
+		r.scanType, r.scanFields = v.Type(), StructColumns(v.Type(), r.mapper)
+	}
+
+	var errs []error
+	var unmatched UnmatchedError
+	var matched map[string]bool
+	if r.strict {
+		matched = make(map[string]bool, len(r.columns))
+	}
+	for _, fc := range r.scanFields {
+		col, ok := r.fieldColumn(row, fc)
+		if !ok {
+			unmatched.Fields = append(unmatched.Fields, fc.Field.Name)
+			continue
+		}
+		if r.strict {
+			matched[col] = true
+		}
+		val := row[col]
+
+		field := FieldByIndex(v, fc.Index)
+		if fc.JSON {
+			if err := ScanJSON(field, val); err != nil {
+				errs = append(errs, fmt.Errorf("sql: StructScan error on field %s: %w", fc.Field.Name, err))
+			}
+			continue
+		}
+		if err := convertAssign(field.Addr().Interface(), val); err != nil {
+			errs = append(errs, fmt.Errorf("sql: StructScan error on field %s: %w", fc.Field.Name, err))
+		}
+	}
+
+	if r.strict {
+		for _, col := range r.columns {
+			if !matched[col] {
+				unmatched.Columns = append(unmatched.Columns, col)
+			}
+		}
+		if len(unmatched.Columns) > 0 || len(unmatched.Fields) > 0 {
+			unmatched.Type = v.Type()
+			errs = append(errs, &unmatched)
+		}
+	}
+	return r.fail(errors.Join(errs...))
+}
+
+// fail records err as the error of Err, unless an earlier one is recorded,
+// and returns it
+func (r *Rows) fail(err error) error {
+	if err != nil && r.lastErr == nil {
+		r.lastErr = err
+	}
+	return err
+}
+
+// fieldColumn returns the column of row that fc is scanned from
+func (r *Rows) fieldColumn(row map[string]interface{}, fc FieldColumn) (string, bool) {
+	if _, ok := row[fc.Column]; ok {
+		return fc.Column, true
+	}
+	if fc.Prefix != "" {
+		col := strings.ReplaceAll(fc.Column, ".", "_")
+		_, ok := row[col]
+		return col, ok
+	}
+	if !fc.Tagged && r.mapper == nil {
+		// Columns named after the lower cased field name predate name mappers
+		col := LowerCase(fc.Field.Name)
+		_, ok := row[col]
+		return col, ok
+	}
+	return "", false
+}
+
+// MapScan copies the current row into dest, keyed by column name.
+// Values are stored as decoded from the response: numbers are float64,
+// text is string and NULL is nil.
+func (r *Rows) MapScan(dest map[string]interface{}) error {
+	if r.current < 0 || r.current >= len(r.rows) {
+		return errors.New("sql: Rows is closed")
+	}
+
+	row := r.rows[r.current]
+	for _, colName := range r.columns {
+		dest[colName] = row[colName]
+	}
+	return nil
+}
+
+// StructScanAll scans all remaining rows into a destination slice.
+// dest must be a pointer to one of:
+//
+//	*[]User                     // structs
+//	*[]*User                    // pointers to structs, allocated per row
+//	*[]map[string]interface{}   // one map per row, see MapScan
+//	*[]string, *[]int64, ...    // single values, the rows must have one column
+//
+// Example:
+//
+//	var users []User
+//	err := rows.StructScanAll(&users)
+//
+// The method will iterate through all rows starting from the current position
+// and store them in a new slice of that length, which replaces the contents
+// of dest.
+func (r *Rows) StructScanAll(dest interface{}) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return unsupportedDest(dest)
+	}
+
+	// A fresh slice, so rows of an earlier call and slices sharing its array
+	// are left alone
+	sliceValue := destValue.Elem()
+	sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), 0, r.remaining()))
+
+	for r.Next() {
+		i := sliceValue.Len()
+		sliceValue.SetLen(i + 1)
+		if err := scanInto(r, sliceValue.Index(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("StructScan failed at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// remaining returns the number of rows Next has yet to return
+func (r *Rows) remaining() int {
+	return max(len(r.rows)-(r.current+1), 0)
+}
+
+var mapType = reflect.TypeOf(map[string]interface{}(nil))
+
+// unsupportedDest describes the destinations StructScanAll accepts
+func unsupportedDest(dest interface{}) error {
+	return fmt.Errorf("dest must be *[]T, *[]*T with T a struct, *[]map[string]interface{}, or a pointer to a slice of single values for one-column results, got %T", dest)
+}
+
+// convertAssign copies to dest the value in src.
+// This is a simplified version of database/sql/convert.go
+func convertAssign(dest, src interface{}) error {
+	// Common case: dest is *string, *int, etc.
+	switch d := dest.(type) {
+	case *string:
+		if src == nil {
+			*d = ""
+			return nil
+		}
+		*d = fmt.Sprintf("%v", src)
+		return nil
+	case *[]byte:
+		switch s := src.(type) {
+		case nil:
+			*d = nil
+		case string:
+			*d = []byte(s)
+		case []byte:
+			*d = append([]byte(nil), s...)
+		case []interface{}:
+			b, err := blobBytes(s)
+			if err != nil {
+				return err
+			}
+			*d = b
+		default:
+			*d = []byte(fmt.Sprintf("%v", s))
+		}
+		return nil
+	case *bool:
+		if src == nil {
+			*d = false
+			return nil
+		}
+		if b, ok := src.(bool); ok {
+			*d = b
+			return nil
+		}
+		// 0/1
+		if f, ok := src.(float64); ok {
+			*d = f != 0
+			return nil
+		}
+		return fmt.Errorf("cannot convert %T to bool", src)
+	case *time.Time:
+		if src == nil {
+			*d = time.Time{}
+			return nil
+		}
+		t, err := asTime(src)
+		if err != nil {
+			return err
+		}
+		*d = t
+		return nil
+	case *interface{}:
+		*d = src
+		return nil
+
+	// The sql.Null types are scanners, but convert JSON numbers and text
+	// more strictly than D1 results need, e.g. 1 into NullBool
+	case *sql.NullString:
+		d.Valid = src != nil
+		return convertAssign(&d.String, src)
+	case *sql.NullInt64:
+		d.Valid = src != nil
+		return convertAssign(&d.Int64, src)
+	case *sql.NullInt32:
+		d.Valid = src != nil
+		return convertAssign(&d.Int32, src)
+	case *sql.NullInt16:
+		d.Valid = src != nil
+		return convertAssign(&d.Int16, src)
+	case *sql.NullByte:
+		d.Valid = src != nil
+		return convertAssign(&d.Byte, src)
+	case *sql.NullFloat64:
+		d.Valid = src != nil
+		return convertAssign(&d.Float64, src)
+	case *sql.NullBool:
+		d.Valid = src != nil
+		return convertAssign(&d.Bool, src)
+	case *sql.NullTime:
+		d.Valid = src != nil
+		return convertAssign(&d.Time, src)
+	case sql.Scanner:
+		return d.Scan(scannerValue(src))
+	}
+
+	// Reflection for numbers, named types and pointers to them
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("destination not a pointer")
+	}
+	dv := v.Elem()
+
+	switch dv.Kind() {
+	case reflect.Ptr:
+		// NULL is a nil pointer, anything else is converted into a new value
+		if src == nil {
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		elem := reflect.New(dv.Type().Elem())
+		if err := convertAssign(elem.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(elem)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if src == nil {
+			dv.SetInt(0)
+			return nil
+		}
+		i, err := asInt64(src)
+		if err != nil {
+			return fmt.Errorf("cannot convert %v (%T) to %s: %w", src, src, dv.Type(), err)
+		}
+		if dv.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, dv.Type())
+		}
+		dv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if src == nil {
+			dv.SetUint(0)
+			return nil
+		}
+		u, err := asUint64(src)
+		if err != nil {
+			return fmt.Errorf("cannot convert %v (%T) to %s: %w", src, src, dv.Type(), err)
+		}
+		if dv.OverflowUint(u) {
+			return fmt.Errorf("value %d overflows %s", u, dv.Type())
+		}
+		dv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if src == nil {
+			dv.SetFloat(0)
+			return nil
+		}
+		f, err := asFloat64(src)
+		if err != nil {
+			return fmt.Errorf("cannot convert %v (%T) to %s: %w", src, src, dv.Type(), err)
+		}
+		if dv.OverflowFloat(f) {
+			return fmt.Errorf("value %v overflows %s", f, dv.Type())
+		}
+		dv.SetFloat(f)
+		return nil
+	case reflect.String:
+		if src == nil {
+			dv.SetString("")
+			return nil
+		}
+		dv.SetString(fmt.Sprintf("%v", src))
+		return nil
+	case reflect.Bool:
+		var b bool
+		if err := convertAssign(&b, src); err != nil {
+			return err
+		}
+		dv.SetBool(b)
+		return nil
+	}
+
+	// Byte slice types take BLOBs like *[]byte does
+	if s, ok := src.([]interface{}); ok && dv.Kind() == reflect.Slice && dv.Type().Elem().Kind() == reflect.Uint8 {
+		b, err := blobBytes(s)
+		if err != nil {
+			return err
+		}
+		dv.SetBytes(b)
+		return nil
+	}
+
+	// NULL leaves slices, maps and interfaces nil, e.g. json.RawMessage
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Interface:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+	} else if sv := reflect.ValueOf(src); sv.Type().ConvertibleTo(dv.Type()) {
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported Scan pair: cannot store %T into %s", src, dv.Type())
+}
+
+// blobBytes converts the JSON array of byte values D1 returns for a BLOB
+// column to bytes
+func blobBytes(values []interface{}) ([]byte, error) {
+	b := make([]byte, len(values))
+	for i, v := range values {
+		f, ok := v.(float64)
+		if !ok || f < 0 || f > 255 || f != math.Trunc(f) {
+			return nil, fmt.Errorf("cannot convert element %d of %T (%v) to a byte", i, values, v)
+		}
+		b[i] = byte(f)
+	}
+	return b, nil
+}
+
+// scannerValue returns src as a database/sql driver would pass it to a
+// Scanner: JSON numbers without a fraction become int64, since D1 returns
+// INTEGER columns as JSON numbers
+func scannerValue(src interface{}) interface{} {
+	if f, ok := src.(float64); ok && f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return int64(f)
+	}
+	return src
+}
+
+// asInt64 converts a decoded JSON value to an integer. D1 returns integers
+// as JSON numbers, and as strings where the column affinity is TEXT.
+func asInt64(src interface{}) (int64, error) {
+	switch s := src.(type) {
+	case float64:
+		if s < math.MinInt64 || s >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v overflows int64", s)
+		}
+		return int64(s), nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	case bool:
+		if s {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported type")
+}
+
+// asUint64 converts a decoded JSON value to an unsigned integer, failing
+// for negative values
+func asUint64(src interface{}) (uint64, error) {
+	if s, ok := src.(string); ok {
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "-") {
+			return strconv.ParseUint(s, 10, 64)
+		}
+	}
+	if f, ok := src.(float64); ok && f >= math.MaxInt64 {
+		if f >= math.MaxUint64 {
+			return 0, fmt.Errorf("value %v overflows uint64", f)
+		}
+		return uint64(f), nil
+	}
+	i, err := asInt64(src)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("negative value %d", i)
+	}
+	return uint64(i), nil
+}
+
+// asFloat64 converts a decoded JSON value to a float
+func asFloat64(src interface{}) (float64, error) {
+	switch s := src.(type) {
+	case float64:
+		return s, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	return 0, fmt.Errorf("unsupported type")
+}
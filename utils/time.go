@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// timeLayouts are the layouts text is parsed with when scanning into a
+// time.Time, in order: RFC 3339, which ConvertParams writes by default, the
+// layout SQLite's CURRENT_TIMESTAMP uses, its variants with fractional
+// seconds and a T, and a plain date. Text without a zone is taken as UTC.
+var timeLayouts = struct {
+	sync.RWMutex
+	layouts []string
+}{layouts: []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}}
+
+// RegisterTimeLayout adds a layout, in the format of time.Parse, that text
+// columns are parsed with when scanning into a time.Time. Registered layouts
+// are tried after the built-in ones, in the order they were registered.
+// It is safe to call concurrently with scanning, but is usually called once
+// from an init function.
+func RegisterTimeLayout(layout string) {
+	timeLayouts.Lock()
+	defer timeLayouts.Unlock()
+	timeLayouts.layouts = append(timeLayouts.layouts, layout)
+}
+
+// DefaultTimeFormat is the layout time.Time parameters are sent in unless
+// changed with SetTimeFormat: RFC 3339 in UTC with nanoseconds, always nine
+// digits of them, so that text order is time order
+const DefaultTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// TimeFormatUnix makes SetTimeFormat send time.Time parameters as whole
+// seconds since the Unix epoch, for INTEGER columns
+const TimeFormatUnix = "unix"
+
+var timeFormat = struct {
+	sync.RWMutex
+	layout string
+}{layout: DefaultTimeFormat}
+
+// SetTimeFormat sets how ConvertParams sends time.Time parameters: a layout
+// in the format of time.Format, applied to the time in UTC, or
+// TimeFormatUnix. It applies to every client, including the migrations
+// executor, and is usually called once from an init function.
+func SetTimeFormat(layout string) {
+	timeFormat.Lock()
+	defer timeFormat.Unlock()
+	timeFormat.layout = layout
+}
+
+// FormatTime formats t as ConvertParams sends it, see SetTimeFormat
+func FormatTime(t time.Time) string {
+	timeFormat.RLock()
+	defer timeFormat.RUnlock()
+	if timeFormat.layout == TimeFormatUnix {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return t.UTC().Format(timeFormat.layout)
+}
+
+// asTime converts a decoded JSON value to a time: text in one of the time
+// layouts, or a number of seconds since the Unix epoch, as stored by
+// unixepoch() or strftime('%s')
+func asTime(src interface{}) (time.Time, error) {
+	switch s := src.(type) {
+	case time.Time:
+		return s, nil
+	case float64:
+		sec, frac := math.Modf(s)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	case string:
+		timeLayouts.RLock()
+		defer timeLayouts.RUnlock()
+		for _, layout := range timeLayouts.layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as time.Time: not RFC 3339, \"2006-01-02 15:04:05\", a date or a registered layout, see RegisterTimeLayout", s)
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %v (%T) to time.Time", src, src)
+}
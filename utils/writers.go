@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// CSVOptions configures WriteCSV. The zero value writes a header row and
+// comma separated values, with NULL as an empty field.
+type CSVOptions struct {
+	// NoHeader leaves out the header row of column names
+	NoHeader bool
+	// Delimiter separates the fields, ',' if zero
+	Delimiter rune
+	// Null is written for NULL values
+	Null string
+	// CRLF ends lines with \r\n rather than \n, as some spreadsheets expect
+	CRLF bool
+}
+
+// WriteCSV writes the remaining rows to w as CSV, quoting fields that hold
+// the delimiter, quotes or line breaks. Numbers are written without an
+// exponent, booleans as true or false, and BLOB and other non-text values
+// as JSON.
+func (r *Rows) WriteCSV(w io.Writer, opts CSVOptions) error {
+	cw := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	cw.UseCRLF = opts.CRLF
+
+	if !opts.NoHeader {
+		if err := cw.Write(r.columns); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(r.columns))
+	for r.Next() {
+		row := r.rows[r.current]
+		for i, col := range r.columns {
+			if row[col] == nil {
+				record[i] = opts.Null
+				continue
+			}
+			record[i] = textValue(row[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// textValue formats a decoded JSON value for a CSV field
+func textValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := marshalJSON(v)
+	return string(b)
+}
+
+// marshalJSON is json.Marshal without the escaping of <, > and &, which
+// only matters for JSON embedded in HTML
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// JSONOptions configures WriteJSON. The zero value writes a JSON array of
+// objects.
+type JSONOptions struct {
+	// Lines writes one object per line without an enclosing array, as JSON
+	// Lines (NDJSON)
+	Lines bool
+}
+
+// WriteJSON writes the remaining rows to w as JSON objects whose keys are
+// the column names, in column order. NULL is written as null.
+func (r *Rows) WriteJSON(w io.Writer, opts JSONOptions) error {
+	bw := bufio.NewWriter(w)
+	if !opts.Lines {
+		bw.WriteByte('[')
+	}
+	for n := 0; r.Next(); n++ {
+		if n > 0 && !opts.Lines {
+			bw.WriteByte(',')
+		}
+		if err := r.writeObject(bw); err != nil {
+			return err
+		}
+		if opts.Lines {
+			bw.WriteByte('\n')
+		}
+	}
+	if !opts.Lines {
+		bw.WriteString("]\n")
+	}
+	return bw.Flush()
+}
+
+// writeObject writes the current row as a JSON object in column order
+func (r *Rows) writeObject(w *bufio.Writer) error {
+	row := r.rows[r.current]
+	w.WriteByte('{')
+	for i, col := range r.columns {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		key, _ := marshalJSON(col)
+		value, err := marshalJSON(row[col])
+		if err != nil {
+			return err
+		}
+		w.Write(key)
+		w.WriteByte(':')
+		w.Write(value)
+	}
+	return w.WriteByte('}')
+}
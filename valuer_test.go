@@ -0,0 +1,121 @@
+package cloudflared1_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// NullEnum is a nullable enum column, one of "draft", "published" or NULL
+type NullEnum struct {
+	Enum  string
+	Valid bool
+}
+
+func (e *NullEnum) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		*e = NullEnum{}
+	case string:
+		if s != "draft" && s != "published" {
+			return fmt.Errorf("invalid enum value %q", s)
+		}
+		*e = NullEnum{Enum: s, Valid: true}
+	default:
+		return fmt.Errorf("cannot scan %T into NullEnum", src)
+	}
+	return nil
+}
+
+func (e NullEnum) Value() (driver.Value, error) {
+	if !e.Valid {
+		return nil, nil
+	}
+	return e.Enum, nil
+}
+
+// money is an amount in cents. Like most scanners written for database/sql
+// drivers, it expects integers as int64.
+type money int64
+
+func (m *money) Scan(src interface{}) error {
+	i, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into money", src)
+	}
+	*m = money(i)
+	return nil
+}
+
+func (m money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+type post struct {
+	ID       uuid.UUID  `db:"id"`
+	AuthorID *uuid.UUID `db:"author_id"`
+	Status   NullEnum   `db:"status"`
+	Price    money      `db:"price"`
+}
+
+var postID = uuid.MustParse("7d444840-9dc0-11d1-b245-5ffdce74fad2")
+
+func TestConvertParamsValuer(t *testing.T) {
+	params, err := utils.ConvertParams(postID, NullEnum{Enum: "draft", Valid: true}, NullEnum{}, money(1250), (*NullEnum)(nil))
+	if err != nil {
+		t.Fatalf("ConvertParams failed: %v", err)
+	}
+	want := []string{"7d444840-9dc0-11d1-b245-5ffdce74fad2", "draft", utils.Null, "1250", utils.Null}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %q, want %q", params, want)
+	}
+}
+
+func TestInsertStructValuer(t *testing.T) {
+	sent := serveStatements(t, 1)
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	if _, err := client.InsertStruct("posts", post{ID: postID, Price: 999}); err != nil {
+		t.Fatalf("InsertStruct failed: %v", err)
+	}
+
+	want := sentStatement{
+		SQL:    `INSERT INTO "posts" ("id", "author_id", "status", "price") VALUES (?, NULL, NULL, ?)`,
+		Params: []string{"7d444840-9dc0-11d1-b245-5ffdce74fad2", "999"},
+	}
+	if len(*sent) != 1 || !reflect.DeepEqual((*sent)[0], want) {
+		t.Errorf("sent %+v, want %+v", *sent, want)
+	}
+}
+
+func TestStructScanScanner(t *testing.T) {
+	author := uuid.MustParse("a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8")
+	rows := utils.NewRows([]map[string]interface{}{
+		{"id": postID.String(), "author_id": author.String(), "status": "published", "price": float64(1250)},
+		{"id": postID.String(), "author_id": nil, "status": nil, "price": float64(0)},
+	}, []string{"id", "author_id", "status", "price"})
+
+	var got []post
+	if err := rows.StructScanAll(&got); err != nil {
+		t.Fatalf("StructScanAll failed: %v", err)
+	}
+	want := []post{
+		{ID: postID, AuthorID: &author, Status: NullEnum{Enum: "published", Valid: true}, Price: 1250},
+		{ID: postID},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	rows = utils.NewRows([]map[string]interface{}{{"status": "archived"}}, []string{"status"})
+	var status NullEnum
+	if rows.Next(); rows.Scan(&status) == nil {
+		t.Error("Scan accepted a value the Scanner rejects")
+	}
+}
@@ -0,0 +1,128 @@
+package cloudflared1_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/migrations"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// serveVerify answers /user/tokens/verify with verify and the database
+// listing with list, and queries with errors like the API does for a
+// rejected token
+func serveVerify(t *testing.T, verify, list interface{}) {
+	t.Helper()
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/client/v4/user/tokens/verify":
+			writeJSON(w, verify)
+		case strings.HasSuffix(r.URL.Path, "/d1/database"):
+			if r.URL.Query().Get("per_page") != "1" {
+				t.Errorf("database listing %s is not limited to one", r.URL)
+			}
+			writeJSON(w, list)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, errorResponse(7400, "The request is malformed"))
+		}
+	})
+}
+
+func listing() map[string]interface{} {
+	return successResponse(map[string]interface{}{"uuid": "db-1", "name": "app"})
+}
+
+func TestVerifyTokenValid(t *testing.T) {
+	serveVerify(t, map[string]interface{}{
+		"result":  map[string]interface{}{"id": "tok-1", "status": "active", "expires_on": "2030-01-01T00:00:00Z"},
+		"success": true,
+		"errors":  []interface{}{},
+	}, listing())
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	info, err := client.VerifyToken()
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	want := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if info.ID != "tok-1" || info.Status != "active" || !info.ExpiresOn.Equal(want) || !info.AccountMatch {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestVerifyTokenAccountOwned(t *testing.T) {
+	serveVerify(t, errorResponse(1000, "Invalid API Token"), listing())
+
+	client := cloudflare_d1_go.NewClient("account_id", "account_token")
+	info, err := client.VerifyToken()
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if !info.AccountMatch || info.Status != "" {
+		t.Errorf("info = %+v, want an account match from the listing", info)
+	}
+}
+
+func TestVerifyTokenInvalid(t *testing.T) {
+	serveVerify(t, errorResponse(1000, "Invalid API Token"), errorResponse(10000, "Authentication error"))
+
+	client := cloudflare_d1_go.NewClient("account_id", "bad_token")
+	info, err := client.VerifyToken()
+	if !errors.Is(err, cloudflare_d1_go.ErrUnauthorized) || info != nil {
+		t.Fatalf("VerifyToken = %+v, %v, want ErrUnauthorized", info, err)
+	}
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 10000 {
+		t.Errorf("err = %v, want the API error too", err)
+	}
+}
+
+func TestVerifyTokenWrongAccount(t *testing.T) {
+	serveVerify(t, map[string]interface{}{
+		"result":  map[string]interface{}{"id": "tok-1", "status": "active"},
+		"success": true,
+		"errors":  []interface{}{},
+	}, errorResponse(7403, "The given account is not valid or is not authorized to access this service"))
+
+	client := cloudflare_d1_go.NewClient("other_account", "api_token")
+	info, err := client.VerifyToken()
+	if !errors.Is(err, cloudflare_d1_go.ErrUnauthorized) || !strings.Contains(err.Error(), "other_account") {
+		t.Fatalf("err = %v, want ErrUnauthorized naming the account", err)
+	}
+	if info == nil || info.Status != "active" || info.AccountMatch {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestVerifyTokenServerError(t *testing.T) {
+	serveHTTPTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	_, err := client.VerifyToken()
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) || errors.Is(err, cloudflare_d1_go.ErrUnauthorized) {
+		t.Errorf("err = %v, want the HTTP error and not ErrUnauthorized", err)
+	}
+}
+
+func TestMigrationsReportRejectedToken(t *testing.T) {
+	serveVerify(t, errorResponse(1000, "Invalid API Token"), errorResponse(10000, "Authentication error"))
+
+	client := cloudflare_d1_go.NewClient("account_id", "bad_token")
+	client.DatabaseID = "db-1"
+	source := migrations.MemoryMigrationSource{Migrations: []*migrations.Migration{
+		memoryMigration("1_init", "CREATE TABLE users (id INTEGER)"),
+	}}
+
+	_, err := migrations.Exec(client, source, migrations.Up)
+	if !errors.Is(err, cloudflare_d1_go.ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized", err)
+	}
+}
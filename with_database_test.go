@@ -0,0 +1,139 @@
+package cloudflared1_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+)
+
+func TestWithDatabaseBindsCopy(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		writeJSON(w, successResponse(queryResult([]string{"n"}, [][]interface{}{{1}}, nil)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-0"
+	a := client.WithDatabase("db-a")
+	b := client.WithDatabase("db-b")
+	if client.DatabaseID != "db-0" || a.DatabaseID != "db-a" || b.DatabaseID != "db-b" {
+		t.Fatalf("DatabaseIDs = %s, %s, %s", client.DatabaseID, a.DatabaseID, b.DatabaseID)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, c := range []*cloudflare_d1_go.Client{a, b} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := c.Exec("UPDATE t SET n = 1"); err != nil {
+					t.Errorf("Exec failed: %v", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, id := range []string{"db-a", "db-b"} {
+		if n := paths["/client/v4/accounts/account_id/d1/database/"+id+"/raw"]; n != 10 {
+			t.Errorf("%s got %d requests, want 10", id, n)
+		}
+	}
+	if len(paths) != 2 {
+		t.Errorf("requests went to %v", paths)
+	}
+
+	// The copies count into the usage of the client they were made from
+	if got := client.Usage().Queries; got != 20 {
+		t.Errorf("Usage().Queries = %d, want 20", got)
+	}
+}
+
+func TestWithDatabaseName(t *testing.T) {
+	serveDatabases(t, map[string]string{"app": "id-1", "app-staging": "id-2"})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	bound, err := client.WithDatabaseName("app")
+	if err != nil {
+		t.Fatalf("WithDatabaseName failed: %v", err)
+	}
+	if bound.DatabaseID != "id-1" {
+		t.Errorf("DatabaseID = %q, want id-1", bound.DatabaseID)
+	}
+	if client.DatabaseID != "" {
+		t.Errorf("original client was changed to %q", client.DatabaseID)
+	}
+
+	if _, err := client.WithDatabaseName("missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("WithDatabaseName(missing) returned %v, want not found", err)
+	}
+}
+
+func TestPoolDBClientReturnsCopy(t *testing.T) {
+	var sql []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sql = append(sql, string(body))
+		writeJSON(w, successResponse(queryResult([]string{"user_id"}, [][]interface{}{{7}}, nil)))
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	db, err := pool.DB("main")
+	if err != nil {
+		t.Fatalf("DB failed: %v", err)
+	}
+	client, err := db.Client()
+	if err != nil {
+		t.Fatalf("Client failed: %v", err)
+	}
+	// Reconfiguring the returned client does not leak into the pool
+	client.SetTrimSemicolons(false)
+	client.DatabaseID = "db-other"
+
+	if _, err := pool.Exec("DELETE FROM t;"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if len(sql) != 1 || !strings.Contains(sql[0], `"DELETE FROM t"`) {
+		t.Errorf("pool sent %q, want the trimmed statement", sql)
+	}
+	if id := pool.GetDatabaseID("main"); id != "db-1" {
+		t.Errorf("GetDatabaseID = %q, want db-1", id)
+	}
+}
+
+func TestPoolConfigurationAppliesToCachedDatabases(t *testing.T) {
+	var bodies []string
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		writeJSON(w, successResponse(queryResult([]string{"UserID"}, [][]interface{}{{7}}, nil)))
+	})
+
+	pool := cloudflare_d1_go.NewConnectionPool("account_id", "api_token")
+	_ = pool.ConnectWithID("main", "db-1")
+
+	// Configured after the database was cached
+	pool.SetNameMapper(func(field string) string { return field })
+	pool.SetTrimSemicolons(false)
+
+	var row struct{ UserID int }
+	if err := pool.Get(&row, "SELECT UserID FROM t;"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if row.UserID != 7 {
+		t.Errorf("UserID = %d, want 7 scanned with the new name mapper", row.UserID)
+	}
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `"SELECT UserID FROM t;"`) {
+		t.Errorf("sent %q, want the semicolon kept", bodies)
+	}
+}
@@ -0,0 +1,92 @@
+package cloudflared1_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	cloudflare_d1_go "github.com/youfun/cloudflare-d1-go/client"
+	"github.com/youfun/cloudflare-d1-go/utils"
+)
+
+// exportRows returns rows with values that need quoting, NULLs and numbers
+func exportRows() *utils.Rows {
+	return utils.NewRows([]map[string]interface{}{
+		{"id": float64(1), "name": "Smith, Alice", "bio": "line one\nline two", "score": 9.5, "note": nil},
+		{"id": float64(2), "name": `Bob "the builder"`, "bio": "", "score": float64(1e7), "note": "<b>&</b>"},
+	}, []string{"id", "name", "bio", "score", "note"})
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportRows().WriteCSV(&buf, utils.CSVOptions{}); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	want := "id,name,bio,score,note\n" +
+		"1,\"Smith, Alice\",\"line one\nline two\",9.5,\n" +
+		"2,\"Bob \"\"the builder\"\"\",,10000000,<b>&</b>\n"
+	if buf.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteCSVOptions(t *testing.T) {
+	var buf bytes.Buffer
+	opts := utils.CSVOptions{NoHeader: true, Delimiter: ';', Null: `\N`, CRLF: true}
+	if err := exportRows().WriteCSV(&buf, opts); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	want := "1;Smith, Alice;\"line one\r\nline two\";9.5;\\N\r\n" +
+		"2;\"Bob \"\"the builder\"\"\";;10000000;<b>&</b>\r\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportRows().WriteJSON(&buf, utils.JSONOptions{}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	want := `[{"id":1,"name":"Smith, Alice","bio":"line one\nline two","score":9.5,"note":null},` +
+		`{"id":2,"name":"Bob \"the builder\"","bio":"","score":10000000,"note":"<b>&</b>"}]` + "\n"
+	if buf.String() != want {
+		t.Errorf("JSON =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Error("output is not valid JSON")
+	}
+
+	buf.Reset()
+	if err := utils.NewRows(nil, []string{"id"}).WriteJSON(&buf, utils.JSONOptions{}); err != nil || buf.String() != "[]\n" {
+		t.Errorf("empty result = %q, %v; want []", buf.String(), err)
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportRows().WriteJSON(&buf, utils.JSONOptions{Lines: true}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 || !json.Valid(lines[0]) || !json.Valid(lines[1]) {
+		t.Errorf("JSON Lines = %q, want two objects", buf.String())
+	}
+}
+
+func TestQueryToCSV(t *testing.T) {
+	mockTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, successResponse(queryResult([]string{"id", "name"}, [][]interface{}{{1, "Alice"}, {2, nil}}, nil)))
+	})
+
+	client := cloudflare_d1_go.NewClient("account_id", "api_token")
+	client.DatabaseID = "db-1"
+	var buf bytes.Buffer
+	if err := client.QueryToCSV(&buf, "SELECT id, name FROM users WHERE id > ?", 0); err != nil {
+		t.Fatalf("QueryToCSV failed: %v", err)
+	}
+	if want := "id,name\n1,Alice\n2,\n"; buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}